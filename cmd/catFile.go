@@ -1,15 +1,12 @@
 package cmd
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/repo"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
-	"strings"
 )
 
 func SetupCatFileCmd(context CommandContext) *cobra.Command {
@@ -67,64 +64,47 @@ func (cmd *CatFileCmd) Execute(options CatFileOptions) error {
 		return err
 	}
 
-	oids, err := ry.Storage.Find(options.OID)
+	resolvedOid, err := ry.ResolveObjectName(options.OID)
 	if err != nil {
 		return err
 	}
-
-	if len(oids) == 0 {
-		return fmt.Errorf("not a valid object name %s", options.OID)
+	objectType, size, reader, err := ry.Storage.Open(resolvedOid)
+	if err != nil {
+		return err
 	}
+	defer reader.Close()
 
-	if len(oids) > 1 {
-		var compOids string
-		for _, oid := range oids {
-			compOids += oid + "\n"
-		}
-		return fmt.Errorf("cannot decide between: %v", compOids)
+	if options.Size {
+		fmt.Fprintf(cmd.writer, "%v", size)
+		return nil
 	}
 
-	resolvedOid := oids[0]
-	data, err := ry.Storage.Get(resolvedOid)
-	if err != nil {
-		return err
+	if options.Type {
+		fmt.Fprintf(cmd.writer, "%s", objectType)
+		return nil
 	}
 
 	if options.Raw {
-		fmt.Fprintf(cmd.writer, "%s", data)
-		return nil
+		fmt.Fprintf(cmd.writer, "%s %d\x00", objectType, size)
+		_, err := io.Copy(cmd.writer, reader)
+		return err
 	}
 
-	var o objects.Object
-	if objects.IsBlob(data) {
-		o, err = objects.LoadBlob(data)
-		if err != nil {
+	// blobs can be arbitrarily large, so they are streamed instead of loaded into memory
+	if objectType == "blob" {
+		if options.Pretty {
+			_, err := io.Copy(cmd.writer, reader)
 			return err
 		}
-	} else if objects.IsTree(data) {
-		o, err = objects.LoadTree(data)
-		if err != nil {
-			return err
-		}
-	} else if objects.IsCommit(data) {
-		o, err = objects.DecodeCommit(resolvedOid, data)
-		if err != nil {
-			return err
-		}
-	} else if objects.IsTag(data) {
-		o, err = objects.DecodeTag(resolvedOid, bytes.NewReader(data))
-		if err != nil {
-			return err
-		}
-	} else {
-		return errors.New("Invalid object type")
+		return nil
 	}
 
-	if options.Size {
-		fmt.Fprintf(cmd.writer, "%v", o.Size())
-	} else if options.Type {
-		fmt.Fprintf(cmd.writer, "%s", strings.ToLower(o.Type()))
-	} else if options.Pretty {
+	o, err := ry.Object(resolvedOid)
+	if err != nil {
+		return err
+	}
+
+	if options.Pretty {
 		output, err := objects.FormatObject(o)
 		if err != nil {
 			return err
@@ -182,11 +182,11 @@ func TestPrettyPrintOfTree(t *testing.T) {
 	}
 
 	treeContent := []string{
-		"40000 tree 9aacd487c128e9d564997629c0c4257f44183aaf     0",
-		"40000 tree 44f70e4f280f5641a30d69706500490032ccce59     1",
-		"40000 tree a1ccacffd24f2c562e75f1fa9502eed3428e4aa2     2",
-		"40000 tree ca2b251fcfd68d8453c594152521a246c249d8ef     3",
-		"40000 tree 7be5f5c4d3cc7b3d007865832f5f00fc442d4075     4",
+		"040000 tree 9aacd487c128e9d564997629c0c4257f44183aaf\t0",
+		"040000 tree 44f70e4f280f5641a30d69706500490032ccce59\t1",
+		"040000 tree a1ccacffd24f2c562e75f1fa9502eed3428e4aa2\t2",
+		"040000 tree ca2b251fcfd68d8453c594152521a246c249d8ef\t3",
+		"040000 tree 7be5f5c4d3cc7b3d007865832f5f00fc442d4075\t4",
 	}
 
 	reader := bufio.NewReader(&output)
@@ -315,8 +315,8 @@ func TestPrettyPrintCommit(t *testing.T) {
 	}
 
 	expected := "tree 80fa9593f3c3d03f011492504e5d877b97b1277f\n" +
-		"author Furisto <24721048+Furisto@users.noreply.github.com> 1609952762 +0000\n" +
-		"committer Furisto <24721048+Furisto@users.noreply.github.com> 1609952762 +0000\n\n" +
+		"author Furisto <24721048+Furisto@users.noreply.github.com> 1609952762 +0100\n" +
+		"committer Furisto <24721048+Furisto@users.noreply.github.com> 1609952762 +0100\n\n" +
 		"print commit\n"
 
 	if expected != output.String() {
@@ -416,7 +416,7 @@ func TestPrettyPrintTag(t *testing.T) {
 		return
 	}
 
-	expected := "object f58d08adb14c10e1d25124bb8bfe5f6fa3c6e3cd\n" +
+	expected := "object b9c57f8d0106b2590046ceb90e048d9097627eca\n" +
 		"type commit\n" +
 		"tag annotated\n" +
 		"tagger furisto <furisto@test.com> 1611348418 +0000\n\n" +
@@ -444,7 +444,7 @@ func TestRawPrintOfTag(t *testing.T) {
 		return
 	}
 
-	expected := "tag 140\x00object f58d08adb14c10e1d25124bb8bfe5f6fa3c6e3cd\n" +
+	expected := "tag 140\x00object b9c57f8d0106b2590046ceb90e048d9097627eca\n" +
 		"type commit\n" +
 		"tag annotated\n" +
 		"tagger furisto <furisto@test.com> 1611348418 +0000\n\n" +
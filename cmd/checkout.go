@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"bytes"
-	"fmt"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/repo"
 	"github.com/spf13/cobra"
@@ -65,57 +63,15 @@ func (cmd *CheckoutCommand) Execute(options CheckoutCmdOptions) error {
 		return err
 	}
 
-	refObjectData, err := ry.Storage.Get(checkoutRef.RefValue)
+	treeObject, err := ry.Peel(checkoutRef.RefValue, objects.TreeObject)
 	if err != nil {
 		return err
 	}
+	tree := treeObject.(*objects.Tree)
 
-	if objects.IsBlob(refObjectData) {
-		return fmt.Errorf("cannot checkout blob")
-	}
-
-	var tree *objects.Tree
-	if objects.IsCommit(refObjectData) {
-		commit, err := objects.DecodeCommit(checkoutRef.RefValue, refObjectData)
-		if err != nil {
-			return err
-		}
-
-		tree, err = cmd.commitToTree(commit, ry)
-		if err != nil {
-			return err
-		}
-	} else if objects.IsTree(refObjectData) {
-		tree, err = objects.LoadTree(refObjectData)
-		if err != nil {
-			return err
-		}
-	} else if objects.IsTag(refObjectData) {
-		tag, err := objects.DecodeTag(checkoutRef.RefValue, bytes.NewReader(refObjectData))
-		if err != nil {
-			return err
-		}
-
-		if tag.TargetType() == "Blob" {
-			return fmt.Errorf("cannot checkout blob")
-		} else if tag.TargetType() == "Commit" {
-			commit, err := objects.DecodeCommit(checkoutRef.RefValue, refObjectData)
-			if err != nil {
-				return err
-			}
-
-			tree, err = cmd.commitToTree(commit, ry)
-			if err != nil {
-				return err
-			}
-		} else if tag.TargetType() == "Tree" {
-			tree, err = objects.LoadTree(refObjectData)
-			if err != nil {
-				return err
-			}
-		}
-	} else {
-		return fmt.Errorf("unknown object type")
+	modes, err := ry.WorkingTreeModes()
+	if err != nil {
+		return err
 	}
 
 	err = filepath.Walk(ry.Info.WorkingDirectory(), func(path string, info os.FileInfo, err error) error {
@@ -130,55 +86,70 @@ func (cmd *CheckoutCommand) Execute(options CheckoutCmdOptions) error {
 		return err
 	}
 
-	return cmd.checkoutTree(ry.Info.WorkingDirectory(), tree, ry)
+	return cmd.checkoutTree(ry.Info.WorkingDirectory(), tree, ry, modes)
+}
+
+func (cmd *CheckoutCommand) checkoutTree(path string, tree *objects.Tree, ry *repo.Repository, modes objects.WorkingTreeModes) error {
+	// directories are visited before their content, so they exist once their files are written
+	return objects.NewTreeWalker(ry.Storage).WalkTree(tree, func(entry objects.WalkEntry) error {
+		entryPath := filepath.Join(path, filepath.FromSlash(entry.Path))
+		switch objects.CanonicalMode(entry.Mode) {
+		case objects.ModeTree:
+			return os.Mkdir(entryPath, 0777)
+		case objects.ModeGitlink:
+			// submodules are not cloned, git leaves an empty directory for them as well
+			return os.Mkdir(entryPath, 0777)
+		case objects.ModeSymlink:
+			return cmd.checkoutSymlink(entryPath, entry.TreeEntry, ry, modes)
+		default:
+			return cmd.checkoutBlob(entryPath, entry.TreeEntry, ry)
+		}
+	})
 }
 
-func (cmd *CheckoutCommand) commitToTree(commit *objects.Commit, ry *repo.Repository) (*objects.Tree, error) {
-	treeData, err := ry.Storage.Get(commit.Tree)
+func (cmd *CheckoutCommand) checkoutBlob(path string, entry objects.TreeEntry, ry *repo.Repository) error {
+	_, _, reader, err := ry.Storage.Open(entry.OID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// permissions are the same as the ones of git, the umask is applied on top
+	var perm os.FileMode = 0666
+	if objects.CanonicalMode(entry.Mode) == objects.ModeExecutable {
+		perm = 0777
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return objects.LoadTree(treeData)
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
 }
 
-func (cmd *CheckoutCommand) checkoutTree(path string, tree *objects.Tree, ry *repo.Repository) error {
-	for _, entry := range tree.Entries() {
-		if entry.Mode != 0o40000 {
-			data, err := ry.Storage.Get(entry.OID)
-			if err != nil {
-				return err
-			}
-
-			blob, err := objects.LoadBlob(data)
-			if err != nil {
-				return err
-			}
-
-			if err = ioutil.WriteFile(filepath.Join(path, entry.Name), blob.Content, entry.Mode); err != nil {
-				return err
-			}
-		} else {
-			dirPath := filepath.Join(path, entry.Name)
-			if err := os.Mkdir(dirPath, os.ModeDir); err != nil {
-				return err
-			}
-
-			treeData, err := ry.Storage.Get(entry.OID)
-			if err != nil {
-				return err
-			}
-
-			subTree, err := objects.LoadTree(treeData)
-			if err != nil {
-				return err
-			}
-
-			if err := cmd.checkoutTree(dirPath, subTree, ry); err != nil {
-				return err
-			}
-		}
+// checkoutSymlink creates a symlink to the target stored in the blob. If symlinks are not supported, a plain file
+// that contains the target is created instead.
+func (cmd *CheckoutCommand) checkoutSymlink(path string, entry objects.TreeEntry, ry *repo.Repository, modes objects.WorkingTreeModes) error {
+	if !modes.Symlinks {
+		return cmd.checkoutBlob(path, entry, ry)
+	}
+
+	_, _, reader, err := ry.Storage.Open(entry.OID)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	target, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return os.Symlink(string(target), path)
 }
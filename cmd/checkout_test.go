@@ -2,6 +2,11 @@ package cmd
 
 import (
 	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -20,3 +25,83 @@ func TestCheckout(t *testing.T) {
 		t.Fatalf("error occured during execution of checkout command: %v", err)
 	}
 }
+
+func TestCheckoutRestoresModes(t *testing.T) {
+	ry := createTestRepository(t)
+	dir := ry.Info.WorkingDirectory()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "run.sh"), []byte("echo"), 0755); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "readme"), []byte("read me"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if err := os.Symlink("run.sh", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("modes")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	catFile := NewCatFileCmd(&output)
+	if err := catFile.Execute(CatFileOptions{OID: commit.Tree, Path: dir, Pretty: true}); err != nil {
+		t.Fatalf("could not print tree: %v", err)
+	}
+	assert.Contains(t, output.String(), "120000 blob "+objects.NewBlob([]byte("run.sh")).OID()+"\tlink\n")
+	assert.Contains(t, output.String(), "100644 blob "+objects.NewBlob([]byte("read me")).OID()+"\treadme\n")
+	assert.Contains(t, output.String(), "100755 blob "+objects.NewBlob([]byte("echo")).OID()+"\trun.sh\n")
+
+	cmd := NewCheckoutCmd(&bytes.Buffer{})
+	if err := cmd.Execute(CheckoutCmdOptions{Path: dir, Ref: "refs/heads/master"}); err != nil {
+		t.Fatalf("error occured during execution of checkout command: %v", err)
+	}
+
+	target, err := os.Readlink(filepath.Join(dir, "link"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "run.sh", target)
+	}
+
+	script, err := os.Stat(filepath.Join(dir, "run.sh"))
+	if assert.NoError(t, err) {
+		assert.NotZero(t, script.Mode()&0100, "run.sh is executable")
+	}
+
+	readme, err := os.Stat(filepath.Join(dir, "readme"))
+	if assert.NoError(t, err) {
+		assert.Zero(t, readme.Mode()&0111, "readme is not executable")
+	}
+}
+
+func TestCheckoutWithoutSymlinks(t *testing.T) {
+	ry := createTestRepository(t)
+	dir := ry.Info.WorkingDirectory()
+
+	if err := os.Symlink("target", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	if _, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("link")
+	}); err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	if err := ry.Config.Set("core", "symlinks", "false"); err != nil {
+		t.Fatalf("could not set core.symlinks: %v", err)
+	}
+
+	cmd := NewCheckoutCmd(&bytes.Buffer{})
+	if err := cmd.Execute(CheckoutCmdOptions{Path: dir, Ref: "refs/heads/master"}); err != nil {
+		t.Fatalf("error occured during execution of checkout command: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "link"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "target", string(content), "link is checked out as a plain file with the target")
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func SetupCloneCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clone <repository> [<directory>]",
+		Short: "Clone a repository into a new directory",
+	}
+
+	cmd.Args = cobra.RangeArgs(1, 2)
+
+	options := CloneCmdOptions{}
+	cmd.Flags().BoolVarP(&options.Shared, "shared", "s", false,
+		"borrow the objects of the source repository instead of copying them")
+	cmd.Flags().StringVar(&options.Reference, "reference", "",
+		"borrow objects from the reference repository, only missing objects are copied")
+	cmd.Flags().BoolVarP(&options.NoCheckout, "no-checkout", "n", false, "do not checkout HEAD after the clone is complete")
+	cmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", false, "be quiet")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		options.Source = args[0]
+		if len(args) == 2 {
+			options.Directory = args[1]
+		} else {
+			options.Directory = defaultCloneDirectory(args[0])
+		}
+
+		if !filepath.IsAbs(options.Directory) {
+			options.Directory = filepath.Join(cwd, options.Directory)
+		}
+
+		handler := NewCloneCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type CloneCmdOptions struct {
+	Source     string
+	Directory  string
+	Shared     bool
+	Reference  string
+	NoCheckout bool
+	Quiet      bool
+}
+
+type CloneCommand struct {
+	writer io.Writer
+}
+
+func NewCloneCmd(writer io.Writer) CloneCommand {
+	return CloneCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *CloneCommand) Execute(options CloneCmdOptions) error {
+	if !options.Quiet {
+		fmt.Fprintf(cmd.writer, "Cloning into '%s'...\n", filepath.Base(options.Directory))
+	}
+
+	ry, err := repo.Clone(options.Source, options.Directory, repo.CloneOptions{
+		Shared:    options.Shared,
+		Reference: options.Reference,
+	})
+	if err != nil {
+		return err
+	}
+
+	if options.NoCheckout {
+		return nil
+	}
+
+	if unborn, err := ry.Info.IsHeadUnborn(); err != nil || unborn {
+		if !options.Quiet {
+			fmt.Fprintln(cmd.writer, "warning: You appear to have cloned an empty repository.")
+		}
+		return err
+	}
+
+	checkout := NewCheckoutCmd(cmd.writer)
+	return checkout.Execute(CheckoutCmdOptions{
+		Path: options.Directory,
+		Ref:  "HEAD",
+	})
+}
+
+// defaultCloneDirectory derives the name of the clone from the source, i.e. /src/project.git becomes project
+func defaultCloneDirectory(source string) string {
+	name := filepath.Base(filepath.Clean(source))
+	if name == ".git" {
+		name = filepath.Base(filepath.Dir(filepath.Clean(source)))
+	}
+
+	return strings.TrimSuffix(name, ".git")
+}
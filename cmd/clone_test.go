@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedCloneBorrowsObjects(t *testing.T) {
+	source, commits := prepareEnvWithCommitObjects(t)
+	target := filepath.Join(createTemporaryDir(t), "clone")
+	defer os.RemoveAll(filepath.Dir(target))
+
+	output := bytes.Buffer{}
+	cmd := NewCloneCmd(&output)
+	options := CloneCmdOptions{
+		Source:    source.Info.WorkingDirectory(),
+		Directory: target,
+		Shared:    true,
+	}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	alternates, err := ioutil.ReadFile(filepath.Join(target, ".git", "objects", "info", "alternates"))
+	if err != nil {
+		t.Fatalf("could not read alternates: %v", err)
+	}
+	assert.Equal(t, filepath.Join(source.Info.GitDirectory(), "objects")+"\n", string(alternates))
+
+	clone, err := repo.FromExisting(target)
+	if err != nil {
+		t.Fatalf("could not open clone: %v", err)
+	}
+
+	loose, err := storage.Unwrap(clone.Storage).(*storage.FilesystemStore).LooseObjects()
+	assert.NoError(t, err)
+	assert.Empty(t, loose, "objects copied into shared clone")
+
+	head, err := clone.Head(true)
+	if err != nil {
+		t.Fatalf("could not resolve head of clone: %v", err)
+	}
+	assert.Equal(t, commits[len(commits)-1].OID(), head.RefValue)
+
+	content, err := ioutil.ReadFile(filepath.Join(target, "4", "1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "41", string(content))
+}
+
+func TestCloneCopiesObjects(t *testing.T) {
+	source, commits := prepareEnvWithCommitObjects(t)
+	target := filepath.Join(createTemporaryDir(t), "clone")
+	defer os.RemoveAll(filepath.Dir(target))
+
+	output := bytes.Buffer{}
+	cmd := NewCloneCmd(&output)
+	options := CloneCmdOptions{
+		Source:     source.Info.WorkingDirectory(),
+		Directory:  target,
+		NoCheckout: true,
+	}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	store := storage.NewFsStore(filepath.Join(target, ".git"))
+	for _, commit := range commits {
+		exists, err := store.StatLocal(commit.OID())
+		assert.NoError(t, err)
+		assert.True(t, exists, "commit %s copied into clone", commit.OID())
+	}
+
+	clone, err := repo.FromExisting(target)
+	if err != nil {
+		t.Fatalf("could not open clone: %v", err)
+	}
+
+	remote, err := clone.Refs.Get("refs/remotes/origin/master")
+	if err != nil {
+		t.Fatalf("could not read remote tracking branch: %v", err)
+	}
+	assert.Equal(t, commits[len(commits)-1].OID(), remote.RefValue)
+
+	url, err := clone.Config.Get("remote \"origin\"", "url")
+	assert.NoError(t, err)
+	assert.Equal(t, source.Info.WorkingDirectory(), url)
+}
+
+func TestCloneIntoNonEmptyDirectoryFails(t *testing.T) {
+	source, _ := prepareEnvWithCommitObjects(t)
+	target := createTemporaryDir(t)
+	defer os.RemoveAll(target)
+
+	if err := ioutil.WriteFile(filepath.Join(target, "file"), []byte("content"), 0644); err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	cmd := NewCloneCmd(&bytes.Buffer{})
+	err := cmd.Execute(CloneCmdOptions{Source: source.Info.WorkingDirectory(), Directory: target})
+	assert.Equal(t, repo.ErrCloneTargetNotEmpty, err)
+}
@@ -17,6 +17,7 @@ func SetupCommitCmd(context CommandContext) *cobra.Command {
 
 	options := CommitOptions{}
 	cmd.Flags().StringVarP(&options.Message, "message", "m", "", "commit message")
+	cmd.Flags().BoolVarP(&options.Sign, "gpg-sign", "S", false, "sign the commit with the key of user.signingKey")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if cwd, err := os.Getwd(); err == nil {
@@ -36,6 +37,7 @@ func SetupCommitCmd(context CommandContext) *cobra.Command {
 type CommitOptions struct {
 	Path    string
 	Message string
+	Sign    bool
 }
 
 type CommitCommand struct {
@@ -54,7 +56,25 @@ func (cmd *CommitCommand) Execute(options CommitOptions) (*objects.Commit, error
 		return nil, err
 	}
 
-	tree, err := objects.NewTreeFromDirectory(r.Info.WorkingDirectory(), "")
+	// the signing key is loaded first so that nothing is written if it is not usable
+	var signer func(payload []byte) (string, error)
+	if options.Sign {
+		if signer, err = r.Signer(); err != nil {
+			return nil, err
+		}
+	}
+
+	modes, err := r.WorkingTreeModes()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := r.RecordedModes(r.Info.WorkingDirectory())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := objects.NewTreeFromDirectoryWithModes(r.Info.WorkingDirectory(), "", r.Storage.ObjectFormat(), modes, recorded)
 	if err != nil {
 		return nil, err
 	}
@@ -86,9 +106,11 @@ func (cmd *CommitCommand) Execute(options CommitOptions) (*objects.Commit, error
 	}
 
 	commit, err := objects.NewCommitBuilder(tree.OID()).
+		WithObjectFormat(r.Storage.ObjectFormat()).
 		WithConfig(r.Config).
 		WithParent(parentRef.RefValue).
 		WithMessage(options.Message).
+		WithSigner(signer).
 		Build()
 
 	if err != nil {
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupCommitGraphCmd(context CommandContext) *cobra.Command {
+	commitGraphCmd := &cobra.Command{
+		Use:   "commit-graph",
+		Short: "Write and verify Git commit-graph files",
+	}
+	handler := NewCommitGraphCmd(context.Logger)
+
+	writeCmd := setupCommitGraphWriteCmd(handler)
+	commitGraphCmd.AddCommand(writeCmd)
+
+	return commitGraphCmd
+}
+
+func setupCommitGraphWriteCmd(handler CommitGraphCommand) *cobra.Command {
+	writeCmd := &cobra.Command{
+		Use:   "write",
+		Short: "Write a commit-graph file of the commits in the repository",
+	}
+
+	writeCmd.Args = cobra.NoArgs
+	options := CommitGraphWriteCmdOptions{}
+	writeCmd.Flags().BoolVar(&options.Reachable, "reachable", false,
+		"walk commits starting at all refs instead of including every commit in the object store")
+	writeCmd.Flags().BoolVar(&options.Split, "split", false,
+		"write only the new commits as an incremental commit-graph on top of the existing chain")
+	writeCmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", false, "do not report the number of written commits")
+
+	writeCmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		options.Path, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+		return handler.ExecuteWrite(options)
+	}
+
+	return writeCmd
+}
+
+type CommitGraphWriteCmdOptions struct {
+	Path      string
+	Reachable bool
+	Split     bool
+	Quiet     bool
+}
+
+type CommitGraphCommand struct {
+	writer io.Writer
+}
+
+func NewCommitGraphCmd(writer io.Writer) CommitGraphCommand {
+	return CommitGraphCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *CommitGraphCommand) ExecuteWrite(options CommitGraphWriteCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	written, err := ry.WriteCommitGraph(repo.CommitGraphOptions{Reachable: options.Reachable, Split: options.Split})
+	if err != nil {
+		return err
+	}
+
+	if !options.Quiet {
+		fmt.Fprintf(cmd.writer, "Wrote %d commits to the commit-graph\n", written)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommitGraphWriteReachable(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewCommitGraphCmd(&output)
+	options := CommitGraphWriteCmdOptions{Path: ry.Info.WorkingDirectory(), Reachable: true}
+	if err := cmd.ExecuteWrite(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	assert.Equal(t, "Wrote 5 commits to the commit-graph\n", output.String())
+
+	graph := storage.LoadCommitGraph(ry.Storage)
+	if graph == nil {
+		t.Fatal("commit-graph was not written")
+	}
+
+	for i, commit := range commits {
+		entry, ok := graph.Lookup(commit.OID())
+		if !ok {
+			t.Errorf("commit %s is not part of the commit-graph", commit.OID())
+			continue
+		}
+
+		assert.Equal(t, commit.Tree, entry.Tree)
+		assert.Equal(t, commit.Parents, entry.Parents)
+		assert.Equal(t, uint32(i+1), entry.Generation)
+		assert.Equal(t, commit.Commiter.TimeStamp.Unix(), entry.CommitTime)
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func SetupCommitTreeCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "commit-tree <tree>",
+		Short: "Create a new commit object from a tree",
+	}
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	options := CommitTreeCmdOptions{}
+	cmd.Flags().StringArrayVarP(&options.Parents, "parent", "p", nil, "id of a parent commit")
+	cmd.Flags().StringArrayVarP(&options.Messages, "message", "m", nil, "a paragraph of the commit message")
+	cmd.Flags().StringArrayVarP(&options.MessageFiles, "file", "F", nil, "read the commit message from a file, - for stdin")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.Tree = args[0]
+		options.Input = os.Stdin
+		handler := NewCommitTreeCmd(context.Logger)
+		_, err := handler.Execute(options)
+		return err
+	}
+
+	return cmd
+}
+
+type CommitTreeCmdOptions struct {
+	Path         string
+	Tree         string
+	Parents      []string
+	Messages     []string
+	MessageFiles []string
+	// Input is read for the message if neither messages nor message files are given, or for the file -
+	Input io.Reader
+}
+
+type CommitTreeCommand struct {
+	writer io.Writer
+}
+
+func NewCommitTreeCmd(writer io.Writer) CommitTreeCommand {
+	return CommitTreeCommand{
+		writer: writer,
+	}
+}
+
+// Execute writes the commit without updating any ref. The identities are taken from GIT_AUTHOR_NAME,
+// GIT_AUTHOR_EMAIL, GIT_AUTHOR_DATE and their GIT_COMMITTER_ counterparts before the configuration is used.
+func (cmd *CommitTreeCommand) Execute(options CommitTreeCmdOptions) (*objects.Commit, error) {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := cmd.resolve(ry, options.Tree, objects.TreeObject)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := objects.NewCommitBuilder(tree).
+		WithConfig(ry.Config).
+		WithObjectFormat(ry.Storage.ObjectFormat())
+
+	seen := make(map[string]bool)
+	for _, name := range options.Parents {
+		parent, err := cmd.resolve(ry, name, objects.CommitObject)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[parent] {
+			fmt.Fprintf(os.Stderr, "error: duplicate parent %s ignored\n", parent)
+			continue
+		}
+		seen[parent] = true
+		builder.WithParent(parent)
+	}
+
+	if err := cmd.configureIdentities(ry, builder); err != nil {
+		return nil, err
+	}
+
+	message, err := cmd.readMessage(options)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := builder.WithMessage(message).Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := commit.Save(ry.Storage); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(cmd.writer, commit.OID())
+	return commit, nil
+}
+
+// resolve returns the id of the object that the name refers to, tags and commits are peeled to the type
+func (cmd *CommitTreeCommand) resolve(ry *repo.Repository, name string, objectType objects.ObjectType) (string, error) {
+	oid, err := ry.ResolveRevision(name)
+	if err != nil {
+		return "", err
+	}
+
+	object, err := ry.Peel(oid, objectType)
+	if err != nil {
+		return "", err
+	}
+
+	return object.OID(), nil
+}
+
+func (cmd *CommitTreeCommand) configureIdentities(ry *repo.Repository, builder *objects.CommitBuilder) error {
+	identities := []struct {
+		prefix   string
+		identity func(name, email string) *objects.CommitBuilder
+		date     func(date time.Time) *objects.CommitBuilder
+	}{
+		{"GIT_AUTHOR_", builder.WithAuthor, builder.WithAuthorDate},
+		{"GIT_COMMITTER_", builder.WithCommitter, builder.WithCommitterDate},
+	}
+
+	for _, identity := range identities {
+		name, hasName := os.LookupEnv(identity.prefix + "NAME")
+		email, hasEmail := os.LookupEnv(identity.prefix + "EMAIL")
+		if hasName || hasEmail {
+			if !hasName {
+				name, _ = ry.Config.Get("user", "name")
+			}
+			if !hasEmail {
+				email, _ = ry.Config.Get("user", "email")
+			}
+			identity.identity(name, email)
+		}
+
+		if value, ok := os.LookupEnv(identity.prefix + "DATE"); ok {
+			date, err := objects.ParseDate(value)
+			if err != nil {
+				return err
+			}
+			identity.date(date)
+		}
+	}
+
+	return nil
+}
+
+// readMessage joins the messages and the content of the message files as paragraphs like git does
+func (cmd *CommitTreeCommand) readMessage(options CommitTreeCmdOptions) (string, error) {
+	if len(options.Messages) == 0 && len(options.MessageFiles) == 0 {
+		content, err := ioutil.ReadAll(options.Input)
+		return string(content), err
+	}
+
+	var message strings.Builder
+	appendParagraph := func(paragraph string) {
+		if message.Len() > 0 {
+			message.WriteString("\n")
+		}
+		message.WriteString(paragraph)
+		if message.Len() > 0 && !strings.HasSuffix(message.String(), "\n") {
+			message.WriteString("\n")
+		}
+	}
+
+	for _, paragraph := range options.Messages {
+		appendParagraph(paragraph)
+	}
+
+	for _, file := range options.MessageFiles {
+		var content []byte
+		var err error
+		if file == "-" {
+			content, err = ioutil.ReadAll(options.Input)
+		} else {
+			content, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not read log file '%s': %v", file, err)
+		}
+		appendParagraph(string(content))
+	}
+
+	return message.String(), nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommitTree(t *testing.T) {
+	ry := createTestRepository(t)
+
+	tree := objects.NewTree(nil)
+	if err := tree.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	environment := map[string]string{
+		"GIT_AUTHOR_NAME":     "author",
+		"GIT_AUTHOR_EMAIL":    "author@test.com",
+		"GIT_AUTHOR_DATE":     "1600000000 +0200",
+		"GIT_COMMITTER_NAME":  "committer",
+		"GIT_COMMITTER_EMAIL": "committer@test.com",
+		"GIT_COMMITTER_DATE":  "@1600000100 +0000",
+	}
+	for key, value := range environment {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	output := bytes.Buffer{}
+	cmd := NewCommitTreeCmd(&output)
+	root, err := cmd.Execute(CommitTreeCmdOptions{
+		Path:     ry.Info.WorkingDirectory(),
+		Tree:     tree.OID(),
+		Messages: []string{"subject", "body"},
+	})
+	if err != nil {
+		t.Fatalf("could not commit tree: %v", err)
+	}
+
+	assert.Equal(t, root.OID()+"\n", output.String())
+	assert.Equal(t, "subject\n\nbody\n", root.Message)
+	assert.Equal(t, "author <author@test.com> 1600000000 +0200", root.Author.String())
+	assert.Equal(t, "committer <committer@test.com> 1600000100 +0000", root.Commiter.String())
+
+	// the same commit is created by git commit-tree for the same input
+	assert.Equal(t, "c3baf56f1f91dd82e7b5ac7fc9274e184ba24f02", root.OID())
+
+	child, err := cmd.Execute(CommitTreeCmdOptions{
+		Path:    ry.Info.WorkingDirectory(),
+		Tree:    root.OID(),
+		Parents: []string{root.OID(), root.OID()[:7]},
+		Input:   strings.NewReader("from stdin\n"),
+	})
+	if err != nil {
+		t.Fatalf("could not commit tree: %v", err)
+	}
+
+	assert.Equal(t, tree.OID(), child.Tree, "commits are peeled to their tree")
+	assert.Equal(t, []string{root.OID()}, child.Parents, "duplicate parents are ignored")
+	assert.Equal(t, "from stdin\n", child.Message)
+
+	stored, err := ry.LookupCommit(child.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, child.Parents, stored.Parents)
+	}
+
+	_, err = cmd.Execute(CommitTreeCmdOptions{Path: ry.Info.WorkingDirectory(), Tree: tree.OID(), Parents: []string{tree.OID()}, Messages: []string{"x"}})
+	assert.Error(t, err, "parents must be commits")
+}
@@ -19,11 +19,11 @@ func TestFirstCommitInRepository(t *testing.T) {
 }
 
 func TestSubsequentCommitInRepository(t *testing.T) {
-	r := prepareEnvWithCommits(t)
+	r, parent := prepareEnvWithCommits(t)
 	defer os.RemoveAll(r.Info.WorkingDirectory())
 
 	commit := executeCommitCmd(r, t)
-	checkCommit(commit, t, r)
+	checkCommit(commit, t, r, parent.OID())
 }
 
 func executeCommitCmd(r *repo.Repository, t *testing.T) *objects.Commit {
@@ -42,7 +42,7 @@ func executeCommitCmd(r *repo.Repository, t *testing.T) *objects.Commit {
 	return commit
 }
 
-func checkCommit(commit *objects.Commit, t *testing.T, r *repo.Repository) {
+func checkCommit(commit *objects.Commit, t *testing.T, r *repo.Repository, parents ...string) {
 	data, err := r.Storage.Get(commit.OID())
 	if err != nil {
 		t.Errorf("could not find expected commit %v", commit.OID())
@@ -61,8 +61,13 @@ func checkCommit(commit *objects.Commit, t *testing.T, r *repo.Repository) {
 		t.Errorf("commit does not contain the expected committer name and/or email")
 	}
 
-	if len(c.Parents) != 0 {
-		t.Errorf("expected %v parents for commit, but was %v", 0, len(commit.Parents))
+	if len(c.Parents) != len(parents) {
+		t.Errorf("expected %v parents for commit, but was %v", len(parents), len(c.Parents))
+	}
+	for i := 0; i < len(parents) && i < len(c.Parents); i++ {
+		if c.Parents[i] != parents[i] {
+			t.Errorf("expected parent %v, but was %v", parents[i], c.Parents[i])
+		}
 	}
 
 	if c.Message != CommitMessage {
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupCountObjectsCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "count-objects",
+		Short: "Count unpacked number of objects and their disk consumption",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := CountObjectsCmdOptions{}
+	cmd.Flags().BoolVarP(&options.Verbose, "verbose", "v", false,
+		"also report packed objects, objects that can be pruned and garbage files")
+	cmd.Flags().BoolVarP(&options.HumanReadable, "human-readable", "H", false, "print sizes in human readable format")
+	cmd.Flags().BoolVar(&options.JSON, "json", false, "print all counts as JSON, sizes are in bytes")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		handler := NewCountObjectsCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type CountObjectsCmdOptions struct {
+	Path          string
+	Verbose       bool
+	HumanReadable bool
+	JSON          bool
+}
+
+type CountObjectsCommand struct {
+	writer io.Writer
+}
+
+func NewCountObjectsCmd(writer io.Writer) CountObjectsCommand {
+	return CountObjectsCommand{
+		writer: writer,
+	}
+}
+
+type countObjectsReport struct {
+	Count         int      `json:"count"`
+	Size          int64    `json:"size"`
+	InPack        int      `json:"in-pack"`
+	Packs         int      `json:"packs"`
+	SizePack      int64    `json:"size-pack"`
+	PrunePackable int      `json:"prune-packable"`
+	Garbage       int      `json:"garbage"`
+	SizeGarbage   int64    `json:"size-garbage"`
+	GarbageFiles  []string `json:"garbage-files"`
+}
+
+func (cmd *CountObjectsCommand) Execute(options CountObjectsCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	count, err := ry.CountObjects()
+	if err != nil {
+		return err
+	}
+
+	if options.JSON {
+		report := countObjectsReport{
+			Count:         count.Count,
+			Size:          count.Size,
+			InPack:        count.InPack,
+			Packs:         count.Packs,
+			SizePack:      count.SizePack,
+			PrunePackable: count.PrunePackable,
+			Garbage:       len(count.Garbage),
+			SizeGarbage:   count.SizeGarbage,
+			GarbageFiles:  append([]string{}, count.Garbage...),
+		}
+
+		encoder := json.NewEncoder(cmd.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	size := func(bytes int64) string {
+		if options.HumanReadable {
+			return humanReadableSize(bytes)
+		}
+		return fmt.Sprintf("%d", bytes/1024)
+	}
+
+	if !options.Verbose {
+		if options.HumanReadable {
+			fmt.Fprintf(cmd.writer, "%d objects, %s\n", count.Count, size(count.Size))
+		} else {
+			fmt.Fprintf(cmd.writer, "%d objects, %s kilobytes\n", count.Count, size(count.Size))
+		}
+		return nil
+	}
+
+	for _, garbage := range count.Garbage {
+		fmt.Fprintf(cmd.writer, "warning: garbage found: %s\n", garbage)
+	}
+
+	fmt.Fprintf(cmd.writer, "count: %d\n", count.Count)
+	fmt.Fprintf(cmd.writer, "size: %s\n", size(count.Size))
+	fmt.Fprintf(cmd.writer, "in-pack: %d\n", count.InPack)
+	fmt.Fprintf(cmd.writer, "packs: %d\n", count.Packs)
+	fmt.Fprintf(cmd.writer, "size-pack: %s\n", size(count.SizePack))
+	fmt.Fprintf(cmd.writer, "prune-packable: %d\n", count.PrunePackable)
+	fmt.Fprintf(cmd.writer, "garbage: %d\n", len(count.Garbage))
+	fmt.Fprintf(cmd.writer, "size-garbage: %s\n", size(count.SizeGarbage))
+
+	return nil
+}
+
+// humanReadableSize formats the size with the largest binary unit that keeps the value at least one
+func humanReadableSize(size int64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB"}
+	if size < 1024 {
+		return fmt.Sprintf("%d bytes", size)
+	}
+
+	value := float64(size) / 1024
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCountObjectsReportsLooseAndPackedObjects(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewCountObjectsCmd(&output)
+	options := CountObjectsCmdOptions{Path: ry.Info.WorkingDirectory(), JSON: true}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	var before countObjectsReport
+	if err := json.Unmarshal(output.Bytes(), &before); err != nil {
+		t.Fatalf("could not decode output: %v", err)
+	}
+	assert.True(t, before.Count > 0)
+	assert.Equal(t, 0, before.Packs)
+
+	repack := NewRepackCmd(&bytes.Buffer{})
+	if err := repack.Execute(RepackCmdOptions{Path: ry.Info.WorkingDirectory(), Quiet: true}); err != nil {
+		t.Fatalf("error occured during repack: %v", err)
+	}
+
+	output.Reset()
+	options.JSON = false
+	options.Verbose = true
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	assert.Contains(t, output.String(), "packs: 1\n")
+	assert.Contains(t, output.String(), "garbage: 0\n")
+	assert.Contains(t, output.String(), fmt.Sprintf("prune-packable: %d\n", before.Count))
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var ErrRepositoryCorrupt = errors.New("repository is corrupt")
+
+func SetupFsckCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: "Verifies the connectivity and validity of the objects in the database",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := FsckCmdOptions{}
+	cmd.Flags().BoolVar(&options.NoDangling, "no-dangling", false, "do not print dangling objects")
+	cmd.Flags().BoolVar(&options.Unreachable, "unreachable", false,
+		"print objects that exist but are not reachable from any of the reference nodes")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		handler := NewFsckCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type FsckCmdOptions struct {
+	Path        string
+	NoDangling  bool
+	Unreachable bool
+}
+
+type FsckCommand struct {
+	writer io.Writer
+}
+
+func NewFsckCmd(writer io.Writer) FsckCommand {
+	return FsckCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *FsckCommand) Execute(options FsckCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	report, err := ry.Fsck(repo.FsckOptions{
+		Dangling:    !options.NoDangling,
+		Unreachable: options.Unreachable,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range report.Problems {
+		fmt.Fprintln(cmd.writer, problem.String())
+	}
+
+	for _, missing := range report.Missing {
+		objectType := missing.Type
+		if objectType == "" {
+			objectType = "object"
+		}
+		fmt.Fprintf(cmd.writer, "missing %s %s (referenced by %s)\n", objectType, missing.OID, missing.ReferencedBy)
+	}
+
+	for _, dangling := range report.Dangling {
+		fmt.Fprintf(cmd.writer, "dangling %s %s\n", dangling.Type, dangling.OID)
+	}
+
+	for _, unreachable := range report.Unreachable {
+		fmt.Fprintf(cmd.writer, "unreachable %s %s\n", unreachable.Type, unreachable.OID)
+	}
+
+	if report.Corrupt() {
+		return ErrRepositoryCorrupt
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"compress/zlib"
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+)
+
+const (
+	aggressiveWindow = 250
+	aggressiveDepth  = 50
+)
+
+func SetupGcCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Cleanup unnecessary files and optimize the local repository",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := GcCmdOptions{}
+	cmd.Flags().BoolVar(&options.Aggressive, "aggressive", false,
+		"optimize the repository more aggressively at the expense of taking much more time")
+	cmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", false, "suppress all progress reports")
+	cmd.Flags().StringVar(&options.Compression, "compression", "",
+		"zlib compression level from -1 to 9 of the written pack (default is pack.compression)")
+	cmd.Flags().StringVar(&options.Prune, "prune", "", "prune loose objects older than date (default is gc.pruneExpire)")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		handler := NewGcCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type GcCmdOptions struct {
+	Path       string
+	Aggressive bool
+	Quiet      bool
+	// Expiry date of unreachable loose objects, gc.pruneExpire is used if empty
+	Prune string
+	// zlib compression level of the pack, pack.compression is used if empty
+	Compression string
+}
+
+type GcCommand struct {
+	writer io.Writer
+}
+
+func NewGcCmd(writer io.Writer) GcCommand {
+	return GcCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *GcCommand) Execute(options GcCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	packOptions, err := ry.PackOptions()
+	if err != nil {
+		return err
+	}
+
+	if options.Compression != "" {
+		level, err := strconv.Atoi(options.Compression)
+		if err != nil || level < zlib.DefaultCompression || level > zlib.BestCompression {
+			return fmt.Errorf("invalid compression level '%s', expected a number from -1 to 9", options.Compression)
+		}
+		packOptions.Compression = level
+	}
+
+	if options.Aggressive {
+		packOptions.Window = aggressiveWindow
+		packOptions.Depth = aggressiveDepth
+	}
+
+	_, err = ry.Repack(repo.RepackOptions{
+		All:               true,
+		DeleteRedundant:   true,
+		Local:             true,
+		UnpackUnreachable: true,
+		Pack:              packOptions,
+	})
+	if err != nil {
+		return err
+	}
+
+	expire, err := pruneExpire(ry, options.Prune)
+	if err != nil {
+		return err
+	}
+
+	if _, err = ry.Prune(repo.PruneOptions{Expire: expire}); err != nil {
+		return err
+	}
+
+	_, err = ry.WriteCommitGraph(repo.CommitGraphOptions{Reachable: true})
+	return err
+}
@@ -0,0 +1,406 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/refs"
+	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGcPacksAllReachableObjects(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewGcCmd(&output)
+	if err := cmd.Execute(GcCmdOptions{Path: ry.Info.WorkingDirectory()}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	store := ry.Storage.(*storage.FilesystemStore)
+	loose, err := store.LooseObjects()
+	if err != nil {
+		t.Fatalf("could not list loose objects: %v", err)
+	}
+	assert.Empty(t, loose, "loose objects after gc")
+
+	packs, err := store.Packs()
+	if err != nil {
+		t.Fatalf("could not list packs: %v", err)
+	}
+	assert.Equal(t, 1, len(packs), "number of packs")
+
+	for _, commit := range commits {
+		if _, err := ry.Storage.Get(commit.OID()); err != nil {
+			t.Errorf("could not read commit %s after gc: %v", commit.OID(), err)
+		}
+	}
+}
+
+func TestGcKeepsObjectsReachableFromPackedRefs(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+	packRefs(t, ry)
+
+	cmd := NewGcCmd(&bytes.Buffer{})
+	if err := cmd.Execute(GcCmdOptions{Path: ry.Info.WorkingDirectory(), Prune: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	repack := NewRepackCmd(&bytes.Buffer{})
+	options := RepackCmdOptions{Path: ry.Info.WorkingDirectory(), All: true, DeleteRedundant: true, Window: -1, Depth: -1}
+	if err := repack.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	for _, commit := range commits {
+		if _, err := ry.LookupCommit(commit.OID()); err != nil {
+			t.Errorf("commit %s of the packed branch was removed: %v", commit.OID(), err)
+		}
+		if _, err := ry.LookupTree(commit.Tree); err != nil {
+			t.Errorf("tree %s of the packed branch was removed: %v", commit.Tree, err)
+		}
+	}
+}
+
+func TestGcKeepsStagedAndUnexpiredPackedObjects(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	path := filepath.Join(ry.Info.WorkingDirectory(), "staged.txt")
+	if err := ioutil.WriteFile(path, []byte("staged"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	add := NewAddCmd(&bytes.Buffer{})
+	if err := add.Execute(AddCmdOptions{Path: ry.Info.WorkingDirectory(), Patterns: []string{"staged.txt"}}); err != nil {
+		t.Fatalf("could not stage file: %v", err)
+	}
+	staged := objects.NewBlob([]byte("staged"))
+
+	unreachable := objects.NewBlob([]byte("unreachable"))
+	if err := unreachable.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	// both objects are only packed, so they are lost if gc drops the pack
+	store := ry.Storage.(*storage.FilesystemStore)
+	if _, err := store.WritePack([]string{staged.OID(), unreachable.OID()}, storage.DefaultPackOptions()); err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+	for _, oid := range []string{staged.OID(), unreachable.OID()} {
+		if err := store.Delete(oid); err != nil {
+			t.Fatalf("could not delete loose object: %v", err)
+		}
+	}
+
+	cmd := NewGcCmd(&bytes.Buffer{})
+	if err := cmd.Execute(GcCmdOptions{Path: ry.Info.WorkingDirectory()}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	_, err := ry.Storage.Get(staged.OID())
+	assert.NoError(t, err, "staged blob was removed")
+
+	_, err = store.StatLoose(unreachable.OID())
+	assert.NoError(t, err, "unreachable object within the grace period is kept as loose object")
+
+	if err := cmd.Execute(GcCmdOptions{Path: ry.Info.WorkingDirectory(), Prune: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	exists, err := ry.Storage.Stat(unreachable.OID())
+	assert.NoError(t, err)
+	assert.False(t, exists, "expired unreachable object was not pruned")
+
+	_, err = ry.Storage.Get(staged.OID())
+	assert.NoError(t, err, "staged blob was removed")
+}
+
+func TestGcHonorsCompressionOverride(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	cmd := NewGcCmd(&bytes.Buffer{})
+	options := GcCmdOptions{Path: ry.Info.WorkingDirectory(), Compression: "10"}
+	assert.Error(t, cmd.Execute(options), "compression level is out of range")
+
+	options.Compression = "0"
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	for _, commit := range commits {
+		if _, err := ry.Storage.Get(commit.OID()); err != nil {
+			t.Errorf("could not read commit %s after gc: %v", commit.OID(), err)
+		}
+	}
+}
+
+func TestRepackOnlyPacksLooseObjects(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewRepackCmd(&output)
+	options := RepackCmdOptions{
+		Path:            ry.Info.WorkingDirectory(),
+		DeleteRedundant: true,
+		Window:          storage.DefaultPackWindow,
+		Depth:           storage.DefaultPackDepth,
+	}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	output.Reset()
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	assert.Equal(t, "Nothing new to pack.\n", output.String())
+}
+
+func TestRepackWritesBitmapIndex(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewRepackCmd(&output)
+	options := RepackCmdOptions{
+		Path:            ry.Info.WorkingDirectory(),
+		All:             true,
+		DeleteRedundant: true,
+		WriteBitmap:     true,
+		Window:          storage.DefaultPackWindow,
+		Depth:           storage.DefaultPackDepth,
+	}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	bitmap := storage.LoadPackBitmap(ry.Storage)
+	if bitmap == nil {
+		t.Fatal("bitmap was not written")
+	}
+
+	_, ok := bitmap.Commit(commits[len(commits)-1].OID())
+	assert.True(t, ok, "tip commit has a bitmap")
+}
+
+func TestRepackWithoutAllDisablesBitmapIndex(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	output := bytes.Buffer{}
+	cmd := NewRepackCmd(&output)
+	options := RepackCmdOptions{
+		Path:        ry.Info.WorkingDirectory(),
+		WriteBitmap: true,
+		Quiet:       true,
+		Window:      storage.DefaultPackWindow,
+		Depth:       storage.DefaultPackDepth,
+	}
+	if err := cmd.Execute(options); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	assert.Equal(t, "warning: disabling bitmap writing, as some objects are not being packed\n", output.String())
+	assert.Nil(t, storage.LoadPackBitmap(ry.Storage))
+}
+
+func TestPruneRemovesExpiredUnreachableObjects(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	expired := objects.NewBlob([]byte("expired"))
+	recent := objects.NewBlob([]byte("recent"))
+	for _, blob := range []*objects.Blob{expired, recent} {
+		if err := blob.Save(ry.Storage); err != nil {
+			t.Fatalf("could not save blob: %v", err)
+		}
+	}
+
+	objectPath := filepath.Join(ry.Info.GitDirectory(), "objects", expired.OID()[:2], expired.OID()[2:])
+	threeWeeksAgo := time.Now().Add(-21 * 24 * time.Hour)
+	if err := os.Chtimes(objectPath, threeWeeksAgo, threeWeeksAgo); err != nil {
+		t.Fatalf("could not change modification time: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	cmd := NewPruneCmd(&output)
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), DryRun: true}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+	assert.Equal(t, expired.OID()+" blob\n", output.String())
+
+	exists, err := ry.Storage.Stat(expired.OID())
+	assert.NoError(t, err)
+	assert.True(t, exists, "object removed during dry run")
+
+	output.Reset()
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory()}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	exists, err = ry.Storage.Stat(expired.OID())
+	assert.NoError(t, err)
+	assert.False(t, exists, "expired object still exists")
+
+	exists, err = ry.Storage.Stat(recent.OID())
+	assert.NoError(t, err)
+	assert.True(t, exists, "recent object was removed")
+
+	for _, commit := range commits {
+		exists, err := ry.Storage.Stat(commit.OID())
+		assert.NoError(t, err)
+		assert.True(t, exists, "reachable commit %s was removed", commit.OID())
+	}
+}
+
+func TestPruneHonorsExpireOption(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	blob := objects.NewBlob([]byte("unreachable"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	cmd := NewPruneCmd(&bytes.Buffer{})
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), Expire: "1.second.ago"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	exists, err := ry.Storage.Stat(blob.OID())
+	assert.NoError(t, err)
+	assert.True(t, exists, "object younger than expiry date was removed")
+
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), Expire: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	exists, err = ry.Storage.Stat(blob.OID())
+	assert.NoError(t, err)
+	assert.False(t, exists, "unreachable object was not removed")
+}
+
+func TestPruneKeepsObjectsReachableFromRemoteBranches(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	tree := objects.NewTreeBuilder().Build()
+	if err := tree.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	commit, err := objects.NewCommitBuilder(tree.OID()).WithMessage("remote").Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	if err := commit.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save commit: %v", err)
+	}
+
+	if _, err := ry.Refs.Set("refs/remotes/origin/feature", commit.OID()); err != nil {
+		t.Fatalf("could not create remote branch: %v", err)
+	}
+
+	cmd := NewPruneCmd(&bytes.Buffer{})
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), Expire: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	for _, oid := range []string{commit.OID(), tree.OID()} {
+		exists, err := ry.Storage.Stat(oid)
+		assert.NoError(t, err)
+		assert.True(t, exists, "object %s reachable from remote branch was removed", oid)
+	}
+}
+
+func TestPruneKeepsObjectsReachableFromAnyRef(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	var kept []string
+	for _, name := range []string{"refs/notes/review", "refs/stash", "refs/custom/namespace/ref"} {
+		blob := objects.NewBlob([]byte(name))
+		tree := objects.NewTree([]objects.TreeEntry{{Mode: objects.ModeRegular, Name: "file", OID: blob.OID()}})
+		commit, err := objects.NewCommitBuilder(tree.OID()).WithMessage(name).Build()
+		if err != nil {
+			t.Fatalf("could not build commit: %v", err)
+		}
+
+		for _, object := range []objects.Object{blob, tree, commit} {
+			if err := object.Save(ry.Storage); err != nil {
+				t.Fatalf("could not save object: %v", err)
+			}
+		}
+
+		if _, err := ry.Refs.Set(name, commit.OID()); err != nil {
+			t.Fatalf("could not create ref %s: %v", name, err)
+		}
+		kept = append(kept, blob.OID(), tree.OID(), commit.OID())
+	}
+
+	cmd := NewPruneCmd(&bytes.Buffer{})
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), Expire: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	for _, oid := range kept {
+		exists, err := ry.Storage.Stat(oid)
+		assert.NoError(t, err)
+		assert.True(t, exists, "object %s reachable from a ref was removed", oid)
+	}
+}
+
+func TestPruneKeepsObjectsReachableFromPackedRefs(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	blob := objects.NewBlob([]byte("packed"))
+	tree := objects.NewTree([]objects.TreeEntry{{Mode: objects.ModeRegular, Name: "file", OID: blob.OID()}})
+	commit, err := objects.NewCommitBuilder(tree.OID()).WithMessage("packed").Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	for _, object := range []objects.Object{blob, tree, commit} {
+		if err := object.Save(ry.Storage); err != nil {
+			t.Fatalf("could not save object: %v", err)
+		}
+	}
+
+	// the packed branch is the only ref that points to the objects
+	if _, err := ry.Refs.Set("refs/heads/packed", commit.OID()); err != nil {
+		t.Fatalf("could not create branch: %v", err)
+	}
+	packRefs(t, ry)
+
+	cmd := NewPruneCmd(&bytes.Buffer{})
+	if err := cmd.Execute(PruneCmdOptions{Path: ry.Info.WorkingDirectory(), Expire: "now"}); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	for _, oid := range []string{blob.OID(), tree.OID(), commit.OID()} {
+		exists, err := ry.Storage.Stat(oid)
+		assert.NoError(t, err)
+		assert.True(t, exists, "object %s reachable from a packed ref was removed", oid)
+	}
+}
+
+// packRefs moves the loose refs below refs/ into the packed-refs file like git pack-refs --all
+func packRefs(t *testing.T, ry *repo.Repository) {
+	t.Helper()
+
+	var packed strings.Builder
+	packed.WriteString("# pack-refs with: peeled fully-peeled sorted \n")
+	for _, ref := range ry.Refs.List(refs.RefPattern) {
+		packed.WriteString(ref.RefValue + " " + ref.Name + "\n")
+		if err := os.Remove(filepath.Join(ry.Info.GitDirectory(), ref.Name)); err != nil {
+			t.Fatalf("could not remove loose ref %s: %v", ref.Name, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(ry.Info.GitDirectory(), "packed-refs"), []byte(packed.String()), 0644); err != nil {
+		t.Fatalf("could not write packed refs: %v", err)
+	}
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/util"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
@@ -59,20 +59,36 @@ func (cmd *HashObjectCommand) Execute(options HashObjectOptions) error {
 		return fmt.Errorf("cannot hash directory %v", options.file)
 	}
 
-	blob, err := objects.NewBlobFromFile(options.file)
+	file, err := os.Open(options.file)
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(cmd.writer, "%v", blob.OID())
+	defer file.Close()
 
+	var oid string
 	if options.store {
 		gitRepo, err := repo.FromExisting(options.file)
 		if err != nil {
 			return err
 		}
 
-		gitRepo.Storage.Put(blob.OID(), blob.Bytes())
+		oid, err = gitRepo.Storage.PutStream("blob", uint64(stat.Size()), file)
+		if err != nil {
+			return err
+		}
+	} else {
+		// outside of a repository objects are hashed with the default format
+		format := util.DefaultObjectFormat
+		if gitRepo, err := repo.FromExisting(options.file); err == nil {
+			format = gitRepo.Storage.ObjectFormat()
+		}
+
+		oid, err = format.HashObject("blob", uint64(stat.Size()), file)
+		if err != nil {
+			return err
+		}
 	}
 
+	fmt.Fprintf(cmd.writer, "%v", oid)
 	return nil
 }
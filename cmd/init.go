@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/util"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
@@ -21,6 +22,8 @@ func SetupInitCmd(context CommandContext) *cobra.Command {
 	options := InitCmdOptions{ }
 	initCmd.Flags().BoolVar(&options.bare, "bare", false, "create a bare repository")
 	initCmd.Flags().BoolVarP(&options.quiet, "quiet", "q", false, "be quiet")
+	initCmd.Flags().StringVar(&options.objectFormat, "object-format", util.DefaultObjectFormat.String(),
+		"specify the hash algorithm to use, either sha1 or sha256")
 	
 	initCmd.Run = func(cmd *cobra.Command, args []string) {
 		newInitCmd := NewInitCmd(context.Logger)
@@ -44,6 +47,7 @@ type InitCmdOptions struct {
 	bare bool
 	quiet bool
 	path string
+	objectFormat string
 }
 
 func NewInitCmd(logger io.Writer) InitCommand {
@@ -62,6 +66,16 @@ func (cmd *InitCommand) Execute(opt InitCmdOptions) {
 		message = fmt.Sprintf("Reinitialized existing Git repository in %v", opt.path)
 	}
 
-	repo.InitDefault(opt.path, opt.bare)
+	format := util.DefaultObjectFormat
+	if opt.objectFormat != "" {
+		parsed, err := util.ParseObjectFormat(opt.objectFormat)
+		if err != nil {
+			fmt.Fprintf(cmd.writer, "fatal: %v", err)
+			return
+		}
+		format = parsed
+	}
+
+	repo.InitDefaultWithFormat(opt.path, opt.bare, format)
 	fmt.Fprintf(cmd.writer, message)
 }
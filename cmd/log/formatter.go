@@ -6,12 +6,20 @@ import (
 	"io"
 )
 
+const gitDateFormat = "Mon Jan 2 15:04:05 2006 -0700"
+
 type logFormatter interface {
 	Write(commit *objects.Commit) error
 }
 
+// signatureLogFormatter is implemented by formatters that can show the result of verifying commit signatures
+type signatureLogFormatter interface {
+	ShowSignatures(verify func(commit *objects.Commit) string)
+}
+
 type defaultLogFormatter struct {
 	writer io.Writer
+	verify func(commit *objects.Commit) string
 }
 
 func newDefaultLogFormatter(writer io.Writer) *defaultLogFormatter {
@@ -20,16 +28,31 @@ func newDefaultLogFormatter(writer io.Writer) *defaultLogFormatter {
 	}
 }
 
+// ShowSignatures shows the result of verify below the id of every commit, verify returns an empty string for
+// unsigned commits
+func (dlf *defaultLogFormatter) ShowSignatures(verify func(commit *objects.Commit) string) {
+	dlf.verify = verify
+}
+
 func (dlf *defaultLogFormatter) Write(commit *objects.Commit) error {
 	if _, err := fmt.Fprintf(dlf.writer, "commit  %v\n", commit.OID()); err != nil {
 		return err
 	}
 
+	if dlf.verify != nil {
+		if result := dlf.verify(commit); result != "" {
+			if _, err := fmt.Fprintln(dlf.writer, result); err != nil {
+				return err
+			}
+		}
+	}
+
 	if _, err := fmt.Fprintf(dlf.writer, "Author: %v %v\n", commit.Author.Name, commit.Author.Email); err != nil {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(dlf.writer, "Date:   %v\n\n", commit.Author.TimeStamp.Format("Mon Jan 2 15:04:05 2006 -0700")); err != nil {
+	// the date is shown in the timezone of the author like git does
+	if _, err := fmt.Fprintf(dlf.writer, "Date:   %v\n\n", commit.Author.TimeStamp.Format(gitDateFormat)); err != nil {
 		return err
 	}
 
@@ -1,12 +1,14 @@
 package log
 
 import (
+	"fmt"
 	"github.com/furisto/gog/cmd"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/repo"
 	"github.com/furisto/gog/storage"
 	"github.com/spf13/cobra"
 	"io"
+	"os"
 	"regexp"
 	"time"
 )
@@ -23,10 +25,17 @@ func SetupLogCmd(context cmd.CommandContext) *cobra.Command {
 		&options.SkipCommits, "skip", 0, "Skip number commits before starting to show the commit output")
 	//cmd.Flags().StringVar(&options.Author, "author", "",
 	//	"Limit the commits output to ones with author/committer header lines that match the specified pattern")
+	cmd.Flags().BoolVar(&options.ShowSignature, "show-signature", false, "Check the signatures of signed commits")
 	cmd.Flags().String("author", "", "Limit the commits output to ones with author/committer header lines that match the specified pattern")
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		handler := NewLogCmd(context.Logger, &defaultLogFormatter{})
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		handler := NewLogCmd(context.Logger, newDefaultLogFormatter(context.Logger))
 		return handler.Execute(options)
 	}
 
@@ -40,6 +49,8 @@ type LogCmdOptions struct {
 	Before      time.Time
 	After       time.Time
 	Author      *regexp.Regexp
+	// ShowSignature verifies the signatures of signed commits
+	ShowSignature bool
 }
 
 type LogCommand struct {
@@ -75,6 +86,20 @@ func (cmd *LogCommand) Execute(options LogCmdOptions) error {
 		return err
 	}
 
+	if formatter, ok := cmd.formatter.(signatureLogFormatter); ok && options.ShowSignature {
+		formatter.ShowSignatures(func(commit *objects.Commit) string {
+			if signature, _, err := commit.SignedPayload(); err != nil || signature == "" {
+				return ""
+			}
+
+			check, err := r.VerifyCommit(commit)
+			if err != nil {
+				return fmt.Sprintf("error: %v", err)
+			}
+			return check.String()
+		})
+	}
+
 	iterator := cmd.createCommitIterator(lastCommit, r.Storage, options)
 	for iterator.MoveNext() {
 		commit := iterator.Current()
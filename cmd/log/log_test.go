@@ -8,6 +8,7 @@ import (
 	"github.com/furisto/gog/repo"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -259,3 +260,53 @@ func reverse(commits []*objects.Commit) []*objects.Commit {
 	}
 	return commits
 }
+
+func TestDefaultLogFormatterShowsAuthorTimezone(t *testing.T) {
+	author, err := objects.DecodeSignature([]byte("Jane Q Public <j@x> 1567343397 +0200"))
+	if err != nil {
+		t.Fatalf("could not decode signature: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	formatter := newDefaultLogFormatter(&output)
+	if err := formatter.Write(&objects.Commit{Author: author, Commiter: author, Message: "message"}); err != nil {
+		t.Fatalf("could not format commit: %v", err)
+	}
+
+	expected := "Author: Jane Q Public j@x\nDate:   Sun Sep 1 15:09:57 2019 +0200\n"
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("expected output to contain %q, got %q", expected, output.String())
+	}
+}
+
+func TestDefaultLogFormatterShowsSignatureBelowCommit(t *testing.T) {
+	author, err := objects.DecodeSignature([]byte("furisto <furisto@test.com> 1567343397 +0000"))
+	if err != nil {
+		t.Fatalf("could not decode signature: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	formatter := newDefaultLogFormatter(&output)
+	formatter.ShowSignatures(func(commit *objects.Commit) string {
+		if commit.Message == "signed" {
+			return "Good \"git\" signature"
+		}
+		return ""
+	})
+
+	for _, message := range []string{"signed", "unsigned"} {
+		commit := &objects.Commit{Author: author, Commiter: author, Message: message}
+		if err := formatter.Write(commit); err != nil {
+			t.Fatalf("could not format commit: %v", err)
+		}
+	}
+
+	expected := "commit  \nGood \"git\" signature\nAuthor: furisto furisto@test.com\n"
+	if !strings.HasPrefix(output.String(), expected) {
+		t.Errorf("expected output to start with %q, got %q", expected, output.String())
+	}
+
+	if strings.Count(output.String(), "signature") != 1 {
+		t.Errorf("expected only the signed commit to show a signature, got %q", output.String())
+	}
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func SetupLsTreeCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls-tree <tree-ish> [<path>...]",
+		Short: "List the contents of a tree object",
+	}
+
+	cmd.Args = cobra.MinimumNArgs(1)
+
+	options := LsTreeCmdOptions{}
+	cmd.Flags().BoolVarP(&options.Recursive, "recursive", "r", false, "recurse into sub-trees")
+	cmd.Flags().BoolVarP(&options.ShowTrees, "trees", "t", false, "show tree entries even when going to recurse them")
+	cmd.Flags().BoolVarP(&options.OnlyTrees, "only-trees", "d", false, "only show trees")
+	cmd.Flags().BoolVar(&options.NameOnly, "name-only", false, "list only filenames")
+	cmd.Flags().BoolVarP(&options.Long, "long", "l", false, "include object size")
+	cmd.Flags().BoolVarP(&options.NulTerminated, "z", "z", false, "terminate entries with NUL")
+	cmd.Flags().IntVar(&options.Abbrev, "abbrev", 0, "use <n> digits to display object names")
+	cmd.Flags().Lookup("abbrev").NoOptDefVal = "-1"
+	cmd.Flags().StringVar(&options.Format, "format", "", "format to use for the output")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.TreeIsh = args[0]
+		options.Paths = args[1:]
+		handler := NewLsTreeCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type LsTreeCmdOptions struct {
+	Path    string
+	TreeIsh string
+	// Paths restrict the output to the entries at or below them, a trailing slash lists the content of a tree
+	Paths     []string
+	Recursive bool
+	ShowTrees bool
+	OnlyTrees bool
+	NameOnly  bool
+	Long      bool
+	// NulTerminated entries end with NUL instead of a newline and their paths are not quoted
+	NulTerminated bool
+	// Abbrev is the minimum length of the shown object ids, zero shows full ids and -1 uses core.abbrev
+	Abbrev int
+	Format string
+}
+
+type LsTreeCommand struct {
+	writer io.Writer
+}
+
+func NewLsTreeCmd(writer io.Writer) LsTreeCommand {
+	return LsTreeCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *LsTreeCommand) Execute(options LsTreeCmdOptions) error {
+	if options.Format != "" && (options.Long || options.NameOnly) {
+		return errors.New("--format can't be combined with other format-altering options")
+	}
+
+	if options.Long && options.NameOnly {
+		return errors.New("--long and --name-only are incompatible")
+	}
+
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	oid, err := ry.ResolveRevision(options.TreeIsh)
+	if err != nil {
+		return fmt.Errorf("not a tree object: %v", err)
+	}
+
+	object, err := ry.Peel(oid, objects.TreeObject)
+	if err != nil {
+		return fmt.Errorf("not a tree object: %v", err)
+	}
+
+	if options.Abbrev < 0 {
+		if options.Abbrev, err = ry.Abbrev(); err != nil {
+			return err
+		}
+	}
+
+	return cmd.listTree(ry, object.(*objects.Tree), options)
+}
+
+// listTree shows the entries of the tree in the order they are stored. Trees are only descended into if the
+// listing is recursive or if they lead to one of the paths.
+func (cmd *LsTreeCommand) listTree(ry *repo.Repository, tree *objects.Tree, options LsTreeCmdOptions) error {
+	walker := objects.NewTreeWalker(ry.Storage).WithPaths(options.Paths...)
+	return walker.Walk(tree.OID(), func(entry objects.WalkEntry) error {
+		show := !options.OnlyTrees || entry.IsTree()
+		if entry.IsTree() && (entry.Leading || options.Recursive) {
+			show = options.ShowTrees || options.OnlyTrees
+		}
+
+		if show {
+			if err := cmd.showEntry(ry, entry, options); err != nil {
+				return err
+			}
+		}
+
+		if entry.IsTree() && !entry.Leading && !options.Recursive {
+			return objects.SkipTree
+		}
+		return nil
+	})
+}
+
+func (cmd *LsTreeCommand) showEntry(ry *repo.Repository, entry objects.WalkEntry, options LsTreeCmdOptions) error {
+	terminator := "\n"
+	if options.NulTerminated {
+		terminator = "\x00"
+	}
+
+	path := entry.Path
+	if !options.NulTerminated {
+		path = quotePath(path)
+	}
+
+	oid := entry.OID
+	if options.Abbrev > 0 {
+		short, err := objects.ShortOID(ry.Storage, entry.OID, options.Abbrev)
+		if err != nil {
+			return err
+		}
+		oid = short
+	}
+
+	format := options.Format
+	switch {
+	case format != "":
+	case options.NameOnly:
+		format = "%(path)"
+	case options.Long:
+		format = "%(objectmode) %(objecttype) %(objectname) %(objectsize:padded)\t%(path)"
+	default:
+		format = "%(objectmode) %(objecttype) %(objectname)\t%(path)"
+	}
+
+	line, err := cmd.expandFormat(ry, format, entry, oid, path)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(cmd.writer, line+terminator)
+	return err
+}
+
+// expandFormat replaces the placeholders of the format, see https://git-scm.com/docs/git-ls-tree#_output_format
+func (cmd *LsTreeCommand) expandFormat(ry *repo.Repository, format string, entry objects.WalkEntry, oid, path string) (string, error) {
+	var output strings.Builder
+	for len(format) > 0 {
+		start := strings.IndexByte(format, '%')
+		if start < 0 || start == len(format)-1 {
+			output.WriteString(format)
+			break
+		}
+
+		output.WriteString(format[:start])
+		format = format[start:]
+
+		switch {
+		case format[1] == '%':
+			output.WriteByte('%')
+			format = format[2:]
+			continue
+		case format[1] == 'n':
+			output.WriteByte('\n')
+			format = format[2:]
+			continue
+		case format[1] == 'x' && len(format) >= 4:
+			if value, err := strconv.ParseUint(format[2:4], 16, 8); err == nil {
+				output.WriteByte(byte(value))
+				format = format[4:]
+				continue
+			}
+		}
+
+		end := strings.IndexByte(format, ')')
+		if format[1] != '(' || end < 0 {
+			return "", fmt.Errorf("bad ls-tree format: %s", format)
+		}
+
+		placeholder := format[:end+1]
+		format = format[end+1:]
+		switch placeholder {
+		case "%(objectmode)":
+			fmt.Fprintf(&output, "%06o", entry.Mode)
+		case "%(objecttype)":
+			output.WriteString(objects.ModeType(entry.Mode))
+		case "%(objectname)":
+			output.WriteString(oid)
+		case "%(objectsize)", "%(objectsize:padded)":
+			size, err := cmd.objectSize(ry, entry)
+			if err != nil {
+				return "", err
+			}
+			if placeholder == "%(objectsize:padded)" {
+				size = fmt.Sprintf("%7s", size)
+			}
+			output.WriteString(size)
+		case "%(path)":
+			output.WriteString(path)
+		default:
+			return "", fmt.Errorf("bad ls-tree format: %s", placeholder)
+		}
+	}
+
+	return output.String(), nil
+}
+
+// objectSize returns the size of blobs, trees and submodule commits have no size and are shown as -
+func (cmd *LsTreeCommand) objectSize(ry *repo.Repository, entry objects.WalkEntry) (string, error) {
+	if objects.ModeType(entry.Mode) != "blob" {
+		return "-", nil
+	}
+
+	_, size, reader, err := ry.Storage.Open(entry.OID)
+	if err != nil {
+		return "", err
+	}
+	reader.Close()
+
+	return strconv.FormatInt(int64(size), 10), nil
+}
+
+// quotePath quotes paths with control characters, quotes, backslashes or non-ASCII bytes like git does with the
+// default core.quotePath
+func quotePath(path string) string {
+	needsQuoting := false
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needsQuoting = true
+			break
+		}
+	}
+
+	if !needsQuoting {
+		return path
+	}
+
+	escapes := map[byte]string{'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`,
+		'"': `\"`, '\\': `\\`}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if escape, ok := escapes[c]; ok {
+			quoted.WriteString(escape)
+		} else if c < 0x20 || c >= 0x7f {
+			fmt.Fprintf(&quoted, "\\%03o", c)
+		} else {
+			quoted.WriteByte(c)
+		}
+	}
+	quoted.WriteByte('"')
+
+	return quoted.String()
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// createLsTreeFixture stores a tree with the file "dir/sub/f", the file "dir/g" and the executable "top"
+func createLsTreeFixture(t *testing.T, ry *repo.Repository) (root *objects.Tree, blob *objects.Blob) {
+	t.Helper()
+
+	blob = objects.NewBlob([]byte("content"))
+	sub := objects.NewTree([]objects.TreeEntry{{Mode: objects.ModeRegular, Name: "f", OID: blob.OID()}})
+	dir := objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.ModeRegular, Name: "g", OID: blob.OID()},
+		{Mode: objects.ModeTree, Name: "sub", OID: sub.OID()},
+	})
+	root = objects.NewTree([]objects.TreeEntry{
+		{Mode: objects.ModeTree, Name: "dir", OID: dir.OID()},
+		{Mode: objects.ModeExecutable, Name: "top", OID: blob.OID()},
+	})
+
+	for _, object := range []objects.Object{blob, sub, dir, root} {
+		if err := object.Save(ry.Storage); err != nil {
+			t.Fatalf("could not save object: %v", err)
+		}
+	}
+
+	return root, blob
+}
+
+func TestLsTree(t *testing.T) {
+	ry := createTestRepository(t)
+	root, blob := createLsTreeFixture(t, ry)
+	dir, _ := root.GetEntryByName("dir")
+
+	tests := []struct {
+		name     string
+		options  LsTreeCmdOptions
+		expected string
+	}{
+		{
+			"top level",
+			LsTreeCmdOptions{},
+			"040000 tree " + dir.OID + "\tdir\n" +
+				"100755 blob " + blob.OID() + "\ttop\n",
+		},
+		{
+			"recursive",
+			LsTreeCmdOptions{Recursive: true, NameOnly: true},
+			"dir/g\ndir/sub/f\ntop\n",
+		},
+		{
+			"recursive with trees",
+			LsTreeCmdOptions{Recursive: true, ShowTrees: true, NameOnly: true},
+			"dir\ndir/g\ndir/sub\ndir/sub/f\ntop\n",
+		},
+		{
+			"only trees",
+			LsTreeCmdOptions{Recursive: true, OnlyTrees: true, NameOnly: true},
+			"dir\ndir/sub\n",
+		},
+		{
+			"path of a tree",
+			LsTreeCmdOptions{Paths: []string{"dir"}, NameOnly: true},
+			"dir\n",
+		},
+		{
+			"content of a tree",
+			LsTreeCmdOptions{Paths: []string{"dir/"}, NameOnly: true},
+			"dir/g\ndir/sub\n",
+		},
+		{
+			"nested path with leading trees",
+			LsTreeCmdOptions{Paths: []string{"dir/sub/f"}, ShowTrees: true, NameOnly: true},
+			"dir\ndir/sub\ndir/sub/f\n",
+		},
+		{
+			"long",
+			LsTreeCmdOptions{Long: true},
+			"040000 tree " + dir.OID + "       -\tdir\n" +
+				"100755 blob " + blob.OID() + "       7\ttop\n",
+		},
+		{
+			"nul terminated",
+			LsTreeCmdOptions{Paths: []string{"top"}, NameOnly: true, NulTerminated: true},
+			"top\x00",
+		},
+		{
+			"format",
+			LsTreeCmdOptions{Paths: []string{"top"}, Format: "%(objecttype)%x09%(objectsize)%%%(path)", Abbrev: 4},
+			"blob\t7%top\n",
+		},
+		{
+			"abbrev",
+			LsTreeCmdOptions{Paths: []string{"top"}, Format: "%(objectname)", Abbrev: 4},
+			blob.OID()[:4] + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := bytes.Buffer{}
+			test.options.Path = ry.Info.WorkingDirectory()
+			test.options.TreeIsh = root.OID()
+
+			cmd := NewLsTreeCmd(&output)
+			if assert.NoError(t, cmd.Execute(test.options)) {
+				assert.Equal(t, test.expected, output.String())
+			}
+		})
+	}
+}
+
+func TestLsTreeResolvesTreeIsh(t *testing.T) {
+	ry := createTestRepository(t)
+	root, blob := createLsTreeFixture(t, ry)
+
+	commit, err := objects.NewCommitBuilder(root.OID()).WithAuthor("author", "author@test.com").
+		WithCommitter("author", "author@test.com").WithMessage("message").Build()
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+	if err := commit.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save commit: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	cmd := NewLsTreeCmd(&output)
+	err = cmd.Execute(LsTreeCmdOptions{Path: ry.Info.WorkingDirectory(), TreeIsh: commit.OID(), NameOnly: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "dir\ntop\n", output.String())
+	}
+
+	err = cmd.Execute(LsTreeCmdOptions{Path: ry.Info.WorkingDirectory(), TreeIsh: blob.OID()})
+	assert.Error(t, err, "blobs are not tree-ish")
+
+	err = cmd.Execute(LsTreeCmdOptions{Path: ry.Info.WorkingDirectory(), TreeIsh: root.OID(), Format: "%(unknown)"})
+	assert.Error(t, err)
+}
+
+func TestQuotePath(t *testing.T) {
+	assert.Equal(t, "plain name", quotePath("plain name"))
+	assert.Equal(t, `"tab\there"`, quotePath("tab\there"))
+	assert.Equal(t, `"\303\251\"\\"`, quotePath("é\"\\"))
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+func SetupMkTagCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mktag",
+		Short: "Create a tag object from the content read from stdin",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := MkTagCmdOptions{}
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.Input = os.Stdin
+		handler := NewMkTagCmd(context.Logger)
+		_, err := handler.Execute(options)
+		return err
+	}
+
+	return cmd
+}
+
+type MkTagCmdOptions struct {
+	Path  string
+	Input io.Reader
+}
+
+type MkTagCommand struct {
+	writer io.Writer
+}
+
+func NewMkTagCmd(writer io.Writer) MkTagCommand {
+	return MkTagCommand{
+		writer: writer,
+	}
+}
+
+// Execute writes the tag after it passed the checks of fsck, which are strict so that warnings are errors as
+// well. The tagged object has to exist with the type that the tag states.
+func (cmd *MkTagCommand) Execute(options MkTagCmdOptions) (*objects.Tag, error) {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(options.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(fmt.Sprintf("tag %d\x00", len(content))), content...)
+	oid := ry.Storage.ObjectFormat().Hash(data)
+
+	links, problems := objects.CheckObject(oid, data, ry.Storage.ObjectFormat())
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("tag on stdin did not pass our strict fsck check: %s", problems[0].Message)
+	}
+
+	target := links[0]
+	objectType, _, reader, err := ry.Storage.Open(target.OID)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		return nil, fmt.Errorf("could not read tagged object '%s'", target.OID)
+	} else if err != nil {
+		return nil, err
+	}
+	reader.Close()
+
+	if objectType != target.Type {
+		return nil, fmt.Errorf("object '%s' tagged as '%s', but is a '%s' type", target.OID, target.Type, objectType)
+	}
+
+	tag, err := objects.DecodeTag(oid, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ry.Storage.Put(oid, data); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(cmd.writer, oid)
+	return tag, nil
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMkTag(t *testing.T) {
+	ry := createTestRepository(t)
+
+	blob := objects.NewBlob([]byte("content"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	input := "object " + blob.OID() + "\n" +
+		"type blob\n" +
+		"tag v1\n" +
+		"tagger tagger <tagger@test.com> 1600000000 +0000\n" +
+		"\n" +
+		"message\n"
+
+	output := bytes.Buffer{}
+	cmd := NewMkTagCmd(&output)
+	tag, err := cmd.Execute(MkTagCmdOptions{Path: ry.Info.WorkingDirectory(), Input: strings.NewReader(input)})
+	if err != nil {
+		t.Fatalf("could not make tag: %v", err)
+	}
+
+	assert.Equal(t, tag.OID()+"\n", output.String())
+
+	stored, err := ry.LookupTag(tag.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "v1", stored.Name())
+		assert.Equal(t, blob.OID(), stored.TargetOID())
+	}
+}
+
+func TestMkTagChecksTag(t *testing.T) {
+	ry := createTestRepository(t)
+
+	blob := objects.NewBlob([]byte("content"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+	missing := objects.NewBlob([]byte("missing")).OID()
+
+	tests := map[string]string{
+		"object " + blob.OID() + "\ntype commit\ntag v1\ntagger t <t@test.com> 1 +0000\n\nm\n": "tagged as 'commit', but is a 'blob' type",
+		"object " + missing + "\ntype blob\ntag v1\ntagger t <t@test.com> 1 +0000\n\nm\n":      "could not read tagged object",
+		"object " + blob.OID() + "\ntype blob\ntag v1\n\nm\n":                                  "did not pass our strict fsck check",
+	}
+
+	cmd := NewMkTagCmd(&bytes.Buffer{})
+	for input, message := range tests {
+		_, err := cmd.Execute(MkTagCmdOptions{Path: ry.Info.WorkingDirectory(), Input: strings.NewReader(input)})
+		if assert.Error(t, err, input) {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+}
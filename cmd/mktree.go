@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func SetupMkTreeCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mktree",
+		Short: "Build a tree object from ls-tree formatted text read from stdin",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := MkTreeCmdOptions{}
+	cmd.Flags().BoolVarP(&options.NulTerminated, "z", "z", false, "read NUL terminated entries")
+	cmd.Flags().BoolVar(&options.AllowMissing, "missing", false, "allow objects that are missing or have a different type")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.Input = os.Stdin
+		handler := NewMkTreeCmd(context.Logger)
+		_, err := handler.Execute(options)
+		return err
+	}
+
+	return cmd
+}
+
+type MkTreeCmdOptions struct {
+	Path  string
+	Input io.Reader
+	// NulTerminated entries are separated by NUL instead of newlines and their paths are not quoted
+	NulTerminated bool
+	// AllowMissing skips the check that the objects of the entries exist with the given type
+	AllowMissing bool
+}
+
+type MkTreeCommand struct {
+	writer io.Writer
+}
+
+func NewMkTreeCmd(writer io.Writer) MkTreeCommand {
+	return MkTreeCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *MkTreeCommand) Execute(options MkTreeCmdOptions) (*objects.Tree, error) {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	separator := byte('\n')
+	if options.NulTerminated {
+		separator = 0
+	}
+
+	var entries []objects.TreeEntry
+	names := make(map[string]bool)
+	reader := bufio.NewReader(options.Input)
+	for {
+		line, err := reader.ReadString(separator)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, string(separator))
+		if line != "" {
+			entry, err := cmd.parseEntry(line, options.NulTerminated)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := cmd.checkEntry(ry, entry, options.AllowMissing); err != nil {
+				return nil, err
+			}
+
+			if names[entry.Name] {
+				return nil, fmt.Errorf("duplicate entry '%s'", entry.Name)
+			}
+			names[entry.Name] = true
+			entries = append(entries, entry)
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	tree := objects.NewTreeWithFormat(entries, ry.Storage.ObjectFormat())
+	if err := ry.Storage.Put(tree.OID(), tree.Bytes()); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(cmd.writer, tree.OID())
+	return tree, nil
+}
+
+// parseEntry parses a line of the form "<mode> SP <type> SP <oid> TAB <path>"
+func (cmd *MkTreeCommand) parseEntry(line string, unquoted bool) (objects.TreeEntry, error) {
+	tab := strings.IndexByte(line, '\t')
+	if tab < 0 {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+
+	fields := strings.Split(line[:tab], " ")
+	if len(fields) != 3 {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+
+	mode, err := strconv.ParseUint(fields[0], 8, 32)
+	if err != nil {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+
+	path := line[tab+1:]
+	if !unquoted && strings.HasPrefix(path, "\"") {
+		if path, err = strconv.Unquote(path); err != nil {
+			return objects.TreeEntry{}, fmt.Errorf("invalid quoting: %s", line)
+		}
+	}
+
+	if path == "" {
+		return objects.TreeEntry{}, fmt.Errorf("input format error: %s", line)
+	}
+
+	if strings.Contains(path, "/") {
+		return objects.TreeEntry{}, fmt.Errorf("path %s contains slash", path)
+	}
+
+	entry := objects.TreeEntry{Mode: os.FileMode(mode), Name: path, OID: fields[2]}
+	if modeType := objects.ModeType(entry.Mode); modeType != fields[1] {
+		return objects.TreeEntry{}, fmt.Errorf("entry '%s' object type (%s) doesn't match mode type (%s)",
+			path, fields[1], modeType)
+	}
+
+	return entry, nil
+}
+
+// checkEntry verifies that the object of the entry exists with the type of its mode. Submodule commits are part
+// of another repository and not checked.
+func (cmd *MkTreeCommand) checkEntry(ry *repo.Repository, entry objects.TreeEntry, allowMissing bool) error {
+	if _, err := hex.DecodeString(entry.OID); err != nil || len(entry.OID) != ry.Storage.ObjectFormat().HexSize() {
+		return fmt.Errorf("input format error: %s is not a valid object id", entry.OID)
+	}
+
+	if allowMissing || objects.CanonicalMode(entry.Mode) == objects.ModeGitlink {
+		return nil
+	}
+
+	objectType, _, reader, err := ry.Storage.Open(entry.OID)
+	if errors.Is(err, objects.ErrObjectNotFound) {
+		return fmt.Errorf("entry '%s' object %s is unavailable", entry.Name, entry.OID)
+	} else if err != nil {
+		return err
+	}
+	reader.Close()
+
+	if objectType != objects.ModeType(entry.Mode) {
+		return fmt.Errorf("entry '%s' object %s is a %s but specified type was (%s)",
+			entry.Name, entry.OID, objectType, objects.ModeType(entry.Mode))
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMkTree(t *testing.T) {
+	ry := createTestRepository(t)
+
+	blob := objects.NewBlob([]byte("content"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	input := "100755 blob " + blob.OID() + "\trun.sh\n" +
+		"100644 blob " + blob.OID() + "\t\"with\\ttab\"\n"
+
+	output := bytes.Buffer{}
+	cmd := NewMkTreeCmd(&output)
+	tree, err := cmd.Execute(MkTreeCmdOptions{Path: ry.Info.WorkingDirectory(), Input: strings.NewReader(input)})
+	if err != nil {
+		t.Fatalf("could not make tree: %v", err)
+	}
+
+	assert.Equal(t, tree.OID()+"\n", output.String())
+
+	stored, err := ry.LookupTree(tree.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []objects.TreeEntry{
+			{Mode: objects.ModeExecutable, Name: "run.sh", OID: blob.OID()},
+			{Mode: objects.ModeRegular, Name: "with\ttab", OID: blob.OID()},
+		}, stored.Entries())
+	}
+
+	nulTerminated := "100644 blob " + blob.OID() + "\tfile\x00"
+	_, err = cmd.Execute(MkTreeCmdOptions{Path: ry.Info.WorkingDirectory(), Input: strings.NewReader(nulTerminated), NulTerminated: true})
+	assert.NoError(t, err)
+}
+
+func TestMkTreeChecksEntries(t *testing.T) {
+	ry := createTestRepository(t)
+
+	blob := objects.NewBlob([]byte("content"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+	missing := objects.NewBlob([]byte("missing")).OID()
+
+	tests := map[string]string{
+		"040000 tree " + blob.OID() + "\tdir\n":      "is a blob but specified type was (tree)",
+		"100644 tree " + blob.OID() + "\tfile\n":     "doesn't match mode type",
+		"100644 blob " + blob.OID() + "\tdir/file\n": "contains slash",
+		"100644 blob " + missing + "\tfile\n":        "is unavailable",
+		"100644 blob " + blob.OID() + "\n":           "input format error",
+	}
+
+	cmd := NewMkTreeCmd(&bytes.Buffer{})
+	for input, message := range tests {
+		_, err := cmd.Execute(MkTreeCmdOptions{Path: ry.Info.WorkingDirectory(), Input: strings.NewReader(input)})
+		if assert.Error(t, err, input) {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+
+	_, err := cmd.Execute(MkTreeCmdOptions{
+		Path:         ry.Info.WorkingDirectory(),
+		Input:        strings.NewReader("100644 blob " + missing + "\tfile\n"),
+		AllowMissing: true,
+	})
+	assert.NoError(t, err, "missing objects are allowed with --missing")
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/config"
+	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/storage"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupMultiPackIndexCmd(context CommandContext) *cobra.Command {
+	midxCmd := &cobra.Command{
+		Use:   "multi-pack-index",
+		Short: "Write and verify multi-pack-indexes",
+	}
+	handler := NewMultiPackIndexCmd(context.Logger)
+
+	writeCmd := setupMultiPackIndexWriteCmd(handler)
+	verifyCmd := setupMultiPackIndexVerifyCmd(handler)
+	expireCmd := setupMultiPackIndexExpireCmd(handler)
+	repackCmd := setupMultiPackIndexRepackCmd(handler)
+
+	midxCmd.AddCommand(writeCmd, verifyCmd, expireCmd, repackCmd)
+
+	return midxCmd
+}
+
+func setupMultiPackIndexWriteCmd(handler MultiPackIndexCommand) *cobra.Command {
+	writeCmd := &cobra.Command{
+		Use:   "write",
+		Short: "Write a new multi-pack-index file of all packs",
+	}
+
+	writeCmd.Args = cobra.NoArgs
+	writeCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		return handler.ExecuteWrite(MultiPackIndexCmdOptions{Path: path})
+	}
+
+	return writeCmd
+}
+
+func setupMultiPackIndexVerifyCmd(handler MultiPackIndexCommand) *cobra.Command {
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the contents of the multi-pack-index file",
+	}
+
+	verifyCmd.Args = cobra.NoArgs
+	verifyCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		return handler.ExecuteVerify(MultiPackIndexCmdOptions{Path: path})
+	}
+
+	return verifyCmd
+}
+
+func setupMultiPackIndexExpireCmd(handler MultiPackIndexCommand) *cobra.Command {
+	expireCmd := &cobra.Command{
+		Use:   "expire",
+		Short: "Delete the packs of the multi-pack-index that no objects are taken from",
+	}
+
+	expireCmd.Args = cobra.NoArgs
+	expireCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		path, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		return handler.ExecuteExpire(MultiPackIndexCmdOptions{Path: path})
+	}
+
+	return expireCmd
+}
+
+func setupMultiPackIndexRepackCmd(handler MultiPackIndexCommand) *cobra.Command {
+	repackCmd := &cobra.Command{
+		Use:   "repack",
+		Short: "Pack the objects of small packs of the multi-pack-index into a new pack",
+	}
+
+	repackCmd.Args = cobra.NoArgs
+	options := MultiPackIndexCmdOptions{}
+	repackCmd.Flags().StringVar(&options.BatchSize, "batch-size", "0",
+		"select packs smaller than size until they add up to size, 0 selects all packs")
+
+	repackCmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		options.Path, err = os.Getwd()
+		if err != nil {
+			return err
+		}
+		return handler.ExecuteRepack(options)
+	}
+
+	return repackCmd
+}
+
+type MultiPackIndexCmdOptions struct {
+	Path      string
+	BatchSize string
+}
+
+type MultiPackIndexCommand struct {
+	writer io.Writer
+}
+
+func NewMultiPackIndexCmd(writer io.Writer) MultiPackIndexCommand {
+	return MultiPackIndexCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *MultiPackIndexCommand) ExecuteWrite(options MultiPackIndexCmdOptions) error {
+	_, store, err := openPackStore(options.Path)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.WriteMultiPackIndex()
+	return err
+}
+
+func (cmd *MultiPackIndexCommand) ExecuteVerify(options MultiPackIndexCmdOptions) error {
+	_, store, err := openPackStore(options.Path)
+	if err != nil {
+		return err
+	}
+
+	return store.VerifyMultiPackIndex()
+}
+
+func (cmd *MultiPackIndexCommand) ExecuteExpire(options MultiPackIndexCmdOptions) error {
+	_, store, err := openPackStore(options.Path)
+	if err != nil {
+		return err
+	}
+
+	expired, err := store.ExpireMultiPackIndex()
+	for _, pack := range expired {
+		fmt.Fprintf(cmd.writer, "Removed %s\n", pack)
+	}
+
+	return err
+}
+
+func (cmd *MultiPackIndexCommand) ExecuteRepack(options MultiPackIndexCmdOptions) error {
+	batchSize, err := config.ParseSize(options.BatchSize)
+	if err != nil {
+		return fmt.Errorf("invalid batch size '%s'", options.BatchSize)
+	}
+
+	ry, store, err := openPackStore(options.Path)
+	if err != nil {
+		return err
+	}
+
+	packOptions, err := ry.PackOptions()
+	if err != nil {
+		return err
+	}
+
+	pack, err := store.RepackMultiPackIndex(batchSize, packOptions)
+	if err != nil {
+		return err
+	}
+
+	if pack == nil {
+		fmt.Fprintln(cmd.writer, "Nothing new to pack.")
+		return nil
+	}
+
+	fmt.Fprintf(cmd.writer, "Total %d objects packed into %s\n", pack.Index().Count(), pack.Path())
+	return nil
+}
+
+func openPackStore(path string) (*repo.Repository, *storage.FilesystemStore, error) {
+	ry, err := repo.FromExisting(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore)
+	if !ok {
+		return nil, nil, repo.ErrPackingNotSupported
+	}
+
+	return ry, store, nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMultiPackIndexWriteAndVerify(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+
+	repack := NewRepackCmd(&bytes.Buffer{})
+	options := RepackCmdOptions{
+		Path:   ry.Info.WorkingDirectory(),
+		Window: storage.DefaultPackWindow,
+		Depth:  storage.DefaultPackDepth,
+	}
+	if err := repack.Execute(options); err != nil {
+		t.Fatalf("error occured during repack: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	cmd := NewMultiPackIndexCmd(&output)
+	midxOptions := MultiPackIndexCmdOptions{Path: ry.Info.WorkingDirectory()}
+	if err := cmd.ExecuteWrite(midxOptions); err != nil {
+		t.Fatalf("error occured during command execution: %v", err)
+	}
+
+	if err := cmd.ExecuteVerify(midxOptions); err != nil {
+		t.Errorf("multi-pack-index could not be verified: %v", err)
+	}
+
+	midx, err := ry.Storage.(*storage.FilesystemStore).MultiPackIndex()
+	assert.NoError(t, err)
+	if midx == nil {
+		t.Fatal("multi-pack-index was not written")
+	}
+
+	for _, commit := range commits {
+		assert.True(t, midx.Contains(commit.OID()), "commit %s is part of the multi-pack-index", commit.OID())
+	}
+}
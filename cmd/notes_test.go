@@ -227,12 +227,13 @@ func TestListingOfNotes(t *testing.T) {
 	}
 
 	actual := strings.FieldsFunc(output.String(), func(c rune) bool { return c == '\n' })
+	// the notes are the blobs "note0" to "note4" of the commits in the same order
 	expected := []string{
-		"105bc203aa908f9bde05cdd3d83d4942ab876452 52337f8c0b9c3a405f61e774e110449667f02c48",
-		"b58359108b45a89fda850ec75dac57d4d04f2a8a 8c2e614ca354d8d72a938d9f03720251bfdf99b9",
-		"9eeccd72f72437225ecd8e19b12c4a3f2f2ed02a a86c9bb220ca126890e1e4e4ecd18d9fc79c057d",
-		"c6706482e0a6e6b27709bc27873a83e5dfb9e601 b9ba344cda497e93f8598392e345eaa749dab27d",
-		"4f01e2291ceb1724d11a9bac61ef5f43fc153267 ff6d4f1e76027c68a784f1af7ae1235aa40f8ad8",
+		"c6706482e0a6e6b27709bc27873a83e5dfb9e601 " + commits[0].OID(),
+		"b58359108b45a89fda850ec75dac57d4d04f2a8a " + commits[1].OID(),
+		"4f01e2291ceb1724d11a9bac61ef5f43fc153267 " + commits[2].OID(),
+		"105bc203aa908f9bde05cdd3d83d4942ab876452 " + commits[3].OID(),
+		"9eeccd72f72437225ecd8e19b12c4a3f2f2ed02a " + commits[4].OID(),
 	}
 
 	assert.ElementsMatch(t, expected, actual)
@@ -256,7 +257,7 @@ func TestListingOfNotesWithFilter(t *testing.T) {
 
 	actual := strings.FieldsFunc(output.String(), func(c rune) bool { return c == '\n' })
 	expected := []string{
-		"b58359108b45a89fda850ec75dac57d4d04f2a8a 8c2e614ca354d8d72a938d9f03720251bfdf99b9",
+		"b58359108b45a89fda850ec75dac57d4d04f2a8a " + targetCommit,
 	}
 
 	assert.ElementsMatch(t, expected, actual)
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/config"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
+)
+
+func SetupPruneCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Prune all unreachable objects from the object database",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := PruneCmdOptions{}
+	cmd.Flags().BoolVarP(&options.DryRun, "dry-run", "n", false, "do not remove anything; just report what it would remove")
+	cmd.Flags().BoolVarP(&options.Verbose, "verbose", "v", false, "report all removed objects")
+	cmd.Flags().StringVar(&options.Expire, "expire", "", "only expire loose objects older than <time>")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		handler := NewPruneCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type PruneCmdOptions struct {
+	Path    string
+	DryRun  bool
+	Verbose bool
+	// Expiry date of unreachable objects, gc.pruneExpire is used if empty
+	Expire string
+}
+
+type PruneCommand struct {
+	writer io.Writer
+}
+
+func NewPruneCmd(writer io.Writer) PruneCommand {
+	return PruneCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *PruneCommand) Execute(options PruneCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	expire, err := pruneExpire(ry, options.Expire)
+	if err != nil {
+		return err
+	}
+
+	pruned, err := ry.Prune(repo.PruneOptions{Expire: expire, DryRun: options.DryRun})
+	if err != nil {
+		return err
+	}
+
+	if options.DryRun || options.Verbose {
+		for _, object := range pruned {
+			fmt.Fprintf(cmd.writer, "%s %s\n", object.OID, object.Type)
+		}
+	}
+
+	return nil
+}
+
+// pruneExpire parses the expiry date given on the command line and falls back to gc.pruneExpire
+func pruneExpire(ry *repo.Repository, value string) (time.Time, error) {
+	now := time.Now()
+	if value == "" {
+		return ry.PruneExpire(now)
+	}
+
+	expire, err := config.ParseExpiry(value, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed expiration date '%s'", value)
+	}
+
+	return expire, nil
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"github.com/furisto/gog/storage"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupRepackCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repack",
+		Short: "Pack unpacked objects in a repository",
+	}
+
+	cmd.Args = cobra.NoArgs
+
+	options := RepackCmdOptions{}
+	cmd.Flags().BoolVarP(&options.All, "all", "a", false, "pack everything referenced into a single pack")
+	cmd.Flags().BoolVarP(&options.UnpackUnreachable, "unpack-unreachable", "A", false,
+		"like -a, but with -d unreachable objects of deleted packs become loose objects")
+	cmd.Flags().BoolVarP(&options.DeleteRedundant, "delete", "d", false,
+		"after packing, remove redundant packs and loose objects")
+	cmd.Flags().BoolVarP(&options.Local, "local", "l", false, "do not pack objects borrowed from alternate object stores")
+	cmd.Flags().IntVar(&options.Window, "window", storage.DefaultPackWindow, "size of the window used for delta compression")
+	cmd.Flags().IntVar(&options.Depth, "depth", storage.DefaultPackDepth, "maximum delta depth")
+	cmd.Flags().BoolVarP(&options.WriteBitmap, "write-bitmap-index", "b", false, "write a bitmap index together with the pack")
+	cmd.Flags().BoolVarP(&options.Quiet, "quiet", "q", false, "be quiet")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		// the configuration of pack.window and pack.depth is only overridden by flags that are given
+		if !cmd.Flags().Changed("window") {
+			options.Window = -1
+		}
+		if !cmd.Flags().Changed("depth") {
+			options.Depth = -1
+		}
+
+		handler := NewRepackCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type RepackCmdOptions struct {
+	Path            string
+	All             bool
+	DeleteRedundant bool
+	Local           bool
+	// UnpackUnreachable implies All, unreachable objects of deleted packs are kept as loose objects
+	UnpackUnreachable bool
+	// Window and Depth override pack.window and pack.depth unless they are negative
+	Window      int
+	Depth       int
+	WriteBitmap bool
+	Quiet       bool
+}
+
+type RepackCommand struct {
+	writer io.Writer
+}
+
+func NewRepackCmd(writer io.Writer) RepackCommand {
+	return RepackCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *RepackCommand) Execute(options RepackCmdOptions) error {
+	ry, err := repo.FromExisting(options.Path)
+	if err != nil {
+		return err
+	}
+
+	if options.UnpackUnreachable {
+		options.All = true
+	}
+
+	if options.WriteBitmap && !options.All {
+		fmt.Fprintln(cmd.writer, "warning: disabling bitmap writing, as some objects are not being packed")
+		options.WriteBitmap = false
+	}
+
+	packOptions, err := ry.PackOptions()
+	if err != nil {
+		return err
+	}
+	if options.Window >= 0 {
+		packOptions.Window = options.Window
+	}
+	if options.Depth >= 0 {
+		packOptions.Depth = options.Depth
+	}
+
+	pack, err := ry.Repack(repo.RepackOptions{
+		All:               options.All,
+		UnpackUnreachable: options.UnpackUnreachable,
+		DeleteRedundant:   options.DeleteRedundant,
+		Local:             options.Local,
+		WriteBitmap:       options.WriteBitmap,
+		Pack:              packOptions,
+	})
+	if err != nil {
+		return err
+	}
+
+	if pack == nil {
+		if !options.Quiet {
+			fmt.Fprintln(cmd.writer, "Nothing new to pack.")
+		}
+		return nil
+	}
+
+	if !options.Quiet {
+		fmt.Fprintf(cmd.writer, "Total %d objects packed into %s\n", pack.Index().Count(), pack.Path())
+	}
+
+	return nil
+}
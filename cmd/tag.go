@@ -35,6 +35,8 @@ func SetupTagCmd(context CommandContext) *cobra.Command {
 	cmd.Flags().StringVarP(&createOptions.Message, "message", "m", "", "tag message")
 	cmd.Flags().StringP("file", "F", "", "read message from a file")
 	cmd.Flags().BoolVarP(&createOptions.Force, "force", "f", false, "replace the tag if exists")
+	cmd.Flags().BoolVarP(&createOptions.Sign, "sign", "s", false,
+		"create an annotated tag signed with the key of user.signingKey")
 
 	listOptions := TagCmdListOptions{}
 	cmd.Flags().StringVar(&listOptions.PointsAt, "points-at", "", "print only tags of the object")
@@ -42,13 +44,35 @@ func SetupTagCmd(context CommandContext) *cobra.Command {
 	deleteOptions := TagCmdDeleteOptions{}
 	cmd.Flags().StringVarP(&deleteOptions.TagName, "delete", "d", "", "delete tags")
 
+	var verify bool
+	cmd.Flags().BoolVarP(&verify, "verify", "v", false, "verify the signatures of the tags")
+
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		if verify {
+			// like git the tags are shown before their signatures are checked
+			handler := NewVerifyTagCmd(context.Logger)
+			return handler.Execute(VerifyTagCmdOptions{Path: cwd, Tags: args, Verbose: true})
+		}
+
 		handler := NewTagCmd(context.Logger)
 		if len(args) == 0 {
+			listOptions.Path = cwd
 			return handler.ExecuteList(listOptions)
 		}
 
-		_, err := handler.ExecuteCreate(createOptions)
+		createOptions.Path = cwd
+		createOptions.TagName = args[0]
+		createOptions.Target = "HEAD"
+		if len(args) > 1 {
+			createOptions.Target = args[1]
+		}
+
+		_, err = handler.ExecuteCreate(createOptions)
 		return err
 	}
 
@@ -75,6 +99,8 @@ type TagCmdCreateOptions struct {
 	Message     string
 	IsAnnotated bool
 	Force       bool
+	// Sign creates an annotated tag with an ssh signature
+	Sign bool
 }
 
 type TagCmdListOptions struct {
@@ -153,7 +179,21 @@ func (cmd *TagCommand) ExecuteCreate(options TagCmdCreateOptions) (*objects.Tag,
 	//	return err
 	//}
 
-	if options.IsAnnotated {
+	// refs like HEAD or branch names are resolved so that the tag references the object id
+	target, err := ry.ResolveRevision(options.Target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to resolve '%s' as a valid ref: %v", repo.ErrInvalidTagTarget, options.Target, err)
+	}
+	options.Target = target
+
+	if options.IsAnnotated || options.Sign {
+		var signer func(payload []byte) (string, error)
+		if options.Sign {
+			if signer, err = ry.Signer(); err != nil {
+				return nil, err
+			}
+		}
+
 		if len(options.Message) == 0 {
 			options.Message, err = cmd.requestMessage(ry)
 			if err != nil {
@@ -166,7 +206,7 @@ func (cmd *TagCommand) ExecuteCreate(options TagCmdCreateOptions) (*objects.Tag,
 			return nil, err
 		}
 
-		annotated, err := ry.Tags.CreateAnnotated(options.TagName, options.Target, tagger, options.Message, options.Force)
+		annotated, err := ry.Tags.CreateSigned(options.TagName, options.Target, tagger, options.Message, options.Force, signer)
 		return annotated, err
 	}
 
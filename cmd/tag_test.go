@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"github.com/furisto/gog/repo"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -14,11 +16,13 @@ const (
 )
 
 func TestCreateLightweightTag(t *testing.T) {
-	ry := createTestRepository(t)
+	ry, commits := prepareEnvWithCommitObjects(t)
+	targetCommit := commits[0].OID()
 
 	options := NewTagCmdOptionsBuilder(ry.Info.WorkingDirectory()).
 		WithTagName(lightweightName).
 		WithAnnotated(false).
+		WithTarget(targetCommit).
 		Build()
 
 	var buffer bytes.Buffer
@@ -37,8 +41,8 @@ func TestCreateLightweightTag(t *testing.T) {
 		t.Errorf("expected tag name of %s, but was %s", lightweightName, tagRef.Name)
 	}
 
-	if tagRef.RefValue != "" {
-		t.Errorf("expected tag target value of %s, but was %s", "", tagRef.RefValue)
+	if tagRef.RefValue != targetCommit {
+		t.Errorf("expected tag target value of %s, but was %s", targetCommit, tagRef.RefValue)
 	}
 }
 
@@ -88,10 +92,10 @@ func TestCreateAnnotatedTagToTree(t *testing.T) {
 	assert.Equal(t, tagMessage, tag.Message(), "tag message")
 }
 
-// todo: test
 func TestCreateAnnotatedTagToBlob(t *testing.T) {
-	ry, commits := prepareEnvWithCommitObjects(t)
-	targetObject := commits[0].Tree
+	ry, _ := prepareEnvWithCommitObjects(t)
+	// git hash-object of 0/0 in the first commit
+	targetObject := "857f065e4154176c98f4274d223066861e8e3d80"
 
 	options := NewTagCmdOptionsBuilder(ry.Info.WorkingDirectory()).
 		WithAnnotated(true).
@@ -106,7 +110,7 @@ func TestCreateAnnotatedTagToBlob(t *testing.T) {
 		return
 	}
 
-	assert.Equal(t, "Commit", tag.TargetType(), "tag target type")
+	assert.Equal(t, "Blob", tag.TargetType(), "tag target type")
 	assert.Equal(t, targetObject, tag.TargetOID(), "target object id")
 	assert.Equal(t, "furisto", tag.Tagger().Name, "tagger name")
 	assert.Equal(t, "furisto@test.com", tag.Tagger().Email, "tagger email")
@@ -128,6 +132,24 @@ func TestHandleInvalidTagTarget(t *testing.T) {
 	assert.Error(t, repo.ErrInvalidTagTarget, err)
 }
 
+func TestHandleInvalidLightweightTagTarget(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	options := NewTagCmdOptionsBuilder(ry.Info.WorkingDirectory()).
+		WithTagName(lightweightName).
+		WithAnnotated(false).
+		WithTarget("no-such-branch").
+		Build()
+
+	var buffer bytes.Buffer
+	cmd := NewTagCmd(&buffer)
+	_, err := cmd.ExecuteCreate(options)
+
+	assert.True(t, errors.Is(err, repo.ErrInvalidTagTarget), "unexpected error: %v", err)
+	_, err = ry.Tags.Get(lightweightName)
+	assert.Error(t, err, "tag was not created")
+}
+
 func TestHandleTagAlreadyExists(t *testing.T) {
 	ry, commits := prepareEnvWithCommitObjects(t)
 	targetObject := commits[0].OID()
@@ -144,7 +166,7 @@ func TestHandleTagAlreadyExists(t *testing.T) {
 	var buffer bytes.Buffer
 	cmd := NewTagCmd(&buffer)
 	_, err := cmd.ExecuteCreate(options)
-	assert.EqualError(t, err, repo.ErrTagAlreadyExists.Error())
+	assert.EqualError(t, err, fmt.Sprintf("tag %s already exists", lightweightName))
 }
 
 func TestHandleOverwriteTag(t *testing.T) {
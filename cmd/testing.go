@@ -16,8 +16,6 @@ import (
 	"time"
 )
 
-const ParentCommit = "48743154a35f5751796d39ebceb615453abac8de"
-
 func createTemporaryDir(t *testing.T) string {
 	t.Helper()
 
@@ -56,22 +54,26 @@ func generateUUID(t *testing.T) string {
 	return uuid
 }
 
-func prepareEnvWithCommits(t *testing.T) *repo.Repository {
+func prepareEnvWithCommits(t *testing.T) (*repo.Repository, *objects.Commit) {
 	t.Helper()
 
 	ry := PrepareEnvWithNoCommmits(t)
 
-	if _, err := ry.Branches.Create("master", ParentCommit); err != nil {
-		t.Fatalf("could not create branch 'master'")
+	parent, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("parent")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit on branch 'master': %v", err)
 	}
 
-	return ry
+	return ry, parent
 }
 
 func prepareEnvWithCommitObjects(t *testing.T) (*repo.Repository, []*objects.Commit) {
 	t.Helper()
 
 	ry := createTestRepository(t)
+	configureUser(t, ry)
 	commits := make([]*objects.Commit, 0, 5)
 
 	for i := 0; i < 5; i++ {
@@ -154,6 +156,14 @@ func PrepareEnvWithNoCommmits(t *testing.T) *repo.Repository {
 
 	ry := createTestRepository(t)
 	populateRepo(t, ry.Info.WorkingDirectory())
+	configureUser(t, ry)
+
+	return ry
+}
+
+// configureUser sets the identity that is used for commits and tags
+func configureUser(t *testing.T, ry *repo.Repository) {
+	t.Helper()
 
 	if err := ry.Config.Set("user", "name", "furisto"); err != nil {
 		t.Fatalf("could not set user name")
@@ -161,8 +171,6 @@ func PrepareEnvWithNoCommmits(t *testing.T) *repo.Repository {
 	if err := ry.Config.Set("user", "email", "furisto@test.com"); err != nil {
 		t.Fatalf("could not set user email")
 	}
-
-	return ry
 }
 
 func createTestRepository(t *testing.T) *repo.Repository {
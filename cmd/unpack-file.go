@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/repo"
@@ -55,17 +56,10 @@ func (cmd *UnpackFileCommand) Execute(options UnpackFileCmdOptions) error {
 		return err
 	}
 
-	data, err := ry.Storage.Get(options.OID)
-	if err != nil {
-		return err
-	}
-
-	if !objects.IsBlob(data) {
+	blob, err := ry.LookupBlob(options.OID)
+	if errors.Is(err, objects.ErrWrongType) {
 		return fmt.Errorf("%s is not a blob object", options.OID)
-	}
-
-	blob, err := objects.LoadBlob(data)
-	if err != nil {
+	} else if err != nil {
 		return err
 	}
 
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/furisto/gog/repo"
+	"io"
+	"strings"
+)
+
+// verifyObject checks the signature of the object with the object id, name is the name given on the command
+// line. It returns the data that is signed together with the result of the check.
+type verifyObject func(ry *repo.Repository, name, oid string) ([]byte, *repo.SignatureCheck, error)
+
+// verifySignatures checks the signatures of the named objects and prints the result of every check, verbose
+// prints the signed data first. An error is returned if any of the signatures is not good.
+func verifySignatures(writer io.Writer, path string, names []string, verbose bool, verify verifyObject) error {
+	ry, err := repo.FromExisting(path)
+	if err != nil {
+		return err
+	}
+
+	var unverified []string
+	for _, name := range names {
+		oid, err := ry.ResolveRevision(name)
+		if err != nil {
+			return err
+		}
+
+		payload, check, err := verify(ry, name, oid)
+		if verbose {
+			writer.Write(payload)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(writer, check)
+		if check.Status != repo.GoodSignature {
+			unverified = append(unverified, name)
+		}
+	}
+
+	if len(unverified) > 0 {
+		return fmt.Errorf("could not verify the signature of %s", strings.Join(unverified, ", "))
+	}
+
+	return nil
+}
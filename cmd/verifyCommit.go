@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupVerifyCommitCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-commit <commit>...",
+		Short: "Check the ssh signature of commits",
+	}
+
+	cmd.Args = cobra.MinimumNArgs(1)
+
+	options := VerifyCommitCmdOptions{}
+	cmd.Flags().BoolVarP(&options.Verbose, "verbose", "v", false, "print the contents of the commit before verifying it")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.Commits = args
+		handler := NewVerifyCommitCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type VerifyCommitCmdOptions struct {
+	Path    string
+	Commits []string
+	Verbose bool
+}
+
+type VerifyCommitCommand struct {
+	writer io.Writer
+}
+
+func NewVerifyCommitCmd(writer io.Writer) VerifyCommitCommand {
+	return VerifyCommitCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *VerifyCommitCommand) Execute(options VerifyCommitCmdOptions) error {
+	return verifySignatures(cmd.writer, options.Path, options.Commits, options.Verbose, verifyCommitSignature)
+}
+
+func verifyCommitSignature(ry *repo.Repository, name, oid string) ([]byte, *repo.SignatureCheck, error) {
+	commit, err := ry.LookupCommit(oid)
+	if errors.Is(err, objects.ErrWrongType) {
+		return nil, nil, fmt.Errorf("%s: cannot verify a non-commit object", name)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	_, payload, err := commit.SignedPayload()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	check, err := ry.VerifyCommit(commit)
+	return payload, check, err
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/furisto/gog/plumbing/sshsig"
+	"github.com/furisto/gog/repo"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// configureSshSigning creates a signing key for the repository and lists it as the only allowed signer
+func configureSshSigning(t *testing.T, ry *repo.Repository) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+
+	keyPath := filepath.Join(ry.Info.GitDirectory(), "signing_key")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("could not write key: %v", err)
+	}
+
+	key, err := sshsig.NewPrivateKey(private)
+	if err != nil {
+		t.Fatalf("could not load key: %v", err)
+	}
+
+	allowedSignersPath := filepath.Join(ry.Info.GitDirectory(), "allowed_signers")
+	allowed := []byte("furisto@test.com " + key.PublicKey().String() + "\n")
+	if err := ioutil.WriteFile(allowedSignersPath, allowed, 0644); err != nil {
+		t.Fatalf("could not write allowed signers: %v", err)
+	}
+
+	settings := [][3]string{
+		{"gpg", "format", "ssh"},
+		{"user", "signingKey", keyPath},
+		{`gpg "ssh"`, "allowedSignersFile", allowedSignersPath},
+	}
+	for _, setting := range settings {
+		if err := ry.Config.Set(setting[0], setting[1], setting[2]); err != nil {
+			t.Fatalf("could not set %s.%s: %v", setting[0], setting[1], err)
+		}
+	}
+}
+
+func TestVerifySignedCommit(t *testing.T) {
+	ry := PrepareEnvWithNoCommmits(t)
+	configureSshSigning(t, ry)
+
+	commitCmd := NewCommitCmd(&bytes.Buffer{})
+	commit, err := commitCmd.Execute(CommitOptions{Path: ry.Info.WorkingDirectory(), Message: "signed", Sign: true})
+	if err != nil {
+		t.Fatalf("error occured during commit: %v", err)
+	}
+
+	_, ok := commit.ExtraHeader("gpgsig")
+	assert.True(t, ok)
+
+	output := bytes.Buffer{}
+	cmd := NewVerifyCommitCmd(&output)
+	err = cmd.Execute(VerifyCommitCmdOptions{Path: ry.Info.WorkingDirectory(), Commits: []string{"HEAD", commit.OID()[:7]}})
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "Good \"git\" signature for furisto@test.com with ED25519 key SHA256:")
+}
+
+func TestVerifyUnsignedCommitFails(t *testing.T) {
+	ry := PrepareEnvWithNoCommmits(t)
+	configureSshSigning(t, ry)
+
+	commitCmd := NewCommitCmd(&bytes.Buffer{})
+	if _, err := commitCmd.Execute(CommitOptions{Path: ry.Info.WorkingDirectory(), Message: "unsigned"}); err != nil {
+		t.Fatalf("error occured during commit: %v", err)
+	}
+
+	cmd := NewVerifyCommitCmd(&bytes.Buffer{})
+	err := cmd.Execute(VerifyCommitCmdOptions{Path: ry.Info.WorkingDirectory(), Commits: []string{"HEAD"}})
+	assert.Error(t, err)
+}
+
+func TestSignedCommitRequiresSigningKey(t *testing.T) {
+	ry := PrepareEnvWithNoCommmits(t)
+
+	commitCmd := NewCommitCmd(&bytes.Buffer{})
+	_, err := commitCmd.Execute(CommitOptions{Path: ry.Info.WorkingDirectory(), Message: "signed", Sign: true})
+	assert.Error(t, err)
+
+	_, err = ry.Head(true)
+	assert.Error(t, err, "no commit is created")
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/repo"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+func SetupVerifyTagCmd(context CommandContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-tag <tag>...",
+		Short: "Check the ssh signature of tags",
+	}
+
+	cmd.Args = cobra.MinimumNArgs(1)
+
+	options := VerifyTagCmdOptions{}
+	cmd.Flags().BoolVarP(&options.Verbose, "verbose", "v", false, "print the contents of the tag before verifying it")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if cwd, err := os.Getwd(); err == nil {
+			options.Path = cwd
+		} else {
+			return err
+		}
+
+		options.Tags = args
+		handler := NewVerifyTagCmd(context.Logger)
+		return handler.Execute(options)
+	}
+
+	return cmd
+}
+
+type VerifyTagCmdOptions struct {
+	Path    string
+	Tags    []string
+	Verbose bool
+}
+
+type VerifyTagCommand struct {
+	writer io.Writer
+}
+
+func NewVerifyTagCmd(writer io.Writer) VerifyTagCommand {
+	return VerifyTagCommand{
+		writer: writer,
+	}
+}
+
+func (cmd *VerifyTagCommand) Execute(options VerifyTagCmdOptions) error {
+	return verifySignatures(cmd.writer, options.Path, options.Tags, options.Verbose, verifyTagSignature)
+}
+
+func verifyTagSignature(ry *repo.Repository, name, oid string) ([]byte, *repo.SignatureCheck, error) {
+	tag, err := ry.LookupTag(oid)
+	if errors.Is(err, objects.ErrWrongType) {
+		return nil, nil, fmt.Errorf("%s: cannot verify a non-tag object", name)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	_, payload, err := tag.SignedPayload()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	check, err := ry.VerifyTag(tag)
+	return payload, check, err
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifySignedTag(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+	configureSshSigning(t, ry)
+	if err := ry.Config.Set("user", "name", "furisto"); err != nil {
+		t.Fatalf("could not set user name: %v", err)
+	}
+	if err := ry.Config.Set("user", "email", "furisto@test.com"); err != nil {
+		t.Fatalf("could not set user email: %v", err)
+	}
+
+	options := TagCmdCreateOptions{
+		Path:    ry.Info.WorkingDirectory(),
+		TagName: "signed",
+		Target:  commits[0].OID(),
+		Message: tagMessage,
+		Sign:    true,
+	}
+
+	tagCmd := NewTagCmd(&bytes.Buffer{})
+	tag, err := tagCmd.ExecuteCreate(options)
+	if err != nil {
+		t.Fatalf("could not create signed tag: %v", err)
+	}
+	assert.Equal(t, tagMessage, tag.Message())
+
+	output := bytes.Buffer{}
+	cmd := NewVerifyTagCmd(&output)
+	err = cmd.Execute(VerifyTagCmdOptions{Path: ry.Info.WorkingDirectory(), Tags: []string{"signed"}, Verbose: true})
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "tag signed\n")
+	assert.Contains(t, output.String(), "Good \"git\" signature for furisto@test.com with ED25519 key SHA256:")
+	assert.NotContains(t, output.String(), "BEGIN SSH SIGNATURE")
+}
+
+func TestVerifyTagRejectsOtherObjects(t *testing.T) {
+	ry, commits := prepareEnvWithCommitObjects(t)
+	configureSshSigning(t, ry)
+
+	cmd := NewVerifyTagCmd(&bytes.Buffer{})
+	err := cmd.Execute(VerifyTagCmdOptions{Path: ry.Info.WorkingDirectory(), Tags: []string{commits[0].OID()}})
+	assert.Error(t, err)
+}
+
+func TestVerifyTagReportsMissingTags(t *testing.T) {
+	ry, _ := prepareEnvWithCommitObjects(t)
+
+	cmd := NewVerifyTagCmd(&bytes.Buffer{})
+	err := cmd.Execute(VerifyTagCmdOptions{Path: ry.Info.WorkingDirectory(), Tags: []string{"v2"}})
+	assert.EqualError(t, err, "unknown revision v2")
+}
@@ -54,7 +54,17 @@ func (cmd *WriteTreeCommand) Execute(options WriteTreeOptions) error {
 			return err
 		}
 
-		tree, err := objects.NewTreeFromDirectory(options.Path, options.Prefix)
+		modes, err := r.WorkingTreeModes()
+		if err != nil {
+			return err
+		}
+
+		recorded, err := r.RecordedModes(options.Path)
+		if err != nil {
+			return err
+		}
+
+		tree, err := objects.NewTreeFromDirectoryWithModes(options.Path, options.Prefix, r.Storage.ObjectFormat(), modes, recorded)
 		if err != nil {
 			return err
 		}
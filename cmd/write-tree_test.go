@@ -18,12 +18,18 @@ func TestWriteTreeFromWorkingDirNoPrefix(t *testing.T) {
 	var output bytes.Buffer
 	cmd := NewWriteTreeCmd(&output)
 	if err := cmd.Execute(options); err != nil {
-		t.Errorf("")
+		t.Errorf("error occured during command execution: %v", err)
 		return
 	}
 
+	// git write-tree of the populated repository and git hash-object of 0/1
+	tree := "80fa9593f3c3d03f011492504e5d877b97b1277f"
+	if _, err := r.Storage.Get(tree); err != nil {
+		t.Errorf("could not retrieve tree %v: %v", tree, err)
+	}
+
 	results := map[string][]byte{
-		"f37c2df7d15983fffe5455764f3c97263a3c492e": []byte("blob 84\x00C:\\Users\\Furisto\\AppData\\Local\\Temp\\4631e64f-1bce-652c-d68c-6fd045d05e8a269543607\\01"),
+		"a616ad491b179d212b8a78f2067b361980fffc54": []byte("blob 2\x0001"),
 	}
 
 	for k, v := range results {
@@ -6,12 +6,16 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
 	ErrUnavailable  = errors.New("configuration unavailable")
 	ErrUnknownKey   = errors.New("unknown key")
 	ErrCannotSetKey = errors.New("cannot set key")
+	ErrInvalidValue = errors.New("invalid value")
 )
 
 func LocateSystemConfig() string {
@@ -243,3 +247,76 @@ func (cb *ConfigBuilder) Build() Config {
 		configs: cb.configs,
 	}
 }
+
+// ParseSize parses an integer value that may carry one of the unit suffixes k, m or g like git does
+// for values such as core.deltaBaseCacheLimit
+func ParseSize(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	factor := int64(1)
+	if len(value) > 0 {
+		switch strings.ToLower(value[len(value)-1:]) {
+		case "k":
+			factor = 1 << 10
+		case "m":
+			factor = 1 << 20
+		case "g":
+			factor = 1 << 30
+		}
+	}
+
+	if factor != 1 {
+		value = value[:len(value)-1]
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, ErrInvalidValue
+	}
+
+	return size * factor, nil
+}
+
+var expiryUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// ParseExpiry parses an expiry date like it is used by gc.pruneExpire. Besides absolute dates the relative
+// form [n].[unit].ago is supported, as well as "now" and "never". For "never" the zero time is returned.
+func ParseExpiry(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "now", "all":
+		return now, nil
+	case "never", "false":
+		return time.Time{}, nil
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool { return r == '.' || r == ' ' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n < 0 {
+			return time.Time{}, ErrInvalidValue
+		}
+
+		unit, ok := expiryUnits[strings.TrimSuffix(fields[1], "s")]
+		if !ok {
+			return time.Time{}, ErrInvalidValue
+		}
+
+		return now.Add(-time.Duration(n) * unit), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if date, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, ErrInvalidValue
+}
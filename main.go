@@ -58,5 +58,47 @@ func setupCommands() *cobra.Command {
 	notes := cmd.SetupNotesCmd(cmdContext)
 	rootCmd.AddCommand(notes)
 
+	repack := cmd.SetupRepackCmd(cmdContext)
+	rootCmd.AddCommand(repack)
+
+	gc := cmd.SetupGcCmd(cmdContext)
+	rootCmd.AddCommand(gc)
+
+	prune := cmd.SetupPruneCmd(cmdContext)
+	rootCmd.AddCommand(prune)
+
+	clone := cmd.SetupCloneCmd(cmdContext)
+	rootCmd.AddCommand(clone)
+
+	fsck := cmd.SetupFsckCmd(cmdContext)
+	rootCmd.AddCommand(fsck)
+
+	commitGraph := cmd.SetupCommitGraphCmd(cmdContext)
+	rootCmd.AddCommand(commitGraph)
+
+	multiPackIndex := cmd.SetupMultiPackIndexCmd(cmdContext)
+	rootCmd.AddCommand(multiPackIndex)
+
+	countObjects := cmd.SetupCountObjectsCmd(cmdContext)
+	rootCmd.AddCommand(countObjects)
+
+	verifyCommit := cmd.SetupVerifyCommitCmd(cmdContext)
+	rootCmd.AddCommand(verifyCommit)
+
+	verifyTag := cmd.SetupVerifyTagCmd(cmdContext)
+	rootCmd.AddCommand(verifyTag)
+
+	mkTree := cmd.SetupMkTreeCmd(cmdContext)
+	rootCmd.AddCommand(mkTree)
+
+	commitTree := cmd.SetupCommitTreeCmd(cmdContext)
+	rootCmd.AddCommand(commitTree)
+
+	mkTag := cmd.SetupMkTagCmd(cmdContext)
+	rootCmd.AddCommand(mkTag)
+
+	lsTree := cmd.SetupLsTreeCmd(cmdContext)
+	rootCmd.AddCommand(lsTree)
+
 	return rootCmd
 }
@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/furisto/gog/storage"
-	hasher "github.com/furisto/gog/util"
+	"github.com/furisto/gog/util"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -15,22 +15,33 @@ var BlobType = []byte("blob")
 
 type Blob struct {
 	oid     string
-	size    uint32
+	size    uint64
 	Content []byte
+	// path of a file of the working tree whose content is streamed from the file when the blob is saved, the
+	// content is not held in memory then
+	path string
 }
 
 func NewBlob(content []byte) *Blob {
+	return NewBlobWithFormat(content, util.DefaultObjectFormat)
+}
+
+func NewBlobWithFormat(content []byte, format util.ObjectFormat) *Blob {
 	blob := Blob{}
-	blob.SetSize(uint32(len(content)))
+	blob.SetSize(uint64(len(content)))
 	blob.Content = content
 
 	header := blob.getHeader()
-	blob.SetOID(hasher.Hash(header, content))
+	blob.SetOID(format.Hash(header, content))
 
 	return &blob
 }
 
 func NewBlobFromFile(filePath string) (*Blob, error) {
+	return NewBlobFromFileWithFormat(filePath, util.DefaultObjectFormat)
+}
+
+func NewBlobFromFileWithFormat(filePath string, format util.ObjectFormat) (*Blob, error) {
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return nil, err
 	}
@@ -41,16 +52,61 @@ func NewBlobFromFile(filePath string) (*Blob, error) {
 	}
 
 	blob := Blob{}
-	blob.SetSize(uint32(len(content)))
+	blob.SetSize(uint64(len(content)))
 	blob.Content = content
 
 	header := blob.getHeader()
-	blob.SetOID(hasher.Hash(header, content))
+	blob.SetOID(format.Hash(header, content))
+
+	return &blob, nil
+}
+
+// NewBlobFromWorkingTree creates the blob that git stores for a file of the working tree
+func NewBlobFromWorkingTree(filePath string) (*Blob, error) {
+	return NewBlobFromWorkingTreeWithFormat(filePath, util.DefaultObjectFormat)
+}
+
+// NewBlobFromWorkingTreeWithFormat creates the blob that git stores for a file of the working tree, which is the
+// target of the link for symlinks and the content of the file otherwise. The content of files is not loaded into
+// memory, it is hashed and later saved from the file so that large files can be stored.
+func NewBlobFromWorkingTreeWithFormat(filePath string, format util.ObjectFormat) (*Blob, error) {
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBlobWithFormat([]byte(target), format), nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	oid, err := format.HashObject("blob", uint64(info.Size()), file)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := Blob{path: filePath}
+	blob.SetSize(uint64(info.Size()))
+	blob.SetOID(oid)
 
 	return &blob, nil
 }
 
 func LoadBlob(blobData []byte) (*Blob, error) {
+	return LoadBlobWithFormat(blobData, util.DefaultObjectFormat)
+}
+
+func LoadBlobWithFormat(blobData []byte, format util.ObjectFormat) (*Blob, error) {
 	if !IsBlob(blobData) {
 		return nil, errors.New("not of type blob")
 	}
@@ -73,13 +129,13 @@ func LoadBlob(blobData []byte) (*Blob, error) {
 		Content: blobData[end+1:],
 	}
 
-	size, err := strconv.Atoi(string(blobData[start+1 : end]))
+	size, err := strconv.ParseUint(string(blobData[start+1:end]), 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
-	blob.SetSize(uint32(size))
-	blob.SetOID(hasher.Hash(blobData))
+	blob.SetSize(size)
+	blob.SetOID(format.Hash(blobData))
 
 	return &blob, nil
 }
@@ -101,11 +157,11 @@ func (b *Blob) SetOID(oid string) {
 	b.oid = oid
 }
 
-func (b *Blob) Size() uint32 {
+func (b *Blob) Size() uint64 {
 	return b.size
 }
 
-func (b *Blob) SetSize(size uint32) {
+func (b *Blob) SetSize(size uint64) {
 	b.size = size
 }
 
@@ -114,8 +170,30 @@ func (b *Blob) Type() string {
 }
 
 func (b *Blob) Save(store storage.ObjectStore) error {
-	by := b.Bytes()
-	return store.Put(b.OID(), by)
+	if b.path == "" {
+		return store.Put(b.OID(), b.Bytes())
+	}
+
+	if exists, err := store.Stat(b.OID()); err != nil || exists {
+		return err
+	}
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	oid, err := store.PutStream("blob", b.Size(), file)
+	if err != nil {
+		return err
+	}
+
+	if oid != b.OID() {
+		return fmt.Errorf("%s changed while it was added", b.path)
+	}
+
+	return nil
 }
 
 func (b *Blob) getHeader() []byte {
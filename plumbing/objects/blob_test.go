@@ -2,7 +2,10 @@ package objects
 
 import (
 	"bytes"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
 	"io/ioutil"
+	"os"
 	"testing"
 )
 
@@ -32,6 +35,41 @@ func TestCreateBlob(t *testing.T) {
 	}
 }
 
+func TestBlobFromWorkingTreeIsStreamed(t *testing.T) {
+	filePath, err := createBlobFile()
+	if err != nil {
+		t.Fatal("Could not create blob file")
+	}
+	defer os.Remove(filePath)
+
+	blob, err := NewBlobFromWorkingTree(filePath)
+	if err != nil {
+		t.Fatalf("could not create blob: %v", err)
+	}
+
+	assert.Equal(t, NewBlob([]byte("lorem ipsum")).OID(), blob.OID())
+	assert.Empty(t, blob.Content, "content is not loaded into memory")
+
+	store := storage.NewMemoryStore()
+	if err := blob.Save(store); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	data, err := store.Get(blob.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "blob 11\x00lorem ipsum", string(data))
+	}
+
+	if err := ioutil.WriteFile(filePath, []byte("lorem dolor"), 0644); err != nil {
+		t.Fatalf("could not change file: %v", err)
+	}
+	blob, _ = NewBlobFromWorkingTree(filePath)
+	if err := ioutil.WriteFile(filePath, []byte("changed now"), 0644); err != nil {
+		t.Fatalf("could not change file: %v", err)
+	}
+	assert.Error(t, blob.Save(store), "file changed after it was hashed")
+}
+
 func createBlobFile() (string, error) {
 	file, err := ioutil.TempFile("", "foo")
 	if err != nil {
@@ -1,16 +1,15 @@
 package objects
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
 	"github.com/furisto/gog/config"
 	"github.com/furisto/gog/storage"
-	hasher "github.com/furisto/gog/util"
+	"github.com/furisto/gog/util"
 	"io"
-	"io/ioutil"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -35,14 +34,57 @@ var ParentPrefix = []byte("parent")
 var AuthorPrefix = []byte("author")
 var CommitterPrefix = []byte("committer")
 
+// signature headers of commits, sha256 repositories use gpgsig-sha256
+const (
+	SignatureHeader       = "gpgsig"
+	SignatureHeaderSHA256 = "gpgsig-sha256"
+)
+
 type Commit struct {
 	oid      string
-	size     uint32
+	size     uint64
 	Tree     string
 	Parents  []string
 	Author   *Signature
 	Commiter *Signature
-	Message  string
+	// Headers after the committer in the order in which they appear in the commit
+	ExtraHeaders []ExtraHeader
+	Message      string
+	// content is the commit as it was decoded without the object header, signatures are checked against it
+	content []byte
+	// ending records how a decoded commit without a blank line ended after its headers, so that it is encoded to
+	// the same bytes as long as its message stays empty
+	ending headerEnding
+}
+
+// headerEnding is what follows the headers of a commit
+type headerEnding int8
+
+const (
+	// blankLine separates the headers from the message
+	blankLine headerEnding = iota
+	// endOfData means that the commit ends right after the last header
+	endOfData
+	// lineBreak means that the commit ends with the line break of the last header
+	lineBreak
+)
+
+// ExtraHeader is a commit header that is not interpreted by gog, like gpgsig, encoding or mergetag. The value of
+// a header that spans several lines contains the line breaks but not the space that starts continuation lines.
+type ExtraHeader struct {
+	Key   string
+	Value string
+}
+
+// ExtraHeader returns the value of the first extra header with the key
+func (c *Commit) ExtraHeader(key string) (string, bool) {
+	for _, header := range c.ExtraHeaders {
+		if header.Key == key {
+			return header.Value, true
+		}
+	}
+
+	return "", false
 }
 
 func DecodeCommit(oid string, data []byte) (*Commit, error) {
@@ -50,152 +92,198 @@ func DecodeCommit(oid string, data []byte) (*Commit, error) {
 		return nil, errors.New("not of type commit")
 	}
 
-	br := bytes.NewReader(data[7:])
-	reader := bufio.NewReader(br)
+	headerEnd := bytes.IndexByte(data, 0)
+	if headerEnd < 0 {
+		return nil, errors.New("commit header is corrupt")
+	}
 
-	sizeString, err := reader.ReadString(byte(0))
+	sizeInt, err := strconv.ParseUint(string(data[len(CommitType)+1:headerEnd]), 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
-	sizeInt, err := strconv.Atoi(sizeString[:len(sizeString)-1])
+	commit, err := decodeCommitContent(data[headerEnd+1:])
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = reader.ReadBytes(' ')
-	if err != nil {
-		return nil, err
+	commit.oid = oid
+	commit.size = sizeInt
+	return commit, nil
+}
+
+// decodeCommitContent decodes the content of a commit, which follows the object header
+func decodeCommitContent(data []byte) (*Commit, error) {
+	// the data may end after the headers without the blank line, the message is empty then
+	content := string(data)
+	headers, message, ending := content, "", endOfData
+	if end := strings.Index(content, "\n\n"); end >= 0 {
+		headers, message, ending = content[:end], content[end+2:], blankLine
+	} else if strings.HasSuffix(content, "\n") {
+		headers, ending = strings.TrimSuffix(content, "\n"), lineBreak
 	}
 
-	treeOid, err := reader.ReadString('\n')
+	fields, err := decodeHeaders(headers)
 	if err != nil {
 		return nil, err
 	}
-	treeOid = treeOid[:len(treeOid)-1]
 
-	var parentOids []string
-	for {
-		parentPrefix, err := reader.Peek(6)
-		if err != nil {
-			return nil, err
+	commit := &Commit{
+		Message: message,
+		content: data,
+		ending:  ending,
+	}
+
+	for i, field := range fields {
+		switch {
+		case i == 0:
+			if field.Key != "tree" {
+				return nil, errors.New("commit does not have tree")
+			}
+			commit.Tree = field.Value
+		case field.Key == "parent" && commit.Author == nil:
+			commit.Parents = append(commit.Parents, field.Value)
+		case field.Key == "author" && commit.Author == nil:
+			if commit.Author, err = DecodeSignature([]byte(field.Value)); err != nil {
+				return nil, err
+			}
+		case field.Key == "committer" && commit.Author != nil && commit.Commiter == nil:
+			if commit.Commiter, err = DecodeSignature([]byte(field.Value)); err != nil {
+				return nil, err
+			}
+		case commit.Commiter != nil:
+			commit.ExtraHeaders = append(commit.ExtraHeaders, field)
+		default:
+			return nil, fmt.Errorf("unexpected header %s in commit", field.Key)
 		}
-
-		if !bytes.Equal(parentPrefix, []byte("parent")) {
-			break
-		}
-
-		_, err = reader.ReadBytes(' ')
-		if err != nil {
-			return nil, err
-		}
-
-		parentOid, err := reader.ReadString('\n')
-		if err != nil {
-			return nil, err
-		}
-
-		parentOids = append(parentOids, parentOid[:len(parentOid)-1])
 	}
 
-	prefix, err := reader.ReadBytes(' ')
-	if err != nil {
-		return nil, err
-	}
-
-	if !bytes.Equal(prefix[:len(prefix)-1], AuthorPrefix) {
+	if commit.Author == nil {
 		return nil, errors.New("commit does not have author")
 	}
 
-	authorBytes, err := reader.ReadBytes('\n')
-	if err != nil {
-		return nil, err
+	if commit.Commiter == nil {
+		return nil, errors.New("commit does not have committer")
 	}
 
-	authorSig, err := DecodeSignature(authorBytes)
-	if err != nil {
-		return nil, err
-	}
+	return commit, nil
+}
 
-	prefix, err = reader.ReadBytes(' ')
-	if err != nil {
-		return nil, err
+// SignedPayload returns the signature of the commit and the data that was signed, which is the commit without
+// its signature headers. Decoded commits only have the signature headers removed from the data they were
+// decoded from. The signature is empty if the commit is not signed.
+func (c *Commit) SignedPayload() (string, []byte, error) {
+	signature, ok := c.ExtraHeader(SignatureHeader)
+	if !ok {
+		signature, ok = c.ExtraHeader(SignatureHeaderSHA256)
 	}
 
-	if !bytes.Equal(prefix[:len(prefix)-1], CommitterPrefix) {
-		return nil, errors.New("commit does not have author")
+	if !ok {
+		return "", nil, nil
 	}
 
-	committerBytes, err := reader.ReadBytes('\n')
-	if err != nil {
-		return nil, err
+	if c.content != nil && !c.modified() {
+		return signature, removeSignatureHeaders(c.content), nil
 	}
 
-	committerSig, err := DecodeSignature(committerBytes)
-	if err != nil {
-		return nil, err
+	unsigned := *c
+	unsigned.ExtraHeaders = nil
+	for _, header := range c.ExtraHeaders {
+		if header.Key != SignatureHeader && header.Key != SignatureHeaderSHA256 {
+			unsigned.ExtraHeaders = append(unsigned.ExtraHeaders, header)
+		}
 	}
 
-	if _, err := reader.ReadBytes('\n'); err != nil {
-		return nil, err
+	var payload bytes.Buffer
+	if err := unsigned.writeContent(&payload); err != nil {
+		return "", nil, err
 	}
 
-	message, err := ioutil.ReadAll(reader)
+	return signature, payload.Bytes(), nil
+}
+
+// modified tells whether the fields of a decoded commit were changed since it was decoded
+func (c *Commit) modified() bool {
+	original, err := decodeCommitContent(c.content)
 	if err != nil {
-		return nil, err
+		return true
 	}
 
-	return &Commit{
-		oid:      oid,
-		size:     uint32(sizeInt),
-		Tree:     treeOid,
-		Parents:  parentOids,
-		Author:   authorSig,
-		Commiter: committerSig,
-		Message:  string(message),
-	}, nil
+	var before, after bytes.Buffer
+	if err := original.writeContent(&before); err != nil {
+		return true
+	}
+	if err := c.writeContent(&after); err != nil {
+		return true
+	}
 
+	return !bytes.Equal(before.Bytes(), after.Bytes())
 }
 
-func EncodeCommit(commit *Commit) ([]byte, error) {
-	buf := bytes.NewBuffer([]byte{})
+// removeSignatureHeaders returns the content of a commit without the lines of its signature headers, the other
+// bytes are kept as they are
+func removeSignatureHeaders(content []byte) []byte {
+	headerEnd := len(content)
+	if end := bytes.Index(content, []byte("\n\n")); end >= 0 {
+		headerEnd = end + 1
+	}
+
+	payload := make([]byte, 0, len(content))
+	inSignature := false
+	for _, line := range bytes.SplitAfter(content[:headerEnd], []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(line, []byte(SignatureHeader+" ")), bytes.HasPrefix(line, []byte(SignatureHeaderSHA256+" ")):
+			inSignature = true
+		case !bytes.HasPrefix(line, []byte(" ")):
+			inSignature = false
+		}
 
-	if err := commit.writeHeader(buf); err != nil {
-		return nil, err
+		if !inSignature {
+			payload = append(payload, line...)
+		}
 	}
 
-	// tree
-	if _, err := fmt.Fprintf(buf, "tree %s", commit.Tree); err != nil {
-		return nil, err
-	}
+	return append(payload, content[headerEnd:]...)
+}
 
-	// parents
-	for _, parent := range commit.Parents {
-		if _, err := fmt.Fprintf(buf, "\nparent %s", parent); err != nil {
-			return nil, err
+// decodeHeaders splits the header lines of a commit or tag into fields. Lines that start with a space continue
+// the value of the previous field.
+func decodeHeaders(headers string) ([]ExtraHeader, error) {
+	var fields []ExtraHeader
+	for _, line := range strings.Split(headers, "\n") {
+		if strings.HasPrefix(line, " ") {
+			if len(fields) == 0 {
+				return nil, errors.New("continuation line without header")
+			}
+			fields[len(fields)-1].Value += "\n" + line[1:]
+			continue
 		}
-	}
 
-	// author
-	if _, err := fmt.Fprintf(buf, "\nauthor "); err != nil {
-		return nil, err
+		space := strings.IndexByte(line, ' ')
+		if space <= 0 {
+			return nil, fmt.Errorf("malformed header line '%s'", line)
+		}
+		fields = append(fields, ExtraHeader{Key: line[:space], Value: line[space+1:]})
 	}
 
-	if err := commit.Author.Encode(buf); err != nil {
-		return nil, err
-	}
+	return fields, nil
+}
 
-	// committer
-	if _, err := fmt.Fprintf(buf, "\ncommitter "); err != nil {
-		return nil, err
-	}
+// encodeHeader writes the field, line breaks in the value are written as continuation lines
+func encodeHeader(writer io.Writer, key, value string) error {
+	_, err := fmt.Fprintf(writer, "\n%s %s", key, strings.ReplaceAll(value, "\n", "\n "))
+	return err
+}
+
+// EncodeCommit returns the commit in the on disk representation including the object header
+func EncodeCommit(commit *Commit) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
 
-	if err := commit.Commiter.Encode(buf); err != nil {
+	if err := commit.writeHeader(buf); err != nil {
 		return nil, err
 	}
 
-	// message
-	if _, err := fmt.Fprintf(buf, "\n\n%v", commit.Message); err != nil {
+	if err := commit.writeContent(buf); err != nil {
 		return nil, err
 	}
 
@@ -214,11 +302,11 @@ func (c *Commit) SetOID(oid string) {
 	c.oid = oid
 }
 
-func (c *Commit) Size() uint32 {
+func (c *Commit) Size() uint64 {
 	return c.size
 }
 
-func (c *Commit) SetSize(size uint32) {
+func (c *Commit) SetSize(size uint64) {
 	c.size = size
 }
 
@@ -272,7 +360,22 @@ func (c *Commit) writeContent(buf io.Writer) error {
 		return err
 	}
 
+	// extra headers like encoding, mergetag or gpgsig
+	for _, header := range c.ExtraHeaders {
+		if err := encodeHeader(buf, header.Key, header.Value); err != nil {
+			return err
+		}
+	}
+
 	// message
+	switch {
+	case c.Message == "" && c.ending == endOfData:
+		return nil
+	case c.Message == "" && c.ending == lineBreak:
+		_, err := fmt.Fprint(buf, "\n")
+		return err
+	}
+
 	if _, err := fmt.Fprintf(buf, "\n\n%v", c.Message); err != nil {
 		return err
 	}
@@ -284,18 +387,24 @@ type CommitBuilder struct {
 	tree           string
 	authorName     string
 	authorEmail    string
+	authorDate     time.Time
 	committerName  string
 	committerEmail string
+	committerDate  time.Time
 	parentOids     []string
+	extraHeaders   []ExtraHeader
 	message        string
 	config         config.Config
 	hook           func(*Commit)
+	signer         func(payload []byte) (string, error)
+	format         util.ObjectFormat
 }
 
 func NewCommitBuilder(treeOid string) *CommitBuilder {
 	return &CommitBuilder{
 		tree:   treeOid,
 		config: &config.NilConfig{},
+		format: util.DefaultObjectFormat,
 	}
 }
 
@@ -318,6 +427,24 @@ func (cb *CommitBuilder) WithCommitter(commiterName, commiterEmail string) *Comm
 	return cb
 }
 
+// WithAuthorDate sets the time of the author signature, the time of the build is used otherwise
+func (cb *CommitBuilder) WithAuthorDate(date time.Time) *CommitBuilder {
+	cb.authorDate = date
+	return cb
+}
+
+// WithCommitterDate sets the time of the committer signature, the time of the build is used otherwise
+func (cb *CommitBuilder) WithCommitterDate(date time.Time) *CommitBuilder {
+	cb.committerDate = date
+	return cb
+}
+
+// WithExtraHeader adds a header that is written after the committer
+func (cb *CommitBuilder) WithExtraHeader(key, value string) *CommitBuilder {
+	cb.extraHeaders = append(cb.extraHeaders, ExtraHeader{Key: key, Value: value})
+	return cb
+}
+
 func (cb *CommitBuilder) WithMessage(message string) *CommitBuilder {
 	cb.message = message
 	return cb
@@ -328,10 +455,22 @@ func (cb *CommitBuilder) WithConfig(cfg config.Config) *CommitBuilder {
 	return cb
 }
 
+func (cb *CommitBuilder) WithObjectFormat(format util.ObjectFormat) *CommitBuilder {
+	cb.format = format
+	return cb
+}
+
 func (cb *CommitBuilder) WithHook(hook func(*Commit)) {
 	cb.hook = hook
 }
 
+// WithSigner signs the commit, the signer returns the armored signature of the commit content which is
+// stored in the gpgsig header
+func (cb *CommitBuilder) WithSigner(signer func(payload []byte) (string, error)) *CommitBuilder {
+	cb.signer = signer
+	return cb
+}
+
 func (cb *CommitBuilder) Build() (*Commit, error) {
 	var err error
 
@@ -355,12 +494,22 @@ func (cb *CommitBuilder) Build() (*Commit, error) {
 		}
 	}
 
+	now := time.Now()
+	authorDate, committerDate := cb.authorDate, cb.committerDate
+	if authorDate.IsZero() {
+		authorDate = now
+	}
+	if committerDate.IsZero() {
+		committerDate = now
+	}
+
 	c := Commit{
-		Tree:     cb.tree,
-		Parents:  cb.parentOids,
-		Author:   &Signature{Name: cb.authorName, Email: cb.authorEmail, TimeStamp: time.Now()},
-		Commiter: &Signature{Name: cb.committerName, Email: cb.committerEmail, TimeStamp: time.Now()},
-		Message:  cb.message,
+		Tree:         cb.tree,
+		Parents:      cb.parentOids,
+		Author:       &Signature{Name: cb.authorName, Email: cb.authorEmail, TimeStamp: authorDate},
+		Commiter:     &Signature{Name: cb.committerName, Email: cb.committerEmail, TimeStamp: committerDate},
+		ExtraHeaders: cb.extraHeaders,
+		Message:      cb.message,
 	}
 
 	if cb.hook != nil {
@@ -372,14 +521,35 @@ func (cb *CommitBuilder) Build() (*Commit, error) {
 		return nil, err
 	}
 
-	c.size = uint32(len(content.Bytes()))
+	if cb.signer != nil {
+		signature, err := cb.signer(content.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		key := SignatureHeader
+		if cb.format == util.SHA256 {
+			key = SignatureHeaderSHA256
+		}
+
+		c.ExtraHeaders = append(append([]ExtraHeader{}, c.ExtraHeaders...),
+			ExtraHeader{Key: key, Value: strings.TrimSuffix(signature, "\n")})
+
+		// the signed payload is not reused, the signer may keep it
+		content = bytes.Buffer{}
+		if err := c.writeContent(&content); err != nil {
+			return nil, err
+		}
+	}
+
+	c.size = uint64(len(content.Bytes()))
 
 	var header bytes.Buffer
 	if err := c.writeHeader(&header); err != nil {
 		return nil, err
 	}
 
-	c.oid = hasher.Hash(header.Bytes(), content.Bytes())
+	c.oid = cb.format.Hash(header.Bytes(), content.Bytes())
 	return &c, nil
 }
 
@@ -387,40 +557,124 @@ type Signature struct {
 	Name      string
 	Email     string
 	TimeStamp time.Time
+	// raw is the signature as it was decoded, it is encoded unchanged as long as the fields above are not
+	// modified so that unusual spacing or a -0000 offset survive a round trip
+	raw string
 }
 
+// DecodeSignature parses a signature of the form "name <email> timestamp +hhmm". The name and the email may
+// contain spaces, the time stamp keeps the offset of the signature.
 func DecodeSignature(data []byte) (*Signature, error) {
-	splits := bytes.Split(data, []byte(" "))
-	if len(splits) < 2 {
+	line := strings.TrimSuffix(string(data), "\n")
+	emailStart := strings.IndexByte(line, '<')
+	emailEnd := strings.LastIndexByte(line, '>')
+	if emailStart < 0 || emailEnd < emailStart {
 		return nil, errors.New("signature is corrupt")
 	}
 
-	name := splits[0]
-	mail := bytes.Trim(splits[1], "<>")
+	fields := strings.Fields(line[emailEnd+1:])
+	if len(fields) != 2 {
+		return nil, errors.New("signature is corrupt")
+	}
+
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("signature has invalid time stamp '%s'", fields[0])
+	}
 
-	n, err := strconv.ParseInt(string(splits[2]), 10, 64)
+	offset, err := parseTimezoneOffset(fields[1])
 	if err != nil {
 		return nil, err
 	}
-	timestamp := time.Unix(n, 0)
 
 	return &Signature{
-		Name:      string(name),
-		Email:     string(mail),
-		TimeStamp: timestamp,
+		Name:      strings.TrimSpace(line[:emailStart]),
+		Email:     line[emailStart+1 : emailEnd],
+		TimeStamp: time.Unix(seconds, 0).In(time.FixedZone("", offset)),
+		raw:       line,
 	}, nil
 }
 
-func (t *Signature) Encode(writer io.Writer) error {
-	if _, err := fmt.Fprintf(writer, "%s <%s>", t.Name, t.Email); err != nil {
-		return err
+// parseTimezoneOffset returns the offset in seconds east of UTC of a zone like +0200 or -0530
+func parseTimezoneOffset(zone string) (int, error) {
+	if len(zone) != 5 || (zone[0] != '+' && zone[0] != '-') {
+		return 0, fmt.Errorf("signature has invalid timezone '%s'", zone)
+	}
+
+	hours, err := strconv.Atoi(zone[1:3])
+	if err != nil {
+		return 0, fmt.Errorf("signature has invalid timezone '%s'", zone)
 	}
 
-	_, err := fmt.Fprintf(writer, " %d %s", t.TimeStamp.Unix(), t.TimeStamp.Format("+0000"))
+	minutes, err := strconv.Atoi(zone[3:5])
+	if err != nil {
+		return 0, fmt.Errorf("signature has invalid timezone '%s'", zone)
+	}
+
+	offset := hours*3600 + minutes*60
+	if zone[0] == '-' {
+		offset = -offset
+	}
+
+	return offset, nil
+}
+
+// dateLayouts are the RFC 2822 and ISO 8601 formats of dates that are accepted besides the raw format
+var dateLayouts = []string{
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006.01.02 15:04:05",
+}
+
+// ParseDate parses a date in one of the formats of GIT_AUTHOR_DATE and GIT_COMMITTER_DATE, which are the raw
+// format "[@]seconds [+hhmm]", RFC 2822 and ISO 8601. Dates without timezone are in the local timezone.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	fields := strings.Fields(strings.TrimPrefix(value, "@"))
+	if len(fields) == 1 || len(fields) == 2 {
+		if seconds, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			date := time.Unix(seconds, 0)
+			if len(fields) == 1 {
+				return date, nil
+			}
+
+			offset, err := parseTimezoneOffset(fields[1])
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+			}
+			return date.In(time.FixedZone("", offset)), nil
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date format: %s", value)
+}
+
+func (t *Signature) Encode(writer io.Writer) error {
+	_, err := io.WriteString(writer, t.String())
 	return err
 }
 
 func (s Signature) String() string {
-	return fmt.Sprintf("%s <%s> %d %s",
-		s.Name, s.Email, s.TimeStamp.Unix(), s.TimeStamp.Format("+0000")) // todo: handle timezones
+	formatted := s.format()
+	if s.raw != "" {
+		if decoded, err := DecodeSignature([]byte(s.raw)); err == nil && decoded.format() == formatted {
+			return s.raw
+		}
+	}
+
+	return formatted
+}
+
+func (s Signature) format() string {
+	return fmt.Sprintf("%s <%s> %d %s", s.Name, s.Email, s.TimeStamp.Unix(), s.TimeStamp.Format("-0700"))
 }
@@ -7,47 +7,82 @@ type CommitIterator interface {
 	Current() *Commit
 }
 
+// SimpleCommitIterator follows the first parents of a commit. Commits that are part of the commit-graph
+// are only decoded when they are accessed, so that skipped commits are never read from the store.
 type SimpleCommitIterator struct {
 	start   *Commit
 	current *Commit
+	oid     string
+	started bool
+	graph   *storage.CommitGraph
 	store   storage.ObjectStore
 }
 
 func NewCommitIterator(commit *Commit, store storage.ObjectStore) *SimpleCommitIterator {
 	return &SimpleCommitIterator{
 		start: commit,
+		graph: storage.LoadCommitGraph(store),
 		store: store,
 	}
 }
 
 func (ci *SimpleCommitIterator) MoveNext() bool {
-	if ci.current == nil {
+	if !ci.started {
+		ci.started = true
 		ci.current = ci.start
+		ci.oid = ci.start.OID()
 		return true
 	}
 
-	if len(ci.current.Parents) == 0 {
-		return false
-	}
-
-	data, err := ci.store.Get(ci.current.Parents[0])
-	if err != nil {
+	parent, ok := ci.firstParent()
+	if !ok {
 		return false
 	}
 
-	commit, err := DecodeCommit(ci.current.Parents[0], data)
-	if err != nil {
-		return false
+	ci.oid = parent
+	ci.current = nil
+	if ci.graph.Contains(parent) {
+		return true
 	}
 
-	ci.current = commit
-	return true
+	return ci.Current() != nil
 }
 
 func (ci *SimpleCommitIterator) Current() *Commit {
+	if ci.current == nil && ci.oid != "" {
+		ci.current, _ = loadCommit(ci.store, ci.oid)
+	}
+
 	return ci.current
 }
 
+func (ci *SimpleCommitIterator) firstParent() (string, bool) {
+	if ci.current == nil {
+		if entry, ok := ci.graph.Lookup(ci.oid); ok {
+			if len(entry.Parents) == 0 {
+				return "", false
+			}
+			return entry.Parents[0], true
+		}
+	}
+
+	current := ci.Current()
+	if current == nil || len(current.Parents) == 0 {
+		return "", false
+	}
+
+	return current.Parents[0], true
+}
+
+func loadCommit(store storage.ObjectStore, oid string) (*Commit, error) {
+	data, err := store.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeCommit(oid, data)
+}
+
 type SkipCommitIterator struct {
 	current uint64
 	skips   uint64
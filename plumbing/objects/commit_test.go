@@ -1,8 +1,11 @@
 package objects
 
 import (
+	"bytes"
+	"fmt"
 	hasher "github.com/furisto/gog/util"
 	"github.com/magiconair/properties/assert"
+	"strings"
 	"testing"
 )
 
@@ -23,3 +26,267 @@ func TestCommitBuilderWithExplicitValues(t *testing.T) {
 	assert.Equal(t, commit.Commiter.Email, "committer@test.com")
 	assert.Equal(t, commit.Message, "Test message")
 }
+
+const extraHeadersCommitPath = "./testdata/decode_commit_extra_headers"
+
+func TestDecodeCommitWithExtraHeadersRoundTrips(t *testing.T) {
+	data, err := hasher.DecompressFile(extraHeadersCommitPath)
+	if err != nil {
+		t.Fatalf("could not read golden file at %s", extraHeadersCommitPath)
+	}
+
+	commit, err := DecodeCommit("b9fde4f297b16092035311e6cab782068c43d5a8", data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	assert.Equal(t, len(commit.Parents), 2)
+	assert.Equal(t, commit.Commiter.Name, "furisto")
+	assert.Equal(t, commit.Message, "Merge tag v1\n\nwith a body\n")
+	assert.Equal(t, len(commit.ExtraHeaders), 3)
+
+	encoding, _ := commit.ExtraHeader("encoding")
+	assert.Equal(t, encoding, "ISO-8859-1")
+
+	mergetag, _ := commit.ExtraHeader("mergetag")
+	assert.Equal(t, mergetag, "object 9d5c4f0e1a2b3c4d5e6f708192a3b4c5d6e7f809\ntype commit\ntag v1\n"+
+		"tagger furisto <furisto@test.com> 1611606380 +0000\n\nrelease")
+
+	signature, _ := commit.ExtraHeader("gpgsig")
+	assert.Equal(t, signature, "-----BEGIN PGP SIGNATURE-----\n\niQEzBAABCAAdFiEEaBcDeFgHiJkLmNoPqRsTuVwXyZ0=\n"+
+		"=abcd\n-----END PGP SIGNATURE-----")
+
+	encoded, err := EncodeCommit(commit)
+	if err != nil {
+		t.Fatalf("could not encode commit: %v", err)
+	}
+
+	assert.Equal(t, string(encoded), string(data))
+	assert.Equal(t, hasher.Hash(encoded), commit.OID())
+}
+
+func TestCommitBuilderWithExtraHeader(t *testing.T) {
+	commit, err := NewCommitBuilder(hasher.Hash()).
+		WithExtraHeader("gpgsig", "line one\nline two").
+		WithMessage("signed").
+		Build()
+	if err != nil {
+		t.Fatalf("encountered error while building commit: %v", err)
+	}
+
+	data, err := EncodeCommit(commit)
+	if err != nil {
+		t.Fatalf("could not encode commit: %v", err)
+	}
+
+	decoded, err := DecodeCommit(commit.OID(), data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	assert.Equal(t, decoded.ExtraHeaders, []ExtraHeader{{Key: "gpgsig", Value: "line one\nline two"}})
+	assert.Equal(t, hasher.Hash(data), commit.OID())
+}
+
+func TestCommitBuilderWithSigner(t *testing.T) {
+	var signed []byte
+	commit, err := NewCommitBuilder(hasher.Hash()).
+		WithExtraHeader("encoding", "UTF-8").
+		WithMessage("signed").
+		WithSigner(func(payload []byte) (string, error) {
+			signed = payload
+			return "-----BEGIN SSH SIGNATURE-----\nc2lnbmF0dXJl\n-----END SSH SIGNATURE-----\n", nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("encountered error while building commit: %v", err)
+	}
+
+	data, err := EncodeCommit(commit)
+	if err != nil {
+		t.Fatalf("could not encode commit: %v", err)
+	}
+	assert.Equal(t, hasher.Hash(data), commit.OID())
+
+	decoded, err := DecodeCommit(commit.OID(), data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	assert.Equal(t, decoded.ExtraHeaders[1], ExtraHeader{
+		Key:   SignatureHeader,
+		Value: "-----BEGIN SSH SIGNATURE-----\nc2lnbmF0dXJl\n-----END SSH SIGNATURE-----",
+	})
+
+	signature, payload, err := decoded.SignedPayload()
+	if err != nil {
+		t.Fatalf("could not get signed payload: %v", err)
+	}
+	assert.Equal(t, signature, decoded.ExtraHeaders[1].Value)
+	assert.Equal(t, string(payload), string(signed))
+	assert.Equal(t, strings.Contains(string(payload), "encoding UTF-8\n\nsigned"), true)
+}
+
+func TestSignedPayloadOfUnsignedCommit(t *testing.T) {
+	commit, err := NewCommitBuilder(hasher.Hash()).WithMessage("unsigned").Build()
+	if err != nil {
+		t.Fatalf("encountered error while building commit: %v", err)
+	}
+
+	signature, payload, err := commit.SignedPayload()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, signature, "")
+	assert.Equal(t, len(payload), 0)
+}
+
+func TestSignedPayloadKeepsOriginalData(t *testing.T) {
+	unsigned := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author  Odd  Spacing <o@x> 1611606380 -0000\n" +
+		"committer furisto <furisto@test.com>  1611606380 +0000\n" +
+		"encoding UTF-8\n\n" +
+		"signed\n"
+	signature := "-----BEGIN SSH SIGNATURE-----\nU1NIU0lH\n-----END SSH SIGNATURE-----"
+	content := strings.Replace(unsigned, "encoding", "gpgsig "+strings.ReplaceAll(signature, "\n", "\n ")+"\nencoding", 1)
+	data := []byte(fmt.Sprintf("commit %d\x00%s", len(content), content))
+
+	commit, err := DecodeCommit(hasher.Hash(data), data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	encoded, err := EncodeCommit(commit)
+	if err != nil {
+		t.Fatalf("could not encode commit: %v", err)
+	}
+	assert.Equal(t, string(encoded), string(data))
+
+	sig, payload, err := commit.SignedPayload()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, sig, signature)
+	assert.Equal(t, string(payload), unsigned)
+
+	commit.Message = "tampered\n"
+	_, payload, _ = commit.SignedPayload()
+	assert.Equal(t, strings.HasSuffix(string(payload), "tampered\n"), true)
+}
+
+func TestDecodeCommitWithoutMessage(t *testing.T) {
+	headers := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+		"author furisto <furisto@test.com> 1611606380 +0000\n" +
+		"committer furisto <furisto@test.com> 1611606380 +0000"
+
+	for _, content := range []string{headers, headers + "\n", headers + "\n\n"} {
+		data := []byte(fmt.Sprintf("commit %d\x00%s", len(content), content))
+		commit, err := DecodeCommit(hasher.Hash(data), data)
+		if err != nil {
+			t.Errorf("could not decode commit %q: %v", content, err)
+			continue
+		}
+
+		assert.Equal(t, commit.Commiter.Name, "furisto")
+		assert.Equal(t, commit.Message, "")
+
+		encoded, err := EncodeCommit(commit)
+		if err != nil {
+			t.Errorf("could not encode commit %q: %v", content, err)
+			continue
+		}
+		assert.Equal(t, string(encoded), string(data))
+	}
+}
+
+func TestFormatCommitShowsAllHeaders(t *testing.T) {
+	data, err := hasher.DecompressFile(extraHeadersCommitPath)
+	if err != nil {
+		t.Fatalf("could not read golden file at %s", extraHeadersCommitPath)
+	}
+
+	commit, err := DecodeCommit("b9fde4f297b16092035311e6cab782068c43d5a8", data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	formatted, err := FormatObject(commit)
+	if err != nil {
+		t.Fatalf("could not format commit: %v", err)
+	}
+
+	assert.Equal(t, formatted, string(data[bytes.IndexByte(data, 0)+1:]))
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	tests := []struct {
+		line   string
+		name   string
+		email  string
+		offset int
+	}{
+		{line: "Jane Q Public <j@x> 1567343397 +0200", name: "Jane Q Public", email: "j@x", offset: 2 * 3600},
+		{line: "furisto <furisto@test.com> 1611606380 +0000", name: "furisto", email: "furisto@test.com"},
+		{line: "A <b c@d> 1611606380 -0530", name: "A", email: "b c@d", offset: -(5*3600 + 30*60)},
+		{line: "Odd  Spacing  <o@x>  1611606380  -0000", name: "Odd  Spacing", email: "o@x"},
+	}
+
+	for _, test := range tests {
+		signature, err := DecodeSignature([]byte(test.line + "\n"))
+		if err != nil {
+			t.Errorf("could not decode signature %s: %v", test.line, err)
+			continue
+		}
+
+		_, offset := signature.TimeStamp.Zone()
+		assert.Equal(t, signature.Name, test.name)
+		assert.Equal(t, signature.Email, test.email)
+		assert.Equal(t, offset, test.offset)
+		assert.Equal(t, signature.String(), test.line)
+	}
+
+	modified, _ := DecodeSignature([]byte("Odd  Spacing  <o@x>  1611606380  -0000"))
+	modified.Name = "Even Spacing"
+	assert.Equal(t, modified.String(), "Even Spacing <o@x> 1611606380 +0000")
+
+	for _, line := range []string{"no email 1611606380 +0000", "name <mail> notanumber +0000", "name <mail> 1611606380 0200"} {
+		if _, err := DecodeSignature([]byte(line)); err == nil {
+			t.Errorf("expected error for corrupt signature %s", line)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := map[string]string{
+		"1600000000 +0200":                "1600000000 +0200",
+		"@1600000000 -0530":               "1600000000 -0530",
+		"Thu, 07 Apr 2005 22:13:13 +0200": "1112904793 +0200",
+		"2005-04-07T22:13:13+02:00":       "1112904793 +0200",
+		"2005-04-07 22:13:13 +0200":       "1112904793 +0200",
+	}
+
+	for value, expected := range tests {
+		date, err := ParseDate(value)
+		if err != nil {
+			t.Fatalf("could not parse %s: %v", value, err)
+		}
+		assert.Equal(t, fmt.Sprintf("%d %s", date.Unix(), date.Format("-0700")), expected)
+	}
+
+	_, err := ParseDate("yesterday")
+	assert.Equal(t, err != nil, true)
+}
+
+func TestCommitBuilderWithDates(t *testing.T) {
+	authorDate, _ := ParseDate("1600000000 +0200")
+	committerDate, _ := ParseDate("1600000100 +0000")
+	commit, err := NewCommitBuilder(hasher.Hash()).
+		WithAuthor("author", "author@test.com").
+		WithAuthorDate(authorDate).
+		WithCommitter("committer", "committer@test.com").
+		WithCommitterDate(committerDate).
+		WithMessage("dated").
+		Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	assert.Equal(t, commit.Author.String(), "author <author@test.com> 1600000000 +0200")
+	assert.Equal(t, commit.Commiter.String(), "committer <committer@test.com> 1600000100 +0000")
+}
@@ -0,0 +1,473 @@
+package objects
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"strconv"
+)
+
+// The checks follow the rules of git fsck, problems are reported with the same message ids so that
+// they can be looked up in https://git-scm.com/docs/git-fsck#_fsck_messages
+
+type FsckSeverity int8
+
+const (
+	FsckWarning FsckSeverity = iota
+	FsckError
+)
+
+func (s FsckSeverity) String() string {
+	if s == FsckError {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// FsckProblem describes a violation of the syntax rules of an object type
+type FsckProblem struct {
+	OID      string
+	Type     string
+	Severity FsckSeverity
+	ID       string
+	Message  string
+}
+
+func (p FsckProblem) String() string {
+	if p.ID == "" {
+		return fmt.Sprintf("%s in %s %s: %s", p.Severity, p.Type, p.OID, p.Message)
+	}
+
+	return fmt.Sprintf("%s in %s %s: %s: %s", p.Severity, p.Type, p.OID, p.ID, p.Message)
+}
+
+// ObjectLink is a reference from one object to another, Type is the type the target is expected to have
+type ObjectLink struct {
+	OID  string
+	Type string
+}
+
+// valid tree entry modes, 100664 was written by early versions of git and is still accepted
+var validTreeModes = map[uint32]bool{
+	0o100644: true,
+	0o100755: true,
+	0o100664: true,
+	0o120000: true,
+	0o040000: true,
+	0o160000: true,
+}
+
+// CheckObject validates an object given in its loose representation, i.e. [type] [size]\x00[content].
+// It returns the objects that are referenced by the object together with the problems that were found.
+// Referenced objects might be incomplete if the object is too broken to be parsed.
+func CheckObject(oid string, data []byte, format util.ObjectFormat) ([]ObjectLink, []FsckProblem) {
+	checker := objectChecker{oid: oid, format: format}
+
+	separator := bytes.IndexByte(data, 0)
+	if separator < 0 {
+		checker.report(FsckError, "badObjectHeader", "invalid object header")
+		return nil, checker.problems
+	}
+
+	var objectType string
+	var size uint64
+	if _, err := fmt.Sscanf(string(data[:separator]), "%s %d", &objectType, &size); err != nil {
+		checker.report(FsckError, "badObjectHeader", "invalid object header")
+		return nil, checker.problems
+	}
+
+	checker.objectType = objectType
+	content := data[separator+1:]
+	if uint64(len(content)) != size {
+		checker.report(FsckError, "badObjectSize", fmt.Sprintf("object size is %d, but header claims %d", len(content), size))
+	}
+
+	switch objectType {
+	case "blob":
+	case "tree":
+		checker.checkTree(content)
+	case "commit":
+		checker.checkCommit(content)
+	case "tag":
+		checker.checkTag(content)
+	default:
+		checker.report(FsckError, "badObjectType", fmt.Sprintf("unknown object type %s", objectType))
+	}
+
+	return checker.links, checker.problems
+}
+
+type objectChecker struct {
+	oid        string
+	objectType string
+	format     util.ObjectFormat
+	links      []ObjectLink
+	problems   []FsckProblem
+}
+
+func (oc *objectChecker) report(severity FsckSeverity, id, message string) {
+	oc.problems = append(oc.problems, FsckProblem{
+		OID:      oc.oid,
+		Type:     oc.objectType,
+		Severity: severity,
+		ID:       id,
+		Message:  message,
+	})
+}
+
+func (oc *objectChecker) checkTree(content []byte) {
+	var previous []byte
+	var previousIsDir bool
+	var badMode, zeroPadded, hasDot, hasDotdot, hasDotgit, fullPath, emptyName, nullOid bool
+	var notSorted, duplicate bool
+
+	for len(content) > 0 {
+		space := bytes.IndexByte(content, ' ')
+		if space <= 0 {
+			oc.report(FsckError, "badTree", "cannot be parsed as a tree")
+			return
+		}
+
+		modeBytes := content[:space]
+		mode, err := strconv.ParseUint(string(modeBytes), 8, 32)
+		if err != nil {
+			oc.report(FsckError, "badTree", "cannot be parsed as a tree")
+			return
+		}
+		content = content[space+1:]
+
+		nul := bytes.IndexByte(content, 0)
+		if nul < 0 || len(content) < nul+1+oc.format.Size() {
+			oc.report(FsckError, "badTree", "cannot be parsed as a tree")
+			return
+		}
+
+		name := content[:nul]
+		rawOid := content[nul+1 : nul+1+oc.format.Size()]
+		content = content[nul+1+oc.format.Size():]
+
+		if modeBytes[0] == '0' {
+			zeroPadded = true
+		}
+		if !validTreeModes[uint32(mode)] {
+			badMode = true
+		}
+
+		switch string(name) {
+		case "":
+			emptyName = true
+		case ".":
+			hasDot = true
+		case "..":
+			hasDotdot = true
+		case ".git":
+			hasDotgit = true
+		}
+		if bytes.IndexByte(name, '/') >= 0 {
+			fullPath = true
+		}
+
+		oid := hex.EncodeToString(rawOid)
+		if isNullOID(rawOid) {
+			nullOid = true
+		}
+
+		isDir := mode == 0o040000
+		if previous != nil {
+			switch cmp := compareTreeNames(previous, previousIsDir, name, isDir); {
+			case bytes.Equal(previous, name):
+				duplicate = true
+			case cmp > 0:
+				notSorted = true
+			}
+		}
+		previous, previousIsDir = name, isDir
+
+		switch mode {
+		case 0o040000:
+			oc.links = append(oc.links, ObjectLink{OID: oid, Type: "tree"})
+		case 0o160000:
+			// submodule commits live in a different repository
+		default:
+			oc.links = append(oc.links, ObjectLink{OID: oid, Type: "blob"})
+		}
+	}
+
+	if nullOid {
+		oc.report(FsckError, "nullSha1", "contains entries pointing to null sha1")
+	}
+	if fullPath {
+		oc.report(FsckWarning, "fullPathname", "contains full pathnames")
+	}
+	if emptyName {
+		oc.report(FsckWarning, "emptyName", "contains empty pathname")
+	}
+	if hasDot {
+		oc.report(FsckWarning, "hasDot", "contains '.'")
+	}
+	if hasDotdot {
+		oc.report(FsckWarning, "hasDotdot", "contains '..'")
+	}
+	if hasDotgit {
+		oc.report(FsckWarning, "hasDotgit", "contains '.git'")
+	}
+	if zeroPadded {
+		oc.report(FsckWarning, "zeroPaddedFilemode", "contains zero-padded file modes")
+	}
+	if badMode {
+		oc.report(FsckWarning, "badFilemode", "contains bad file modes")
+	}
+	if duplicate {
+		oc.report(FsckError, "duplicateEntries", "contains duplicate file entries")
+	}
+	if notSorted {
+		oc.report(FsckError, "treeNotSorted", "not properly sorted")
+	}
+}
+
+// compareTreeNames orders tree entries like git does, directories are compared as if their name
+// had a trailing slash
+func compareTreeNames(a []byte, aIsDir bool, b []byte, bIsDir bool) int {
+	if aIsDir {
+		a = append(a[:len(a):len(a)], '/')
+	}
+
+	if bIsDir {
+		b = append(b[:len(b):len(b)], '/')
+	}
+
+	return bytes.Compare(a, b)
+}
+
+func (oc *objectChecker) checkCommit(content []byte) {
+	header, ok := oc.verifyHeaders(content)
+	if !ok {
+		return
+	}
+
+	line, header := nextLine(header)
+	oid, ok := cutPrefix(line, "tree ")
+	if !ok {
+		oc.report(FsckError, "missingTree", "invalid format - expected 'tree' line")
+		return
+	}
+	if !oc.isValidOID(oid) {
+		oc.report(FsckError, "badTreeSha1", "invalid 'tree' line format - bad sha1")
+		return
+	}
+	oc.links = append(oc.links, ObjectLink{OID: string(oid), Type: "tree"})
+
+	line, header = nextLine(header)
+	for bytes.HasPrefix(line, []byte("parent ")) {
+		oid, _ := cutPrefix(line, "parent ")
+		if !oc.isValidOID(oid) {
+			oc.report(FsckError, "badParentSha1", "invalid 'parent' line format - bad sha1")
+			return
+		}
+		oc.links = append(oc.links, ObjectLink{OID: string(oid), Type: "commit"})
+		line, header = nextLine(header)
+	}
+
+	ident, ok := cutPrefix(line, "author ")
+	if !ok {
+		oc.report(FsckError, "missingAuthor", "invalid format - expected 'author' line")
+		return
+	}
+	if !oc.checkIdent(ident) {
+		return
+	}
+
+	line, _ = nextLine(header)
+	ident, ok = cutPrefix(line, "committer ")
+	if !ok {
+		oc.report(FsckError, "missingCommitter", "invalid format - expected 'committer' line")
+		return
+	}
+	oc.checkIdent(ident)
+}
+
+func (oc *objectChecker) checkTag(content []byte) {
+	header, ok := oc.verifyHeaders(content)
+	if !ok {
+		return
+	}
+
+	line, header := nextLine(header)
+	oid, ok := cutPrefix(line, "object ")
+	if !ok {
+		oc.report(FsckError, "missingObject", "invalid format - expected 'object' line")
+		return
+	}
+	if !oc.isValidOID(oid) {
+		oc.report(FsckError, "badObjectSha1", "invalid 'object' line format - bad sha1")
+		return
+	}
+
+	line, header = nextLine(header)
+	targetType, ok := cutPrefix(line, "type ")
+	if !ok {
+		oc.report(FsckError, "missingTypeEntry", "invalid format - expected 'type' line")
+		return
+	}
+
+	switch string(targetType) {
+	case "blob", "tree", "commit", "tag":
+		oc.links = append(oc.links, ObjectLink{OID: string(oid), Type: string(targetType)})
+	default:
+		oc.report(FsckError, "badType", "invalid 'type' value")
+		return
+	}
+
+	line, header = nextLine(header)
+	name, ok := cutPrefix(line, "tag ")
+	if !ok {
+		oc.report(FsckError, "missingTagEntry", "invalid format - expected 'tag' line")
+		return
+	}
+	if len(name) == 0 {
+		oc.report(FsckWarning, "badTagName", "invalid 'tag' name")
+	}
+
+	line, _ = nextLine(header)
+	ident, ok := cutPrefix(line, "tagger ")
+	if !ok {
+		// tags created by very old versions of git do not have a tagger
+		oc.report(FsckWarning, "missingTaggerEntry", "invalid format - expected 'tagger' line")
+		return
+	}
+	oc.checkIdent(ident)
+}
+
+// verifyHeaders returns the header of a commit or tag including the newline of the last header line
+func (oc *objectChecker) verifyHeaders(content []byte) ([]byte, bool) {
+	end := bytes.Index(content, []byte("\n\n"))
+	if end < 0 {
+		if len(content) == 0 || content[len(content)-1] != '\n' {
+			oc.report(FsckError, "unterminatedHeader", "unterminated header")
+			return nil, false
+		}
+		end = len(content) - 1
+	}
+
+	header := content[:end+1]
+	if bytes.IndexByte(header, 0) >= 0 {
+		oc.report(FsckError, "nulInHeader", "unterminated header: NUL at offset "+strconv.Itoa(bytes.IndexByte(header, 0)))
+		return nil, false
+	}
+
+	return header, true
+}
+
+// checkIdent validates a signature of the form [name] <[email]> [timestamp] [timezone]
+func (oc *objectChecker) checkIdent(ident []byte) bool {
+	lt := bytes.IndexByte(ident, '<')
+	if lt < 0 {
+		oc.report(FsckError, "missingEmail", "invalid author/committer line - missing email")
+		return false
+	}
+	if lt == 0 {
+		oc.report(FsckError, "missingNameBeforeEmail", "invalid author/committer line - missing space before email")
+		return false
+	}
+	if ident[lt-1] != ' ' {
+		oc.report(FsckError, "missingSpaceBeforeEmail", "invalid author/committer line - missing space before email")
+		return false
+	}
+	if bytes.IndexByte(ident[:lt], '>') >= 0 {
+		oc.report(FsckError, "badName", "invalid author/committer line - bad name")
+		return false
+	}
+
+	rest := ident[lt+1:]
+	gt := bytes.IndexByte(rest, '>')
+	if gt < 0 || bytes.IndexByte(rest[:gt], '<') >= 0 {
+		oc.report(FsckError, "badEmail", "invalid author/committer line - bad email")
+		return false
+	}
+
+	rest = rest[gt+1:]
+	if len(rest) == 0 || rest[0] != ' ' {
+		oc.report(FsckError, "missingSpaceBeforeDate", "invalid author/committer line - missing space before date")
+		return false
+	}
+	rest = rest[1:]
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 {
+		oc.report(FsckError, "badDate", "invalid author/committer line - bad date")
+		return false
+	}
+	if rest[0] == '0' && digits > 1 {
+		oc.report(FsckError, "zeroPaddedDate", "invalid author/committer line - zero-padded date")
+		return false
+	}
+	if _, err := strconv.ParseUint(string(rest[:digits]), 10, 64); err != nil {
+		oc.report(FsckError, "badDateOverflow", "invalid author/committer line - date causes integer overflow")
+		return false
+	}
+	rest = rest[digits:]
+
+	if len(rest) != 6 || rest[0] != ' ' || (rest[1] != '+' && rest[1] != '-') || !isDigits(rest[2:]) {
+		oc.report(FsckError, "badTimezone", "invalid author/committer line - bad time zone")
+		return false
+	}
+
+	return true
+}
+
+func (oc *objectChecker) isValidOID(oid []byte) bool {
+	if len(oid) != oc.format.HexSize() {
+		return false
+	}
+
+	for _, c := range oid {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isNullOID(oid []byte) bool {
+	for _, b := range oid {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigits(data []byte) bool {
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nextLine returns the first line without its newline and the remaining data
+func nextLine(data []byte) ([]byte, []byte) {
+	newline := bytes.IndexByte(data, '\n')
+	if newline < 0 {
+		return data, nil
+	}
+
+	return data[:newline], data[newline+1:]
+}
+
+func cutPrefix(data []byte, prefix string) ([]byte, bool) {
+	if !bytes.HasPrefix(data, []byte(prefix)) {
+		return nil, false
+	}
+
+	return data[len(prefix):], true
+}
@@ -0,0 +1,112 @@
+package objects
+
+import (
+	"encoding/hex"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+const fsckOID = "404ab0364d9ca3f06936d7c7c97c1d2de1e696f3"
+
+func treeEntry(mode, name string) string {
+	oid, _ := hex.DecodeString(fsckOID)
+	return mode + " " + name + "\x00" + string(oid)
+}
+
+func looseObject(objectType, content string) []byte {
+	return []byte(objectType + " " + strconv.Itoa(len(content)) + "\x00" + content)
+}
+
+func TestCheckObject(t *testing.T) {
+	ident := "furisto <furisto@test.com> 1609334881 +0100"
+	tests := []struct {
+		name     string
+		data     []byte
+		problems []string
+	}{
+		{
+			name: "valid tree",
+			data: looseObject("tree", treeEntry("100644", "a")+treeEntry("40000", "a-b")+treeEntry("40000", "a")),
+		},
+		{
+			name:     "unsorted tree",
+			data:     looseObject("tree", treeEntry("100644", "b")+treeEntry("100644", "a")),
+			problems: []string{"treeNotSorted"},
+		},
+		{
+			name:     "duplicate entries",
+			data:     looseObject("tree", treeEntry("100644", "a")+treeEntry("100644", "a")),
+			problems: []string{"duplicateEntries"},
+		},
+		{
+			name:     "bad file modes",
+			data:     looseObject("tree", treeEntry("644", "a")+treeEntry("040000", "b")),
+			problems: []string{"zeroPaddedFilemode", "badFilemode"},
+		},
+		{
+			name:     "truncated tree",
+			data:     looseObject("tree", treeEntry("100644", "a")[:10]),
+			problems: []string{"badTree"},
+		},
+		{
+			name: "valid commit",
+			data: looseObject("commit", "tree "+fsckOID+"\nparent "+fsckOID+"\nauthor "+ident+"\ncommitter "+ident+"\n\nmessage"),
+		},
+		{
+			name:     "commit without author",
+			data:     looseObject("commit", "tree "+fsckOID+"\ncommitter "+ident+"\n\nmessage"),
+			problems: []string{"missingAuthor"},
+		},
+		{
+			name:     "commit with bad timezone",
+			data:     looseObject("commit", "tree "+fsckOID+"\nauthor "+ident+"\ncommitter furisto <furisto@test.com> 1609334881 0100\n\nmessage"),
+			problems: []string{"badTimezone"},
+		},
+		{
+			name:     "commit without email",
+			data:     looseObject("commit", "tree "+fsckOID+"\nauthor furisto 1609334881 +0100\ncommitter "+ident+"\n\nmessage"),
+			problems: []string{"missingEmail"},
+		},
+		{
+			name:     "commit with unterminated header",
+			data:     looseObject("commit", "tree "+fsckOID),
+			problems: []string{"unterminatedHeader"},
+		},
+		{
+			name: "valid tag",
+			data: looseObject("tag", "object "+fsckOID+"\ntype commit\ntag v1\ntagger "+ident+"\n\nmessage\n"),
+		},
+		{
+			name:     "tag with bad type",
+			data:     looseObject("tag", "object "+fsckOID+"\ntype branch\ntag v1\ntagger "+ident+"\n\nmessage\n"),
+			problems: []string{"badType"},
+		},
+		{
+			name:     "tag with zero padded date",
+			data:     looseObject("tag", "object "+fsckOID+"\ntype commit\ntag v1\ntagger furisto <furisto@test.com> 01609334881 +0100\n\nmessage\n"),
+			problems: []string{"zeroPaddedDate"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, problems := CheckObject("", test.data, util.SHA1)
+
+			var ids []string
+			for _, problem := range problems {
+				ids = append(ids, problem.ID)
+			}
+			assert.Equal(t, test.problems, ids)
+		})
+	}
+}
+
+func TestCheckObjectReturnsLinks(t *testing.T) {
+	data := looseObject("tree", treeEntry("100644", "a")+treeEntry("40000", "b")+treeEntry("160000", "c"))
+
+	links, problems := CheckObject("", data, util.SHA1)
+	assert.Empty(t, problems)
+	assert.Equal(t, []ObjectLink{{OID: fsckOID, Type: "blob"}, {OID: fsckOID, Type: "tree"}}, links)
+}
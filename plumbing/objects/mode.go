@@ -0,0 +1,80 @@
+package objects
+
+import (
+	"os"
+)
+
+// Modes of tree entries as git stores them. They are plain numbers and not the bits of os.FileMode.
+const (
+	ModeTree       os.FileMode = 0o040000
+	ModeRegular    os.FileMode = 0o100644
+	ModeExecutable os.FileMode = 0o100755
+	ModeSymlink    os.FileMode = 0o120000
+	ModeGitlink    os.FileMode = 0o160000
+)
+
+// mask of the type bits of a mode, they are the same as the ones of S_IFMT
+const modeTypeMask os.FileMode = 0o170000
+
+// WorkingTreeModes tells which modes of the working tree can be trusted, see core.filemode and core.symlinks
+type WorkingTreeModes struct {
+	// Executable is false if the executable bit of files does not reflect the mode of the file
+	Executable bool
+	// Symlinks is false if symlinks are checked out as plain files that contain the link target
+	Symlinks bool
+}
+
+// DefaultWorkingTreeModes trusts the executable bit and symlinks like git does if nothing is configured
+var DefaultWorkingTreeModes = WorkingTreeModes{Executable: true, Symlinks: true}
+
+// ModeOf returns the mode of a file of the working tree. The previous mode of the file, or zero for new files,
+// is kept if the mode on disk cannot be trusted.
+func (wtm WorkingTreeModes) ModeOf(info os.FileInfo, previous os.FileMode) os.FileMode {
+	switch {
+	case info.IsDir():
+		return ModeTree
+	case info.Mode()&os.ModeSymlink != 0:
+		return ModeSymlink
+	case !wtm.Symlinks && previous == ModeSymlink:
+		return ModeSymlink
+	case !wtm.Executable:
+		if previous == ModeExecutable {
+			return ModeExecutable
+		}
+		return ModeRegular
+	case info.Mode()&0o100 != 0:
+		return ModeExecutable
+	default:
+		return ModeRegular
+	}
+}
+
+// CanonicalMode maps a mode to one of the modes git writes today, e.g. 100664 of early git versions to 100644.
+// Modes without type bits, like the 644 of very old trees, are files.
+func CanonicalMode(mode os.FileMode) os.FileMode {
+	switch mode & modeTypeMask {
+	case ModeSymlink:
+		return ModeSymlink
+	case ModeTree:
+		return ModeTree
+	case ModeGitlink:
+		return ModeGitlink
+	default:
+		if mode&0o100 != 0 {
+			return ModeExecutable
+		}
+		return ModeRegular
+	}
+}
+
+// ModeType returns the type of the object that an entry with the mode references
+func ModeType(mode os.FileMode) string {
+	switch CanonicalMode(mode) {
+	case ModeTree:
+		return "tree"
+	case ModeGitlink:
+		return "commit"
+	default:
+		return "blob"
+	}
+}
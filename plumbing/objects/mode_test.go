@@ -0,0 +1,142 @@
+package objects
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalMode(t *testing.T) {
+	tests := []struct {
+		mode     os.FileMode
+		expected os.FileMode
+		typ      string
+	}{
+		{0o100644, ModeRegular, "blob"},
+		{0o100664, ModeRegular, "blob"},
+		{0o100755, ModeExecutable, "blob"},
+		{0o100775, ModeExecutable, "blob"},
+		{0o644, ModeRegular, "blob"},
+		{0o755, ModeExecutable, "blob"},
+		{0o120000, ModeSymlink, "blob"},
+		{0o40000, ModeTree, "tree"},
+		{0o160000, ModeGitlink, "commit"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, CanonicalMode(test.mode), "canonical mode of %o", test.mode)
+		assert.Equal(t, test.typ, ModeType(test.mode), "type of %o", test.mode)
+	}
+}
+
+func TestLegacyModeIsRegularFile(t *testing.T) {
+	oid := NewBlob([]byte("content")).OID()
+	rawOID, _ := hex.DecodeString(oid)
+	content := append([]byte("644 file\x00"), rawOID...)
+	data := append([]byte(fmt.Sprintf("tree %d\x00", len(content))), content...)
+
+	tree, err := LoadTree(data)
+	if err != nil {
+		t.Fatalf("could not decode tree: %v", err)
+	}
+
+	entry, _ := tree.GetEntryByName("file")
+	assert.Equal(t, os.FileMode(0o644), entry.Mode, "mode is kept as it is stored")
+	assert.Equal(t, ModeRegular, CanonicalMode(entry.Mode))
+	assert.Equal(t, "blob", ModeType(entry.Mode))
+}
+
+func TestTreeFromDirectoryModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gog-modes")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]os.FileMode{"a.b": 0644, "run.sh": 0755}
+	for name, perm := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), perm); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "a"), 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "a", "file"), []byte("file"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if err := os.Symlink("a/file", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	tree, err := NewTreeFromDirectory(dir, "")
+	if err != nil {
+		t.Fatalf("could not create tree: %v", err)
+	}
+
+	var names []string
+	modes := make(map[string]os.FileMode)
+	for _, entry := range tree.Entries() {
+		names = append(names, entry.Name)
+		modes[entry.Name] = entry.Mode
+	}
+
+	// directories are sorted as if their name ended with a slash
+	assert.Equal(t, []string{"a.b", "a", "link", "run.sh"}, names)
+	assert.Equal(t, map[string]os.FileMode{"a": ModeTree, "a.b": ModeRegular, "link": ModeSymlink, "run.sh": ModeExecutable}, modes)
+
+	link, _ := tree.GetEntryByName("link")
+	assert.Equal(t, NewBlob([]byte("a/file")).OID(), link.OID, "symlinks are stored as their target")
+
+	tree, err = NewTreeFromDirectoryWithModes(dir, "", util.DefaultObjectFormat, WorkingTreeModes{Symlinks: true}, nil)
+	if err != nil {
+		t.Fatalf("could not create tree: %v", err)
+	}
+
+	script, _ := tree.GetEntryByName("run.sh")
+	assert.Equal(t, ModeRegular, script.Mode, "executable bit is ignored without core.filemode")
+
+	previous := map[string]os.FileMode{"a.b": ModeExecutable, "a/file": ModeExecutable, "run.sh": ModeRegular}
+	tree, err = NewTreeFromDirectoryWithModes(dir, "", util.DefaultObjectFormat, WorkingTreeModes{}, previous)
+	if err != nil {
+		t.Fatalf("could not create tree: %v", err)
+	}
+
+	file, _ := tree.GetEntryByName("a.b")
+	assert.Equal(t, ModeExecutable, file.Mode, "previous mode is kept without core.filemode")
+	script, _ = tree.GetEntryByName("run.sh")
+	assert.Equal(t, ModeRegular, script.Mode, "previous mode is kept without core.filemode")
+	subtree := tree.entries[1].Object.(*Tree)
+	nested, _ := subtree.GetEntryByName("file")
+	assert.Equal(t, ModeExecutable, nested.Mode, "previous modes of nested files are found by their path")
+}
+
+func TestModeOfKeepsUntrustedModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gog-modes")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(path, []byte("target"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatalf("could not stat file: %v", err)
+	}
+
+	untrusted := WorkingTreeModes{}
+	assert.Equal(t, ModeExecutable, untrusted.ModeOf(info, ModeExecutable))
+	assert.Equal(t, ModeSymlink, untrusted.ModeOf(info, ModeSymlink))
+	assert.Equal(t, ModeRegular, untrusted.ModeOf(info, 0))
+	assert.Equal(t, ModeRegular, DefaultWorkingTreeModes.ModeOf(info, ModeExecutable))
+}
@@ -1,20 +1,72 @@
 package objects
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
+	"strings"
 )
 
 const sizeStartMarker = byte(' ')
 const sizeEndMarker = byte('\x00')
 
+var (
+	// ErrObjectNotFound is returned if an object is not in the object store
+	ErrObjectNotFound = storage.ErrObjectNotFound
+	// ErrWrongType is returned if an object is not of the type that was asked for
+	ErrWrongType = errors.New("wrong object type")
+	// ErrMalformed is returned if the data of an object cannot be decoded
+	ErrMalformed = errors.New("malformed object")
+)
+
 type Object interface {
 	OID() string
-	Size() uint32
+	Size() uint64
 	Type() string
 	Save(store storage.ObjectStore) error
 }
 
+// ObjectType is the type of a git object, the values are the same as the ones used in pack files
+type ObjectType int8
+
+const (
+	InvalidObject ObjectType = iota
+	CommitObject
+	TreeObject
+	BlobObject
+	TagObject
+)
+
+var objectTypeNames = map[ObjectType]string{
+	CommitObject: "commit",
+	TreeObject:   "tree",
+	BlobObject:   "blob",
+	TagObject:    "tag",
+}
+
+// ParseObjectType returns the type with the name that git uses, e.g. commit. The name of Object.Type is
+// accepted as well.
+func ParseObjectType(name string) (ObjectType, error) {
+	for objectType, typeName := range objectTypeNames {
+		if strings.ToLower(name) == typeName {
+			return objectType, nil
+		}
+	}
+
+	return InvalidObject, fmt.Errorf("invalid object type \"%s\"", name)
+}
+
+// String returns the name that git uses for the type
+func (ot ObjectType) String() string {
+	if name, ok := objectTypeNames[ot]; ok {
+		return name
+	}
+
+	return "invalid"
+}
+
 func GetObjectType(data []byte) (string, error) {
 	if IsBlob(data) {
 		return "Blob", nil
@@ -28,3 +80,54 @@ func GetObjectType(data []byte) (string, error) {
 
 	return "", fmt.Errorf("unknown object")
 }
+
+// Decode decodes an object given in its loose representation, i.e. [type] [size]\x00[content]
+func Decode(oid string, data []byte) (Object, error) {
+	return DecodeWithFormat(oid, data, util.DefaultObjectFormat)
+}
+
+// DecodeWithFormat decodes an object whose references to other objects use ids of the given format. Errors
+// match ErrMalformed if the data cannot be decoded.
+func DecodeWithFormat(oid string, data []byte, format util.ObjectFormat) (Object, error) {
+	space := bytes.IndexByte(data, sizeStartMarker)
+	if space < 0 || bytes.IndexByte(data[:space], sizeEndMarker) >= 0 {
+		return nil, fmt.Errorf("%w: %s has no object header", ErrMalformed, oid)
+	}
+
+	objectType, err := ParseObjectType(string(data[:space]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, oid, err)
+	}
+
+	var object Object
+	switch objectType {
+	case CommitObject:
+		object, err = DecodeCommit(oid, data)
+	case TreeObject:
+		var tree *Tree
+		if tree, err = LoadTreeWithFormat(data, format); err == nil {
+			tree.SetOID(oid)
+			object = tree
+		}
+	case BlobObject:
+		var blob *Blob
+		if blob, err = LoadBlobWithFormat(data, format); err == nil {
+			blob.SetOID(oid)
+			object = blob
+		}
+	case TagObject:
+		object, err = DecodeTag(oid, bytes.NewReader(data))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformed, oid, err)
+	}
+
+	return object, nil
+}
+
+// TypeOf returns the type of the object
+func TypeOf(object Object) ObjectType {
+	objectType, _ := ParseObjectType(object.Type())
+	return objectType
+}
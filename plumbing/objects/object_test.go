@@ -0,0 +1,84 @@
+package objects
+
+import (
+	"errors"
+	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseObjectType(t *testing.T) {
+	for _, objectType := range []ObjectType{CommitObject, TreeObject, BlobObject, TagObject} {
+		parsed, err := ParseObjectType(objectType.String())
+		if assert.NoError(t, err) {
+			assert.Equal(t, objectType, parsed)
+		}
+	}
+
+	parsed, err := ParseObjectType("Commit")
+	if assert.NoError(t, err) {
+		assert.Equal(t, CommitObject, parsed, "names of Object.Type are accepted")
+	}
+
+	_, err = ParseObjectType("note")
+	assert.Error(t, err)
+	assert.Equal(t, "invalid", InvalidObject.String())
+}
+
+func TestDecode(t *testing.T) {
+	blob := NewBlob([]byte("content"))
+	tree := NewTree([]TreeEntry{{Mode: ModeRegular, Name: "file", OID: blob.OID()}})
+	commit, err := NewCommitBuilder(tree.OID()).
+		WithAuthor("furisto", "furisto@test.com").
+		WithCommitter("furisto", "furisto@test.com").
+		WithMessage("message").
+		Build()
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+	tag, err := NewTag(commit.OID(), "commit", "v1.0", &Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Unix(1611606380, 0)}, "release")
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	store := storage.NewMemoryStore()
+	for _, object := range []Object{commit, tag} {
+		if err := object.Save(store); err != nil {
+			t.Fatalf("could not save %s: %v", object.Type(), err)
+		}
+	}
+
+	commitData, _ := store.Get(commit.OID())
+	tagData, _ := store.Get(tag.OID())
+
+	tests := []struct {
+		oid  string
+		data []byte
+		typ  ObjectType
+	}{
+		{blob.OID(), blob.Bytes(), BlobObject},
+		{tree.OID(), tree.Bytes(), TreeObject},
+		{commit.OID(), commitData, CommitObject},
+		{tag.OID(), tagData, TagObject},
+	}
+
+	for _, test := range tests {
+		object, err := Decode(test.oid, test.data)
+		if assert.NoError(t, err, "decoding %s", test.typ) {
+			assert.Equal(t, test.typ, TypeOf(object))
+			assert.Equal(t, test.oid, object.OID())
+		}
+	}
+
+	decoded, _ := DecodeWithFormat(tree.OID(), tree.Bytes(), util.SHA1)
+	assert.Equal(t, []TreeEntry{{Mode: ModeRegular, Name: "file", OID: blob.OID()}}, decoded.(*Tree).Entries())
+}
+
+func TestDecodeMalformedObjects(t *testing.T) {
+	for _, data := range [][]byte{[]byte("garbage"), []byte("note 4\x00text"), []byte("commit 4\x00text")} {
+		_, err := Decode("", data)
+		assert.True(t, errors.Is(err, ErrMalformed), "%q is malformed: %v", data, err)
+	}
+}
@@ -0,0 +1,317 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/furisto/gog/storage"
+	"sort"
+)
+
+// ReachableObjects walks the object graph starting at the given tips and returns the ids of all
+// objects that can be reached, including the tips themselves. Blobs are not read from the store and
+// commits are not decoded if they are part of the commit-graph. If a pack has a reachability bitmap
+// the walk stops at commits that have a bitmap.
+func ReachableObjects(store storage.ObjectStore, tips []string) ([]string, error) {
+	walk := newObjectWalk(store, storage.LoadPackBitmap(store))
+	if err := walk.walk(tips); err != nil {
+		return nil, err
+	}
+
+	reachable := walk.outside
+	if walk.bitmap != nil {
+		walk.result.ForEach(func(pos int) {
+			reachable = append(reachable, walk.bitmap.OID(pos))
+		})
+	}
+
+	return reachable, nil
+}
+
+// objectWalk collects reachable objects, objects that are part of the bitmap pack are recorded as bits
+type objectWalk struct {
+	store   storage.ObjectStore
+	graph   *storage.CommitGraph
+	bitmap  *storage.PackBitmap
+	result  *storage.Bitmap
+	seen    map[string]bool
+	outside []string
+}
+
+func newObjectWalk(store storage.ObjectStore, bitmap *storage.PackBitmap) *objectWalk {
+	return &objectWalk{
+		store:  store,
+		graph:  storage.LoadCommitGraph(store),
+		bitmap: bitmap,
+		result: storage.NewBitmap(),
+		seen:   make(map[string]bool),
+	}
+}
+
+// mark records the object as reachable, it returns false if the object has been seen before
+func (w *objectWalk) mark(oid string) bool {
+	if w.bitmap != nil {
+		if pos, ok := w.bitmap.Position(oid); ok {
+			if w.result.Get(pos) {
+				return false
+			}
+
+			if commit, ok := w.bitmap.Commit(oid); ok {
+				// the bitmap of a commit already contains everything that can be reached from it
+				w.result.Or(commit)
+				return false
+			}
+
+			w.result.Set(pos)
+			return true
+		}
+	}
+
+	if w.seen[oid] {
+		return false
+	}
+
+	w.seen[oid] = true
+	w.outside = append(w.outside, oid)
+	return true
+}
+
+func (w *objectWalk) walk(tips []string) error {
+	pending := make([]string, 0, len(tips))
+	for _, tip := range tips {
+		if tip != "" {
+			pending = append(pending, tip)
+		}
+	}
+
+	for len(pending) > 0 {
+		oid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if !w.mark(oid) {
+			continue
+		}
+
+		if entry, ok := w.graph.Lookup(oid); ok {
+			pending = append(pending, entry.Tree)
+			pending = append(pending, entry.Parents...)
+			continue
+		}
+
+		data, err := w.store.Get(oid)
+		if err != nil {
+			return err
+		}
+
+		object, err := DecodeWithFormat(oid, data, w.store.ObjectFormat())
+		if err != nil {
+			return err
+		}
+
+		switch TypeOf(object) {
+		case CommitObject:
+			commit := object.(*Commit)
+			pending = append(pending, commit.Tree)
+			pending = append(pending, commit.Parents...)
+		case TreeObject:
+			for _, entry := range object.(*Tree).Entries() {
+				switch CanonicalMode(entry.Mode) {
+				case ModeTree:
+					pending = append(pending, entry.OID)
+				case ModeGitlink:
+					// submodule commits live in a different repository
+				default:
+					w.mark(entry.OID)
+				}
+			}
+		case TagObject:
+			pending = append(pending, object.(*Tag).TargetOID())
+		}
+	}
+
+	return nil
+}
+
+// bitmapCommitInterval controls how many commits are selected for a bitmap, every tip and every n-th commit
+// of the history get one
+const bitmapCommitInterval = 100
+
+// BuildPackBitmap computes the reachability bitmaps of the commits reachable from the tips. All objects reachable
+// from the tips have to be part of the pack.
+func BuildPackBitmap(store storage.ObjectStore, pack *storage.Packfile, tips []string) (*storage.PackBitmap, error) {
+	pb, err := storage.NewPackBitmap(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	var tipCommits []string
+	for _, tip := range tips {
+		commit, err := peelToCommit(store, tip)
+		if err != nil {
+			return nil, err
+		}
+		if commit != "" {
+			tipCommits = append(tipCommits, commit)
+		}
+	}
+
+	commits, err := sortCommitsParentsFirst(store, tipCommits)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, tip := range tipCommits {
+		selected[tip] = true
+	}
+	for i := bitmapCommitInterval - 1; i < len(commits); i += bitmapCommitInterval {
+		selected[commits[i]] = true
+	}
+
+	for _, commit := range commits {
+		if !selected[commit] {
+			continue
+		}
+
+		// bitmaps of older commits have already been added and cut the walk short
+		walk := newObjectWalk(store, pb)
+		if err := walk.walk([]string{commit}); err != nil {
+			return nil, err
+		}
+
+		if len(walk.outside) > 0 {
+			return nil, fmt.Errorf("object %s reachable from %s is not part of the pack", walk.outside[0], commit)
+		}
+
+		if err := pb.AddCommit(commit, walk.result); err != nil {
+			return nil, err
+		}
+	}
+
+	return pb, nil
+}
+
+// peelToCommit follows tags until a commit is found, an empty id is returned if the target is not a commit
+func peelToCommit(store storage.ObjectStore, oid string) (string, error) {
+	for {
+		data, err := store.Get(oid)
+		if err != nil {
+			return "", err
+		}
+
+		object, err := DecodeWithFormat(oid, data, store.ObjectFormat())
+		if err != nil {
+			return "", err
+		}
+
+		switch TypeOf(object) {
+		case CommitObject:
+			return oid, nil
+		case TagObject:
+			oid = object.(*Tag).TargetOID()
+		default:
+			return "", nil
+		}
+	}
+}
+
+// sortCommitsParentsFirst returns all commits reachable from the tips, every commit appears after its parents
+func sortCommitsParentsFirst(store storage.ObjectStore, tips []string) ([]string, error) {
+	graph := storage.LoadCommitGraph(store)
+	parents := make(map[string][]string)
+	children := make(map[string][]string)
+	pending := append([]string(nil), tips...)
+	for len(pending) > 0 {
+		oid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, ok := parents[oid]; ok {
+			continue
+		}
+
+		var commitParents []string
+		if entry, ok := graph.Lookup(oid); ok {
+			commitParents = entry.Parents
+		} else {
+			commit, err := loadCommit(store, oid)
+			if err != nil {
+				return nil, err
+			}
+			commitParents = commit.Parents
+		}
+
+		parents[oid] = commitParents
+		for _, parent := range commitParents {
+			children[parent] = append(children[parent], oid)
+			pending = append(pending, parent)
+		}
+	}
+
+	missing := make(map[string]int, len(parents))
+	var ready []string
+	for oid, commitParents := range parents {
+		missing[oid] = len(commitParents)
+		if len(commitParents) == 0 {
+			ready = append(ready, oid)
+		}
+	}
+
+	sort.Strings(ready)
+	sorted := make([]string, 0, len(parents))
+	for len(ready) > 0 {
+		oid := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		sorted = append(sorted, oid)
+
+		for _, child := range children[oid] {
+			missing[child]--
+			if missing[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+
+	return sorted, nil
+}
+
+// IsAncestor reports whether ancestor can be reached from descendant by following parents, a commit is
+// its own ancestor. Generation numbers of the commit-graph are used to stop the walk at commits that are
+// older than the ancestor.
+func IsAncestor(store storage.ObjectStore, ancestor, descendant string) (bool, error) {
+	graph := storage.LoadCommitGraph(store)
+
+	var minGeneration uint32
+	if entry, ok := graph.Lookup(ancestor); ok && entry.Generation < storage.MaxGenerationNumber {
+		minGeneration = entry.Generation
+	}
+
+	seen := map[string]bool{descendant: true}
+	pending := []string{descendant}
+	for len(pending) > 0 {
+		oid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if oid == ancestor {
+			return true, nil
+		}
+
+		var parents []string
+		if entry, ok := graph.Lookup(oid); ok {
+			// generation numbers strictly decrease along parents
+			if entry.Generation <= minGeneration {
+				continue
+			}
+			parents = entry.Parents
+		} else {
+			commit, err := loadCommit(store, oid)
+			if err != nil {
+				return false, err
+			}
+			parents = commit.Parents
+		}
+
+		for _, parent := range parents {
+			if !seen[parent] {
+				seen[parent] = true
+				pending = append(pending, parent)
+			}
+		}
+	}
+
+	return false, nil
+}
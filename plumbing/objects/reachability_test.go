@@ -0,0 +1,171 @@
+package objects
+
+import (
+	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// createHistory writes root <- second <- third and a side branch second <- side into the store
+func createHistory(t *testing.T, store storage.ObjectStore) (tree string, commits []*Commit) {
+	emptyTree := []byte("tree 0\x00")
+	tree = util.Hash(emptyTree)
+	if err := store.Put(tree, emptyTree); err != nil {
+		t.Fatalf("could not write tree: %v", err)
+	}
+
+	parent := ""
+	for _, message := range []string{"root", "second", "third", "side"} {
+		if message == "side" {
+			parent = commits[1].OID()
+		}
+
+		commit, err := NewCommitBuilder(tree).WithParent(parent).WithMessage(message).Build()
+		if err != nil {
+			t.Fatalf("could not build commit: %v", err)
+		}
+
+		if err := commit.Save(store); err != nil {
+			t.Fatalf("could not save commit: %v", err)
+		}
+
+		commits = append(commits, commit)
+		parent = commit.OID()
+	}
+
+	return tree, commits
+}
+
+func TestIsAncestor(t *testing.T) {
+	store := storage.NewMemoryStore()
+	_, commits := createHistory(t, store)
+
+	tests := []struct {
+		ancestor   *Commit
+		descendant *Commit
+		expected   bool
+	}{
+		{ancestor: commits[0], descendant: commits[2], expected: true},
+		{ancestor: commits[2], descendant: commits[2], expected: true},
+		{ancestor: commits[2], descendant: commits[0], expected: false},
+		{ancestor: commits[3], descendant: commits[2], expected: false},
+		{ancestor: commits[1], descendant: commits[3], expected: true},
+	}
+
+	for _, test := range tests {
+		isAncestor, err := IsAncestor(store, test.ancestor.OID(), test.descendant.OID())
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, isAncestor, "%s is ancestor of %s", test.ancestor.Message, test.descendant.Message)
+	}
+}
+
+func TestHistoryTraversalUsesCommitGraph(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := storage.NewFsStore(dir)
+	tree, commits := createHistory(t, store)
+
+	var entries []storage.CommitGraphEntry
+	for _, commit := range commits {
+		entries = append(entries, storage.CommitGraphEntry{OID: commit.OID(), Tree: commit.Tree, Parents: commit.Parents})
+	}
+
+	if _, err := store.WriteCommitGraph(entries, false); err != nil {
+		t.Fatalf("could not write commit-graph: %v", err)
+	}
+
+	// commits in the graph are reachable without being read from the store
+	assert.NoError(t, store.Delete(commits[1].OID()))
+
+	isAncestor, err := IsAncestor(store, commits[0].OID(), commits[2].OID())
+	assert.NoError(t, err)
+	assert.True(t, isAncestor)
+
+	isAncestor, err = IsAncestor(store, commits[3].OID(), commits[2].OID())
+	assert.NoError(t, err)
+	assert.False(t, isAncestor)
+
+	reachable, err := ReachableObjects(store, []string{commits[2].OID()})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{commits[2].OID(), commits[1].OID(), commits[0].OID(), tree}, reachable)
+
+	iterator := NewSkipCommitIterator(NewCommitIterator(commits[2], store), 2)
+	assert.True(t, iterator.MoveNext())
+	assert.Equal(t, commits[0].OID(), iterator.Current().OID())
+	assert.False(t, iterator.MoveNext())
+}
+
+func TestReachableObjectsUsesPackBitmap(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := storage.NewFsStore(dir)
+	tree, commits := createHistory(t, store)
+	tips := []string{commits[2].OID(), commits[3].OID()}
+
+	expected, err := ReachableObjects(store, tips)
+	if err != nil {
+		t.Fatalf("could not walk objects: %v", err)
+	}
+
+	pack, err := store.WritePack(expected, storage.DefaultPackOptions())
+	if err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+
+	bitmap, err := BuildPackBitmap(store, pack, tips)
+	if err != nil {
+		t.Fatalf("could not build bitmap: %v", err)
+	}
+	assert.NoError(t, store.WritePackBitmap(bitmap))
+
+	for _, oid := range expected {
+		assert.NoError(t, store.Delete(oid))
+	}
+
+	assert.Equal(t, len(tips), bitmap.Commits())
+	reachable, err := ReachableObjects(store, tips)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, expected, reachable)
+
+	reachable, err = ReachableObjects(store, []string{commits[1].OID()})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{commits[1].OID(), commits[0].OID(), tree}, reachable)
+}
+
+func TestReachableObjectsCanonicalizesModes(t *testing.T) {
+	store := storage.NewMemoryStore()
+
+	blob := NewBlob([]byte("content"))
+	if err := blob.Save(store); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	subtree := NewTree([]TreeEntry{{Mode: 0o644, Name: "file", OID: blob.OID()}})
+	if err := subtree.Save(store); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	// a tree with non-canonical mode bits and a submodule commit that is not part of the repository
+	submodule := util.Hash([]byte("submodule"))
+	tree := NewTree([]TreeEntry{
+		{Mode: 0o40755, Name: "dir", OID: subtree.OID()},
+		{Mode: 0o160644, Name: "module", OID: submodule},
+	})
+	if err := tree.Save(store); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	reachable, err := ReachableObjects(store, []string{tree.OID()})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{tree.OID(), subtree.OID(), blob.OID()}, reachable)
+}
@@ -0,0 +1,211 @@
+package objects
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/furisto/gog/storage"
+	"sort"
+	"strings"
+)
+
+// DefaultAbbrev is the length of abbreviated object ids if the repository is too small to need longer ones
+const DefaultAbbrev = 7
+
+// ObjectCandidate is one of the objects that match an ambiguous short object id
+type ObjectCandidate struct {
+	OID string
+	// Short is the shortest unique abbreviation of the id
+	Short string
+	Type  string
+	// Summary describes the object, the date and subject for commits and the name for tags
+	Summary string
+}
+
+// AmbiguousObjectError is returned if a short object id matches more than one object
+type AmbiguousObjectError struct {
+	Prefix     string
+	Candidates []ObjectCandidate
+}
+
+func (e *AmbiguousObjectError) Error() string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "short object ID %s is ambiguous\nhint: The candidates are:", e.Prefix)
+	for _, candidate := range e.Candidates {
+		fmt.Fprintf(&msg, "\nhint:   %s %s", candidate.Short, candidate.Type)
+		if candidate.Summary != "" {
+			fmt.Fprintf(&msg, " %s", candidate.Summary)
+		}
+	}
+
+	return msg.String()
+}
+
+// ResolveObjectName returns the full id of the object that the possibly abbreviated name refers to. The name
+// may end with ^{type} to only consider objects of that type, tags are peeled until an object of the type is
+// found. Abbrev returns the minimum length of the abbreviated ids that are listed if the name is ambiguous, it is
+// only called in that case.
+func ResolveObjectName(store storage.ObjectStore, name string, abbrev func() (int, error)) (string, error) {
+	prefix, wantedType := name, ""
+	if start := strings.Index(name, "^{"); start >= 0 && strings.HasSuffix(name, "}") {
+		prefix, wantedType = name[:start], name[start+2:len(name)-1]
+		switch wantedType {
+		case "commit", "tree", "blob", "tag":
+		default:
+			return "", fmt.Errorf("invalid object type %s in %s", wantedType, name)
+		}
+	}
+
+	oids, err := store.Find(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	types := make(map[string]string, len(oids))
+	for _, oid := range oids {
+		objectType, err := typeOf(store, oid)
+		if err != nil {
+			return "", err
+		}
+		types[oid] = objectType
+
+		if wantedType == "" || objectType == wantedType {
+			matches = append(matches, oid)
+			continue
+		}
+
+		if objectType == "tag" {
+			if _, err := peel(store, oid, wantedType); err == nil {
+				matches = append(matches, oid)
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("not a valid object name %s", name)
+	case 1:
+		if wantedType == "" || types[matches[0]] == wantedType {
+			return matches[0], nil
+		}
+		return peel(store, matches[0], wantedType)
+	}
+
+	length, err := abbrev()
+	if err != nil {
+		return "", err
+	}
+
+	ambiguous := &AmbiguousObjectError{Prefix: prefix}
+	for _, oid := range matches {
+		short, err := ShortOID(store, oid, length)
+		if err != nil {
+			return "", err
+		}
+
+		summary, err := objectSummary(store, oid, types[oid])
+		if err != nil {
+			return "", err
+		}
+
+		ambiguous.Candidates = append(ambiguous.Candidates, ObjectCandidate{
+			OID:     oid,
+			Short:   short,
+			Type:    types[oid],
+			Summary: summary,
+		})
+	}
+
+	// like git, tags are listed first, then commits, trees and blobs
+	rank := map[string]int{"tag": 0, "commit": 1, "tree": 2, "blob": 3}
+	sort.SliceStable(ambiguous.Candidates, func(i, j int) bool {
+		return rank[ambiguous.Candidates[i].Type] < rank[ambiguous.Candidates[j].Type]
+	})
+
+	return "", ambiguous
+}
+
+// ShortOID returns the shortest prefix of the object id with at least length characters that is unique in the store
+func ShortOID(store storage.ObjectStore, oid string, length int) (string, error) {
+	if length < storage.MinAbbrev {
+		length = storage.MinAbbrev
+	}
+
+	for n := length; n < len(oid); n++ {
+		oids, err := store.Find(oid[:n])
+		if err != nil {
+			return "", err
+		}
+
+		if len(oids) <= 1 {
+			return oid[:n], nil
+		}
+	}
+
+	return oid, nil
+}
+
+func typeOf(store storage.ObjectStore, oid string) (string, error) {
+	objectType, _, reader, err := store.Open(oid)
+	if err != nil {
+		return "", err
+	}
+	reader.Close()
+
+	return objectType, nil
+}
+
+// peel follows tags until an object of the wanted type is found
+func peel(store storage.ObjectStore, oid, wantedType string) (string, error) {
+	for {
+		data, err := store.Get(oid)
+		if err != nil {
+			return "", err
+		}
+
+		object, err := DecodeWithFormat(oid, data, store.ObjectFormat())
+		if err != nil {
+			return "", err
+		}
+
+		objectType := TypeOf(object)
+		if objectType != TagObject {
+			if objectType.String() != wantedType {
+				return "", fmt.Errorf("object %s cannot be peeled to a %s", oid, wantedType)
+			}
+			return oid, nil
+		}
+
+		if wantedType == "tag" {
+			return oid, nil
+		}
+		oid = object.(*Tag).TargetOID()
+	}
+}
+
+// objectSummary describes commits and tags in the way git lists candidates for ambiguous object ids
+func objectSummary(store storage.ObjectStore, oid, objectType string) (string, error) {
+	switch objectType {
+	case "commit":
+		commit, err := loadCommit(store, oid)
+		if err != nil {
+			return "", err
+		}
+
+		subject := strings.SplitN(strings.TrimSpace(commit.Message), "\n", 2)[0]
+		return fmt.Sprintf("%s - %s", commit.Commiter.TimeStamp.Format("2006-01-02"), subject), nil
+	case "tag":
+		data, err := store.Get(oid)
+		if err != nil {
+			return "", err
+		}
+
+		tag, err := DecodeTag(oid, bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		return tag.Name(), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,97 @@
+package objects
+
+import (
+	"fmt"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// createAmbiguousBlobs writes blobs until two of them share the first four characters of their ids
+func createAmbiguousBlobs(t *testing.T, store storage.ObjectStore) (string, string) {
+	seen := make(map[string]string)
+	for i := 0; ; i++ {
+		content := fmt.Sprintf("blob %d", i)
+		data := []byte(fmt.Sprintf("blob %d\x00%s", len(content), content))
+		oid := store.ObjectFormat().Hash(data)
+		if err := store.Put(oid, data); err != nil {
+			t.Fatalf("could not write blob: %v", err)
+		}
+
+		if other, ok := seen[oid[:4]]; ok {
+			return other, oid
+		}
+		seen[oid[:4]] = oid
+	}
+}
+
+func defaultAbbrev() (int, error) {
+	return DefaultAbbrev, nil
+}
+
+func TestResolveObjectName(t *testing.T) {
+	store := storage.NewMemoryStore()
+	first, second := createAmbiguousBlobs(t, store)
+
+	// the abbreviation length is only needed to list the candidates of ambiguous names
+	oid, err := ResolveObjectName(store, first[:10], func() (int, error) {
+		t.Error("abbreviation length is computed for a unique name")
+		return DefaultAbbrev, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, first, oid)
+
+	_, err = ResolveObjectName(store, first[:4], defaultAbbrev)
+	ambiguous, ok := err.(*AmbiguousObjectError)
+	if !ok {
+		t.Fatalf("expected ambiguity error, got %v", err)
+	}
+
+	assert.Equal(t, first[:4], ambiguous.Prefix)
+	assert.Equal(t, 2, len(ambiguous.Candidates))
+	for _, candidate := range ambiguous.Candidates {
+		assert.Equal(t, "blob", candidate.Type)
+		assert.Equal(t, candidate.OID[:DefaultAbbrev], candidate.Short)
+	}
+	assert.Contains(t, err.Error(), "hint:   "+second[:DefaultAbbrev]+" blob")
+
+	_, err = ResolveObjectName(store, first[:4]+"^{commit}", defaultAbbrev)
+	assert.Error(t, err, "no commit matches the prefix")
+}
+
+func TestResolveObjectNamePeelsTags(t *testing.T) {
+	store := storage.NewMemoryStore()
+	_, commits := createHistory(t, store)
+
+	tagger := &Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Unix(1609334881, 0)}
+	tag, err := NewTag(commits[2].OID(), "commit", "v1.0", tagger, "release")
+	if err != nil {
+		t.Fatalf("could not build tag: %v", err)
+	}
+	if err := tag.Save(store); err != nil {
+		t.Fatalf("could not save tag: %v", err)
+	}
+
+	oid, err := ResolveObjectName(store, tag.OID()[:8]+"^{commit}", defaultAbbrev)
+	assert.NoError(t, err)
+	assert.Equal(t, commits[2].OID(), oid)
+
+	oid, err = ResolveObjectName(store, tag.OID()[:8], defaultAbbrev)
+	assert.NoError(t, err)
+	assert.Equal(t, tag.OID(), oid)
+}
+
+func TestShortOID(t *testing.T) {
+	store := storage.NewMemoryStore()
+	first, second := createAmbiguousBlobs(t, store)
+
+	short, err := ShortOID(store, first, 4)
+	assert.NoError(t, err)
+	assert.NotEqual(t, second[:len(short)], short, "abbreviation is unique")
+	assert.True(t, len(short) > 4)
+
+	short, err = ShortOID(store, first, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, first[:12], short)
+}
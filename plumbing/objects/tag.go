@@ -3,11 +3,10 @@ package objects
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha1"
-	"encoding/hex"
 	"fmt"
 	"github.com/furisto/gog/plumbing/refs"
 	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
 	"io"
 	"io/ioutil"
 	"strconv"
@@ -21,6 +20,9 @@ import (
 // tagger [signature]
 //
 // [commit message]
+// [signature]
+//
+// The optional signature is appended to the message and covers everything before it.
 //
 // An example would be
 // tag
@@ -29,16 +31,20 @@ var tagType = []byte("tag")
 
 type Tag struct {
 	ref        refs.Ref
-	size       uint32
+	size       uint64
 	oid        string
 	targetOID  string
 	targetType string
 	name       string
 	tagger     *Signature
 	message    string
+	signature  string
+	// content is the tag as it was decoded or created without the object header, it is written as it is so that
+	// headers gog does not interpret are kept. The signature covers everything before it.
+	content []byte
 }
 
-func (t *Tag) Size() uint32 {
+func (t *Tag) Size() uint64 {
 	return t.size
 }
 
@@ -78,11 +84,45 @@ func (t *Tag) Message() string {
 	return t.message
 }
 
+// SignedPayload returns the signature of the tag and the data that was signed, which is the tag without the
+// signature. The signature is empty if the tag is not signed.
+func (t *Tag) SignedPayload() (string, []byte, error) {
+	if t.signature == "" {
+		return "", nil, nil
+	}
+
+	if bytes.HasSuffix(t.content, []byte(t.signature)) {
+		return t.signature, t.content[:len(t.content)-len(t.signature)], nil
+	}
+
+	unsigned := *t
+	unsigned.content = nil
+	unsigned.signature = ""
+
+	var payload bytes.Buffer
+	if err := unsigned.writeBody(&payload); err != nil {
+		return "", nil, err
+	}
+
+	return t.signature, payload.Bytes(), nil
+}
+
 func IsTag(data []byte) bool {
 	return bytes.HasPrefix(data, tagType)
 }
 
 func NewTag(targetOID, targetType, name string, tagger *Signature, message string) (*Tag, error) {
+	return NewTagWithFormat(targetOID, targetType, name, tagger, message, util.DefaultObjectFormat)
+}
+
+func NewTagWithFormat(targetOID, targetType, name string, tagger *Signature, message string, format util.ObjectFormat) (*Tag, error) {
+	return NewSignedTagWithFormat(targetOID, targetType, name, tagger, message, format, nil)
+}
+
+// NewSignedTagWithFormat creates a tag that is signed by the signer, which returns the armored signature of the
+// tag content. The tag is not signed if the signer is nil.
+func NewSignedTagWithFormat(targetOID, targetType, name string, tagger *Signature, message string,
+	format util.ObjectFormat, signer func(payload []byte) (string, error)) (*Tag, error) {
 	tag := &Tag{
 		targetOID:  targetOID,
 		targetType: targetType,
@@ -96,14 +136,28 @@ func NewTag(targetOID, targetType, name string, tagger *Signature, message strin
 		return nil, err
 	}
 
-	hasher := sha1.New()
-	_, err := hasher.Write(buf.Bytes())
-	if err != nil {
+	if signer != nil {
+		signature, err := signer(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		tag.signature = signature
+		buf = bytes.Buffer{}
+		if err := tag.writeBody(&buf); err != nil {
+			return nil, err
+		}
+	}
+
+	tag.content = buf.Bytes()
+	tag.size = uint64(buf.Len())
+
+	var header bytes.Buffer
+	if err := tag.writeHeader(&header); err != nil {
 		return nil, err
 	}
 
-	tag.oid = hex.EncodeToString(hasher.Sum(nil))
-	tag.size = uint32(buf.Len())
+	tag.oid = format.Hash(header.Bytes(), buf.Bytes())
 
 	return tag, nil
 }
@@ -126,6 +180,11 @@ func (t *Tag) writeHeader(writer io.Writer) error {
 }
 
 func (t *Tag) writeBody(writer io.Writer) error {
+	if t.content != nil {
+		_, err := writer.Write(t.content)
+		return err
+	}
+
 	if _, err := fmt.Fprintf(writer, "object %s\n", t.targetOID); err != nil {
 		return err
 	}
@@ -142,7 +201,7 @@ func (t *Tag) writeBody(writer io.Writer) error {
 		return err
 	}
 
-	if _, err := fmt.Fprintf(writer, "%s\n", t.message); err != nil {
+	if _, err := fmt.Fprintf(writer, "%s\n%s", t.message, t.signature); err != nil {
 		return err
 	}
 
@@ -166,11 +225,17 @@ func DecodeTag(oid string, reader io.Reader) (*Tag, error) {
 		return nil, err
 	}
 
-	size, err := strconv.Atoi(sizeBytes[:len(sizeBytes)-1])
+	size, err := strconv.ParseUint(sizeBytes[:len(sizeBytes)-1], 10, 64)
 	if err != nil {
 		return nil, err
 	}
 
+	content, err := ioutil.ReadAll(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = bufio.NewReader(bytes.NewReader(content))
 	targetOID, err := readEntry(buf, "object")
 	if err != nil {
 		return nil, err
@@ -186,18 +251,29 @@ func DecodeTag(oid string, reader io.Reader) (*Tag, error) {
 		return nil, err
 	}
 
-	tagger, err := readEntry(buf, "tagger")
+	taggerEntry, err := readEntry(buf, "tagger")
 	if err != nil {
 		return nil, err
 	}
 
-	signature, err := DecodeSignature([]byte(tagger))
+	tagger, err := DecodeSignature([]byte(taggerEntry))
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := buf.ReadBytes('\n'); err != nil {
-		return nil, err
+	// headers that gog does not interpret are skipped until the blank line before the message, they are kept
+	// in the content
+	for {
+		line, err := buf.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == "\n" {
+			break
+		}
 	}
 
 	messageBytes, err := ioutil.ReadAll(buf)
@@ -205,20 +281,55 @@ func DecodeTag(oid string, reader io.Reader) (*Tag, error) {
 		return nil, err
 	}
 
-	message := string(messageBytes)
+	message, signature := splitTagSignature(string(messageBytes))
 	message = strings.TrimSuffix(message, "\n")
 
 	return &Tag{
 		oid:        oid,
-		size:       uint32(size),
+		size:       size,
 		targetOID:  targetOID,
 		targetType: targetType,
 		name:       tagName,
-		tagger:     signature,
+		tagger:     tagger,
 		message:    message,
+		signature:  signature,
+		content:    content,
 	}, nil
 }
 
+// signatureStarts are the first lines of the signatures that git appends to tag messages
+var signatureStarts = []string{
+	"-----BEGIN PGP SIGNATURE-----",
+	"-----BEGIN PGP MESSAGE-----",
+	"-----BEGIN SIGNED MESSAGE-----",
+	"-----BEGIN SSH SIGNATURE-----",
+}
+
+// splitTagSignature separates the signature from the message, like git the last line that starts a signature
+// is taken as the start of the signature
+func splitTagSignature(message string) (string, string) {
+	start := -1
+	for offset := 0; offset < len(message); {
+		for _, signatureStart := range signatureStarts {
+			if strings.HasPrefix(message[offset:], signatureStart) {
+				start = offset
+			}
+		}
+
+		end := strings.IndexByte(message[offset:], '\n')
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+
+	if start < 0 {
+		return message, ""
+	}
+
+	return message[:start], message[start:]
+}
+
 func readEntry(reader *bufio.Reader, name string) (string, error) {
 	entryName, err := reader.ReadString(' ')
 	if err != nil {
@@ -2,9 +2,11 @@ package objects
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/furisto/gog/util"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 const tagFilePath = "./testdata/decode_tag"
@@ -21,13 +23,100 @@ func TestDecodeTag(t *testing.T) {
 		return
 	}
 
-	assert.Equal(t, uint32(161), tag.Size(), "tag object size")
+	assert.Equal(t, uint64(161), tag.Size(), "tag object size")
 	assert.Equal(t, "404ab0364d9ca3f06936d7c7c97c1d2de1e696f3", tag.TargetOID(), "target oid")
 	assert.Equal(t, "commit", tag.TargetType(), "target type")
 	assert.Equal(t, "annotated", tag.Name(), "name")
 	assert.Equal(t, "annotated", tag.Message(), "message")
 }
 
+func TestSignedTagRoundTrip(t *testing.T) {
+	tagger := &Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Unix(1609334881, 0).UTC()}
+	armored := "-----BEGIN SSH SIGNATURE-----\nc2lnbmF0dXJl\n-----END SSH SIGNATURE-----\n"
+
+	var signed []byte
+	tag, err := NewSignedTagWithFormat("404ab0364d9ca3f06936d7c7c97c1d2de1e696f3", "commit", "v1.0", tagger,
+		"release", util.DefaultObjectFormat, func(payload []byte) (string, error) {
+			signed = payload
+			return armored, nil
+		})
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	var encoded bytes.Buffer
+	if err := tag.EncodeTag(&encoded); err != nil {
+		t.Fatalf("could not encode tag: %v", err)
+	}
+	assert.Equal(t, util.Hash(encoded.Bytes()), tag.OID())
+	assert.True(t, bytes.HasSuffix(encoded.Bytes(), []byte("\n\nrelease\n"+armored)))
+
+	decoded, err := DecodeTag(tag.OID(), bytes.NewReader(encoded.Bytes()))
+	if err != nil {
+		t.Fatalf("could not decode tag: %v", err)
+	}
+	assert.Equal(t, "release", decoded.Message())
+
+	signature, payload, err := decoded.SignedPayload()
+	assert.NoError(t, err)
+	assert.Equal(t, armored, signature)
+	assert.Equal(t, string(signed), string(payload))
+}
+
+func TestSignedPayloadOfDecodedTagKeepsOriginalData(t *testing.T) {
+	headers := "object 404ab0364d9ca3f06936d7c7c97c1d2de1e696f3\n" +
+		"type commit\n" +
+		"tag v1.0\n" +
+		"tagger furisto <furisto@test.com> 1609334881 +0000\n"
+	armored := "-----BEGIN SSH SIGNATURE-----\nc2lnbmF0dXJl\n-----END SSH SIGNATURE-----\n"
+
+	tests := []struct {
+		payload string
+		message string
+	}{
+		// the signature directly follows the headers if the message is empty
+		{payload: headers + "\n", message: ""},
+		// headers that are not written by gog are part of the payload
+		{payload: headers + "unknown header\n\nrelease\n", message: "release"},
+	}
+
+	for _, test := range tests {
+		content := test.payload + armored
+		data := []byte(fmt.Sprintf("tag %d\x00%s", len(content), content))
+		tag, err := DecodeTag(util.Hash(data), bytes.NewReader(data))
+		if err != nil {
+			t.Errorf("could not decode tag %q: %v", content, err)
+			continue
+		}
+		assert.Equal(t, test.message, tag.Message())
+
+		signature, payload, err := tag.SignedPayload()
+		assert.NoError(t, err)
+		assert.Equal(t, armored, signature)
+		assert.Equal(t, test.payload, string(payload))
+
+		var encoded bytes.Buffer
+		if err := tag.EncodeTag(&encoded); err != nil {
+			t.Errorf("could not encode tag %q: %v", content, err)
+			continue
+		}
+		assert.Equal(t, string(data), encoded.String())
+	}
+}
+
+func TestSplitTagSignatureUsesLastSignature(t *testing.T) {
+	message := "quoting\n-----BEGIN PGP SIGNATURE-----\nin the message\n"
+	signature := "-----BEGIN SSH SIGNATURE-----\nc2lnbmF0dXJl\n-----END SSH SIGNATURE-----\n"
+
+	actualMessage, actualSignature := splitTagSignature(message + signature)
+	assert.Equal(t, message, actualMessage)
+	assert.Equal(t, signature, actualSignature)
+
+	actualMessage, actualSignature = splitTagSignature("not -----BEGIN SSH SIGNATURE----- at line start\n")
+	assert.Equal(t, "not -----BEGIN SSH SIGNATURE----- at line start\n", actualMessage)
+	assert.Equal(t, "", actualSignature)
+}
+
 // todo: needs timezone handling
 //func TestEncodeTag(t *testing.T) {
 //	tagger := Signature{
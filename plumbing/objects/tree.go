@@ -7,7 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/furisto/gog/storage"
-	hasher "github.com/furisto/gog/util"
+	"github.com/furisto/gog/util"
 	"io"
 	"io/ioutil"
 	"os"
@@ -21,25 +21,44 @@ var TreeType = []byte("tree")
 
 type Tree struct {
 	oid     string
-	size    uint32
+	size    uint64
 	Trees   []TreeEntry
 	Blobs   []TreeEntry
 	entries []TreeEntry
 }
 
 func NewTree(entries []TreeEntry) *Tree {
+	return NewTreeWithFormat(entries, util.DefaultObjectFormat)
+}
+
+func NewTreeWithFormat(entries []TreeEntry, format util.ObjectFormat) *Tree {
 	tree := &Tree{
 		entries: entries,
 	}
 
 	content := tree.getContent()
-	tree.size = uint32(len(content))
+	tree.size = uint64(len(content))
 
-	tree.oid = hasher.Hash(tree.getHeader(), content)
+	tree.oid = format.Hash(tree.getHeader(), content)
 	return tree
 }
 
 func NewTreeFromDirectory(path string, prefix string) (*Tree, error) {
+	return NewTreeFromDirectoryWithFormat(path, prefix, util.DefaultObjectFormat)
+}
+
+func NewTreeFromDirectoryWithFormat(path string, prefix string, format util.ObjectFormat) (*Tree, error) {
+	return NewTreeFromDirectoryWithModes(path, prefix, format, DefaultWorkingTreeModes, nil)
+}
+
+// NewTreeFromDirectoryWithModes creates the tree of a directory, the modes of the entries are derived from the
+// file system as far as it can be trusted. Where it can't, the previous modes are kept, they are keyed by the slash
+// separated path of the file relative to the directory.
+func NewTreeFromDirectoryWithModes(path string, prefix string, format util.ObjectFormat, modes WorkingTreeModes, previous map[string]os.FileMode) (*Tree, error) {
+	return newTreeFromDirectory(path, "", prefix, format, modes, previous)
+}
+
+func newTreeFromDirectory(path, base, prefix string, format util.ObjectFormat, modes WorkingTreeModes, previous map[string]os.FileMode) (*Tree, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, err
 	}
@@ -59,33 +78,39 @@ func NewTreeFromDirectory(path string, prefix string) (*Tree, error) {
 			if !strings.HasPrefix(f.Name(), prefix) {
 				continue
 			}
-			subTree, err := NewTreeFromDirectory(filepath.Join(path, f.Name()), "")
+			subTree, err := newTreeFromDirectory(filepath.Join(path, f.Name()), base+f.Name()+"/", "", format, modes, previous)
 			if err != nil {
 				return nil, err
 			}
 
-			tree.entries = append(tree.entries, TreeEntry{Mode: 0o40000, Name: f.Name(), OID: subTree.OID(), Object: subTree})
+			tree.entries = append(tree.entries, TreeEntry{Mode: ModeTree, Name: f.Name(), OID: subTree.OID(), Object: subTree})
 		} else {
 			if !strings.HasPrefix(f.Name(), prefix) {
 				continue
 			}
-			blob, err := NewBlobFromFile(filepath.Join(path, f.Name()))
+			blob, err := NewBlobFromWorkingTreeWithFormat(filepath.Join(path, f.Name()), format)
 			if err != nil {
 				return nil, err
 			}
 
-			tree.entries = append(tree.entries, TreeEntry{Mode: f.Mode(), Name: f.Name(), OID: blob.OID(), Object: blob})
+			mode := modes.ModeOf(f, previous[base+f.Name()])
+			tree.entries = append(tree.entries, TreeEntry{Mode: mode, Name: f.Name(), OID: blob.OID(), Object: blob})
 		}
 	}
 
 	content := tree.getContent()
-	tree.size = uint32(len(content))
-	tree.oid = hasher.Hash(tree.getHeader(), content)
+	tree.size = uint64(len(content))
+	tree.oid = format.Hash(tree.getHeader(), content)
 
 	return &tree, nil
 }
 
 func LoadTree(treeData []byte) (*Tree, error) {
+	return LoadTreeWithFormat(treeData, util.DefaultObjectFormat)
+}
+
+// LoadTreeWithFormat decodes a tree whose entries reference objects by ids of the given format
+func LoadTreeWithFormat(treeData []byte, format util.ObjectFormat) (*Tree, error) {
 	if !IsTree(treeData) {
 		return nil, errors.New("not of type tree")
 	}
@@ -100,11 +125,11 @@ func LoadTree(treeData []byte) (*Tree, error) {
 
 	tree := new(Tree)
 
-	sizeInt, err := strconv.Atoi(sizeSlice[:len(sizeSlice)-1])
+	size, err := strconv.ParseUint(sizeSlice[:len(sizeSlice)-1], 10, 64)
 	if err != nil {
 		return nil, err
 	}
-	tree.size = uint32(sizeInt)
+	tree.size = size
 
 	for {
 		modeString, err := reader.ReadString(' ')
@@ -127,7 +152,7 @@ func LoadTree(treeData []byte) (*Tree, error) {
 		}
 		name = name[:len(name)-1] // remove null byte
 
-		var oid = make([]byte, 20)
+		var oid = make([]byte, format.Size())
 		_, err = io.ReadFull(reader, oid)
 		if err != nil {
 			return nil, err
@@ -157,11 +182,11 @@ func (t *Tree) SetOID(oid string) {
 	t.oid = oid
 }
 
-func (t *Tree) Size() uint32 {
+func (t *Tree) Size() uint64 {
 	return t.size
 }
 
-func (t *Tree) SetSize(size uint32) {
+func (t *Tree) SetSize(size uint64) {
 	t.size = size
 }
 
@@ -233,6 +258,7 @@ func (t *Tree) getContent() []byte {
 }
 
 type TreeEntry struct {
+	// Mode is one of the git modes like ModeRegular and not made of os.FileMode bits
 	Mode   os.FileMode
 	Name   string
 	OID    string
@@ -266,7 +292,7 @@ func (tes treeEntrySorter) Swap(i int, j int) {
 }
 
 func (*treeEntrySorter) adaptName(entry TreeEntry) string {
-	if entry.Mode.IsDir() {
+	if CanonicalMode(entry.Mode) == ModeTree {
 		return entry.Name + "/"
 	}
 
@@ -276,15 +302,23 @@ func (*treeEntrySorter) adaptName(entry TreeEntry) string {
 type TreeBuilder struct {
 	entries      map[string]TreeEntry
 	treeBuilders map[string]*TreeBuilder
+	format       util.ObjectFormat
 }
 
 func NewTreeBuilder() *TreeBuilder {
 	return &TreeBuilder{
 		entries:      make(map[string]TreeEntry),
 		treeBuilders: make(map[string]*TreeBuilder),
+		format:       util.DefaultObjectFormat,
 	}
 }
 
+// WithObjectFormat sets the format of the object ids of the tree and all of its sub trees
+func (tb *TreeBuilder) WithObjectFormat(format util.ObjectFormat) *TreeBuilder {
+	tb.format = format
+	return tb
+}
+
 func (tb *TreeBuilder) AddBlob(oid, name string, mode os.FileMode) {
 	blobEntry, ok := tb.entries[name+".blob"]
 	if ok && blobEntry.OID == oid {
@@ -306,11 +340,11 @@ func (tb *TreeBuilder) AddSubTree(name string, treeBuilder *TreeBuilder) {
 
 func (tb *TreeBuilder) Build() *Tree {
 	for name, treeBuilder := range tb.treeBuilders {
-		tree := treeBuilder.Build()
+		tree := treeBuilder.WithObjectFormat(tb.format).Build()
 		tb.entries[name+".dir"] = TreeEntry{
 			OID:    tree.oid,
 			Name:   name,
-			Mode:   0o040000,
+			Mode:   ModeTree,
 			Object: tree,
 		}
 	}
@@ -321,5 +355,5 @@ func (tb *TreeBuilder) Build() *Tree {
 	}
 
 	sort.Sort(treeEntrySorter(entries))
-	return NewTree(entries)
+	return NewTreeWithFormat(entries, tb.format)
 }
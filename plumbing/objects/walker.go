@@ -0,0 +1,241 @@
+package objects
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/storage"
+	"strings"
+	"sync"
+)
+
+// WalkOrder tells whether a tree is visited before or after its entries
+type WalkOrder int
+
+const (
+	PreOrder WalkOrder = iota
+	PostOrder
+)
+
+// SkipTree can be returned by a WalkFunc for a tree to not descend into it. It is only honored in pre-order, in
+// post-order the entries of the tree have already been visited.
+var SkipTree = errors.New("skip this tree")
+
+// WalkEntry is an entry of a tree together with its path relative to the root of the walk
+type WalkEntry struct {
+	TreeEntry
+	Path string
+	// Leading is true for trees that are only visited because one of the paths of the walk is inside of them
+	Leading bool
+}
+
+// IsTree tells whether the entry references a tree that the walk descends into. Submodule commits are not
+// descended into, their trees live in another repository.
+func (we WalkEntry) IsTree() bool {
+	return CanonicalMode(we.Mode) == ModeTree
+}
+
+// WalkFunc is called for every entry of the walk. Returning SkipTree skips the content of a tree, any other
+// error stops the walk and is returned by it.
+type WalkFunc func(entry WalkEntry) error
+
+// TreeWalker visits the entries of a tree and its subtrees in the order they are stored. Subtrees are only
+// read from the store once the walk descends into them.
+type TreeWalker struct {
+	store storage.ObjectStore
+	order WalkOrder
+	paths []string
+}
+
+func NewTreeWalker(store storage.ObjectStore) *TreeWalker {
+	return &TreeWalker{
+		store: store,
+		order: PreOrder,
+	}
+}
+
+func (w *TreeWalker) WithOrder(order WalkOrder) *TreeWalker {
+	w.order = order
+	return w
+}
+
+// WithPaths restricts the walk to the entries at or below the paths. A path with a trailing slash matches the
+// content of a tree but not the tree itself. Trees that contain one of the paths are visited as leading entries.
+func (w *TreeWalker) WithPaths(paths ...string) *TreeWalker {
+	w.paths = paths
+	return w
+}
+
+// Walk visits the entries of the tree with the object id
+func (w *TreeWalker) Walk(oid string, fn WalkFunc) error {
+	tree, err := w.loadTree(oid)
+	if err != nil {
+		return err
+	}
+
+	return w.WalkTree(tree, fn)
+}
+
+// WalkTree visits the entries of a tree that has already been read, only its subtrees are read from the store
+func (w *TreeWalker) WalkTree(tree *Tree, fn WalkFunc) error {
+	return w.walkTree(tree, "", fn)
+}
+
+func (w *TreeWalker) walkTree(tree *Tree, base string, fn WalkFunc) error {
+	for _, treeEntry := range tree.Entries() {
+		entry, ok := w.entry(treeEntry, base)
+		if !ok {
+			continue
+		}
+
+		descend := entry.IsTree()
+		if w.order == PreOrder {
+			if err := fn(entry); err == SkipTree {
+				descend = false
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if descend {
+			subtree, err := w.loadTree(entry.OID)
+			if err != nil {
+				return err
+			}
+
+			if err := w.walkTree(subtree, entry.Path+"/", fn); err != nil {
+				return err
+			}
+		}
+
+		if w.order == PostOrder {
+			if err := fn(entry); err != nil && err != SkipTree {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WalkParallel visits the entries of the tree with the object id like Walk, but subtrees are read and walked by
+// up to workers goroutines at once. The function is called concurrently and the entries of different trees are
+// visited in no particular order. A tree is always visited before its entries, the order of the walker is ignored.
+func (w *TreeWalker) WalkParallel(oid string, workers int, fn WalkFunc) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	tree, err := w.loadTree(oid)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		once      sync.Once
+		walkErr   error
+		failed    = make(chan struct{})
+		semaphore = make(chan struct{}, workers)
+	)
+
+	fail := func(err error) {
+		once.Do(func() {
+			walkErr = err
+			close(failed)
+		})
+	}
+
+	var walkTree func(tree *Tree, base string)
+	walkTree = func(tree *Tree, base string) {
+		for _, treeEntry := range tree.Entries() {
+			select {
+			case <-failed:
+				return
+			default:
+			}
+
+			entry, ok := w.entry(treeEntry, base)
+			if !ok {
+				continue
+			}
+
+			if err := fn(entry); err == SkipTree {
+				continue
+			} else if err != nil {
+				fail(err)
+				return
+			}
+
+			if !entry.IsTree() {
+				continue
+			}
+
+			wg.Add(1)
+			go func(entry WalkEntry) {
+				defer wg.Done()
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				subtree, err := w.loadTree(entry.OID)
+				if err != nil {
+					fail(err)
+					return
+				}
+				walkTree(subtree, entry.Path+"/")
+			}(entry)
+		}
+	}
+
+	walkTree(tree, "")
+	wg.Wait()
+
+	return walkErr
+}
+
+// entry returns the walk entry for the tree entry, it returns false if the entry is not part of the walk
+func (w *TreeWalker) entry(treeEntry TreeEntry, base string) (WalkEntry, bool) {
+	entry := WalkEntry{TreeEntry: treeEntry, Path: base + treeEntry.Name}
+	if len(w.paths) == 0 {
+		return entry, true
+	}
+
+	matched := false
+	for _, path := range w.paths {
+		if strings.HasPrefix(path, entry.Path+"/") {
+			entry.Leading = true
+		}
+		if path = strings.TrimSuffix(path, "/"); path == "" || strings.HasPrefix(entry.Path+"/", path+"/") {
+			matched = true
+		}
+	}
+
+	// entries that are not trees cannot contain other paths
+	if entry.Leading && !entry.IsTree() {
+		entry.Leading = false
+	}
+
+	if entry.Leading {
+		return entry, true
+	}
+
+	return entry, matched
+}
+
+func (w *TreeWalker) loadTree(oid string) (*Tree, error) {
+	data, err := w.store.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := DecodeWithFormat(oid, data, w.store.ObjectFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, ok := object.(*Tree)
+	if !ok {
+		return nil, fmt.Errorf("%w: object %s is a %s, not a tree", ErrWrongType, oid, TypeOf(object))
+	}
+
+	return tree, nil
+}
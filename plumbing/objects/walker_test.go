@@ -0,0 +1,175 @@
+package objects
+
+import (
+	"errors"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// createWalkerFixture stores a tree with the files "a/b/c", "a/d" and "e" and a submodule "m"
+func createWalkerFixture(t *testing.T) (*storage.MemoryStore, *Tree) {
+	t.Helper()
+
+	store := storage.NewMemoryStore()
+	blob := NewBlob([]byte("content"))
+	b := NewTree([]TreeEntry{{Mode: ModeRegular, Name: "c", OID: blob.OID()}})
+	a := NewTree([]TreeEntry{
+		{Mode: ModeTree, Name: "b", OID: b.OID()},
+		{Mode: ModeExecutable, Name: "d", OID: blob.OID()},
+	})
+	root := NewTree([]TreeEntry{
+		{Mode: ModeTree, Name: "a", OID: a.OID()},
+		{Mode: ModeRegular, Name: "e", OID: blob.OID()},
+		{Mode: ModeGitlink, Name: "m", OID: "0123456789012345678901234567890123456789"},
+	})
+
+	for _, object := range []Object{blob, b, a, root} {
+		if err := object.Save(store); err != nil {
+			t.Fatalf("could not save object: %v", err)
+		}
+	}
+
+	return store, root
+}
+
+func walkPaths(t *testing.T, walker *TreeWalker, oid string, fn func(entry WalkEntry) error) []string {
+	t.Helper()
+
+	var paths []string
+	err := walker.Walk(oid, func(entry WalkEntry) error {
+		paths = append(paths, entry.Path)
+		if fn != nil {
+			return fn(entry)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not walk tree: %v", err)
+	}
+
+	return paths
+}
+
+func TestTreeWalkerOrder(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	paths := walkPaths(t, NewTreeWalker(store), root.OID(), nil)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c", "a/d", "e", "m"}, paths)
+
+	paths = walkPaths(t, NewTreeWalker(store).WithOrder(PostOrder), root.OID(), nil)
+	assert.Equal(t, []string{"a/b/c", "a/b", "a/d", "a", "e", "m"}, paths)
+}
+
+func TestTreeWalkerWalksGivenTree(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	// the tree itself is not read from the store
+	entries := append([]TreeEntry(nil), root.Entries()...)
+	unsaved := NewTree(append(entries, TreeEntry{Mode: ModeRegular, Name: "z", OID: root.OID()}))
+
+	var paths []string
+	err := NewTreeWalker(store).WalkTree(unsaved, func(entry WalkEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a/b", "a/b/c", "a/d", "e", "m", "z"}, paths)
+}
+
+func TestTreeWalkerSkipTree(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	paths := walkPaths(t, NewTreeWalker(store), root.OID(), func(entry WalkEntry) error {
+		if entry.Path == "a/b" {
+			return SkipTree
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"a", "a/b", "a/d", "e", "m"}, paths)
+}
+
+func TestTreeWalkerPaths(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	tests := []struct {
+		paths    []string
+		expected []string
+		leading  []string
+	}{
+		{[]string{"a"}, []string{"a", "a/b", "a/b/c", "a/d"}, nil},
+		{[]string{"a/"}, []string{"a", "a/b", "a/b/c", "a/d"}, []string{"a"}},
+		{[]string{"a/b/c", "e"}, []string{"a", "a/b", "a/b/c", "e"}, []string{"a", "a/b"}},
+		{[]string{"e/f"}, nil, nil},
+	}
+
+	for _, test := range tests {
+		var leading []string
+		paths := walkPaths(t, NewTreeWalker(store).WithPaths(test.paths...), root.OID(), func(entry WalkEntry) error {
+			if entry.Leading {
+				leading = append(leading, entry.Path)
+			}
+			return nil
+		})
+
+		assert.Equal(t, test.expected, paths, "paths %v", test.paths)
+		assert.Equal(t, test.leading, leading, "leading trees of %v", test.paths)
+	}
+}
+
+func TestTreeWalkerErrors(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	stop := errors.New("stop")
+	err := NewTreeWalker(store).Walk(root.OID(), func(entry WalkEntry) error {
+		if entry.Path == "a/d" {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+
+	blob, _ := root.GetEntryByName("e")
+	err = NewTreeWalker(store).Walk(blob.OID, func(entry WalkEntry) error { return nil })
+	assert.True(t, errors.Is(err, ErrWrongType))
+
+	incomplete := storage.NewMemoryStore()
+	if err := root.Save(incomplete); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+	err = NewTreeWalker(incomplete).Walk(root.OID(), func(entry WalkEntry) error { return nil })
+	assert.True(t, errors.Is(err, ErrObjectNotFound), "subtrees are loaded from the store")
+}
+
+func TestTreeWalkerParallel(t *testing.T) {
+	store, root := createWalkerFixture(t)
+
+	var mutex sync.Mutex
+	var paths []string
+	err := NewTreeWalker(store).WalkParallel(root.OID(), 4, func(entry WalkEntry) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		paths = append(paths, entry.Path)
+		if entry.Path == "a/b" {
+			return SkipTree
+		}
+		return nil
+	})
+
+	if assert.NoError(t, err) {
+		sort.Strings(paths)
+		assert.Equal(t, []string{"a", "a/b", "a/d", "e", "m"}, paths)
+	}
+
+	stop := errors.New("stop")
+	err = NewTreeWalker(store).WalkParallel(root.OID(), 4, func(entry WalkEntry) error {
+		if entry.Path == "a/b/c" {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+}
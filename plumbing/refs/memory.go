@@ -0,0 +1,134 @@
+package refs
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrRefCycle = errors.New("symbolic ref points to itself")
+
+const maxSymbolicRefDepth = 5
+
+// MemoryRefManager keeps all refs in memory, it is safe for concurrent use.
+// Ref names are compared without leading slashes, so "/refs/heads/master" and "refs/heads/master"
+// denote the same ref.
+type MemoryRefManager struct {
+	refs map[string]*Ref
+	mtx  sync.RWMutex
+}
+
+func NewMemoryRefManager() *MemoryRefManager {
+	return &MemoryRefManager{
+		refs: make(map[string]*Ref),
+	}
+}
+
+func (mrm *MemoryRefManager) Set(name string, target string) (*Ref, error) {
+	ref, err := NewRef(name, target)
+	if err != nil {
+		return nil, err
+	}
+
+	mrm.mtx.Lock()
+	defer mrm.mtx.Unlock()
+
+	mrm.refs[normalizeRefName(name)] = ref
+	return copyRef(ref), nil
+}
+
+func (mrm *MemoryRefManager) Get(name string) (*Ref, error) {
+	mrm.mtx.RLock()
+	defer mrm.mtx.RUnlock()
+
+	ref, ok := mrm.refs[normalizeRefName(name)]
+	if !ok {
+		return nil, ErrRefNotExist
+	}
+
+	return copyRef(ref), nil
+}
+
+func (mrm *MemoryRefManager) List(prefix string) []*Ref {
+	mrm.mtx.RLock()
+	defer mrm.mtx.RUnlock()
+
+	prefix = normalizeRefName(prefix)
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var refs []*Ref
+	for name, ref := range mrm.refs {
+		if strings.HasPrefix(name, prefix) {
+			refs = append(refs, copyRef(ref))
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Name < refs[j].Name
+	})
+
+	return refs
+}
+
+func (mrm *MemoryRefManager) Delete(name string) error {
+	mrm.mtx.Lock()
+	defer mrm.mtx.Unlock()
+
+	name = normalizeRefName(name)
+	if _, ok := mrm.refs[name]; !ok {
+		return ErrRefNotExist
+	}
+
+	delete(mrm.refs, name)
+	return nil
+}
+
+// see https://mirrors.edge.kernel.org/pub/software/scm/git/docs/gitrevisions.html
+func (mrm *MemoryRefManager) Find(ref string) (*Ref, error) {
+	candidates := []string{
+		ref,
+		path.Join(RefPattern, ref),
+		path.Join(TagPattern, ref),
+		path.Join(BranchPattern, ref),
+		path.Join(RemotePattern, ref),
+		path.Join(RemotePattern, ref, "HEAD"),
+	}
+
+	for _, candidate := range candidates {
+		if found, err := mrm.Get(candidate); err == nil {
+			return found, nil
+		}
+	}
+
+	return nil, ErrRefNotExist
+}
+
+// follows symbolic refs until it reaches a hash ref
+func (mrm *MemoryRefManager) Resolve(ref *Ref) (*Ref, error) {
+	for depth := 0; depth <= maxSymbolicRefDepth; depth++ {
+		if ref.IsRefType(HashRef) {
+			return ref, nil
+		}
+
+		next, err := mrm.Get(ref.RefValue)
+		if err != nil {
+			return nil, err
+		}
+		ref = next
+	}
+
+	return nil, ErrRefCycle
+}
+
+func normalizeRefName(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+func copyRef(ref *Ref) *Ref {
+	c := *ref
+	return &c
+}
@@ -0,0 +1,82 @@
+package refs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+const testOID = "48743154a35f5751796d39ebceb615453abac8de"
+
+func TestMemoryRefManagerResolveSymbolicRef(t *testing.T) {
+	refMgr := NewMemoryRefManager()
+	if _, err := refMgr.Set("HEAD", "ref: refs/heads/master"); err != nil {
+		t.Fatalf("could not set HEAD: %v", err)
+	}
+
+	head, err := refMgr.Get("HEAD")
+	if err != nil {
+		t.Fatalf("could not get HEAD: %v", err)
+	}
+
+	if _, err := refMgr.Resolve(head); err != ErrRefNotExist {
+		t.Errorf("expected unborn branch to not exist, but got %v", err)
+	}
+
+	if _, err := refMgr.Set("/refs/heads/master", testOID); err != nil {
+		t.Fatalf("could not set branch: %v", err)
+	}
+
+	resolved, err := refMgr.Resolve(head)
+	if err != nil {
+		t.Fatalf("could not resolve HEAD: %v", err)
+	}
+
+	assert.Equal(t, testOID, resolved.RefValue)
+}
+
+func TestMemoryRefManagerListAndFind(t *testing.T) {
+	refMgr := NewMemoryRefManager()
+	for _, name := range []string{"refs/heads/master", "refs/heads/feature/x", "refs/tags/v1"} {
+		if _, err := refMgr.Set(name, testOID); err != nil {
+			t.Fatalf("could not set %s: %v", name, err)
+		}
+	}
+
+	var names []string
+	for _, ref := range refMgr.List("refs/heads/") {
+		names = append(names, ref.Name)
+	}
+	assert.Equal(t, []string{"refs/heads/feature/x", "refs/heads/master"}, names)
+
+	tag, err := refMgr.Find("v1")
+	if err != nil {
+		t.Fatalf("could not find tag: %v", err)
+	}
+	assert.Equal(t, "refs/tags/v1", tag.Name)
+
+	if err := refMgr.Delete("refs/tags/v1"); err != nil {
+		t.Fatalf("could not delete tag: %v", err)
+	}
+
+	if _, err := refMgr.Find("v1"); err != ErrRefNotExist {
+		t.Errorf("expected deleted tag to not exist, but got %v", err)
+	}
+}
+
+func TestMemoryRefManagerConcurrentAccess(t *testing.T) {
+	refMgr := NewMemoryRefManager()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			refMgr.Set("refs/heads/master", testOID)
+			refMgr.List("refs/heads")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, len(refMgr.List("refs/heads")))
+}
@@ -0,0 +1,128 @@
+package refs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// packedRefsFile holds refs that were packed by git pack-refs, gc or a clone. Loose refs take precedence over
+// packed refs with the same name.
+// see https://git-scm.com/docs/git-pack-refs
+const packedRefsFile = "packed-refs"
+
+// readPackedRefs returns the refs of the packed-refs file in the order of the file, a missing file has no refs.
+// The header and the peeled object ids of annotated tags are skipped
+func (grm *GitRefManager) readPackedRefs() ([]*Ref, error) {
+	lines, err := grm.readPackedRefLines()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*Ref
+	for _, line := range lines {
+		if !isPackedRefLine(line) {
+			continue
+		}
+
+		ref, err := decodePackedRef(line)
+		if err != nil {
+			return nil, err
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// findPackedRef returns the packed ref with the name
+func findPackedRef(packed []*Ref, name string) (*Ref, bool) {
+	for _, ref := range packed {
+		if ref.Name == name {
+			return ref, true
+		}
+	}
+
+	return nil, false
+}
+
+// deletePackedRef rewrites the packed-refs file without the ref and the peeled object id that follows it,
+// it reports whether the ref was packed
+func (grm *GitRefManager) deletePackedRef(name string) (bool, error) {
+	lines, err := grm.readPackedRefLines()
+	if err != nil {
+		return false, err
+	}
+
+	deleted := false
+	kept := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		if isPackedRefLine(lines[i]) {
+			if ref, err := decodePackedRef(lines[i]); err == nil && ref.Name == name {
+				deleted = true
+				for i+1 < len(lines) && strings.HasPrefix(lines[i+1], "^") {
+					i++
+				}
+				continue
+			}
+		}
+		kept = append(kept, lines[i])
+	}
+
+	if !deleted {
+		return false, nil
+	}
+
+	tempFile, err := ioutil.TempFile(grm.gitDir, "tmp_packed_refs_")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.WriteString(strings.Join(kept, "\n")); err != nil {
+		tempFile.Close()
+		return false, err
+	}
+	if err := tempFile.Close(); err != nil {
+		return false, err
+	}
+
+	return true, os.Rename(tempFile.Name(), filepath.Join(grm.gitDir, packedRefsFile))
+}
+
+// packedRefName returns the name under which the ref is stored in the packed-refs file
+func packedRefName(name string) string {
+	return strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+}
+
+// isPackedRefLine reports whether the line holds a ref instead of the header or the peeled object id of the
+// annotated tag in the line before
+func isPackedRefLine(line string) bool {
+	return line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "^")
+}
+
+// decodePackedRef decodes a line of the form '<oid> <name>'
+func decodePackedRef(line string) (*Ref, error) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return nil, fmt.Errorf("%w: unexpected line '%s'", ErrCorruptPackedRefs, line)
+	}
+
+	return NewHashRef(fields[1], fields[0]), nil
+}
+
+func (grm *GitRefManager) readPackedRefLines() ([]string, error) {
+	content, err := ioutil.ReadFile(filepath.Join(grm.gitDir, packedRefsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n"), nil
+}
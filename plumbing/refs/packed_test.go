@@ -0,0 +1,119 @@
+package refs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	packedOID = "1000000000000000000000000000000000000000"
+	peeledOID = "2000000000000000000000000000000000000000"
+)
+
+func createPackedRefManager(t *testing.T) (*GitRefManager, string) {
+	t.Helper()
+
+	gitDir, err := ioutil.TempDir("", "packed-refs")
+	if err != nil {
+		t.Fatalf("could not create git dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(gitDir) })
+
+	content := "# pack-refs with: peeled fully-peeled sorted \n" +
+		packedOID + " refs/heads/master\n" +
+		packedOID + " refs/heads/packed\n" +
+		packedOID + " refs/tags/v1\n" +
+		"^" + peeledOID + "\n"
+	if err := ioutil.WriteFile(filepath.Join(gitDir, packedRefsFile), []byte(content), 0644); err != nil {
+		t.Fatalf("could not write packed refs: %v", err)
+	}
+
+	return NewGitRefManager(gitDir), gitDir
+}
+
+func TestGitRefManagerReadsPackedRefs(t *testing.T) {
+	refMgr, _ := createPackedRefManager(t)
+	if _, err := refMgr.Set("HEAD", "ref: refs/heads/packed"); err != nil {
+		t.Fatalf("could not set HEAD: %v", err)
+	}
+
+	ref, err := refMgr.Get("refs/heads/packed")
+	if err != nil {
+		t.Fatalf("could not get packed ref: %v", err)
+	}
+	assert.Equal(t, packedOID, ref.RefValue)
+
+	tag, err := refMgr.Find("v1")
+	if err != nil {
+		t.Fatalf("could not find packed tag: %v", err)
+	}
+	assert.Equal(t, "refs/tags/v1", tag.Name)
+	assert.Equal(t, packedOID, tag.RefValue, "peeled object id is not the value of the tag")
+
+	head, err := refMgr.Get("HEAD")
+	if err != nil {
+		t.Fatalf("could not get HEAD: %v", err)
+	}
+	resolved, err := refMgr.Resolve(head)
+	if err != nil {
+		t.Fatalf("could not resolve HEAD: %v", err)
+	}
+	assert.Equal(t, packedOID, resolved.RefValue)
+}
+
+func TestGitRefManagerLooseRefsShadowPackedRefs(t *testing.T) {
+	refMgr, _ := createPackedRefManager(t)
+	if _, err := refMgr.Set("refs/heads/master", testOID); err != nil {
+		t.Fatalf("could not set branch: %v", err)
+	}
+	if _, err := refMgr.Set("refs/heads/loose", testOID); err != nil {
+		t.Fatalf("could not set branch: %v", err)
+	}
+
+	values := make(map[string]string)
+	for _, ref := range refMgr.List("refs/heads") {
+		_, listed := values[ref.Name]
+		assert.False(t, listed, "%s is listed once", ref.Name)
+		values[ref.Name] = ref.RefValue
+	}
+
+	assert.Equal(t, map[string]string{
+		"refs/heads/master": testOID,
+		"refs/heads/loose":  testOID,
+		"refs/heads/packed": packedOID,
+	}, values)
+
+	master, err := refMgr.Find("master")
+	if err != nil {
+		t.Fatalf("could not find branch: %v", err)
+	}
+	assert.Equal(t, testOID, master.RefValue)
+}
+
+func TestGitRefManagerDeletesPackedRefs(t *testing.T) {
+	refMgr, gitDir := createPackedRefManager(t)
+	if _, err := refMgr.Set("refs/tags/v1", testOID); err != nil {
+		t.Fatalf("could not set tag: %v", err)
+	}
+
+	if err := refMgr.Delete("refs/tags/v1"); err != nil {
+		t.Fatalf("could not delete tag: %v", err)
+	}
+	if err := refMgr.Delete("refs/heads/packed"); err != nil {
+		t.Fatalf("could not delete packed branch: %v", err)
+	}
+
+	if _, err := refMgr.Get("refs/tags/v1"); err != ErrRefNotExist {
+		t.Errorf("expected deleted tag to not exist, but got %v", err)
+	}
+	assert.Equal(t, ErrRefNotExist, refMgr.Delete("refs/heads/packed"))
+
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, packedRefsFile))
+	if err != nil {
+		t.Fatalf("could not read packed refs: %v", err)
+	}
+	assert.Equal(t, "# pack-refs with: peeled fully-peeled sorted \n"+packedOID+" refs/heads/master\n", string(content))
+}
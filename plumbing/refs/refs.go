@@ -18,7 +18,8 @@ const (
 )
 
 var (
-	ErrRefNotExist = errors.New("ref does not exist")
+	ErrRefNotExist       = errors.New("ref does not exist")
+	ErrCorruptPackedRefs = errors.New("packed-refs file is corrupt")
 )
 
 const refMarker = "ref: "
@@ -54,7 +55,7 @@ func DecodeRefFromFile(name, path string) (*Ref, error) {
 		return nil, err
 	}
 
-	return NewRef(name, string(content))
+	return NewRef(name, strings.TrimSpace(string(content)))
 }
 
 func NewRef(name, value string) (*Ref, error) {
@@ -120,11 +121,16 @@ func (grm *GitRefManager) Set(name, target string) (*Ref, error) {
 	}
 
 	refPath := filepath.Join(grm.gitDir, ref.Name)
-	if err := os.MkdirAll(filepath.Dir(refPath), os.ModeDir); err != nil {
+	if err := os.MkdirAll(filepath.Dir(refPath), 0777); err != nil {
 		return nil, err
 	}
 
-	err = ioutil.WriteFile(refPath, []byte(ref.RefValue), 0644)
+	content := ref.RefValue
+	if ref.IsRefType(SymbolicRef) {
+		content = refMarker + content
+	}
+
+	err = ioutil.WriteFile(refPath, []byte(content), 0644)
 	if err != nil {
 		return nil, err
 	}
@@ -132,61 +138,143 @@ func (grm *GitRefManager) Set(name, target string) (*Ref, error) {
 	return ref, nil
 }
 
+// Get returns the loose ref with the name or the packed one if there is no loose ref
 func (grm *GitRefManager) Get(name string) (*Ref, error) {
 	refPath := filepath.Join(grm.gitDir, name)
-	if _, err := os.Stat(refPath); os.IsNotExist(err) {
-		return nil, ErrRefNotExist
+	if info, err := os.Stat(refPath); err == nil && !info.IsDir() {
+		return DecodeRefFromFile(name, refPath)
 	}
 
-	return DecodeRefFromFile(name, refPath)
+	packed, err := grm.readPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	if ref, ok := findPackedRef(packed, packedRefName(name)); ok {
+		return ref, nil
+	}
+
+	return nil, ErrRefNotExist
 }
 
 func (grm *GitRefManager) List(suffix string) []*Ref {
 	var refs []*Ref
 	suffix = strings.TrimSuffix(suffix, "/")
 
+	// a corrupt packed-refs file is skipped like unreadable loose refs
+	packed, _ := grm.readPackedRefs()
+	listed := make(map[string]bool)
+
 	for _, dir := range grm.refDirs {
 		if !strings.HasSuffix(dir, suffix) {
 			continue
 		}
 
-		files, err := ioutil.ReadDir(dir)
+		loose := grm.listDir(dir, suffix, dir != grm.gitDir)
+		for _, ref := range loose {
+			listed[ref.Name] = true
+		}
+		refs = append(refs, loose...)
+
+		if dir == grm.gitDir {
+			continue
+		}
+
+		// packed refs are named like the loose refs of the directory unless a loose ref shadows them
+		prefix, err := filepath.Rel(grm.gitDir, dir)
 		if err != nil {
 			continue
 		}
+		prefix = filepath.ToSlash(prefix) + "/"
+		for _, ref := range packed {
+			if !strings.HasPrefix(ref.Name, prefix) {
+				continue
+			}
 
-		for _, f := range files {
-			ref, err := DecodeRefFromFile(path.Join(suffix, f.Name()), path.Join(dir, f.Name()))
-			if err != nil {
+			name := path.Join(suffix, strings.TrimPrefix(ref.Name, prefix))
+			if listed[name] {
 				continue
 			}
+			listed[name] = true
+			refs = append(refs, NewHashRef(name, ref.RefValue))
+		}
+	}
+
+	return refs
+}
 
-			refs = append(refs, ref)
+// listDir decodes the refs stored in the directory, refs in nested directories like refs/remotes/origin
+// are only listed if recursive is set
+func (grm *GitRefManager) listDir(dir, name string, recursive bool) []*Ref {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var refs []*Ref
+	for _, f := range files {
+		if f.IsDir() {
+			if recursive {
+				refs = append(refs, grm.listDir(path.Join(dir, f.Name()), path.Join(name, f.Name()), true)...)
+			}
+			continue
 		}
+
+		ref, err := DecodeRefFromFile(path.Join(name, f.Name()), path.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+
+		refs = append(refs, ref)
 	}
 
 	return refs
 }
 
+// Delete removes the loose ref and the packed ref with the name, so that a packed ref does not show up again
+// once its loose ref is gone
 func (grm *GitRefManager) Delete(name string) error {
 	refPath := filepath.Join(grm.gitDir, name)
+	loose := true
 	if _, err := os.Stat(refPath); os.IsNotExist(err) {
-		return ErrRefNotExist
+		loose = false
+	} else if err := os.Remove(refPath); err != nil {
+		return err
 	}
 
-	if err := os.Remove(refPath); err != nil {
+	packed, err := grm.deletePackedRef(packedRefName(name))
+	if err != nil {
 		return err
 	}
 
+	if !loose && !packed {
+		return ErrRefNotExist
+	}
+
 	return nil
 }
 
 // see https://mirrors.edge.kernel.org/pub/software/scm/git/docs/gitrevisions.html
 func (grm *GitRefManager) Find(ref string) (*Ref, error) {
-	for _, refDir := range append([]string{grm.gitDir}, grm.refDirs...) {
-		_, err := os.Stat(filepath.Join(refDir, ref))
-		if err == nil {
-			return DecodeRefFromFile(ref, filepath.Join(grm.gitDir, ref))
+	packed, err := grm.readPackedRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, refDir := range grm.refDirs {
+		refPath := filepath.Join(refDir, ref)
+		name, err := filepath.Rel(grm.gitDir, refPath)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(name)
+
+		if info, err := os.Stat(refPath); err == nil && !info.IsDir() {
+			return DecodeRefFromFile(name, refPath)
+		}
+
+		if packedRef, ok := findPackedRef(packed, name); ok {
+			return packedRef, nil
 		}
 	}
 
@@ -199,8 +287,7 @@ func (grm *GitRefManager) Resolve(ref *Ref) (*Ref, error) {
 		return ref, nil
 	}
 
-	refPath := filepath.Join(grm.gitDir, ref.RefValue)
-	newRef, err := DecodeRefFromFile(ref.RefValue, refPath)
+	newRef, err := grm.Get(ref.RefValue)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,203 @@
+package sshsig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// An allowed signers file lists the keys that are trusted to make signatures, one per line as
+// [principals] [options] [key type] [base64 encoded key] [comment]
+// Principals are comma separated patterns that may contain * and ?. The optional options are comma separated
+// too and restrict the key to namespaces or a validity interval.
+//
+// see https://man.openbsd.org/ssh-keygen#ALLOWED_SIGNERS
+
+// AllowedSigner is an entry of an allowed signers file
+type AllowedSigner struct {
+	Principals []string
+	// Namespaces in which the key may sign, all namespaces are allowed if empty
+	Namespaces []string
+	// CertAuthority marks keys that sign certificates instead of messages
+	CertAuthority bool
+	ValidAfter    time.Time
+	ValidBefore   time.Time
+	Key           *PublicKey
+}
+
+// AllowedSigners are the entries of an allowed signers file
+type AllowedSigners []AllowedSigner
+
+// ParseAllowedSigners reads an allowed signers file, empty lines and comments that start with # are skipped
+func ParseAllowedSigners(reader io.Reader) (AllowedSigners, error) {
+	var signers AllowedSigners
+	scanner := bufio.NewScanner(reader)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		signer, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("allowed signers line %d: %v", lineNumber, err)
+		}
+		signers = append(signers, *signer)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return signers, nil
+}
+
+func parseAllowedSigner(line string) (*AllowedSigner, error) {
+	principals, rest := nextField(line)
+	signer := &AllowedSigner{}
+	for _, principal := range strings.Split(strings.Trim(principals, `"`), ",") {
+		if principal != "" {
+			signer.Principals = append(signer.Principals, principal)
+		}
+	}
+
+	if len(signer.Principals) == 0 {
+		return nil, fmt.Errorf("missing principals")
+	}
+
+	// options are present if the next field is not a key type
+	if field, remainder := nextField(rest); field != "" && !isKeyType(field) {
+		if err := signer.parseOptions(field); err != nil {
+			return nil, err
+		}
+		rest = remainder
+	}
+
+	key, err := ParseAuthorizedKey(rest)
+	if err != nil {
+		return nil, err
+	}
+	signer.Key = key
+
+	return signer, nil
+}
+
+func (signer *AllowedSigner) parseOptions(options string) error {
+	for _, option := range splitOutsideQuotes(options, ',') {
+		name, value := option, ""
+		if equals := strings.IndexByte(option, '='); equals >= 0 {
+			name, value = option[:equals], strings.Trim(option[equals+1:], `"`)
+		}
+
+		var err error
+		switch strings.ToLower(name) {
+		case "cert-authority":
+			signer.CertAuthority = true
+		case "namespaces":
+			signer.Namespaces = strings.Split(value, ",")
+		case "valid-after":
+			signer.ValidAfter, err = parseValidityTime(value)
+		case "valid-before":
+			signer.ValidBefore, err = parseValidityTime(value)
+		default:
+			return fmt.Errorf("unsupported option %s", name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseValidityTime parses times of the form YYYYMMDD[HHMM[SS]], which are in the local timezone unless they
+// end with Z
+func parseValidityTime(value string) (time.Time, error) {
+	location := time.Local
+	if strings.HasSuffix(value, "Z") {
+		value, location = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid time %s", value)
+	}
+
+	return time.ParseInLocation(layout, value, location)
+}
+
+// FindPrincipals returns the principals that are allowed to sign in the namespace at the time with the key
+func (signers AllowedSigners) FindPrincipals(key *PublicKey, namespace string, at time.Time) []string {
+	var principals []string
+	for _, signer := range signers {
+		if signer.CertAuthority || !signer.Key.Equal(key) || !signer.allowsNamespace(namespace) {
+			continue
+		}
+
+		if (!signer.ValidAfter.IsZero() && at.Before(signer.ValidAfter)) ||
+			(!signer.ValidBefore.IsZero() && !at.Before(signer.ValidBefore)) {
+			continue
+		}
+
+		principals = append(principals, signer.Principals...)
+	}
+
+	return principals
+}
+
+func (signer *AllowedSigner) allowsNamespace(namespace string) bool {
+	if len(signer.Namespaces) == 0 {
+		return true
+	}
+
+	for _, pattern := range signer.Namespaces {
+		if matched, err := path.Match(pattern, namespace); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isKeyType(field string) bool {
+	return field == keyTypeEd25519 || field == keyTypeRSA || strings.HasPrefix(field, keyTypeECDSA) ||
+		strings.HasPrefix(field, "sk-") || strings.HasSuffix(field, "-cert-v01@openssh.com")
+}
+
+// nextField returns the first whitespace separated field of the line and the remainder, whitespace inside
+// quotes does not end a field
+func nextField(line string) (string, string) {
+	line = strings.TrimLeft(line, " \t")
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case (c == ' ' || c == '\t') && !quoted:
+			return line[:i], strings.TrimLeft(line[i:], " \t")
+		}
+	}
+
+	return line, ""
+}
+
+func splitOutsideQuotes(value string, separator rune) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i, c := range value {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == separator && !quoted:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
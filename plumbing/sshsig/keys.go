@@ -0,0 +1,425 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrEncryptedKey = errors.New("encrypted ssh keys are not supported")
+	ErrNoKey        = errors.New("no ssh key found")
+)
+
+const (
+	keyTypeEd25519 = "ssh-ed25519"
+	keyTypeRSA     = "ssh-rsa"
+	keyTypeECDSA   = "ecdsa-sha2-"
+
+	opensshKeyMagic = "openssh-key-v1\x00"
+)
+
+type ecdsaCurve struct {
+	curve elliptic.Curve
+	hash  crypto.Hash
+}
+
+// ecdsaCurves are the supported curves by their ssh name, the hash is used for signatures made with the curve
+var ecdsaCurves = map[string]ecdsaCurve{
+	"nistp256": {elliptic.P256(), crypto.SHA256},
+	"nistp384": {elliptic.P384(), crypto.SHA384},
+	"nistp521": {elliptic.P521(), crypto.SHA512},
+}
+
+// PublicKey is an ed25519, rsa or ecdsa key in the ssh wire format
+type PublicKey struct {
+	keyType string
+	key     crypto.PublicKey
+	blob    []byte
+}
+
+// ParsePublicKey parses a key in the ssh wire format
+func ParsePublicKey(blob []byte) (*PublicKey, error) {
+	reader := newWireReader(blob)
+	keyType := reader.string()
+
+	var key crypto.PublicKey
+	switch {
+	case keyType == keyTypeEd25519:
+		raw := reader.bytes()
+		if reader.err == nil && len(raw) != ed25519.PublicKeySize {
+			return nil, ErrMalformed
+		}
+		key = ed25519.PublicKey(raw)
+	case keyType == keyTypeRSA:
+		e := reader.mpint()
+		n := reader.mpint()
+		if reader.err == nil && (!e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3) {
+			return nil, ErrMalformed
+		}
+		key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case strings.HasPrefix(keyType, keyTypeECDSA):
+		curveName := reader.string()
+		point := reader.bytes()
+		curve, ok := ecdsaCurves[curveName]
+		if reader.err == nil && (!ok || keyType != keyTypeECDSA+curveName) {
+			return nil, fmt.Errorf("unsupported ssh key type %s", keyType)
+		}
+		if reader.err != nil {
+			break
+		}
+
+		x, y := elliptic.Unmarshal(curve.curve, point)
+		if x == nil {
+			return nil, ErrMalformed
+		}
+		key = &ecdsa.PublicKey{Curve: curve.curve, X: x, Y: y}
+	default:
+		if reader.err == nil {
+			return nil, fmt.Errorf("unsupported ssh key type %s", keyType)
+		}
+	}
+
+	if reader.err != nil || len(reader.rest()) != 0 {
+		return nil, ErrMalformed
+	}
+
+	return &PublicKey{keyType: keyType, key: key, blob: append([]byte{}, blob...)}, nil
+}
+
+// ParseAuthorizedKey parses a key in the format of authorized_keys and .pub files, which is the key type
+// followed by the base64 encoded key and an optional comment
+func ParseAuthorizedKey(text string) (*PublicKey, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, ErrNoKey
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	key, err := ParsePublicKey(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Type() != fields[0] {
+		return nil, fmt.Errorf("ssh key type %s does not match %s", key.Type(), fields[0])
+	}
+
+	return key, nil
+}
+
+func newPublicKey(key crypto.PublicKey) (*PublicKey, error) {
+	writer := wireWriter{}
+	var keyType string
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		keyType = keyTypeEd25519
+		writer.string(keyType)
+		writer.bytes(key)
+	case *rsa.PublicKey:
+		keyType = keyTypeRSA
+		writer.string(keyType)
+		writer.mpint(big.NewInt(int64(key.E)))
+		writer.mpint(key.N)
+	case *ecdsa.PublicKey:
+		curveName, err := ecdsaCurveName(key.Curve)
+		if err != nil {
+			return nil, err
+		}
+
+		keyType = keyTypeECDSA + curveName
+		writer.string(keyType)
+		writer.string(curveName)
+		writer.bytes(elliptic.Marshal(key.Curve, key.X, key.Y))
+	default:
+		return nil, fmt.Errorf("unsupported ssh key type %T", key)
+	}
+
+	return &PublicKey{keyType: keyType, key: key, blob: writer.data}, nil
+}
+
+func ecdsaCurveName(curve elliptic.Curve) (string, error) {
+	for name, candidate := range ecdsaCurves {
+		if candidate.curve == curve {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported ecdsa curve %s", curve.Params().Name)
+}
+
+// Type returns the ssh name of the key type like ssh-ed25519
+func (k *PublicKey) Type() string {
+	return k.keyType
+}
+
+// Name returns the name of the key type that ssh-keygen shows, like ED25519, RSA or ECDSA
+func (k *PublicKey) Name() string {
+	switch {
+	case k.keyType == keyTypeEd25519:
+		return "ED25519"
+	case k.keyType == keyTypeRSA:
+		return "RSA"
+	default:
+		return "ECDSA"
+	}
+}
+
+// Marshal returns the key in the ssh wire format
+func (k *PublicKey) Marshal() []byte {
+	return append([]byte{}, k.blob...)
+}
+
+// Fingerprint returns the sha256 fingerprint of the key in the format of ssh-keygen -l
+func (k *PublicKey) Fingerprint() string {
+	sum := sha256.Sum256(k.blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// String returns the key in the format of authorized_keys files
+func (k *PublicKey) String() string {
+	return k.keyType + " " + base64.StdEncoding.EncodeToString(k.blob)
+}
+
+func (k *PublicKey) Equal(other *PublicKey) bool {
+	return other != nil && bytes.Equal(k.blob, other.blob)
+}
+
+// verify checks the signature, which is in the ssh wire format, of the data
+func (k *PublicKey) verify(data, signature []byte) error {
+	reader := newWireReader(signature)
+	format := reader.string()
+	raw := reader.bytes()
+	if reader.err != nil || len(reader.rest()) != 0 {
+		return ErrMalformed
+	}
+
+	switch key := k.key.(type) {
+	case ed25519.PublicKey:
+		if format == keyTypeEd25519 && ed25519.Verify(key, data, raw) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		hashes := map[string]crypto.Hash{"rsa-sha2-256": crypto.SHA256, "rsa-sha2-512": crypto.SHA512}
+		hash, ok := hashes[format]
+		if !ok {
+			return fmt.Errorf("unsupported rsa signature algorithm %s", format)
+		}
+
+		hasher := hash.New()
+		hasher.Write(data)
+		if rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), raw) == nil {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		inner := newWireReader(raw)
+		r := inner.mpint()
+		s := inner.mpint()
+		if inner.err != nil || len(inner.rest()) != 0 {
+			return ErrMalformed
+		}
+
+		hasher := ecdsaCurves[strings.TrimPrefix(k.keyType, keyTypeECDSA)].hash.New()
+		hasher.Write(data)
+		if format == k.keyType && ecdsa.Verify(key, hasher.Sum(nil), r, s) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// PrivateKey is a key that can create ssh signatures
+type PrivateKey struct {
+	public *PublicKey
+	key    crypto.PrivateKey
+}
+
+// ParsePrivateKey parses an unencrypted private key in the OpenSSH format that ssh-keygen writes or in one of
+// the PEM formats PKCS#1, PKCS#8 and SEC 1
+func ParsePrivateKey(data []byte) (*PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoKey
+	}
+
+	if strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		return nil, ErrEncryptedKey
+	}
+
+	var key crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "OPENSSH PRIVATE KEY":
+		key, err = parseOpenSSHPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key format %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrivateKey(key)
+}
+
+// NewPrivateKey wraps an ed25519.PrivateKey, *rsa.PrivateKey or *ecdsa.PrivateKey
+func NewPrivateKey(key crypto.PrivateKey) (*PrivateKey, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ssh key type %T", key)
+	}
+
+	public, err := newPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrivateKey{public: public, key: key}, nil
+}
+
+// parseOpenSSHPrivateKey parses the content of the PEM block of an OpenSSH private key,
+// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.key
+func parseOpenSSHPrivateKey(data []byte) (crypto.PrivateKey, error) {
+	if !bytes.HasPrefix(data, []byte(opensshKeyMagic)) {
+		return nil, ErrMalformed
+	}
+
+	reader := newWireReader(data[len(opensshKeyMagic):])
+	cipherName := reader.string()
+	reader.string() // kdf name
+	reader.bytes()  // kdf options
+	count := reader.uint32()
+	reader.bytes() // public key
+	private := reader.bytes()
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if cipherName != "none" {
+		return nil, ErrEncryptedKey
+	}
+
+	if count != 1 {
+		return nil, fmt.Errorf("files with %d ssh keys are not supported", count)
+	}
+
+	reader = newWireReader(private)
+	if check1, check2 := reader.uint32(), reader.uint32(); check1 != check2 {
+		return nil, ErrMalformed
+	}
+
+	var key crypto.PrivateKey
+	keyType := reader.string()
+	switch {
+	case keyType == keyTypeEd25519:
+		reader.bytes() // public key
+		raw := reader.bytes()
+		if reader.err == nil && len(raw) != ed25519.PrivateKeySize {
+			return nil, ErrMalformed
+		}
+		key = ed25519.PrivateKey(raw)
+	case keyType == keyTypeRSA:
+		n, e, d := reader.mpint(), reader.mpint(), reader.mpint()
+		reader.mpint() // iqmp, recomputed by Precompute
+		p, q := reader.mpint(), reader.mpint()
+		if reader.err != nil {
+			return nil, reader.err
+		}
+
+		rsaKey := &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())}, D: d, Primes: []*big.Int{p, q}}
+		if err := rsaKey.Validate(); err != nil {
+			return nil, err
+		}
+		rsaKey.Precompute()
+		key = rsaKey
+	case strings.HasPrefix(keyType, keyTypeECDSA):
+		curve, ok := ecdsaCurves[reader.string()]
+		point := reader.bytes()
+		d := reader.mpint()
+		if reader.err != nil {
+			return nil, reader.err
+		}
+		if !ok {
+			return nil, fmt.Errorf("unsupported ssh key type %s", keyType)
+		}
+
+		x, y := elliptic.Unmarshal(curve.curve, point)
+		if x == nil {
+			return nil, ErrMalformed
+		}
+		key = &ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve.curve, X: x, Y: y}, D: d}
+	default:
+		return nil, fmt.Errorf("unsupported ssh key type %s", keyType)
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	return key, nil
+}
+
+// PublicKey returns the public part of the key
+func (k *PrivateKey) PublicKey() *PublicKey {
+	return k.public
+}
+
+// sign returns the signature of the data in the ssh wire format. Rsa keys sign with sha512 like ssh-keygen.
+func (k *PrivateKey) sign(data []byte) ([]byte, error) {
+	writer := wireWriter{}
+	switch key := k.key.(type) {
+	case ed25519.PrivateKey:
+		writer.string(keyTypeEd25519)
+		writer.bytes(ed25519.Sign(key, data))
+	case *rsa.PrivateKey:
+		digest := crypto.SHA512.New()
+		digest.Write(data)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, digest.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		writer.string("rsa-sha2-512")
+		writer.bytes(signature)
+	case *ecdsa.PrivateKey:
+		digest := ecdsaCurves[strings.TrimPrefix(k.public.keyType, keyTypeECDSA)].hash.New()
+		digest.Write(data)
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest.Sum(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		inner := wireWriter{}
+		inner.mpint(r)
+		inner.mpint(s)
+		writer.string(k.public.keyType)
+		writer.bytes(inner.data)
+	default:
+		return nil, fmt.Errorf("unsupported ssh key type %T", key)
+	}
+
+	return writer.data, nil
+}
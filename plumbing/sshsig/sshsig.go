@@ -0,0 +1,139 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// An ssh signature as created by ssh-keygen -Y sign is stored as
+// SSHSIG [version] [public key] [namespace] [reserved] [hash algorithm] [signature]
+// The signature is not made over the message itself but over
+// SSHSIG [namespace] [reserved] [hash algorithm] [hash of the message]
+// All fields except the magic and the version are strings in the ssh wire format. The signature is armored
+// with base64 between BEGIN and END lines like PEM.
+//
+// see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+
+const (
+	signatureMagic   = "SSHSIG"
+	signatureVersion = 1
+	armorStart       = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd         = "-----END SSH SIGNATURE-----"
+	armorLineLength  = 70
+
+	// GitNamespace is the namespace git uses for signatures of commits and tags
+	GitNamespace = "git"
+)
+
+var (
+	ErrInvalidSignature = errors.New("incorrect signature")
+	ErrNotSigned        = errors.New("no ssh signature found")
+)
+
+var hashAlgorithms = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha512": crypto.SHA512,
+}
+
+// Signature is a decoded ssh signature
+type Signature struct {
+	PublicKey     *PublicKey
+	Namespace     string
+	HashAlgorithm string
+	signature     []byte
+}
+
+// Sign creates the armored signature of the message in the namespace, the armor ends with a line break
+func Sign(key *PrivateKey, namespace string, message []byte) (string, error) {
+	signature, err := key.sign(signedData(namespace, "sha512", message))
+	if err != nil {
+		return "", err
+	}
+
+	writer := wireWriter{data: []byte(signatureMagic)}
+	writer.uint32(signatureVersion)
+	writer.bytes(key.PublicKey().Marshal())
+	writer.string(namespace)
+	writer.string("")
+	writer.string("sha512")
+	writer.bytes(signature)
+
+	encoded := base64.StdEncoding.EncodeToString(writer.data)
+	var armored strings.Builder
+	armored.WriteString(armorStart + "\n")
+	for len(encoded) > armorLineLength {
+		armored.WriteString(encoded[:armorLineLength] + "\n")
+		encoded = encoded[armorLineLength:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString(armorEnd + "\n")
+
+	return armored.String(), nil
+}
+
+// Decode parses an armored signature
+func Decode(armored string) (*Signature, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, armorStart) || !strings.HasSuffix(armored, armorEnd) {
+		return nil, ErrNotSigned
+	}
+
+	encoded := strings.Join(strings.Fields(armored[len(armorStart):len(armored)-len(armorEnd)]), "")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !bytes.HasPrefix(data, []byte(signatureMagic)) {
+		return nil, ErrMalformed
+	}
+
+	reader := newWireReader(data[len(signatureMagic):])
+	version := reader.uint32()
+	blob := reader.bytes()
+	sig := &Signature{Namespace: reader.string()}
+	reader.bytes() // reserved
+	sig.HashAlgorithm = reader.string()
+	sig.signature = reader.bytes()
+	if reader.err != nil || len(reader.rest()) != 0 {
+		return nil, ErrMalformed
+	}
+
+	if version != signatureVersion {
+		return nil, fmt.Errorf("ssh signature version %d not supported", version)
+	}
+
+	if sig.PublicKey, err = ParsePublicKey(blob); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// Verify checks that the signature was made over the message in the namespace with the key of the signature.
+// Whether the key is trusted has to be checked separately, see AllowedSigners.
+func (s *Signature) Verify(namespace string, message []byte) error {
+	if s.Namespace != namespace {
+		return fmt.Errorf("signature namespace %s does not match %s", s.Namespace, namespace)
+	}
+
+	if _, ok := hashAlgorithms[s.HashAlgorithm]; !ok {
+		return fmt.Errorf("unsupported signature hash algorithm %s", s.HashAlgorithm)
+	}
+
+	return s.PublicKey.verify(signedData(namespace, s.HashAlgorithm, message), s.signature)
+}
+
+// signedData returns the data that is actually signed for the message
+func signedData(namespace, hashAlgorithm string, message []byte) []byte {
+	hasher := hashAlgorithms[hashAlgorithm].New()
+	hasher.Write(message)
+
+	writer := wireWriter{data: []byte(signatureMagic)}
+	writer.string(namespace)
+	writer.string("")
+	writer.string(hashAlgorithm)
+	writer.bytes(hasher.Sum(nil))
+	return writer.data
+}
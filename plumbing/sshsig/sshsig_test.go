@@ -0,0 +1,132 @@
+package sshsig
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKeys = []string{"ed25519", "ecdsa", "rsa"}
+
+func readTestFile(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("could not read %s: %v", name, err)
+	}
+	return data
+}
+
+func loadTestKey(t *testing.T, name string) (*PrivateKey, *PublicKey) {
+	t.Helper()
+
+	private, err := ParsePrivateKey(readTestFile(t, "id_"+name))
+	if err != nil {
+		t.Fatalf("could not parse private key %s: %v", name, err)
+	}
+
+	public, err := ParseAuthorizedKey(string(readTestFile(t, "id_"+name+".pub")))
+	if err != nil {
+		t.Fatalf("could not parse public key %s: %v", name, err)
+	}
+
+	return private, public
+}
+
+func TestVerifySignaturesOfSshKeygen(t *testing.T) {
+	message := readTestFile(t, "message")
+	for _, name := range testKeys {
+		_, public := loadTestKey(t, name)
+
+		sig, err := Decode(string(readTestFile(t, "message_"+name+".sig")))
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		assert.True(t, sig.PublicKey.Equal(public), name)
+		assert.Equal(t, GitNamespace, sig.Namespace)
+		assert.NoError(t, sig.Verify(GitNamespace, message), name)
+		assert.Equal(t, ErrInvalidSignature, sig.Verify(GitNamespace, []byte("tampered\n")), name)
+		assert.Error(t, sig.Verify("file", message), name)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	message := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n")
+	for _, name := range testKeys {
+		private, public := loadTestKey(t, name)
+		assert.True(t, private.PublicKey().Equal(public), name)
+
+		armored, err := Sign(private, GitNamespace, message)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(armored, armorStart+"\n"))
+		assert.True(t, strings.HasSuffix(armored, armorEnd+"\n"))
+
+		sig, err := Decode(armored)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+
+		assert.True(t, sig.PublicKey.Equal(public), name)
+		assert.Equal(t, "sha512", sig.HashAlgorithm)
+		assert.NoError(t, sig.Verify(GitNamespace, message), name)
+		assert.Equal(t, ErrInvalidSignature, sig.Verify(GitNamespace, append(message, 'x')), name)
+	}
+}
+
+func TestDecodeRejectsUnsignedData(t *testing.T) {
+	_, err := Decode("hello world")
+	assert.Equal(t, ErrNotSigned, err)
+
+	_, err = Decode(armorStart + "\nbm90IGEgc2lnbmF0dXJl\n" + armorEnd)
+	assert.Equal(t, ErrMalformed, err)
+}
+
+func TestFingerprintMatchesSshKeygen(t *testing.T) {
+	_, public := loadTestKey(t, "ed25519")
+	assert.Equal(t, "ED25519", public.Name())
+	assert.Equal(t, "SHA256:VOxC0+toYAAJjBarLy/f03YSYi1BTlrzQiYCTgA5alU", public.Fingerprint())
+}
+
+func TestFindPrincipals(t *testing.T) {
+	_, alice := loadTestKey(t, "ed25519")
+	_, bob := loadTestKey(t, "ecdsa")
+	_, carol := loadTestKey(t, "rsa")
+
+	allowed := "# trusted keys\n\n" +
+		"alice@example.com,alice@work.example.com " + alice.String() + " alice\n" +
+		`bob@example.com namespaces="file,git",valid-after="20200101Z" ` + bob.String() + "\n" +
+		`"carol@example.com" namespaces="file",valid-before=20200101Z ` + carol.String() + "\n"
+
+	signers, err := ParseAllowedSigners(strings.NewReader(allowed))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 3, len(signers))
+
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []string{"alice@example.com", "alice@work.example.com"},
+		signers.FindPrincipals(alice, GitNamespace, now))
+	assert.Equal(t, []string{"bob@example.com"}, signers.FindPrincipals(bob, GitNamespace, now))
+	assert.Empty(t, signers.FindPrincipals(bob, GitNamespace, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Empty(t, signers.FindPrincipals(carol, GitNamespace, now), "namespace is not allowed")
+	assert.Empty(t, signers.FindPrincipals(carol, "file", now), "key is expired")
+	assert.Equal(t, []string{"carol@example.com"},
+		signers.FindPrincipals(carol, "file", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestParseAllowedSignersReportsLine(t *testing.T) {
+	_, err := ParseAllowedSigners(strings.NewReader("# comment\nalice@example.com ssh-ed25519 invalid\n"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "line 2")
+	}
+
+	_, err = ParseAllowedSigners(strings.NewReader("alice@example.com unknown-option ssh-ed25519 AAAA\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package sshsig
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+// ErrMalformed is returned if data in the ssh wire format is truncated or otherwise corrupt
+var ErrMalformed = errors.New("malformed ssh data")
+
+// The ssh wire format encodes strings with a four byte length prefix and integers as strings that hold the
+// two's complement of the value, see https://www.rfc-editor.org/rfc/rfc4251#section-5
+
+type wireReader struct {
+	data []byte
+	err  error
+}
+
+func newWireReader(data []byte) *wireReader {
+	return &wireReader{data: data}
+}
+
+func (r *wireReader) uint32() uint32 {
+	if r.err != nil || len(r.data) < 4 {
+		r.err = ErrMalformed
+		return 0
+	}
+
+	value := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return value
+}
+
+func (r *wireReader) bytes() []byte {
+	length := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < length {
+		r.err = ErrMalformed
+		return nil
+	}
+
+	value := r.data[:length]
+	r.data = r.data[length:]
+	return value
+}
+
+func (r *wireReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *wireReader) mpint() *big.Int {
+	value := r.bytes()
+	if len(value) > 0 && value[0]&0x80 != 0 {
+		// negative numbers do not occur in keys or signatures
+		r.err = ErrMalformed
+	}
+
+	return new(big.Int).SetBytes(value)
+}
+
+func (r *wireReader) rest() []byte {
+	value := r.data
+	r.data = nil
+	return value
+}
+
+type wireWriter struct {
+	data []byte
+}
+
+func (w *wireWriter) uint32(value uint32) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], value)
+	w.data = append(w.data, buf[:]...)
+}
+
+func (w *wireWriter) bytes(value []byte) {
+	w.uint32(uint32(len(value)))
+	w.data = append(w.data, value...)
+}
+
+func (w *wireWriter) string(value string) {
+	w.bytes([]byte(value))
+}
+
+func (w *wireWriter) mpint(value *big.Int) {
+	raw := value.Bytes()
+	if len(raw) > 0 && raw[0]&0x80 != 0 {
+		raw = append([]byte{0}, raw...)
+	}
+	w.bytes(raw)
+}
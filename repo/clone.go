@@ -0,0 +1,201 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/refs"
+	"github.com/furisto/gog/storage"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultRemote = "origin"
+
+var ErrCloneTargetNotEmpty = errors.New("destination path already exists and is not an empty directory")
+
+type CloneOptions struct {
+	// Borrow all objects from the source repository instead of copying them
+	Shared bool
+	// Borrow objects from the given repository, only objects missing there are copied
+	Reference string
+}
+
+// Clone creates a new repository at path from a repository on the local filesystem. Branches of the
+// source become remote tracking branches of the clone and the branch HEAD of the source points to is
+// created as local branch.
+func Clone(source, path string, options CloneOptions) (*Repository, error) {
+	sourceRepo, err := FromExisting(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isEmptyDir(path) {
+		return nil, ErrCloneTargetNotEmpty
+	}
+
+	ry, err := InitDefault(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore)
+	if !ok {
+		return nil, fmt.Errorf("clone requires a filesystem object store")
+	}
+
+	if options.Shared {
+		if err := store.AddAlternate(filepath.Join(sourceRepo.Info.GitDirectory(), "objects")); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Reference != "" {
+		reference, err := FromExisting(options.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("reference repository '%s' is not a local repository", options.Reference)
+		}
+
+		if err := store.AddAlternate(filepath.Join(reference.Info.GitDirectory(), "objects")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := copyObjects(sourceRepo, ry); err != nil {
+		return nil, err
+	}
+
+	if err := cloneRefs(sourceRepo, ry); err != nil {
+		return nil, err
+	}
+
+	sourcePath, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteSection := fmt.Sprintf("remote \"%s\"", defaultRemote)
+	if err := ry.Config.Set(remoteSection, "url", sourcePath); err != nil {
+		return nil, err
+	}
+
+	fetchSpec := fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", defaultRemote)
+	if err := ry.Config.Set(remoteSection, "fetch", fetchSpec); err != nil {
+		return nil, err
+	}
+
+	return ry, nil
+}
+
+// copyObjects copies all objects reachable from the refs of the source that are not yet available
+// in the target, either locally or through its alternates
+func copyObjects(source, target *Repository) error {
+	tips, err := source.refTips()
+	if err != nil {
+		return err
+	}
+
+	reachable, err := objects.ReachableObjects(source.Storage, tips)
+	if err != nil {
+		return err
+	}
+
+	for _, oid := range reachable {
+		exists, err := target.Storage.Stat(oid)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			continue
+		}
+
+		data, err := source.Storage.Get(oid)
+		if err != nil {
+			return err
+		}
+
+		if err := target.Storage.Put(oid, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cloneRefs(source, target *Repository) error {
+	remotePrefix := refs.RemotePattern + "/" + defaultRemote + "/"
+	for _, branch := range source.Branches.List() {
+		resolved, err := source.Refs.Resolve(branch)
+		if err != nil {
+			return err
+		}
+
+		if _, err := target.Refs.Set(remotePrefix+shortRefName(branch.Name, refs.BranchPattern), resolved.RefValue); err != nil {
+			return err
+		}
+	}
+
+	for _, tag := range source.Tags.List() {
+		if _, err := target.Refs.Set(refs.TagPattern+"/"+shortRefName(tag.Name, refs.TagPattern), tag.RefValue); err != nil {
+			return err
+		}
+	}
+
+	head, err := source.Head(false)
+	if err == refs.ErrRefNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resolved, err := source.Refs.Resolve(head)
+	if err == refs.ErrRefNotExist {
+		// the source does not have any commits yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if head.IsRefType(refs.HashRef) {
+		return target.SetHead(resolved.RefValue)
+	}
+
+	branchName := shortRefName(head.RefValue, refs.BranchPattern)
+	if _, err := target.Branches.Create(branchName, resolved.RefValue); err != nil {
+		return err
+	}
+
+	if _, err := target.Refs.Set(remotePrefix+"HEAD", "ref: "+remotePrefix+branchName); err != nil {
+		return err
+	}
+
+	branchSection := fmt.Sprintf("branch \"%s\"", branchName)
+	if err := target.Config.Set(branchSection, "remote", defaultRemote); err != nil {
+		return err
+	}
+
+	if err := target.Config.Set(branchSection, "merge", refs.BranchPattern+"/"+branchName); err != nil {
+		return err
+	}
+
+	return target.SetHead("ref: " + refs.BranchPattern + "/" + branchName)
+}
+
+func shortRefName(name, pattern string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "/"), pattern+"/")
+}
+
+// isEmptyDir reports whether the path does not exist or is an empty directory
+func isEmptyDir(path string) bool {
+	entries, err := ioutil.ReadDir(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+
+	return err == nil && len(entries) == 0
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"errors"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/storage"
+)
+
+var ErrCommitGraphNotSupported = errors.New("object store does not support commit-graphs")
+
+type CommitGraphOptions struct {
+	// Only include commits reachable from refs instead of every commit in the object store
+	Reachable bool
+	// Write the commits that are not part of the existing chain as a new graph on top of the chain
+	Split bool
+}
+
+// WriteCommitGraph writes the commit-graph of the repository and returns the number of commits written.
+// Commits that are already part of a commit-graph are not decoded again.
+func (ry *Repository) WriteCommitGraph(options CommitGraphOptions) (int, error) {
+	store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore)
+	if !ok {
+		return 0, ErrCommitGraphNotSupported
+	}
+
+	var tips []string
+	var err error
+	if options.Reachable {
+		tips, err = ry.refTips()
+	} else {
+		tips, err = commitObjects(store)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	graph := storage.LoadCommitGraph(store)
+	seen := make(map[string]bool)
+	var entries []storage.CommitGraphEntry
+	pending := tips
+
+	for len(pending) > 0 {
+		oid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[oid] {
+			continue
+		}
+		seen[oid] = true
+
+		if entry, ok := graph.Lookup(oid); ok {
+			entries = append(entries, *entry)
+			pending = append(pending, entry.Parents...)
+			continue
+		}
+
+		data, err := store.Get(oid)
+		if err != nil {
+			return 0, err
+		}
+
+		object, err := objects.DecodeWithFormat(oid, data, store.ObjectFormat())
+		if err != nil {
+			return 0, err
+		}
+
+		switch objects.TypeOf(object) {
+		case objects.CommitObject:
+			commit := object.(*objects.Commit)
+			entry := storage.CommitGraphEntry{OID: oid, Tree: commit.Tree, Parents: commit.Parents}
+			if commit.Commiter != nil {
+				entry.CommitTime = commit.Commiter.TimeStamp.Unix()
+			}
+			entries = append(entries, entry)
+			pending = append(pending, commit.Parents...)
+		case objects.TagObject:
+			pending = append(pending, object.(*objects.Tag).TargetOID())
+		}
+	}
+
+	return store.WriteCommitGraph(entries, options.Split)
+}
+
+// commitObjects returns the ids of all commits in the object store
+func commitObjects(store *storage.FilesystemStore) ([]string, error) {
+	oids, err := store.Objects()
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []string
+	for _, oid := range oids {
+		objectType, _, reader, err := store.Open(oid)
+		if err != nil {
+			return nil, err
+		}
+		reader.Close()
+
+		if objectType == "commit" {
+			commits = append(commits, oid)
+		}
+	}
+
+	return commits, nil
+}
@@ -0,0 +1,264 @@
+package repo
+
+import (
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/storage"
+	"path/filepath"
+	"sort"
+)
+
+type FsckOptions struct {
+	// Report objects that are neither reachable from a ref nor referenced by another object
+	Dangling bool
+	// Report all objects that are not reachable from a ref
+	Unreachable bool
+}
+
+// FsckObject is an object that is reported by Fsck, ReferencedBy names the object or ref that points
+// to a missing object
+type FsckObject struct {
+	OID          string
+	Type         string
+	ReferencedBy string
+}
+
+type FsckReport struct {
+	// Problems found while verifying packs and objects, only errors make a repository corrupt
+	Problems    []objects.FsckProblem
+	Missing     []FsckObject
+	Dangling    []FsckObject
+	Unreachable []FsckObject
+}
+
+// Corrupt reports whether objects are missing or violate the rules of their type
+func (r *FsckReport) Corrupt() bool {
+	if len(r.Missing) > 0 {
+		return true
+	}
+
+	for _, problem := range r.Problems {
+		if problem.Severity == objects.FsckError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fsck verifies the integrity of the repository. Every object is rehashed and checked for syntax errors,
+// afterwards the connectivity of all objects reachable from refs and the index is verified.
+func (ry *Repository) Fsck(options FsckOptions) (*FsckReport, error) {
+	store := storage.Unwrap(ry.Storage)
+	oids, err := store.Objects()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FsckReport{}
+	if fsStore, ok := store.(*storage.FilesystemStore); ok {
+		packs, err := fsStore.Packs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pack := range packs {
+			if err := pack.Verify(); err != nil {
+				report.Problems = append(report.Problems, objects.FsckProblem{
+					OID:      filepath.Base(pack.Path()),
+					Type:     "pack",
+					Severity: objects.FsckError,
+					Message:  err.Error(),
+				})
+			}
+		}
+
+		if err := fsStore.VerifyMultiPackIndex(); err != nil {
+			report.Problems = append(report.Problems, objects.FsckProblem{
+				OID:      "multi-pack-index",
+				Type:     "pack",
+				Severity: objects.FsckError,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	fsck := &fsckWalker{
+		store:      store,
+		report:     report,
+		types:      make(map[string]string, len(oids)),
+		links:      make(map[string][]objects.ObjectLink, len(oids)),
+		referenced: make(map[string]bool),
+		missing:    make(map[string]bool),
+	}
+
+	for _, oid := range oids {
+		fsck.checkObject(oid)
+	}
+
+	for _, oid := range oids {
+		fsck.checkLinks(oid, fsck.links[oid])
+	}
+
+	roots, err := ry.rootTips()
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := fsck.walk(roots)
+	for _, oid := range oids {
+		if reachable[oid] {
+			continue
+		}
+
+		object := FsckObject{OID: oid, Type: fsck.types[oid]}
+		if options.Unreachable {
+			report.Unreachable = append(report.Unreachable, object)
+		} else if options.Dangling && !fsck.referenced[oid] {
+			report.Dangling = append(report.Dangling, object)
+		}
+	}
+
+	sort.Slice(report.Missing, func(i, j int) bool {
+		return report.Missing[i].OID < report.Missing[j].OID
+	})
+
+	return report, nil
+}
+
+type fsckWalker struct {
+	store      storage.ObjectStore
+	report     *FsckReport
+	types      map[string]string
+	links      map[string][]objects.ObjectLink
+	referenced map[string]bool
+	missing    map[string]bool
+}
+
+// checkObject verifies that the object id matches the content of the object and that the object
+// is well formed
+func (fw *fsckWalker) checkObject(oid string) {
+	format := fw.store.ObjectFormat()
+	objectType, size, reader, err := fw.store.Open(oid)
+	if err != nil {
+		fw.addProblem(oid, "object", fmt.Sprintf("unable to read object: %v", err))
+		return
+	}
+
+	var actual string
+	if objectType == "blob" {
+		actual, err = format.HashObject(objectType, size, reader)
+		reader.Close()
+		if err != nil {
+			fw.addProblem(oid, objectType, fmt.Sprintf("unable to read object: %v", err))
+			return
+		}
+	} else {
+		reader.Close()
+		data, err := fw.store.Get(oid)
+		if err != nil {
+			fw.addProblem(oid, objectType, fmt.Sprintf("unable to read object: %v", err))
+			return
+		}
+
+		actual = format.Hash(data)
+		links, problems := objects.CheckObject(oid, data, format)
+		fw.links[oid] = links
+		fw.report.Problems = append(fw.report.Problems, problems...)
+	}
+
+	fw.types[oid] = objectType
+	if actual != oid {
+		fw.addProblem(oid, objectType, fmt.Sprintf("hash mismatch, content hashes to %s", actual))
+	}
+}
+
+// checkLinks reports the objects referenced by the given object that do not exist
+func (fw *fsckWalker) checkLinks(oid string, links []objects.ObjectLink) {
+	for _, link := range links {
+		fw.referenced[link.OID] = true
+		if !fw.exists(link.OID) {
+			fw.addMissing(link.OID, link.Type, fw.types[oid]+" "+oid)
+		}
+	}
+}
+
+// walk returns all existing objects that can be reached from the roots, missing roots are reported
+func (fw *fsckWalker) walk(roots []refTip) map[string]bool {
+	reachable := make(map[string]bool)
+	var pending []string
+
+	for _, root := range roots {
+		if !fw.exists(root.oid) {
+			fw.addMissing(root.oid, "", root.name)
+			continue
+		}
+		pending = append(pending, root.oid)
+	}
+
+	for len(pending) > 0 {
+		oid := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if reachable[oid] {
+			continue
+		}
+		reachable[oid] = true
+
+		links, checked := fw.links[oid]
+		if _, local := fw.types[oid]; !local && !checked {
+			// objects borrowed from alternates are not checked, but their references are followed
+			links = fw.borrowedLinks(oid)
+			fw.checkLinks(oid, links)
+		}
+
+		for _, link := range links {
+			if fw.exists(link.OID) {
+				pending = append(pending, link.OID)
+			}
+		}
+	}
+
+	return reachable
+}
+
+func (fw *fsckWalker) borrowedLinks(oid string) []objects.ObjectLink {
+	data, err := fw.store.Get(oid)
+	if err != nil {
+		return nil
+	}
+
+	links, _ := objects.CheckObject(oid, data, fw.store.ObjectFormat())
+	fw.links[oid] = links
+	return links
+}
+
+func (fw *fsckWalker) exists(oid string) bool {
+	if _, ok := fw.types[oid]; ok {
+		return true
+	}
+
+	if fw.missing[oid] {
+		return false
+	}
+
+	exists, err := fw.store.Stat(oid)
+	return err == nil && exists
+}
+
+func (fw *fsckWalker) addMissing(oid, objectType, referencedBy string) {
+	if fw.missing[oid] {
+		return
+	}
+
+	fw.missing[oid] = true
+	fw.report.Missing = append(fw.report.Missing, FsckObject{OID: oid, Type: objectType, ReferencedBy: referencedBy})
+}
+
+func (fw *fsckWalker) addProblem(oid, objectType, message string) {
+	fw.report.Problems = append(fw.report.Problems, objects.FsckProblem{
+		OID:      oid,
+		Type:     objectType,
+		Severity: objects.FsckError,
+		Message:  message,
+	})
+}
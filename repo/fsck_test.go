@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func prepareFsckRepository(t *testing.T) (*Repository, *objects.Blob, *objects.Commit) {
+	t.Helper()
+
+	ry, err := InitInMemory()
+	if err != nil {
+		t.Fatalf("could not create in memory repository: %v", err)
+	}
+
+	blob := objects.NewBlob([]byte("fsck"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	tb := objects.NewTreeBuilder()
+	tb.AddBlob(blob.OID(), "file", 0o100644)
+	tree := tb.Build()
+	if err := tree.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	commit, err := objects.NewCommitBuilder(tree.OID()).
+		WithAuthor("furisto", "furisto@test.com").
+		WithCommitter("furisto", "furisto@test.com").
+		WithMessage("fsck").
+		Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	if err := commit.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save commit: %v", err)
+	}
+
+	if _, err := ry.Branches.Create("master", commit.OID()); err != nil {
+		t.Fatalf("could not create branch: %v", err)
+	}
+
+	return ry, blob, commit
+}
+
+func TestFsckOfHealthyRepository(t *testing.T) {
+	ry, _, commit := prepareFsckRepository(t)
+
+	tagger := &objects.Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Unix(1609334881, 0)}
+	tag, err := objects.NewTag(commit.OID(), "commit", "v1", tagger, "release")
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	if err := tag.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tag: %v", err)
+	}
+
+	if _, err := ry.Refs.Set("refs/tags/v1", tag.OID()); err != nil {
+		t.Fatalf("could not create tag ref: %v", err)
+	}
+
+	report, err := ry.Fsck(FsckOptions{Dangling: true})
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+
+	assert.False(t, report.Corrupt())
+	assert.Empty(t, report.Problems)
+	assert.Empty(t, report.Missing)
+	assert.Empty(t, report.Dangling)
+}
+
+func TestFsckReportsMissingAndDanglingObjects(t *testing.T) {
+	ry, blob, _ := prepareFsckRepository(t)
+
+	if err := ry.Storage.(*storage.MemoryStore).Delete(blob.OID()); err != nil {
+		t.Fatalf("could not delete blob: %v", err)
+	}
+
+	dangling := objects.NewBlob([]byte("dangling"))
+	if err := dangling.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	report, err := ry.Fsck(FsckOptions{Dangling: true})
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+
+	assert.True(t, report.Corrupt())
+	if assert.Len(t, report.Missing, 1) {
+		assert.Equal(t, blob.OID(), report.Missing[0].OID)
+		assert.Equal(t, "blob", report.Missing[0].Type)
+	}
+	assert.Equal(t, []FsckObject{{OID: dangling.OID(), Type: "blob"}}, report.Dangling)
+
+	report, err = ry.Fsck(FsckOptions{Unreachable: true})
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+	assert.Equal(t, []FsckObject{{OID: dangling.OID(), Type: "blob"}}, report.Unreachable)
+}
+
+func TestFsckDetectsHashMismatch(t *testing.T) {
+	ry, _, _ := prepareFsckRepository(t)
+
+	oid := "0123456789012345678901234567890123456789"
+	if err := ry.Storage.Put(oid, []byte("blob 7\x00corrupt")); err != nil {
+		t.Fatalf("could not save object: %v", err)
+	}
+
+	report, err := ry.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+
+	assert.True(t, report.Corrupt())
+	if assert.Len(t, report.Problems, 1) {
+		assert.Equal(t, oid, report.Problems[0].OID)
+		assert.Equal(t, objects.FsckError, report.Problems[0].Severity)
+	}
+}
+
+func TestFsckFollowsPackedRefs(t *testing.T) {
+	ry, err := InitDefault(createTemporaryDir(t), true)
+	if err != nil {
+		t.Fatalf("could not initialize repository: %v", err)
+	}
+	defer os.RemoveAll(ry.Info.GitDirectory())
+
+	tree := objects.NewTreeBuilder().Build()
+	if err := tree.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	commit, err := objects.NewCommitBuilder(tree.OID()).WithMessage("packed").Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	if err := commit.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save commit: %v", err)
+	}
+
+	// like git clone --bare the branch that HEAD points to only exists in packed-refs
+	packed := "# pack-refs with: peeled fully-peeled sorted \n" + commit.OID() + " refs/heads/master\n"
+	if err := ioutil.WriteFile(filepath.Join(ry.Info.GitDirectory(), "packed-refs"), []byte(packed), 0644); err != nil {
+		t.Fatalf("could not write packed refs: %v", err)
+	}
+
+	report, err := ry.Fsck(FsckOptions{Dangling: true})
+	if err != nil {
+		t.Fatalf("fsck failed: %v", err)
+	}
+
+	assert.False(t, report.Corrupt())
+	assert.Empty(t, report.Dangling)
+}
@@ -3,10 +3,10 @@ package repo
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha1"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
+	"github.com/furisto/gog/config"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/storage"
 	"github.com/furisto/gog/util"
@@ -20,7 +20,8 @@ import (
 
 var dirCacheMarker = []byte("DIRC")
 
-const indexEntryOffset = 62
+// Size of the fixed length fields of an index entry without the object id
+const indexEntryFixedSize = 42
 
 var (
 	ErrEntryDoesNotExist       = errors.New("index entry does not exist")
@@ -34,19 +35,28 @@ type Index struct {
 	version    uint32
 	entries    map[string]*IndexEntry
 	store      storage.ObjectStore
+	format     util.ObjectFormat
+	config     config.Config
 }
 
-func NewIndex(workingDir, gitDir string, store storage.ObjectStore) *Index {
+func NewIndex(workingDir, gitDir string, store storage.ObjectStore, config config.Config) *Index {
 	return &Index{
 		workingDir: workingDir,
 		gitDir:     gitDir,
 		version:    2,
 		entries:    make(map[string]*IndexEntry),
 		store:      store,
+		format:     store.ObjectFormat(),
+		config:     config,
 	}
 }
 
 func DecodeIndex(path string) (*Index, error) {
+	return DecodeIndexWithFormat(path, util.DefaultObjectFormat)
+}
+
+// DecodeIndexWithFormat reads an index whose object ids and checksum are computed with the object format
+func DecodeIndexWithFormat(path string, format util.ObjectFormat) (*Index, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return nil, err
 	}
@@ -57,7 +67,7 @@ func DecodeIndex(path string) (*Index, error) {
 	}
 	defer util.CloseFile(indexFile, err)
 
-	hasher := sha1.New()
+	hasher := format.NewHasher()
 	reader := io.TeeReader(bufio.NewReader(indexFile), hasher)
 
 	version, entryLength, err := readHeader(reader)
@@ -65,7 +75,7 @@ func DecodeIndex(path string) (*Index, error) {
 		return nil, err
 	}
 
-	entries, err := readEntries(reader, entryLength)
+	entries, err := readEntries(reader, entryLength, format.Size())
 	if err != nil {
 		return nil, err
 	}
@@ -77,6 +87,7 @@ func DecodeIndex(path string) (*Index, error) {
 	return &Index{
 		version: version,
 		entries: entries,
+		format:  format,
 	}, nil
 }
 
@@ -105,7 +116,7 @@ func readHeader(reader io.Reader) (version uint32, entryLength uint32, err error
 	return version, entryLength, nil
 }
 
-func readEntries(reader io.Reader, entryLength uint32) (map[string]*IndexEntry, error) {
+func readEntries(reader io.Reader, entryLength uint32, hashSize int) (map[string]*IndexEntry, error) {
 	entries := make(map[string]*IndexEntry)
 
 	for i := uint32(0); i < entryLength; i++ {
@@ -132,7 +143,7 @@ func readEntries(reader io.Reader, entryLength uint32) (map[string]*IndexEntry,
 		entry.ChangedTime = time.Unix(int64(csec), int64(cnano))
 		entry.ModifiedTime = time.Unix(int64(msec), int64(mnano))
 
-		oidBytes := make([]byte, 20)
+		oidBytes := make([]byte, hashSize)
 		if _, err := io.ReadFull(reader, oidBytes); err != nil {
 			return nil, err
 		}
@@ -152,7 +163,7 @@ func readEntries(reader io.Reader, entryLength uint32) (map[string]*IndexEntry,
 		entry.Path = string(pathBytes[:len(pathBytes)-1])
 		entries[entry.Path] = &entry
 
-		if err = discardPadding(reader, &entry); err != nil {
+		if err = discardPadding(reader, &entry, hashSize); err != nil {
 			return nil, err
 		}
 	}
@@ -160,8 +171,8 @@ func readEntries(reader io.Reader, entryLength uint32) (map[string]*IndexEntry,
 	return entries, nil
 }
 
-func discardPadding(reader io.Reader, entry *IndexEntry) error {
-	paddingInverse := (indexEntryOffset + entry.Flags.Length() + 1) % 8
+func discardPadding(reader io.Reader, entry *IndexEntry, hashSize int) error {
+	paddingInverse := (indexEntryFixedSize + hashSize + int(entry.Flags.Length()) + 1) % 8
 	if paddingInverse > 0 {
 		discard := make([]byte, 8-paddingInverse)
 		if _, err := io.ReadFull(reader, discard); err != nil {
@@ -173,7 +184,7 @@ func discardPadding(reader io.Reader, entry *IndexEntry) error {
 }
 
 func verifyFooter(reader io.Reader, hash []byte) error {
-	readHash := make([]byte, 20)
+	readHash := make([]byte, len(hash))
 	if _, err := io.ReadFull(reader, readHash); err != nil {
 		return err
 	}
@@ -186,7 +197,7 @@ func verifyFooter(reader io.Reader, hash []byte) error {
 }
 
 func (ix *Index) EncodeIndex(writer io.Writer) error {
-	hasher := sha1.New()
+	hasher := ix.format.NewHasher()
 	mw := io.MultiWriter(writer, hasher)
 
 	if err := ix.writeHeader(mw); err != nil {
@@ -254,9 +265,10 @@ func (ix *Index) Entries() []*IndexEntry {
 }
 
 func (ix *Index) Set(path string) error {
+	var previous os.FileMode
 	entry, err := ix.Find(path)
 	if entry != nil {
-		stat, err := os.Stat(path)
+		stat, err := os.Lstat(path)
 		if err != nil {
 			return err
 		}
@@ -264,9 +276,17 @@ func (ix *Index) Set(path string) error {
 		if entry.Match(stat) {
 			return nil
 		}
+		previous = entry.Mode
+	}
+
+	modes := objects.DefaultWorkingTreeModes
+	if ix.config != nil {
+		if modes, err = workingTreeModes(ix.config); err != nil {
+			return err
+		}
 	}
 
-	blob, err := objects.NewBlobFromFile(path)
+	blob, err := objects.NewBlobFromWorkingTreeWithFormat(path, ix.format)
 	if err != nil {
 		return err
 	}
@@ -276,7 +296,7 @@ func (ix *Index) Set(path string) error {
 		return err
 	}
 
-	entry, err = newIndexEntryFromFile(blob.OID(), path, ix.workingDir)
+	entry, err = newIndexEntryFromFile(blob.OID(), path, ix.workingDir, modes, previous)
 	if err != nil {
 		return err
 	}
@@ -284,6 +304,24 @@ func (ix *Index) Set(path string) error {
 	return nil
 }
 
+// Add sets the entries of all files below the path, the git directory is skipped
+func (ix *Index) Add(path string) error {
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return ix.Set(path)
+	})
+}
+
 func (ix *Index) Delete(path string) {
 	delete(ix.entries, path)
 }
@@ -329,7 +367,7 @@ func NewIndexToTreeConverter(index *Index) *IndexToTreeConverter {
 }
 
 func (ic *IndexToTreeConverter) Convert() (*objects.Tree, error) {
-	rootBuilder := objects.NewTreeBuilder()
+	rootBuilder := objects.NewTreeBuilder().WithObjectFormat(ic.index.format)
 	ic.entries[""] = rootBuilder
 
 	for _, indexEntry := range ic.index.Entries() {
@@ -352,10 +390,10 @@ func (ic *IndexToTreeConverter) Convert() (*objects.Tree, error) {
 
 func (ic *IndexToTreeConverter) convert(indexEntry *IndexEntry, parentPath, combinedPath string) {
 	if indexEntry.Path == combinedPath {
-		ic.entries[parentPath].AddBlob(indexEntry.OID, filepath.Base(combinedPath), 0o100644)
+		ic.entries[parentPath].AddBlob(indexEntry.OID, filepath.Base(combinedPath), indexEntry.Mode)
 	} else {
 		if _, ok := ic.entries[combinedPath]; !ok {
-			ic.entries[combinedPath] = objects.NewTreeBuilder()
+			ic.entries[combinedPath] = objects.NewTreeBuilder().WithObjectFormat(ic.index.format)
 		}
 
 		ic.entries[parentPath].AddSubTree(filepath.Base(combinedPath), ic.entries[combinedPath])
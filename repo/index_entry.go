@@ -3,6 +3,7 @@ package repo
 import (
 	"encoding/binary"
 	"encoding/hex"
+	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/util"
 	"io"
 	"os"
@@ -119,11 +120,13 @@ func (ie *IndexEntry) IsRegular() bool {
 }
 
 func (ie *IndexEntry) Encode(writer io.Writer) error {
+	// timestamps are stored as 32 bit seconds and nanoseconds, see
+	// https://git-scm.com/docs/index-format
 	fields := []interface{}{
-		ie.ChangedTime.Unix(),
-		ie.ChangedTime.UnixNano(),
-		ie.ModifiedTime.Unix(),
-		ie.ModifiedTime.UnixNano(),
+		uint32(ie.ChangedTime.Unix()),
+		uint32(ie.ChangedTime.Nanosecond()),
+		uint32(ie.ModifiedTime.Unix()),
+		uint32(ie.ModifiedTime.Nanosecond()),
 		ie.DeviceId,
 		ie.Inode,
 		ie.Mode,
@@ -157,7 +160,7 @@ func (ie *IndexEntry) Encode(writer io.Writer) error {
 		return err
 	}
 
-	paddingLength := (indexEntryOffset + len(ie.Path) + 1) % 8
+	paddingLength := (indexEntryFixedSize + len(oidBytes) + len(ie.Path) + 1) % 8
 	if paddingLength > 0 {
 		padding := make([]byte, 8-paddingLength)
 		if _, err := writer.Write(padding); err != nil {
@@ -168,12 +171,14 @@ func (ie *IndexEntry) Encode(writer io.Writer) error {
 	return nil
 }
 
-func newIndexEntryFromFile(oid, path, workingDir string) (*IndexEntry, error) {
+// newIndexEntryFromFile creates the entry of a file in the working tree, previous is the mode of the entry that
+// is replaced or zero for new files
+func newIndexEntryFromFile(oid, path, workingDir string, modes objects.WorkingTreeModes, previous os.FileMode) (*IndexEntry, error) {
 	if filepath.IsAbs(path) {
 		path = strings.TrimPrefix(path, workingDir+string(os.PathSeparator))
 	}
 
-	stat, err := os.Stat(filepath.Join(workingDir, path))
+	stat, err := os.Lstat(filepath.Join(workingDir, path))
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +188,7 @@ func newIndexEntryFromFile(oid, path, workingDir string) (*IndexEntry, error) {
 		ModifiedTime:  stat.ModTime(),
 		DeviceId:      0,
 		Inode:         0,
-		Mode:          0o100644,
+		Mode:          modes.ModeOf(stat, previous),
 		UID:           0,
 		GID:           0,
 		FileSize:      uint32(stat.Size()),
@@ -3,6 +3,10 @@ package repo
 import (
 	"fmt"
 	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -145,3 +149,74 @@ func areTreeEntriesEqual(t *testing.T, expected objects.TreeEntry, actual object
 		t.Errorf("file modes of trees do not match")
 	}
 }
+
+func TestIndexStoresModes(t *testing.T) {
+	ry := createTestRepository(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	dir := ry.Info.WorkingDirectory()
+	if err := ioutil.WriteFile(filepath.Join(dir, "run.sh"), []byte("echo"), 0755); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if err := os.Symlink("run.sh", filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if err := ry.Config.Set("core", "filemode", "true"); err != nil {
+		t.Fatalf("could not set core.filemode: %v", err)
+	}
+
+	if err := ry.Index.Add(dir); err != nil {
+		t.Fatalf("could not add files to index: %v", err)
+	}
+
+	modes := make(map[string]os.FileMode)
+	for _, entry := range ry.Index.Entries() {
+		modes[entry.Path] = entry.Mode
+	}
+	assert.Equal(t, map[string]os.FileMode{"link": objects.ModeSymlink, "run.sh": objects.ModeExecutable}, modes)
+
+	tree, err := NewIndexToTreeConverter(ry.Index).Convert()
+	if err != nil {
+		t.Fatalf("could not convert index to tree: %v", err)
+	}
+
+	link, _ := tree.GetEntryByName("link")
+	assert.Equal(t, objects.ModeSymlink, link.Mode)
+	assert.Equal(t, objects.NewBlob([]byte("run.sh")).OID(), link.OID)
+
+	// without core.filemode the executable bit of the index is kept even if it is lost on disk
+	if err := ry.Config.Set("core", "filemode", "false"); err != nil {
+		t.Fatalf("could not set core.filemode: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "run.sh"), 0644); err != nil {
+		t.Fatalf("could not change mode: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "run.sh"), []byte("echo changed"), 0644); err != nil {
+		t.Fatalf("could not write file: %v", err)
+	}
+	if err := ry.Index.Set(filepath.Join(dir, "run.sh")); err != nil {
+		t.Fatalf("could not update index: %v", err)
+	}
+
+	entry, err := ry.Index.Find(filepath.Join(dir, "run.sh"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, objects.ModeExecutable, entry.Mode)
+	}
+}
+
+func TestInitProbesWorkingTreeModes(t *testing.T) {
+	ry := createTestRepository(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	modes, err := ry.WorkingTreeModes()
+	if assert.NoError(t, err) {
+		assert.True(t, modes.Executable)
+		assert.True(t, modes.Symlinks)
+	}
+
+	if err := ry.Config.Set("core", "symlinks", "maybe"); err != nil {
+		t.Fatalf("could not set core.symlinks: %v", err)
+	}
+	_, err = ry.WorkingTreeModes()
+	assert.Error(t, err)
+}
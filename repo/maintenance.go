@@ -0,0 +1,367 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/config"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/refs"
+	"github.com/furisto/gog/storage"
+	"time"
+)
+
+var (
+	ErrPackingNotSupported = errors.New("object store does not support pack files")
+	ErrPruningNotSupported = errors.New("object store does not support pruning of loose objects")
+)
+
+// DefaultPruneExpire is the grace period for unreachable objects if gc.pruneExpire is not configured
+const DefaultPruneExpire = "2.weeks.ago"
+
+type RepackOptions struct {
+	// Pack all reachable objects into a single pack instead of only the loose ones
+	All bool
+	// Remove loose objects and packs that became redundant
+	DeleteRedundant bool
+	// Skip objects that are borrowed from alternate object stores
+	Local bool
+	// Write a reachability bitmap for the new pack, only used together with All
+	WriteBitmap bool
+	// Unreachable objects of packs that are deleted are written as loose objects with the modification time of
+	// their pack instead of being dropped, so that they are only pruned once they expire. Only used together with
+	// All and DeleteRedundant.
+	UnpackUnreachable bool
+	Pack              storage.PackOptions
+}
+
+// Repack writes the objects that are reachable from refs or the index into a new pack file. It returns nil if
+// there was nothing to pack.
+func (ry *Repository) Repack(options RepackOptions) (*storage.Packfile, error) {
+	store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore)
+	if !ok {
+		return nil, ErrPackingNotSupported
+	}
+
+	roots, err := ry.rootTips()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make([]string, 0, len(roots))
+	for _, root := range roots {
+		tips = append(tips, root.oid)
+	}
+
+	reachable, err := objects.ReachableObjects(store, tips)
+	if err != nil {
+		return nil, err
+	}
+
+	oldPacks, err := store.Packs()
+	if err != nil {
+		return nil, err
+	}
+
+	looseObjects, err := store.LooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := reachable
+	if !options.All {
+		loose := make(map[string]bool, len(looseObjects))
+		for _, oid := range looseObjects {
+			loose[oid] = true
+		}
+
+		candidates = nil
+		for _, oid := range reachable {
+			if loose[oid] {
+				candidates = append(candidates, oid)
+			}
+		}
+	}
+
+	if options.Local {
+		var local []string
+		for _, oid := range candidates {
+			exists, err := store.StatLocal(oid)
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				local = append(local, oid)
+			}
+		}
+		candidates = local
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	pack, err := store.WritePack(candidates, options.Pack)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.All && options.WriteBitmap {
+		refTips, err := ry.refTips()
+		if err != nil {
+			return nil, err
+		}
+
+		bitmap, err := objects.BuildPackBitmap(store, pack, refTips)
+		if err != nil {
+			return nil, fmt.Errorf("could not write bitmap: %v", err)
+		}
+
+		if err := store.WritePackBitmap(bitmap); err != nil {
+			return nil, err
+		}
+	}
+
+	if !options.DeleteRedundant {
+		return pack, nil
+	}
+
+	if options.All {
+		for _, oldPack := range oldPacks {
+			if oldPack.Path() == pack.Path() {
+				continue
+			}
+
+			if options.UnpackUnreachable {
+				if err := unpackUnreachable(store, oldPack, pack); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := store.DeletePack(oldPack); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, oid := range looseObjects {
+		if !pack.Contains(oid) {
+			continue
+		}
+
+		if err := store.Delete(oid); err != nil {
+			return nil, err
+		}
+	}
+
+	return pack, nil
+}
+
+// unpackUnreachable loosens the objects of the old pack that are not part of the new pack
+func unpackUnreachable(store *storage.FilesystemStore, oldPack, newPack *storage.Packfile) error {
+	index := oldPack.Index()
+	for n := 0; n < index.Count(); n++ {
+		oid := index.OID(n)
+		if newPack.Contains(oid) {
+			continue
+		}
+
+		if err := store.Loosen(oldPack, oid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CountObjects reports the number and size of the objects in the repository. Only the number of objects is
+// known for stores that do not keep their objects on disk.
+func (ry *Repository) CountObjects() (*storage.ObjectCount, error) {
+	if store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore); ok {
+		return store.CountObjects()
+	}
+
+	oids, err := ry.Storage.Objects()
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.ObjectCount{Count: len(oids)}, nil
+}
+
+type PruneOptions struct {
+	// Only unreachable objects that were modified before this time are removed
+	Expire time.Time
+	// Report the objects that would be removed without removing them
+	DryRun bool
+}
+
+type PrunedObject struct {
+	OID  string
+	Type string
+}
+
+// Prune removes loose objects that are neither reachable from refs nor from the index and that are
+// older than the expiry date. Objects in packs are not touched.
+func (ry *Repository) Prune(options PruneOptions) ([]PrunedObject, error) {
+	store, ok := storage.Unwrap(ry.Storage).(*storage.FilesystemStore)
+	if !ok {
+		return nil, ErrPruningNotSupported
+	}
+
+	roots, err := ry.rootTips()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make([]string, 0, len(roots))
+	for _, root := range roots {
+		tips = append(tips, root.oid)
+	}
+
+	reachable, err := objects.ReachableObjects(ry.Storage, tips)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool, len(reachable))
+	for _, oid := range reachable {
+		keep[oid] = true
+	}
+
+	looseObjects, err := store.LooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []PrunedObject
+	for _, oid := range looseObjects {
+		if keep[oid] {
+			continue
+		}
+
+		stat, err := store.StatLoose(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		if !stat.ModTime().Before(options.Expire) {
+			continue
+		}
+
+		objectType, _, reader, err := store.Open(oid)
+		if err != nil {
+			return nil, err
+		}
+		reader.Close()
+
+		pruned = append(pruned, PrunedObject{OID: oid, Type: objectType})
+		if options.DryRun {
+			continue
+		}
+
+		if err := ry.deleteObject(store, oid); err != nil {
+			return nil, err
+		}
+	}
+
+	return pruned, nil
+}
+
+// PruneExpire returns the expiry date for unreachable objects as configured by gc.pruneExpire
+func (ry *Repository) PruneExpire(now time.Time) (time.Time, error) {
+	value, err := ry.Config.Get("gc", "pruneExpire")
+	if err != nil {
+		value = DefaultPruneExpire
+	}
+
+	expire, err := config.ParseExpiry(value, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid value '%s' for 'gc.pruneExpire'", value)
+	}
+
+	return expire, nil
+}
+
+// deleteObject removes the object through the configured store so that caches are invalidated
+func (ry *Repository) deleteObject(store *storage.FilesystemStore, oid string) error {
+	if deleter, ok := ry.Storage.(interface{ Delete(string) error }); ok {
+		return deleter.Delete(oid)
+	}
+
+	return store.Delete(oid)
+}
+
+// rootTips returns the objects that are referenced by refs and by the index
+func (ry *Repository) rootTips() ([]refTip, error) {
+	roots, err := ry.namedRefTips()
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := ry.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range index.Entries() {
+		if objects.CanonicalMode(entry.Mode) == objects.ModeGitlink {
+			continue
+		}
+		roots = append(roots, refTip{name: "index entry " + entry.Path, oid: entry.OID})
+	}
+
+	return roots, nil
+}
+
+// refTip is an object id that a ref points to
+type refTip struct {
+	name string
+	oid  string
+}
+
+// refTips returns the object ids that HEAD and the refs below refs/ point to
+func (ry *Repository) refTips() ([]string, error) {
+	named, err := ry.namedRefTips()
+	if err != nil {
+		return nil, err
+	}
+
+	tips := make([]string, 0, len(named))
+	for _, tip := range named {
+		tips = append(tips, tip.oid)
+	}
+
+	return tips, nil
+}
+
+// namedRefTips returns the object ids that HEAD and the refs below refs/ point to together with the name
+// of the ref
+func (ry *Repository) namedRefTips() ([]refTip, error) {
+	var candidates []*refs.Ref
+	if head, err := ry.Head(false); err == nil {
+		candidates = append(candidates, head)
+	} else if err != refs.ErrRefNotExist {
+		return nil, err
+	}
+
+	// every namespace counts, e.g. notes of other notes refs or refs/stash
+	candidates = append(candidates, ry.Refs.List(refs.RefPattern)...)
+
+	var tips []refTip
+	for _, ref := range candidates {
+		resolved, err := ry.Refs.Resolve(ref)
+		if err != nil {
+			if err == refs.ErrRefNotExist {
+				continue
+			}
+			return nil, err
+		}
+
+		if resolved.RefValue != "" {
+			tips = append(tips, refTip{name: ref.Name, oid: resolved.RefValue})
+		}
+	}
+
+	return tips, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/plumbing/refs"
 	"github.com/furisto/gog/storage"
+	"strings"
 )
 
 const commitMessageForNotes = "Notes added by 'git notes add'"
@@ -32,7 +33,12 @@ func (n *Notes) Create(message, commitOid string, force bool) (*Note, error) {
 		return nil, err
 	}
 
-	if !objects.IsCommit(commitData) {
+	object, err := objects.DecodeWithFormat(commitOid, commitData, n.store.ObjectFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	if objects.TypeOf(object) != objects.CommitObject {
 		return nil, fmt.Errorf("notes only support commits")
 	}
 
@@ -42,7 +48,7 @@ func (n *Notes) Create(message, commitOid string, force bool) (*Note, error) {
 	}
 
 	var oldNoteHead *objects.Commit
-	treeBuilder := objects.NewTreeBuilder()
+	treeBuilder := objects.NewTreeBuilder().WithObjectFormat(n.store.ObjectFormat())
 	if noteRef != nil {
 		noteRef, err = n.refMgr.Resolve(noteRef)
 		if err != nil {
@@ -63,7 +69,7 @@ func (n *Notes) Create(message, commitOid string, force bool) (*Note, error) {
 		}
 	}
 
-	messageBlob := objects.NewBlob([]byte(message))
+	messageBlob := objects.NewBlobWithFormat([]byte(message), n.store.ObjectFormat())
 	treeBuilder.AddBlob(messageBlob.OID(), commitOid, 0o100644)
 	noteTree := treeBuilder.Build()
 
@@ -75,6 +81,7 @@ func (n *Notes) Create(message, commitOid string, force bool) (*Note, error) {
 	}
 
 	cb := objects.NewCommitBuilder(noteTree.OID()).
+		WithObjectFormat(n.store.ObjectFormat()).
 		WithConfig(n.config).
 		WithMessage(commitMessageForNotes)
 	if oldNoteHead != nil {
@@ -110,7 +117,7 @@ func (n *Notes) Append(commitOid, message string) (*Note, error) {
 		return nil, err
 	}
 
-	treeBuilder := objects.NewTreeBuilder()
+	treeBuilder := objects.NewTreeBuilder().WithObjectFormat(n.store.ObjectFormat())
 	var targetEntry *objects.TreeEntry
 	for _, entry := range noteTree.Entries() {
 		if entry.Name == commitOid {
@@ -130,12 +137,12 @@ func (n *Notes) Append(commitOid, message string) (*Note, error) {
 		return nil, err
 	}
 
-	oldBlob, err := objects.LoadBlob(oldBlobData)
+	oldBlob, err := objects.LoadBlobWithFormat(oldBlobData, n.store.ObjectFormat())
 	if err != nil {
 		return nil, err
 	}
 
-	modifiedBlob := objects.NewBlob(append(oldBlob.Content, "\n\n"+message...))
+	modifiedBlob := objects.NewBlobWithFormat(append(oldBlob.Content, "\n\n"+message...), n.store.ObjectFormat())
 	if err := modifiedBlob.Save(n.store); err != nil {
 		return nil, err
 	}
@@ -147,6 +154,7 @@ func (n *Notes) Append(commitOid, message string) (*Note, error) {
 	}
 
 	newNoteHead, err := objects.NewCommitBuilder(modifiedNodeTree.OID()).
+		WithObjectFormat(n.store.ObjectFormat()).
 		WithConfig(n.config).
 		WithMessage(commitMessageForNotes).
 		WithParent(oldNoteHead.RefValue).
@@ -166,21 +174,26 @@ func (n *Notes) Append(commitOid, message string) (*Note, error) {
 }
 
 func (n *Notes) List(objectRef string) ([]*Note, error) {
-	noteTree, err := n.retrieveNoteHeadTree()
+	noteHead, err := n.retrieveNoteHead()
 	if err != nil {
 		return nil, err
 	}
 
+	// notes trees with many notes are fanned out into directories like "ab/cdef...", the path without
+	// slashes is the id of the annotated object
 	var notes []*Note
-	for _, entry := range noteTree.Entries() {
-		if objectRef != "" && objectRef != entry.Name {
-			continue
+	err = objects.NewTreeWalker(n.store).Walk(noteHead.Tree, func(entry objects.WalkEntry) error {
+		objectOid := strings.ReplaceAll(entry.Path, "/", "")
+		if entry.IsTree() || (objectRef != "" && objectRef != objectOid) {
+			return nil
 		}
-		n := newNote(entry.Name, entry.OID, n.store)
+
+		n := newNote(objectOid, entry.OID, n.store)
 		notes = append(notes, &n)
-	}
+		return nil
+	})
 
-	return notes, nil
+	return notes, err
 }
 
 func (n *Notes) Copy(sourceCommit, targetCommit string, force bool) (*Note, error) {
@@ -208,7 +221,7 @@ func (n *Notes) Copy(sourceCommit, targetCommit string, force bool) (*Note, erro
 		return nil, err
 	}
 
-	sourceBlob, err := objects.LoadBlob(sourceBlobData)
+	sourceBlob, err := objects.LoadBlobWithFormat(sourceBlobData, n.store.ObjectFormat())
 	if err != nil {
 		return nil, err
 	}
@@ -222,19 +235,12 @@ func (n *Notes) Find(commitOid string) (*Note, error) {
 		return nil, nil
 	}
 
-	noteTree, err := n.retrieveNoteHeadTree()
-	if err != nil {
+	notes, err := n.List(commitOid)
+	if err != nil || len(notes) == 0 {
 		return nil, err
 	}
 
-	for _, entry := range noteTree.Entries() {
-		if entry.Name == commitOid {
-			note := newNote(entry.Name, entry.OID, n.store)
-			return &note, nil
-		}
-	}
-
-	return nil, nil
+	return notes[0], nil
 }
 
 func (n *Notes) Remove(commitOid string) error {
@@ -248,7 +254,7 @@ func (n *Notes) Remove(commitOid string) error {
 		return err
 	}
 
-	treeBuilder := objects.NewTreeBuilder()
+	treeBuilder := objects.NewTreeBuilder().WithObjectFormat(n.store.ObjectFormat())
 	noteExists := false
 	for _, entry := range noteTree.Entries() {
 		if entry.Name == commitOid {
@@ -259,11 +265,12 @@ func (n *Notes) Remove(commitOid string) error {
 	}
 
 	if !noteExists {
-		return fmt.Errorf("object %s has no note")
+		return fmt.Errorf("object %s has no note", commitOid)
 	}
 
 	newNoteTree := treeBuilder.Build()
 	newNoteHead, err := objects.NewCommitBuilder(newNoteTree.OID()).
+		WithObjectFormat(n.store.ObjectFormat()).
 		WithMessage("notes").
 		WithParent(noteHeadRef.RefValue).
 		Build()
@@ -303,7 +310,7 @@ func (n *Notes) retrieveNoteHeadTree() (*objects.Tree, error) {
 		return nil, err
 	}
 
-	return objects.LoadTree(noteTreeData)
+	return objects.LoadTreeWithFormat(noteTreeData, n.store.ObjectFormat())
 }
 
 func (n *Notes) retrieveNoteHead() (*objects.Commit, error) {
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"github.com/furisto/gog/config"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/refs"
+	"github.com/furisto/gog/storage"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNotesInFanoutDirectories(t *testing.T) {
+	store := storage.NewMemoryStore()
+	refMgr := refs.NewMemoryRefManager()
+
+	annotated := "0123456789012345678901234567890123456789"
+	message := objects.NewBlob([]byte("note"))
+	fanout := objects.NewTree([]objects.TreeEntry{{Mode: objects.ModeRegular, Name: annotated[2:], OID: message.OID()}})
+	root := objects.NewTree([]objects.TreeEntry{{Mode: objects.ModeTree, Name: annotated[:2], OID: fanout.OID()}})
+	commit, err := objects.NewCommitBuilder(root.OID()).
+		WithAuthor("furisto", "furisto@test.com").
+		WithCommitter("furisto", "furisto@test.com").
+		WithMessage(commitMessageForNotes).
+		Build()
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	for _, object := range []objects.Object{message, fanout, root, commit} {
+		if err := object.Save(store); err != nil {
+			t.Fatalf("could not save object: %v", err)
+		}
+	}
+	if _, err := refMgr.Set("refs/notes/commits", commit.OID()); err != nil {
+		t.Fatalf("could not set notes ref: %v", err)
+	}
+
+	cfg := config.NewInMemoryConfig()
+	notes := NewNotes(refMgr, store, &cfg, "commits")
+	list, err := notes.List("")
+	if assert.NoError(t, err) && assert.Len(t, list, 1) {
+		assert.Equal(t, annotated, list[0].CommitOID)
+		assert.Equal(t, message.OID(), list[0].MessageOID)
+	}
+
+	note, err := notes.Find(annotated)
+	if assert.NoError(t, err) && assert.NotNil(t, note) {
+		assert.Equal(t, message.OID(), note.MessageOID)
+	}
+}
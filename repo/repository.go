@@ -1,18 +1,26 @@
 package repo
 
 import (
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"github.com/furisto/gog/config"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/plumbing/refs"
 	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
 	"io/ioutil"
+	"math/bits"
 	"os"
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
+// ErrUnknownRevision is returned if a name is neither a ref nor something that could be an abbreviated object id
+var ErrUnknownRevision = errors.New("unknown revision")
+
 var defaultConfigValues = map[string]string{
 	"repositoryformatversion": "0",
 	"filemode":                "false",
@@ -58,7 +66,7 @@ func Init(path string, bare bool, storage storage.ObjectStore, refs refs.RefMana
 	}
 
 	for _, directory := range directories {
-		if err := os.MkdirAll(filepath.Join(gitDir, directory), os.ModeDir); err != nil {
+		if err := os.MkdirAll(filepath.Join(gitDir, directory), 0777); err != nil {
 			return nil, err
 		}
 	}
@@ -69,28 +77,88 @@ func Init(path string, bare bool, storage storage.ObjectStore, refs refs.RefMana
 		}
 	}
 
-	cfg, err := createConfig(
-		filepath.Join(gitDir, "config"), map[string]string{"bare": strconv.FormatBool(bare)})
+	values := probeWorkingTreeModes(gitDir)
+	values["bare"] = strconv.FormatBool(bare)
+	cfg, err := createConfig(filepath.Join(gitDir, "config"), values)
 	if err != nil {
 		return nil, err
 	}
 
+	// repositories that do not use sha1 need format version 1, otherwise the extension is ignored
+	if format := storage.ObjectFormat(); format != util.SHA1 {
+		if err := cfg.Set("core", "repositoryformatversion", "1"); err != nil {
+			return nil, err
+		}
+
+		if err := cfg.Set("extensions", "objectformat", format.String()); err != nil {
+			return nil, err
+		}
+	}
+
 	repo := NewRepo(workingDir, gitDir, storage, cfg, refs)
 	return repo, nil
 }
 
+// probeWorkingTreeModes checks if the file system keeps the executable bit and supports symlinks, the results are
+// stored as core.filemode and core.symlinks like git init does
+func probeWorkingTreeModes(gitDir string) map[string]string {
+	values := make(map[string]string)
+
+	probe := filepath.Join(gitDir, "config.probe")
+	if err := ioutil.WriteFile(probe, nil, 0644); err == nil {
+		if err := os.Chmod(probe, 0755); err == nil {
+			if stat, err := os.Lstat(probe); err == nil {
+				values["filemode"] = strconv.FormatBool(stat.Mode()&0100 != 0)
+			}
+		}
+
+		link := probe + ".link"
+		values["symlinks"] = strconv.FormatBool(os.Symlink(probe, link) == nil)
+		os.Remove(link)
+		os.Remove(probe)
+	}
+
+	return values
+}
+
 func InitDefault(path string, bare bool) (*Repository, error) {
+	return InitDefaultWithFormat(path, bare, util.DefaultObjectFormat)
+}
+
+// InitDefaultWithFormat creates a repository whose object ids are computed with the given hash algorithm
+func InitDefaultWithFormat(path string, bare bool, format util.ObjectFormat) (*Repository, error) {
 	gitPath := createGitPaths(path, bare)
-	repo, err := Init(path, bare, storage.NewFsStore(gitPath), refs.NewGitRefManager(gitPath))
+	repo, err := Init(path, bare, storage.NewFsStoreWithFormat(gitPath, format), refs.NewGitRefManager(gitPath))
 	return repo, err
 }
 
+// InitInMemory creates a bare repository whose objects, refs and configuration are only kept in memory
+func InitInMemory() (*Repository, error) {
+	refMgr := refs.NewMemoryRefManager()
+	if _, err := refMgr.Set("HEAD", "ref: refs/heads/master"); err != nil {
+		return nil, err
+	}
+
+	cfg := config.NewInMemoryConfig()
+	for k, v := range defaultConfigValues {
+		if err := cfg.Set("core", k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Set("core", "bare", "true"); err != nil {
+		return nil, err
+	}
+
+	return NewRepo("", "", storage.NewMemoryStore(), &cfg, refMgr), nil
+}
+
 func NewRepo(workingDir string, gitDir string, store storage.ObjectStore, cfg config.Config, refs refs.RefManager) *Repository {
 	r := &Repository{
 		gitDir:     gitDir,
 		workingDir: workingDir,
 		Storage:    store,
-		Index:      NewIndex(workingDir, gitDir, store),
+		Index:      NewIndex(workingDir, gitDir, store, cfg),
 		Config:     cfg,
 		Refs:       refs,
 		Branches:   NewBranches(refs),
@@ -126,9 +194,281 @@ func FromExisting(path string) (*Repository, error) {
 		return nil, err
 	}
 
+	repoCfg := cfg.Build()
+	cacheLimit, err := objectCacheLimit(repoCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := objectFormat(repoCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := sharedPermissions(repoCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	looseCompression, err := compressionLevel(repoCfg, "core", "looseCompression", zlib.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+
+	fsStore := storage.NewFsStoreWithFormat(gitDir, format)
+	fsStore.SetSharedPermissions(shared)
+	if err := fsStore.SetCompression(looseCompression); err != nil {
+		return nil, err
+	}
+
 	refMgr := refs.NewGitRefManager(gitDir)
+	store := storage.NewCachedStore(fsStore, cacheLimit)
+
+	return NewRepo(workingDir, gitDir, store, repoCfg, refMgr), nil
+}
+
+// objectCacheLimit returns the memory budget of the object cache as configured by core.deltaBaseCacheLimit
+func objectCacheLimit(cfg config.Config) (int64, error) {
+	value, err := cfg.Get("core", "deltaBaseCacheLimit")
+	if err != nil {
+		return storage.DefaultCacheLimit, nil
+	}
+
+	limit, err := config.ParseSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s' for 'core.deltaBaseCacheLimit'", value)
+	}
+
+	return limit, nil
+}
+
+// PackOptions returns the options for writing packs as configured by pack.window, pack.depth and
+// pack.compression
+func (ry *Repository) PackOptions() (storage.PackOptions, error) {
+	options := storage.DefaultPackOptions()
+	level, err := compressionLevel(ry.Config, "pack", "compression", zlib.DefaultCompression)
+	if err != nil {
+		return storage.PackOptions{}, err
+	}
+	options.Compression = level
+
+	for key, value := range map[string]*int{"window": &options.Window, "depth": &options.Depth} {
+		configured, err := ry.Config.Get("pack", key)
+		if err != nil {
+			continue
+		}
+
+		number, err := strconv.Atoi(configured)
+		if err != nil || number < 0 {
+			return storage.PackOptions{}, fmt.Errorf("bad numeric config value '%s' for 'pack.%s'", configured, key)
+		}
+		*value = number
+	}
+
+	return options, nil
+}
+
+// compressionLevel returns the zlib level configured by the key. If the key is not set, core.compression and
+// then the fallback are used, see https://git-scm.com/docs/git-config#Documentation/git-config.txt-corecompression
+func compressionLevel(cfg config.Config, section, key string, fallback int) (int, error) {
+	for _, option := range [][2]string{{section, key}, {"core", "compression"}} {
+		value, err := cfg.Get(option[0], option[1])
+		if err != nil {
+			continue
+		}
+
+		level, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("bad numeric config value '%s' for '%s.%s'", value, option[0], option[1])
+		}
+
+		if level < zlib.DefaultCompression || level > zlib.BestCompression {
+			return 0, fmt.Errorf("bad zlib compression level %d", level)
+		}
+
+		return level, nil
+	}
+
+	return fallback, nil
+}
+
+// sharedPermissions returns the permissions of files in the object store as configured by core.sharedRepository,
+// see https://git-scm.com/docs/git-config#Documentation/git-config.txt-coresharedRepository
+func sharedPermissions(cfg config.Config) (storage.SharedPermissions, error) {
+	value, err := cfg.Get("core", "sharedRepository")
+	if err != nil {
+		return storage.SharedPermissions{}, nil
+	}
+
+	switch strings.ToLower(value) {
+	case "", "umask", "false", "no", "off", "0":
+		return storage.SharedPermissions{}, nil
+	case "group", "true", "yes", "on", "1":
+		return storage.SharedPermissions{Perm: 0660}, nil
+	case "all", "world", "everybody", "2":
+		return storage.SharedPermissions{Perm: 0664}, nil
+	}
+
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || perm&^0777 != 0 {
+		return storage.SharedPermissions{}, fmt.Errorf("bad config value '%s' for 'core.sharedRepository'", value)
+	}
+
+	if perm&0600 != 0600 {
+		return storage.SharedPermissions{}, fmt.Errorf("problem with core.sharedRepository filemode value (0%03o), "+
+			"the owner of files must always have read and write permissions", perm)
+	}
+
+	return storage.SharedPermissions{Perm: os.FileMode(perm), Replace: true}, nil
+}
+
+// WorkingTreeModes returns which modes of files in the working tree are trusted as configured by core.filemode and
+// core.symlinks, both are trusted if they are not configured
+func (ry *Repository) WorkingTreeModes() (objects.WorkingTreeModes, error) {
+	return workingTreeModes(ry.Config)
+}
+
+// RecordedModes returns the modes that the index, or HEAD for files that are not staged, records for the files
+// below the directory. They are keyed by the slash separated path of the file relative to the directory.
+func (ry *Repository) RecordedModes(dir string) (map[string]os.FileMode, error) {
+	base, err := filepath.Rel(ry.Info.WorkingDirectory(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if base = filepath.ToSlash(base); base != "." {
+		prefix = base + "/"
+	}
+
+	recorded := make(map[string]os.FileMode)
+	record := func(path string, mode os.FileMode) {
+		if strings.HasPrefix(path, prefix) {
+			recorded[strings.TrimPrefix(path, prefix)] = mode
+		}
+	}
+
+	head, err := ry.Head(false)
+	if err != nil {
+		return nil, err
+	}
+	if commitRef, err := ry.Refs.Resolve(head); err == nil {
+		commit, err := ry.LookupCommit(commitRef.RefValue)
+		if err != nil {
+			return nil, err
+		}
+
+		err = objects.NewTreeWalker(ry.Storage).Walk(commit.Tree, func(entry objects.WalkEntry) error {
+			if !entry.IsTree() {
+				record(entry.Path, entry.Mode)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else if err != refs.ErrRefNotExist {
+		return nil, err
+	}
+
+	index, err := ry.currentIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range index.Entries() {
+		record(entry.Path, entry.Mode)
+	}
+
+	return recorded, nil
+}
+
+// currentIndex returns the index of the git directory, or the in memory index if there is none
+func (ry *Repository) currentIndex() (*Index, error) {
+	indexPath := filepath.Join(ry.gitDir, "index")
+	if _, err := os.Stat(indexPath); ry.gitDir == "" || err != nil {
+		return ry.Index, nil
+	}
+
+	index, err := DecodeIndexWithFormat(indexPath, ry.Storage.ObjectFormat())
+	if err != nil {
+		return nil, fmt.Errorf("index file corrupt: %v", err)
+	}
+	return index, nil
+}
+
+func workingTreeModes(cfg config.Config) (objects.WorkingTreeModes, error) {
+	modes := objects.DefaultWorkingTreeModes
+	for key, trusted := range map[string]*bool{"filemode": &modes.Executable, "symlinks": &modes.Symlinks} {
+		value, err := cfg.Get("core", key)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(value) {
+		case "", "false", "no", "off", "0":
+			*trusted = false
+		case "true", "yes", "on", "1":
+			*trusted = true
+		default:
+			return objects.WorkingTreeModes{}, fmt.Errorf("bad boolean config value '%s' for 'core.%s'", value, key)
+		}
+	}
+
+	return modes, nil
+}
+
+// Abbrev returns the length of abbreviated object ids as configured by core.abbrev. With the default "auto" the
+// length grows with the number of objects so that abbreviations are likely to stay unique,
+// see https://git-scm.com/docs/git-config#Documentation/git-config.txt-coreabbrev
+func (ry *Repository) Abbrev() (int, error) {
+	hexLength := 2 * ry.Storage.ObjectFormat().Size()
+	value, err := ry.Config.Get("core", "abbrev")
+	if err != nil {
+		value = "auto"
+	}
 
-	return NewRepo(workingDir, gitDir, storage.NewFsStore(gitDir), cfg.Build(), refMgr), nil
+	switch strings.ToLower(value) {
+	case "auto":
+		count, err := ry.CountObjects()
+		if err != nil {
+			return 0, err
+		}
+
+		// a collision is expected once there are about 2^(n/2) objects, every hex digit holds 4 bits
+		length := (bits.Len(uint(count.Count+count.InPack)) + 1) / 2
+		if length < objects.DefaultAbbrev {
+			length = objects.DefaultAbbrev
+		}
+		return length, nil
+	case "false", "no", "off":
+		return hexLength, nil
+	}
+
+	length, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("bad numeric config value '%s' for 'core.abbrev'", value)
+	}
+
+	if length < storage.MinAbbrev || length > hexLength {
+		return 0, fmt.Errorf("abbrev length out of range: %d", length)
+	}
+
+	return length, nil
+}
+
+// objectFormat returns the hash algorithm configured by extensions.objectformat, which is only
+// recognized in repositories with format version 1
+func objectFormat(cfg config.Config) (util.ObjectFormat, error) {
+	name, err := cfg.Get("extensions", "objectformat")
+	if err != nil {
+		return util.DefaultObjectFormat, nil
+	}
+
+	if version, err := cfg.Get("core", "repositoryformatversion"); err != nil || version == "0" {
+		return 0, fmt.Errorf("repo version is 0, but v1-only extension found: objectformat")
+	}
+
+	return util.ParseObjectFormat(name)
 }
 
 func isGitRepository(path string) RepositoryType {
@@ -149,17 +489,17 @@ func isGitRepository(path string) RepositoryType {
 // see https://github.com/git/git/blob/e31aba42fb12bdeb0f850829e008e1e3f43af500/setup.c#L328-L394
 func isGitDirectory(path string) bool {
 	headPath := filepath.Join(path, "HEAD")
-	if _, err := os.Stat(headPath); os.IsNotExist(err) {
+	if _, err := os.Stat(headPath); err != nil {
 		return false
 	}
 
 	objectsPath := filepath.Join(path, "objects")
-	if _, err := os.Stat(objectsPath); os.IsNotExist(err) {
+	if _, err := os.Stat(objectsPath); err != nil {
 		return false
 	}
 
 	refsPath := filepath.Join(path, "refs")
-	if _, err := os.Stat(refsPath); os.IsNotExist(err) {
+	if _, err := os.Stat(refsPath); err != nil {
 		return false
 	}
 
@@ -212,13 +552,67 @@ func (ry *Repository) Head(resolve bool) (*refs.Ref, error) {
 	return ref, nil
 }
 
+// ResolveRevision returns the object id that a ref like HEAD, a branch or tag name or a possibly abbreviated
+// object id refers to
+func (ry *Repository) ResolveRevision(name string) (string, error) {
+	if ref, err := ry.Refs.Find(name); err == nil {
+		resolved, err := ry.Refs.Resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		return resolved.RefValue, nil
+	}
+
+	// names like v2 are missing refs, complaining that they are too short to be an object id would be misleading
+	if !isObjectName(name) {
+		return "", fmt.Errorf("%w %s", ErrUnknownRevision, name)
+	}
+
+	return ry.ResolveObjectName(name)
+}
+
+// isObjectName reports whether the name is a possibly abbreviated object id, optionally followed by ^{type}
+func isObjectName(name string) bool {
+	if start := strings.Index(name, "^{"); start >= 0 && strings.HasSuffix(name, "}") {
+		name = name[:start]
+	}
+
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ResolveObjectName returns the full id of the object that the possibly abbreviated name refers to, see
+// objects.ResolveObjectName. Core.abbrev is only evaluated if the name is ambiguous.
+func (ry *Repository) ResolveObjectName(name string) (string, error) {
+	return objects.ResolveObjectName(ry.Storage, name, ry.Abbrev)
+}
+
 func (ry *Repository) SetHead(ref string) error {
 	_, err := ry.Refs.Set("HEAD", ref)
 	return err
 }
 
 func (ry *Repository) Commit(configure func(builder *objects.CommitBuilder) *objects.CommitBuilder) (*objects.Commit, error) {
-	tree, err := objects.NewTreeFromDirectory(ry.Info.WorkingDirectory(), "")
+	modes, err := ry.WorkingTreeModes()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := ry.RecordedModes(ry.Info.WorkingDirectory())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := objects.NewTreeFromDirectoryWithModes(ry.Info.WorkingDirectory(), "", ry.Storage.ObjectFormat(), modes, recorded)
 	if err != nil {
 		return nil, err
 	}
@@ -250,6 +644,7 @@ func (ry *Repository) Commit(configure func(builder *objects.CommitBuilder) *obj
 	}
 
 	builder := objects.NewCommitBuilder(tree.OID()).
+		WithObjectFormat(ry.Storage.ObjectFormat()).
 		WithConfig(ry.Config).
 		WithParent(parentRef.RefValue)
 
@@ -1,6 +1,14 @@
 package repo
 
 import (
+	"compress/zlib"
+	"errors"
+	"github.com/furisto/gog/config"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/storage"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -40,3 +48,273 @@ func AssertFsObjectExists(t *testing.T, path string) {
 		t.Errorf("Does not exist %v", path)
 	}
 }
+
+func TestCommitInMemoryRepository(t *testing.T) {
+	ry, err := InitInMemory()
+	if err != nil {
+		t.Fatalf("could not create in memory repository: %v", err)
+	}
+
+	unborn, err := ry.Info.IsHeadUnborn()
+	if err != nil || !unborn {
+		t.Fatalf("expected head of new repository to be unborn")
+	}
+
+	blob := objects.NewBlob([]byte("in memory"))
+	if err := blob.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save blob: %v", err)
+	}
+
+	tb := objects.NewTreeBuilder()
+	tb.AddBlob(blob.OID(), "file", 0o100644)
+	tree := tb.Build()
+	if err := tree.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save tree: %v", err)
+	}
+
+	commit, err := objects.NewCommitBuilder(tree.OID()).
+		WithAuthor("furisto", "furisto@test.com").
+		WithCommitter("furisto", "furisto@test.com").
+		WithMessage("in memory").
+		Build()
+	if err != nil {
+		t.Fatalf("could not build commit: %v", err)
+	}
+
+	if err := commit.Save(ry.Storage); err != nil {
+		t.Fatalf("could not save commit: %v", err)
+	}
+
+	if _, err := ry.Branches.Create("master", commit.OID()); err != nil {
+		t.Fatalf("could not create branch: %v", err)
+	}
+
+	head, err := ry.Head(true)
+	if err != nil {
+		t.Fatalf("could not resolve head: %v", err)
+	}
+
+	loaded, err := LoadCommit(ry.Storage, head.RefValue)
+	if err != nil {
+		t.Fatalf("could not load commit: %v", err)
+	}
+
+	if loaded.Tree != tree.OID() {
+		t.Errorf("expected tree %s, but was %s", tree.OID(), loaded.Tree)
+	}
+}
+
+func TestSha256RepositoryRoundTrip(t *testing.T) {
+	repoPath := createTemporaryDir(t)
+	defer os.RemoveAll(repoPath)
+
+	if _, err := InitDefaultWithFormat(repoPath, false, util.SHA256); err != nil {
+		t.Fatalf("could not create repository: %v", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(repoPath, "file"), []byte("content"), 0644); err != nil {
+		t.Fatalf("could not create file: %v", err)
+	}
+
+	ry, err := FromExisting(repoPath)
+	if err != nil {
+		t.Fatalf("could not open repository: %v", err)
+	}
+
+	if ry.Storage.ObjectFormat() != util.SHA256 {
+		t.Fatalf("expected object format sha256, but was %v", ry.Storage.ObjectFormat())
+	}
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("sha256")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	if len(commit.OID()) != util.SHA256.HexSize() {
+		t.Errorf("expected object id of length %d, but was %s", util.SHA256.HexSize(), commit.OID())
+	}
+
+	loaded, err := LoadCommit(ry.Storage, commit.OID())
+	if err != nil {
+		t.Fatalf("could not load commit: %v", err)
+	}
+
+	tree, err := LoadTreeFromCommit(ry.Storage, loaded)
+	if err != nil {
+		t.Fatalf("could not load tree: %v", err)
+	}
+
+	entry, ok := tree.GetEntryByName("file")
+	if !ok {
+		t.Fatalf("tree does not contain file")
+	}
+
+	// git hash-object --object-format=sha256 of "content"
+	expected := "992696de5972f6d97df477db0b28da5c63519ab737c22fb21ff97750997f5888"
+	blob, err := LoadBlob(ry.Storage, entry.OID)
+	if err != nil {
+		t.Fatalf("could not load blob: %v", err)
+	}
+	if blob.OID() != expected {
+		t.Errorf("expected blob %s, but was %s", expected, blob.OID())
+	}
+}
+
+func TestAbbrevHonorsCoreAbbrev(t *testing.T) {
+	ry, err := InitInMemory()
+	if err != nil {
+		t.Fatalf("could not create in memory repository: %v", err)
+	}
+
+	tests := []struct {
+		value    string
+		expected int
+		err      bool
+	}{
+		{value: "auto", expected: objects.DefaultAbbrev},
+		{value: "12", expected: 12},
+		{value: "no", expected: 40},
+		{value: "3", err: true},
+		{value: "41", err: true},
+		{value: "abc", err: true},
+	}
+
+	for _, test := range tests {
+		if err := ry.Config.Set("core", "abbrev", test.value); err != nil {
+			t.Fatalf("could not set core.abbrev: %v", err)
+		}
+
+		abbrev, err := ry.Abbrev()
+		if test.err {
+			if err == nil {
+				t.Errorf("expected error for core.abbrev=%s", test.value)
+			}
+			continue
+		}
+
+		if err != nil || abbrev != test.expected {
+			t.Errorf("core.abbrev=%s: expected %d, got %d (%v)", test.value, test.expected, abbrev, err)
+		}
+	}
+}
+
+func TestResolveRevisionReportsUnknownRevisions(t *testing.T) {
+	ry, err := InitInMemory()
+	if err != nil {
+		t.Fatalf("could not create in memory repository: %v", err)
+	}
+
+	_, err = ry.ResolveRevision("v2")
+	assert.True(t, errors.Is(err, ErrUnknownRevision), "unexpected error: %v", err)
+	assert.EqualError(t, err, "unknown revision v2")
+
+	_, err = ry.ResolveRevision("ab")
+	assert.False(t, errors.Is(err, ErrUnknownRevision), "unexpected error: %v", err)
+	assert.Contains(t, err.Error(), "too short")
+}
+
+func TestCompressionLevelFallsBackToCoreCompression(t *testing.T) {
+	cfg := config.NewInMemoryConfig()
+
+	level, err := compressionLevel(&cfg, "core", "looseCompression", zlib.BestSpeed)
+	assert.NoError(t, err)
+	assert.Equal(t, zlib.BestSpeed, level, "default without configuration")
+
+	assert.NoError(t, cfg.Set("core", "compression", "9"))
+	level, err = compressionLevel(&cfg, "pack", "compression", zlib.DefaultCompression)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, level, "core.compression is the fallback")
+
+	assert.NoError(t, cfg.Set("pack", "compression", "0"))
+	level, err = compressionLevel(&cfg, "pack", "compression", zlib.DefaultCompression)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, level, "pack.compression takes precedence")
+
+	assert.NoError(t, cfg.Set("pack", "compression", "12"))
+	_, err = compressionLevel(&cfg, "pack", "compression", zlib.DefaultCompression)
+	assert.Error(t, err)
+}
+
+func TestPackOptionsHonorPackWindowAndDepth(t *testing.T) {
+	ry, err := InitInMemory()
+	if err != nil {
+		t.Fatalf("could not create in memory repository: %v", err)
+	}
+
+	options, err := ry.PackOptions()
+	if assert.NoError(t, err) {
+		assert.Equal(t, storage.DefaultPackWindow, options.Window)
+		assert.Equal(t, storage.DefaultPackDepth, options.Depth)
+	}
+
+	assert.NoError(t, ry.Config.Set("pack", "window", "20"))
+	assert.NoError(t, ry.Config.Set("pack", "depth", "0"))
+	options, err = ry.PackOptions()
+	if assert.NoError(t, err) {
+		assert.Equal(t, 20, options.Window)
+		assert.Equal(t, 0, options.Depth)
+	}
+
+	assert.NoError(t, ry.Config.Set("pack", "depth", "deep"))
+	_, err = ry.PackOptions()
+	assert.Error(t, err)
+}
+
+func TestCommitKeepsRecordedModesOfUntrustedFiles(t *testing.T) {
+	ry := createTestRepository(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	dir := ry.Info.WorkingDirectory()
+	if err := os.Mkdir(filepath.Join(dir, "bin"), 0755); err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	for _, name := range []string{"run.sh", filepath.Join("bin", "tool")} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("echo"), 0755); err != nil {
+			t.Fatalf("could not write file: %v", err)
+		}
+	}
+
+	commit := func() *objects.Tree {
+		commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+			return builder.WithMessage("modes")
+		})
+		if err != nil {
+			t.Fatalf("could not create commit: %v", err)
+		}
+
+		tree, err := LoadTreeFromCommit(ry.Storage, commit)
+		if err != nil {
+			t.Fatalf("could not load tree: %v", err)
+		}
+		return tree
+	}
+
+	if err := ry.Config.Set("core", "filemode", "true"); err != nil {
+		t.Fatalf("could not set core.filemode: %v", err)
+	}
+	commit()
+
+	// the executable bit is lost on disk, but HEAD records it
+	if err := ry.Config.Set("core", "filemode", "false"); err != nil {
+		t.Fatalf("could not set core.filemode: %v", err)
+	}
+	for _, name := range []string{"run.sh", filepath.Join("bin", "tool")} {
+		if err := os.Chmod(filepath.Join(dir, name), 0644); err != nil {
+			t.Fatalf("could not change mode: %v", err)
+		}
+	}
+
+	tree := commit()
+	script, _ := tree.GetEntryByName("run.sh")
+	assert.Equal(t, objects.ModeExecutable, script.Mode)
+
+	bin, _ := tree.GetEntryByName("bin")
+	subtree, err := ry.LookupTree(bin.OID)
+	if err != nil {
+		t.Fatalf("could not load tree: %v", err)
+	}
+	tool, _ := subtree.GetEntryByName("tool")
+	assert.Equal(t, objects.ModeExecutable, tool.Mode)
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"fmt"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/sshsig"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SignatureStatus is the outcome of verifying a signature
+type SignatureStatus int8
+
+const (
+	// GoodSignature is a valid signature made with a key of the allowed signers
+	GoodSignature SignatureStatus = iota
+	// UnknownSigner is a valid signature made with a key that is not one of the allowed signers
+	UnknownSigner
+	// BadSignature is a signature that does not match the signed data
+	BadSignature
+)
+
+// SignatureCheck describes the result of verifying a signature
+type SignatureCheck struct {
+	Status SignatureStatus
+	// Principal that the key belongs to according to the allowed signers, if the signature is good
+	Principal string
+	// KeyType is the name of the key type like ED25519
+	KeyType     string
+	Fingerprint string
+	// Reason why the signature is bad
+	Reason error
+}
+
+// String describes the result in the same way as git
+func (check *SignatureCheck) String() string {
+	switch check.Status {
+	case GoodSignature:
+		return fmt.Sprintf("Good \"%s\" signature for %s with %s key %s",
+			sshsig.GitNamespace, check.Principal, check.KeyType, check.Fingerprint)
+	case UnknownSigner:
+		return fmt.Sprintf("Good \"%s\" signature with %s key %s\nNo principal matched.",
+			sshsig.GitNamespace, check.KeyType, check.Fingerprint)
+	}
+
+	if check.KeyType == "" {
+		return fmt.Sprintf("Could not verify signature: %v", check.Reason)
+	}
+
+	return fmt.Sprintf("Signature verification failed: %v\nBad \"%s\" signature with %s key %s",
+		check.Reason, sshsig.GitNamespace, check.KeyType, check.Fingerprint)
+}
+
+// Signer returns a function that creates ssh signatures with the key configured by user.signingKey. Signing
+// requires gpg.format to be ssh, since gpg and x509 signatures are not supported.
+func (ry *Repository) Signer() (func(payload []byte) (string, error), error) {
+	format, err := ry.Config.Get("gpg", "format")
+	if err != nil {
+		format = "openpgp"
+	}
+
+	if format != "ssh" {
+		return nil, fmt.Errorf("gpg.format %s is not supported, only ssh signatures can be created", format)
+	}
+
+	keyPath, err := ry.Config.Get("user", "signingKey")
+	if err != nil || keyPath == "" {
+		return nil, fmt.Errorf("user.signingKey needs to be set for ssh signing")
+	}
+
+	if strings.HasPrefix(keyPath, "key::") || strings.HasPrefix(keyPath, "ssh-") {
+		return nil, fmt.Errorf("user.signingKey must be the path of a private key, ssh-agent is not supported")
+	}
+
+	key, err := readSigningKey(expandHome(keyPath))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(payload []byte) (string, error) {
+		return sshsig.Sign(key, sshsig.GitNamespace, payload)
+	}, nil
+}
+
+// readSigningKey reads the private key. If the path is the one of a public key, the private key is read from
+// the file of the same name without .pub extension like ssh-keygen does.
+func readSigningKey(path string) (*sshsig.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing key: %v", err)
+	}
+
+	if _, err := sshsig.ParseAuthorizedKey(string(data)); err == nil && strings.HasSuffix(path, ".pub") {
+		return readSigningKey(strings.TrimSuffix(path, ".pub"))
+	}
+
+	key, err := sshsig.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not load signing key %s: %v", path, err)
+	}
+
+	return key, nil
+}
+
+// VerifySignature checks the ssh signature of the payload. The key is looked up in the file configured by
+// gpg.ssh.allowedSignersFile at the time of the signature.
+func (ry *Repository) VerifySignature(signature string, payload []byte, at time.Time) (*SignatureCheck, error) {
+	signers, err := ry.allowedSigners()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := sshsig.Decode(signature)
+	if err != nil {
+		return &SignatureCheck{Status: BadSignature, Reason: err}, nil
+	}
+
+	check := &SignatureCheck{KeyType: sig.PublicKey.Name(), Fingerprint: sig.PublicKey.Fingerprint()}
+	if err := sig.Verify(sshsig.GitNamespace, payload); err != nil {
+		check.Status, check.Reason = BadSignature, err
+		return check, nil
+	}
+
+	principals := signers.FindPrincipals(sig.PublicKey, sshsig.GitNamespace, at)
+	if len(principals) == 0 {
+		check.Status = UnknownSigner
+		return check, nil
+	}
+
+	check.Status, check.Principal = GoodSignature, principals[0]
+	return check, nil
+}
+
+// VerifyCommit checks the signature of the commit at the time it was committed, an error is returned if the
+// commit is not signed
+func (ry *Repository) VerifyCommit(commit *objects.Commit) (*SignatureCheck, error) {
+	signature, payload, err := commit.SignedPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	if signature == "" {
+		return nil, fmt.Errorf("commit %s is not signed", commit.OID())
+	}
+
+	return ry.VerifySignature(signature, payload, commit.Commiter.TimeStamp)
+}
+
+// VerifyTag checks the signature of the tag at the time it was tagged, an error is returned if the tag is not
+// signed
+func (ry *Repository) VerifyTag(tag *objects.Tag) (*SignatureCheck, error) {
+	signature, payload, err := tag.SignedPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	if signature == "" {
+		return nil, fmt.Errorf("tag %s is not signed", tag.Name())
+	}
+
+	return ry.VerifySignature(signature, payload, tag.Tagger().TimeStamp)
+}
+
+func (ry *Repository) allowedSigners() (sshsig.AllowedSigners, error) {
+	// the subsection of [gpg "ssh"] is part of the section name
+	path, err := ry.Config.Get(`gpg "ssh"`, "allowedSignersFile")
+	if err != nil || path == "" {
+		return nil, fmt.Errorf("gpg.ssh.allowedSignersFile needs to be configured and exist for ssh signature verification")
+	}
+
+	file, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return sshsig.ParseAllowedSigners(file)
+}
+
+// expandHome replaces a leading ~/ with the home directory of the user
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[2:])
+}
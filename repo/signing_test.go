@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/furisto/gog/plumbing/sshsig"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// configureSigning creates a signing key for the repository and returns its public key
+func configureSigning(t *testing.T, ry *Repository) *sshsig.PublicKey {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("could not generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("could not marshal key: %v", err)
+	}
+
+	keyPath := filepath.Join(ry.Info.GitDirectory(), "signing_key")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("could not write key: %v", err)
+	}
+
+	key, err := sshsig.NewPrivateKey(private)
+	if err != nil {
+		t.Fatalf("could not load key: %v", err)
+	}
+
+	allowedSignersPath := filepath.Join(ry.Info.GitDirectory(), "allowed_signers")
+	allowed := []byte("furisto@test.com " + key.PublicKey().String() + "\n")
+	if err := ioutil.WriteFile(allowedSignersPath, allowed, 0644); err != nil {
+		t.Fatalf("could not write allowed signers: %v", err)
+	}
+
+	settings := [][3]string{
+		{"gpg", "format", "ssh"},
+		{"user", "signingKey", keyPath},
+		{`gpg "ssh"`, "allowedSignersFile", allowedSignersPath},
+	}
+	for _, setting := range settings {
+		if err := ry.Config.Set(setting[0], setting[1], setting[2]); err != nil {
+			t.Fatalf("could not set %s.%s: %v", setting[0], setting[1], err)
+		}
+	}
+
+	return key.PublicKey()
+}
+
+func TestSignedCommitIsVerified(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+	public := configureSigning(t, ry)
+
+	signer, err := ry.Signer()
+	if err != nil {
+		t.Fatalf("could not create signer: %v", err)
+	}
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("signed").WithSigner(signer)
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	data, err := ry.Storage.Get(commit.OID())
+	if err != nil {
+		t.Fatalf("could not read commit: %v", err)
+	}
+
+	decoded, err := objects.DecodeCommit(commit.OID(), data)
+	if err != nil {
+		t.Fatalf("could not decode commit: %v", err)
+	}
+
+	check, err := ry.VerifyCommit(decoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, GoodSignature, check.Status)
+		assert.Equal(t, "furisto@test.com", check.Principal)
+		assert.Equal(t, public.Fingerprint(), check.Fingerprint)
+		assert.Equal(t, "Good \"git\" signature for furisto@test.com with ED25519 key "+public.Fingerprint(),
+			check.String())
+	}
+
+	decoded.Message = "tampered"
+	check, err = ry.VerifyCommit(decoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, BadSignature, check.Status)
+	}
+}
+
+func TestCommitSignedByUnknownKey(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+	configureSigning(t, ry)
+
+	signer, err := ry.Signer()
+	if err != nil {
+		t.Fatalf("could not create signer: %v", err)
+	}
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("signed").WithSigner(signer)
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	// a second key replaces the one that signed the commit
+	configureSigning(t, ry)
+
+	check, err := ry.VerifyCommit(commit)
+	if assert.NoError(t, err) {
+		assert.Equal(t, UnknownSigner, check.Status)
+		assert.Contains(t, check.String(), "No principal matched.")
+	}
+}
+
+func TestSignerRequiresSshFormat(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	_, err := ry.Signer()
+	assert.Error(t, err)
+
+	configureSigning(t, ry)
+	if err := ry.Config.Set("gpg", "format", "openpgp"); err != nil {
+		t.Fatalf("could not set gpg.format: %v", err)
+	}
+
+	_, err = ry.Signer()
+	assert.Error(t, err)
+}
+
+func TestSignedTagIsVerified(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+	configureSigning(t, ry)
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("tagged")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	signer, err := ry.Signer()
+	if err != nil {
+		t.Fatalf("could not create signer: %v", err)
+	}
+
+	tagger := &objects.Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Now()}
+	tag, err := ry.Tags.CreateSigned("v1.0", commit.OID(), tagger, "release", false, signer)
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	data, err := ry.Storage.Get(tag.OID())
+	if err != nil {
+		t.Fatalf("could not read tag: %v", err)
+	}
+
+	decoded, err := objects.DecodeTag(tag.OID(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("could not decode tag: %v", err)
+	}
+	assert.Equal(t, "release", decoded.Message())
+
+	check, err := ry.VerifyTag(decoded)
+	if assert.NoError(t, err) {
+		assert.Equal(t, GoodSignature, check.Status)
+		assert.Equal(t, "furisto@test.com", check.Principal)
+	}
+
+	tampered, err := objects.DecodeTag(tag.OID(), bytes.NewReader(bytes.Replace(data, []byte("v1.0"), []byte("v2.0"), 1)))
+	if err != nil {
+		t.Fatalf("could not decode tag: %v", err)
+	}
+
+	check, err = ry.VerifyTag(tampered)
+	if assert.NoError(t, err) {
+		assert.Equal(t, BadSignature, check.Status)
+		assert.Contains(t, check.String(), "Bad \"git\" signature")
+	}
+
+	unsigned, err := ry.Tags.CreateAnnotated("v1.1", commit.OID(), tagger, "unsigned", false)
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	_, err = ry.VerifyTag(unsigned)
+	assert.Error(t, err)
+}
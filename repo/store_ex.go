@@ -1,42 +1,125 @@
 package repo
 
 import (
+	"fmt"
 	"github.com/furisto/gog/plumbing/objects"
 	"github.com/furisto/gog/storage"
 )
 
 func LoadTreeFromCommit(store storage.ObjectStore, commit *objects.Commit) (*objects.Tree, error) {
-	treeData, err := store.Get(commit.Tree)
+	tree, err := loadObject(store, commit.Tree, objects.TreeObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return objects.LoadTree(treeData)
+	return tree.(*objects.Tree), nil
 }
 
 func LoadBlobFromTreeEntry(store storage.ObjectStore, treeEntry *objects.TreeEntry) (*objects.Blob, error) {
-	blobData, err := store.Get(treeEntry.OID)
+	return LoadBlob(store, treeEntry.OID)
+}
+
+func LoadBlob(store storage.ObjectStore, oid string) (*objects.Blob, error) {
+	blob, err := loadObject(store, oid, objects.BlobObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return objects.LoadBlob(blobData)
+	return blob.(*objects.Blob), nil
 }
 
-func LoadBlob(store storage.ObjectStore, oid string) (*objects.Blob, error) {
-	blobData, err := store.Get(oid)
+func LoadCommit(store storage.ObjectStore, oid string) (*objects.Commit, error) {
+	commit, err := loadObject(store, oid, objects.CommitObject)
 	if err != nil {
 		return nil, err
 	}
 
-	return objects.LoadBlob(blobData)
+	return commit.(*objects.Commit), nil
 }
 
-func LoadCommit(store storage.ObjectStore, oid string) (*objects.Commit, error) {
-	commitData, err := store.Get(oid)
+// loadObject reads and decodes the object, an error matching objects.ErrWrongType is returned if it is not of
+// the expected type
+func loadObject(store storage.ObjectStore, oid string, expected objects.ObjectType) (objects.Object, error) {
+	data, err := store.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := objects.DecodeWithFormat(oid, data, store.ObjectFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := objects.TypeOf(object); actual != expected {
+		return nil, wrongType(oid, actual, expected)
+	}
+
+	return object, nil
+}
+
+func wrongType(oid string, actual, expected objects.ObjectType) error {
+	return fmt.Errorf("%w: object %s is a %s, not a %s", objects.ErrWrongType, oid, actual, expected)
+}
+
+// Object reads the object with the full object id from the object store
+func (ry *Repository) Object(oid string) (objects.Object, error) {
+	data, err := ry.Storage.Get(oid)
 	if err != nil {
 		return nil, err
 	}
 
-	return objects.DecodeCommit(oid, commitData)
+	return objects.DecodeWithFormat(oid, data, ry.Storage.ObjectFormat())
+}
+
+// LookupCommit reads the commit with the object id, errors match objects.ErrWrongType for other objects
+func (ry *Repository) LookupCommit(oid string) (*objects.Commit, error) {
+	return LoadCommit(ry.Storage, oid)
+}
+
+// LookupTree reads the tree with the object id, errors match objects.ErrWrongType for other objects
+func (ry *Repository) LookupTree(oid string) (*objects.Tree, error) {
+	tree, err := loadObject(ry.Storage, oid, objects.TreeObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree.(*objects.Tree), nil
+}
+
+// LookupBlob reads the blob with the object id, errors match objects.ErrWrongType for other objects
+func (ry *Repository) LookupBlob(oid string) (*objects.Blob, error) {
+	return LoadBlob(ry.Storage, oid)
+}
+
+// LookupTag reads the annotated tag with the object id, errors match objects.ErrWrongType for other objects
+func (ry *Repository) LookupTag(oid string) (*objects.Tag, error) {
+	tag, err := loadObject(ry.Storage, oid, objects.TagObject)
+	if err != nil {
+		return nil, err
+	}
+
+	return tag.(*objects.Tag), nil
+}
+
+// Peel follows tags, and commits if a tree is wanted, until an object of the type is found like <rev>^{type}
+// does. An error matching objects.ErrWrongType is returned if the object cannot be peeled to the type.
+func (ry *Repository) Peel(oid string, wanted objects.ObjectType) (objects.Object, error) {
+	for {
+		object, err := ry.Object(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		actual := objects.TypeOf(object)
+		switch {
+		case actual == wanted:
+			return object, nil
+		case actual == objects.TagObject:
+			oid = object.(*objects.Tag).TargetOID()
+		case actual == objects.CommitObject && wanted == objects.TreeObject:
+			oid = object.(*objects.Commit).Tree
+		default:
+			return nil, wrongType(oid, actual, wanted)
+		}
+	}
 }
@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"errors"
+	"github.com/furisto/gog/plumbing/objects"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLookupObjects(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("lookup")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	object, err := ry.Object(commit.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, objects.CommitObject, objects.TypeOf(object))
+	}
+
+	loaded, err := ry.LookupCommit(commit.OID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, commit.Tree, loaded.Tree)
+	}
+
+	tree, err := ry.LookupTree(commit.Tree)
+	if assert.NoError(t, err) {
+		assert.Equal(t, commit.Tree, tree.OID())
+	}
+
+	_, err = ry.LookupTree(commit.OID())
+	assert.True(t, errors.Is(err, objects.ErrWrongType), "commit is not a tree: %v", err)
+
+	_, err = ry.LookupBlob(objects.NewBlob([]byte("missing")).OID())
+	assert.True(t, errors.Is(err, objects.ErrObjectNotFound), "blob is not stored: %v", err)
+}
+
+func TestPeel(t *testing.T) {
+	ry := prepareEnvWithNoCommmits(t)
+	defer os.RemoveAll(ry.Info.WorkingDirectory())
+
+	commit, err := ry.Commit(func(builder *objects.CommitBuilder) *objects.CommitBuilder {
+		return builder.WithMessage("peel")
+	})
+	if err != nil {
+		t.Fatalf("could not create commit: %v", err)
+	}
+
+	tagger := &objects.Signature{Name: "furisto", Email: "furisto@test.com", TimeStamp: time.Now()}
+	tag, err := ry.Tags.CreateAnnotated("v1.0", commit.OID(), tagger, "release", false)
+	if err != nil {
+		t.Fatalf("could not create tag: %v", err)
+	}
+
+	peeled, err := ry.Peel(tag.OID(), objects.CommitObject)
+	if assert.NoError(t, err) {
+		assert.Equal(t, commit.OID(), peeled.OID())
+	}
+
+	peeled, err = ry.Peel(tag.OID(), objects.TreeObject)
+	if assert.NoError(t, err) {
+		assert.Equal(t, commit.Tree, peeled.OID())
+	}
+
+	peeled, err = ry.Peel(tag.OID(), objects.TagObject)
+	if assert.NoError(t, err) {
+		assert.Equal(t, tag.OID(), peeled.OID())
+	}
+
+	_, err = ry.Peel(commit.Tree, objects.CommitObject)
+	assert.True(t, errors.Is(err, objects.ErrWrongType), "tree cannot be peeled to a commit: %v", err)
+}
@@ -29,8 +29,15 @@ func NewTags(refMgr refs.RefManager, store storage.ObjectStore) Tags {
 }
 
 func (t *Tags) CreateAnnotated(tagName, target string, tagger *objects.Signature, message string, overwrite bool) (*objects.Tag, error) {
+	return t.CreateSigned(tagName, target, tagger, message, overwrite, nil)
+}
+
+// CreateSigned creates an annotated tag whose message is followed by the signature of the signer, see
+// Repository.Signer. The tag is not signed if the signer is nil.
+func (t *Tags) CreateSigned(tagName, target string, tagger *objects.Signature, message string, overwrite bool,
+	signer func(payload []byte) (string, error)) (*objects.Tag, error) {
 	if tagName == "" || tagger == nil || message == "" {
-		fmt.Errorf("invalid paramters")
+		return nil, fmt.Errorf("invalid parameters")
 	}
 
 	fullTagName := t.prefix + tagName
@@ -50,7 +57,7 @@ func (t *Tags) CreateAnnotated(tagName, target string, tagger *objects.Signature
 		return nil, err
 	}
 
-	tag, err := objects.NewTag(target, targetType, tagName, tagger, message)
+	tag, err := objects.NewSignedTagWithFormat(target, targetType, tagName, tagger, message, t.store.ObjectFormat(), signer)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"github.com/furisto/gog/util"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// DefaultCacheLimit is the memory budget of the object cache, it matches the default of core.deltaBaseCacheLimit
+const DefaultCacheLimit int64 = 96 * 1024 * 1024
+
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Number of objects currently in the cache
+	Objects int
+	// Number of bytes currently used by the cached objects
+	Bytes int64
+}
+
+type cacheEntry struct {
+	oid  string
+	data []byte
+}
+
+// CachedStore wraps an object store and keeps recently read objects in memory. Objects are evicted in
+// least recently used order once the size of the cached objects exceeds the limit. It is safe for
+// concurrent use if the wrapped store is.
+type CachedStore struct {
+	store   ObjectStore
+	limit   int64
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   CacheStats
+	mtx     sync.Mutex
+}
+
+func NewCachedStore(store ObjectStore, limit int64) *CachedStore {
+	return &CachedStore{
+		store:   store,
+		limit:   limit,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Unwrap returns the store whose objects are cached
+func (cs *CachedStore) Unwrap() ObjectStore {
+	return cs.store
+}
+
+func (cs *CachedStore) Stats() CacheStats {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	return cs.stats
+}
+
+// Purge removes all objects from the cache, the statistics are kept
+func (cs *CachedStore) Purge() {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	cs.entries = make(map[string]*list.Element)
+	cs.lru.Init()
+	cs.stats.Objects = 0
+	cs.stats.Bytes = 0
+}
+
+func (cs *CachedStore) Get(oid string) ([]byte, error) {
+	if data, ok := cs.lookup(oid); ok {
+		return copyBytes(data), nil
+	}
+
+	data, err := cs.store.Get(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.add(oid, copyBytes(data))
+	return data, nil
+}
+
+// Open serves cached objects from memory. Objects that are not cached are streamed from the wrapped
+// store without being added to the cache, since they might not fit into memory.
+func (cs *CachedStore) Open(oid string) (string, uint64, io.ReadCloser, error) {
+	data, ok := cs.lookup(oid)
+	if !ok {
+		return cs.store.Open(oid)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	objectType, size, err := readLooseHeader(reader)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	return objectType, size, ioutil.NopCloser(reader), nil
+}
+
+func (cs *CachedStore) Put(oid string, data []byte) error {
+	if err := cs.store.Put(oid, data); err != nil {
+		return err
+	}
+
+	cs.add(oid, copyBytes(data))
+	return nil
+}
+
+func (cs *CachedStore) PutStream(objectType string, size uint64, reader io.Reader) (string, error) {
+	return cs.store.PutStream(objectType, size, reader)
+}
+
+func (cs *CachedStore) Stat(oid string) (bool, error) {
+	cs.mtx.Lock()
+	_, ok := cs.entries[oid]
+	cs.mtx.Unlock()
+
+	if ok {
+		return true, nil
+	}
+
+	return cs.store.Stat(oid)
+}
+
+func (cs *CachedStore) ObjectFormat() util.ObjectFormat {
+	return cs.store.ObjectFormat()
+}
+
+func (cs *CachedStore) Find(prefix string) ([]string, error) {
+	return cs.store.Find(prefix)
+}
+
+func (cs *CachedStore) Objects() ([]string, error) {
+	return cs.store.Objects()
+}
+
+// Delete removes the object from the cache and from the wrapped store if the store supports deletion
+func (cs *CachedStore) Delete(oid string) error {
+	cs.mtx.Lock()
+	if element, ok := cs.entries[oid]; ok {
+		cs.remove(element)
+	}
+	cs.mtx.Unlock()
+
+	if deleter, ok := cs.store.(interface{ Delete(string) error }); ok {
+		return deleter.Delete(oid)
+	}
+
+	return nil
+}
+
+func (cs *CachedStore) lookup(oid string) ([]byte, bool) {
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	element, ok := cs.entries[oid]
+	if !ok {
+		cs.stats.Misses++
+		return nil, false
+	}
+
+	cs.stats.Hits++
+	cs.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry).data, true
+}
+
+func (cs *CachedStore) add(oid string, data []byte) {
+	size := int64(len(data))
+	if size > cs.limit {
+		return
+	}
+
+	cs.mtx.Lock()
+	defer cs.mtx.Unlock()
+
+	if element, ok := cs.entries[oid]; ok {
+		cs.remove(element)
+	}
+
+	cs.entries[oid] = cs.lru.PushFront(&cacheEntry{oid: oid, data: data})
+	cs.stats.Objects++
+	cs.stats.Bytes += size
+
+	for cs.stats.Bytes > cs.limit {
+		cs.remove(cs.lru.Back())
+		cs.stats.Evictions++
+	}
+}
+
+func (cs *CachedStore) remove(element *list.Element) {
+	entry := cs.lru.Remove(element).(*cacheEntry)
+	delete(cs.entries, entry.oid)
+	cs.stats.Objects--
+	cs.stats.Bytes -= int64(len(entry.data))
+}
+
+// Unwrap returns the innermost store, removing all layers that wrap other stores like CachedStore
+func Unwrap(store ObjectStore) ObjectStore {
+	for {
+		wrapper, ok := store.(interface{ Unwrap() ObjectStore })
+		if !ok {
+			return store
+		}
+		store = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCachedStoreCountsHitsAndMisses(t *testing.T) {
+	backing := NewMemoryStore()
+	data := encodeLooseObject("blob", []byte("cached"))
+	oid := hashData(data)
+	assert.NoError(t, backing.Put(oid, data))
+
+	cache := NewCachedStore(backing, DefaultCacheLimit)
+	for i := 0; i < 3; i++ {
+		read, err := cache.Get(oid)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", oid, err)
+		}
+		assert.Equal(t, data, read)
+	}
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Objects)
+	assert.Equal(t, int64(len(data)), stats.Bytes)
+}
+
+func TestCachedStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	backing := NewMemoryStore()
+	var oids []string
+	for _, content := range []string{"first", "second", "third"} {
+		data := encodeLooseObject("blob", []byte(content))
+		oid := hashData(data)
+		assert.NoError(t, backing.Put(oid, data))
+		oids = append(oids, oid)
+	}
+
+	// every object is 12 or 13 bytes, so only two of them fit
+	cache := NewCachedStore(backing, 26)
+	for _, oid := range []string{oids[0], oids[1], oids[0], oids[2]} {
+		if _, err := cache.Get(oid); err != nil {
+			t.Fatalf("could not read %s: %v", oid, err)
+		}
+	}
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Objects)
+	assert.True(t, stats.Bytes <= 26)
+
+	// the second object was used least recently and must have been evicted
+	cache.Get(oids[0])
+	cache.Get(oids[1])
+	stats = cache.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(4), stats.Misses)
+}
+
+func TestCachedStoreOpenServesCachedObject(t *testing.T) {
+	backing := NewMemoryStore()
+	data := encodeLooseObject("blob", []byte("streamed"))
+	oid := hashData(data)
+
+	cache := NewCachedStore(backing, DefaultCacheLimit)
+	assert.NoError(t, cache.Put(oid, data))
+
+	objectType, size, reader, err := cache.Open(oid)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", oid, err)
+	}
+	defer reader.Close()
+
+	content, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "blob", objectType)
+	assert.Equal(t, uint64(8), size)
+	assert.Equal(t, "streamed", string(content))
+	assert.Equal(t, uint64(1), cache.Stats().Hits)
+}
+
+func TestCachedStoreDeleteInvalidates(t *testing.T) {
+	backing := NewMemoryStore()
+	data := encodeLooseObject("blob", []byte("deleted"))
+	oid := hashData(data)
+
+	cache := NewCachedStore(backing, DefaultCacheLimit)
+	assert.NoError(t, cache.Put(oid, data))
+	assert.NoError(t, cache.Delete(oid))
+
+	exists, err := cache.Stat(oid)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 0, cache.Stats().Objects)
+}
+
+func TestUnwrapReturnsInnermostStore(t *testing.T) {
+	backing := NewMemoryStore()
+	cache := NewCachedStore(NewCachedStore(backing, 10), 10)
+
+	assert.Equal(t, backing, Unwrap(cache))
+	assert.Equal(t, backing, Unwrap(backing))
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/furisto/gog/util"
+	"io"
+)
+
+// Files like the commit-graph and the multi-pack-index consist of a header, a table of contents and the
+// chunks. Every entry of the table of contents holds the 4 byte id of a chunk and its 8 byte offset from
+// the start of the file. The table is terminated by an entry with id 0 and the offset of the end of the
+// last chunk, so that the size of every chunk can be computed.
+//
+// see https://git-scm.com/docs/chunk-format
+
+const chunkTocEntrySize = 12
+
+var errCorruptChunks = errors.New("chunk table of contents is corrupt")
+
+type chunk struct {
+	id   uint32
+	data []byte
+}
+
+func chunkID(name string) uint32 {
+	return binary.BigEndian.Uint32([]byte(name))
+}
+
+// writeChunks writes the table of contents followed by the chunks, headerSize is the number of bytes that
+// were written before the table of contents
+func writeChunks(writer io.Writer, headerSize int, chunks []chunk) error {
+	offset := uint64(headerSize + (len(chunks)+1)*chunkTocEntrySize)
+	for _, c := range chunks {
+		if err := binary.Write(writer, binary.BigEndian, c.id); err != nil {
+			return err
+		}
+
+		if err := binary.Write(writer, binary.BigEndian, offset); err != nil {
+			return err
+		}
+		offset += uint64(len(c.data))
+	}
+
+	if err := binary.Write(writer, binary.BigEndian, uint32(0)); err != nil {
+		return err
+	}
+
+	if err := binary.Write(writer, binary.BigEndian, offset); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		if _, err := writer.Write(c.data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readChunks parses the table of contents with count entries that starts at tocOffset. The chunks are
+// returned as slices of data and must end before the trailer that starts at dataEnd.
+func readChunks(data []byte, tocOffset, count, dataEnd int) (map[uint32][]byte, error) {
+	if tocOffset+(count+1)*chunkTocEntrySize > dataEnd {
+		return nil, errCorruptChunks
+	}
+
+	chunks := make(map[uint32][]byte, count)
+	for i := 0; i < count; i++ {
+		entry := data[tocOffset+i*chunkTocEntrySize:]
+		id := binary.BigEndian.Uint32(entry)
+		start := binary.BigEndian.Uint64(entry[4:])
+		end := binary.BigEndian.Uint64(entry[4+chunkTocEntrySize:])
+
+		if id == 0 || start > end || end > uint64(dataEnd) {
+			return nil, errCorruptChunks
+		}
+
+		chunks[id] = data[start:end]
+	}
+
+	return chunks, nil
+}
+
+// hashVersion returns the id of the object format that is stored in the header of chunk files
+func hashVersion(format util.ObjectFormat) byte {
+	if format == util.SHA256 {
+		return 2
+	}
+
+	return 1
+}
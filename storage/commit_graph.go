@@ -0,0 +1,708 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The on disk representation of a commit-graph file is
+// CGPH [version] [hash version] [number of chunks] [number of base graphs] [table of contents] [chunks] [checksum]
+// The OIDF and OIDL chunks hold a fanout table and the sorted ids of the commits. CDAT holds for every commit
+// the id of its root tree, the positions of the first two parents and the generation number together with
+// the commit time. The remaining parents of octopus merges are stored in the EDGE chunk. Graphs that are part
+// of a chain list the checksums of the graphs below them in the BASE chunk, the commits of base graphs are
+// positioned before the commits of the graph itself.
+//
+// see https://git-scm.com/docs/commit-graph-format
+
+var commitGraphMagic = []byte("CGPH")
+
+const (
+	commitGraphVersion    = 1
+	commitGraphHeaderSize = 8
+	commitGraphParentNone = uint32(0x70000000)
+	commitGraphEdgeFlag   = uint32(0x80000000)
+)
+
+var (
+	chunkOIDFanout  = chunkID("OIDF")
+	chunkOIDLookup  = chunkID("OIDL")
+	chunkCommitData = chunkID("CDAT")
+	chunkExtraEdges = chunkID("EDGE")
+	chunkBaseGraphs = chunkID("BASE")
+)
+
+// MaxGenerationNumber is the largest generation number a commit-graph can store, larger generations are capped
+const MaxGenerationNumber = uint32(0x3fffffff)
+
+var ErrCorruptCommitGraph = errors.New("commit-graph file is corrupt")
+
+type CommitGraphEntry struct {
+	OID     string
+	Tree    string
+	Parents []string
+	// Generation is one more than the largest generation of the parents, commits without parents have generation 1
+	Generation uint32
+	CommitTime int64
+}
+
+// CommitGraph is a single commit-graph file, graphs that are part of a chain are linked to the graph below them
+type CommitGraph struct {
+	base       *CommitGraph
+	baseCount  uint32
+	fanout     [256]uint32
+	oids       []byte
+	commitData []byte
+	edges      []byte
+	checksum   []byte
+	hashSize   int
+}
+
+func OpenCommitGraph(path string, format util.ObjectFormat, base *CommitGraph) (*CommitGraph, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeCommitGraph(data, format, base)
+}
+
+// DecodeCommitGraph parses a commit-graph, base is the graph below it if the graph is part of a chain
+func DecodeCommitGraph(data []byte, format util.ObjectFormat, base *CommitGraph) (*CommitGraph, error) {
+	hashSize := format.Size()
+	if len(data) < commitGraphHeaderSize+hashSize || !bytes.Equal(data[:4], commitGraphMagic) {
+		return nil, ErrCorruptCommitGraph
+	}
+
+	if data[4] != commitGraphVersion {
+		return nil, fmt.Errorf("commit-graph version %d not supported", data[4])
+	}
+
+	if data[5] != hashVersion(format) {
+		return nil, fmt.Errorf("commit-graph hash version %d does not match object format %s", data[5], format)
+	}
+
+	end := len(data) - hashSize
+	chunks, err := readChunks(data, commitGraphHeaderSize, int(data[6]), end)
+	if err != nil {
+		return nil, ErrCorruptCommitGraph
+	}
+
+	graph := &CommitGraph{
+		base:       base,
+		oids:       chunks[chunkOIDLookup],
+		commitData: chunks[chunkCommitData],
+		edges:      chunks[chunkExtraEdges],
+		checksum:   data[end:],
+		hashSize:   hashSize,
+	}
+
+	fanout := chunks[chunkOIDFanout]
+	if len(fanout) != len(graph.fanout)*4 {
+		return nil, ErrCorruptCommitGraph
+	}
+
+	for i := range graph.fanout {
+		graph.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+		if i > 0 && graph.fanout[i] < graph.fanout[i-1] {
+			return nil, ErrCorruptCommitGraph
+		}
+	}
+
+	count := int(graph.fanout[255])
+	if len(graph.oids) != count*hashSize || len(graph.commitData) != count*(hashSize+16) {
+		return nil, ErrCorruptCommitGraph
+	}
+
+	baseGraphs := chunks[chunkBaseGraphs]
+	if int(data[7]) != graph.depth()-1 || len(baseGraphs) != int(data[7])*hashSize {
+		return nil, fmt.Errorf("commit-graph has %d base graphs, but chain provides %d", data[7], graph.depth()-1)
+	}
+
+	for layer := base; layer != nil; layer = layer.base {
+		offset := (layer.depth() - 1) * hashSize
+		if !bytes.Equal(baseGraphs[offset:offset+hashSize], layer.checksum) {
+			return nil, fmt.Errorf("commit-graph chain does not match")
+		}
+	}
+
+	if base != nil {
+		graph.baseCount = base.baseCount + base.fanout[255]
+	}
+
+	return graph, nil
+}
+
+// Checksum returns the trailing checksum, which identifies graphs in a chain
+func (g *CommitGraph) Checksum() []byte {
+	return g.checksum
+}
+
+// Base returns the graph below this graph in a chain
+func (g *CommitGraph) Base() *CommitGraph {
+	return g.base
+}
+
+// Count returns the number of commits in the graph including the commits of its base graphs
+func (g *CommitGraph) Count() int {
+	return int(g.baseCount + g.fanout[255])
+}
+
+func (g *CommitGraph) Contains(oid string) bool {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || g == nil {
+		return false
+	}
+
+	_, ok := g.position(raw)
+	return ok
+}
+
+// Lookup returns the commit with the given id. Commits that are not part of the graph or whose entry is
+// corrupt are not found, callers are expected to fall back to the commit object in that case.
+func (g *CommitGraph) Lookup(oid string) (*CommitGraphEntry, bool) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || g == nil {
+		return nil, false
+	}
+
+	pos, ok := g.position(raw)
+	if !ok {
+		return nil, false
+	}
+
+	entry, err := g.entryAt(pos)
+	if err != nil {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (g *CommitGraph) depth() int {
+	if g.base == nil {
+		return 1
+	}
+
+	return g.base.depth() + 1
+}
+
+// position returns the position of the commit in the chain of graphs
+func (g *CommitGraph) position(raw []byte) (uint32, bool) {
+	for layer := g; layer != nil; layer = layer.base {
+		if len(raw) != layer.hashSize {
+			return 0, false
+		}
+
+		lo, hi := 0, int(layer.fanout[raw[0]])
+		if raw[0] > 0 {
+			lo = int(layer.fanout[raw[0]-1])
+		}
+
+		n := lo + sort.Search(hi-lo, func(i int) bool {
+			return bytes.Compare(layer.rawOID(lo+i), raw) >= 0
+		})
+
+		if n < hi && bytes.Equal(layer.rawOID(n), raw) {
+			return layer.baseCount + uint32(n), true
+		}
+	}
+
+	return 0, false
+}
+
+// layer returns the graph that contains the commit at the position together with its local position
+func (g *CommitGraph) layer(pos uint32) (*CommitGraph, int, bool) {
+	for layer := g; layer != nil; layer = layer.base {
+		if pos >= layer.baseCount {
+			if pos-layer.baseCount >= layer.fanout[255] {
+				return nil, 0, false
+			}
+			return layer, int(pos - layer.baseCount), true
+		}
+	}
+
+	return nil, 0, false
+}
+
+func (g *CommitGraph) rawOID(n int) []byte {
+	return g.oids[n*g.hashSize : (n+1)*g.hashSize]
+}
+
+func (g *CommitGraph) oidAt(pos uint32) (string, error) {
+	layer, n, ok := g.layer(pos)
+	if !ok {
+		return "", ErrCorruptCommitGraph
+	}
+
+	return hex.EncodeToString(layer.rawOID(n)), nil
+}
+
+func (g *CommitGraph) entryAt(pos uint32) (*CommitGraphEntry, error) {
+	layer, n, ok := g.layer(pos)
+	if !ok {
+		return nil, ErrCorruptCommitGraph
+	}
+
+	data := layer.commitData[n*(layer.hashSize+16) : (n+1)*(layer.hashSize+16)]
+	parent1 := binary.BigEndian.Uint32(data[layer.hashSize:])
+	parent2 := binary.BigEndian.Uint32(data[layer.hashSize+4:])
+	generation := binary.BigEndian.Uint32(data[layer.hashSize+8:])
+	commitTime := binary.BigEndian.Uint32(data[layer.hashSize+12:])
+
+	entry := &CommitGraphEntry{
+		OID:        hex.EncodeToString(layer.rawOID(n)),
+		Tree:       hex.EncodeToString(data[:layer.hashSize]),
+		Generation: generation >> 2,
+		CommitTime: int64(generation&0x3)<<32 | int64(commitTime),
+	}
+
+	var parents []uint32
+	if parent1 != commitGraphParentNone {
+		parents = append(parents, parent1)
+	}
+
+	switch {
+	case parent2 == commitGraphParentNone:
+	case parent2&commitGraphEdgeFlag == 0:
+		parents = append(parents, parent2)
+	default:
+		for i := int(parent2 &^ commitGraphEdgeFlag); ; i++ {
+			if (i+1)*4 > len(layer.edges) {
+				return nil, ErrCorruptCommitGraph
+			}
+
+			edge := binary.BigEndian.Uint32(layer.edges[i*4:])
+			parents = append(parents, edge&^commitGraphEdgeFlag)
+			if edge&commitGraphEdgeFlag != 0 {
+				break
+			}
+		}
+	}
+
+	// parents are always written before their children
+	limit := layer.baseCount + layer.fanout[255]
+	for _, parent := range parents {
+		if parent >= limit {
+			return nil, ErrCorruptCommitGraph
+		}
+
+		oid, err := g.oidAt(parent)
+		if err != nil {
+			return nil, err
+		}
+		entry.Parents = append(entry.Parents, oid)
+	}
+
+	return entry, nil
+}
+
+// EncodeCommitGraph writes a graph of the entries on top of the base graph, which is nil for graphs that
+// are not part of a chain. The parents of every entry must be part of the entries or of the base graph.
+// Generation numbers are computed while writing. It returns the checksum of the written graph.
+func EncodeCommitGraph(writer io.Writer, entries []CommitGraphEntry, base *CommitGraph, format util.ObjectFormat) ([]byte, error) {
+	sorted := make([]CommitGraphEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OID < sorted[j].OID
+	})
+
+	var baseCount uint32
+	if base != nil {
+		baseCount = uint32(base.Count())
+	}
+
+	positions := make(map[string]uint32, len(sorted))
+	for i, entry := range sorted {
+		positions[entry.OID] = baseCount + uint32(i)
+	}
+
+	generations, err := computeGenerations(sorted, positions, base)
+	if err != nil {
+		return nil, err
+	}
+
+	var fanout, oids, commitData, edges, baseGraphs bytes.Buffer
+	var counts [256]uint32
+	for i, entry := range sorted {
+		raw, err := decodeOID(entry.OID, format)
+		if err != nil {
+			return nil, err
+		}
+		oids.Write(raw)
+		counts[raw[0]]++
+
+		tree, err := decodeOID(entry.Tree, format)
+		if err != nil {
+			return nil, err
+		}
+		commitData.Write(tree)
+
+		parents := make([]uint32, len(entry.Parents))
+		for j, parent := range entry.Parents {
+			pos, ok := positions[parent]
+			if !ok {
+				raw, err := decodeOID(parent, format)
+				if err != nil {
+					return nil, err
+				}
+
+				if pos, ok = base.position(raw); base == nil || !ok {
+					return nil, fmt.Errorf("parent %s of commit %s is not part of the commit-graph", parent, entry.OID)
+				}
+			}
+			parents[j] = pos
+		}
+
+		parent1, parent2 := commitGraphParentNone, commitGraphParentNone
+		if len(parents) > 0 {
+			parent1 = parents[0]
+		}
+
+		if len(parents) == 2 {
+			parent2 = parents[1]
+		} else if len(parents) > 2 {
+			parent2 = commitGraphEdgeFlag | uint32(edges.Len()/4)
+			for j, parent := range parents[1:] {
+				if j == len(parents)-2 {
+					parent |= commitGraphEdgeFlag
+				}
+				binary.Write(&edges, binary.BigEndian, parent)
+			}
+		}
+
+		commitTime := uint64(0)
+		if entry.CommitTime > 0 {
+			commitTime = uint64(entry.CommitTime) & 0x3ffffffff
+		}
+
+		binary.Write(&commitData, binary.BigEndian, parent1)
+		binary.Write(&commitData, binary.BigEndian, parent2)
+		binary.Write(&commitData, binary.BigEndian, generations[i]<<2|uint32(commitTime>>32))
+		binary.Write(&commitData, binary.BigEndian, uint32(commitTime))
+	}
+
+	var total uint32
+	for _, count := range counts {
+		total += count
+		binary.Write(&fanout, binary.BigEndian, total)
+	}
+
+	var chain []*CommitGraph
+	for layer := base; layer != nil; layer = layer.base {
+		chain = append([]*CommitGraph{layer}, chain...)
+	}
+
+	for _, layer := range chain {
+		baseGraphs.Write(layer.checksum)
+	}
+
+	chunks := []chunk{
+		{id: chunkOIDFanout, data: fanout.Bytes()},
+		{id: chunkOIDLookup, data: oids.Bytes()},
+		{id: chunkCommitData, data: commitData.Bytes()},
+	}
+
+	if edges.Len() > 0 {
+		chunks = append(chunks, chunk{id: chunkExtraEdges, data: edges.Bytes()})
+	}
+
+	if len(chain) > 0 {
+		chunks = append(chunks, chunk{id: chunkBaseGraphs, data: baseGraphs.Bytes()})
+	}
+
+	hasher := format.NewHasher()
+	mw := io.MultiWriter(writer, hasher)
+
+	header := append(commitGraphMagic[:4:4], commitGraphVersion, hashVersion(format), byte(len(chunks)), byte(len(chain)))
+	if _, err := mw.Write(header); err != nil {
+		return nil, err
+	}
+
+	if err := writeChunks(mw, len(header), chunks); err != nil {
+		return nil, err
+	}
+
+	checksum := hasher.Sum(nil)
+	if _, err := writer.Write(checksum); err != nil {
+		return nil, err
+	}
+
+	return checksum, nil
+}
+
+// computeGenerations returns the generation numbers of the sorted entries, generations of commits in base
+// graphs are taken from the graph
+func computeGenerations(sorted []CommitGraphEntry, positions map[string]uint32, base *CommitGraph) ([]uint32, error) {
+	var baseCount uint32
+	if base != nil {
+		baseCount = uint32(base.Count())
+	}
+
+	generations := make([]uint32, len(sorted))
+	generationOf := func(oid string) (uint32, bool) {
+		if pos, ok := positions[oid]; ok {
+			return generations[pos-baseCount], generations[pos-baseCount] != 0
+		}
+
+		if entry, ok := base.Lookup(oid); ok {
+			return entry.Generation, true
+		}
+
+		return 0, false
+	}
+
+	for i := range sorted {
+		if generations[i] != 0 {
+			continue
+		}
+
+		// parents are visited before their children without recursion, since histories can be very deep
+		pending := []int{i}
+		for len(pending) > 0 {
+			current := pending[len(pending)-1]
+			if generations[current] != 0 {
+				pending = pending[:len(pending)-1]
+				continue
+			}
+
+			generation := uint32(1)
+			complete := true
+			for _, parent := range sorted[current].Parents {
+				parentGeneration, ok := generationOf(parent)
+				if ok {
+					if parentGeneration >= generation {
+						generation = parentGeneration + 1
+					}
+					continue
+				}
+
+				pos, inGraph := positions[parent]
+				if !inGraph {
+					return nil, fmt.Errorf("parent %s of commit %s is not part of the commit-graph", parent, sorted[current].OID)
+				}
+
+				complete = false
+				pending = append(pending, int(pos-baseCount))
+			}
+
+			if complete {
+				if generation > MaxGenerationNumber {
+					generation = MaxGenerationNumber
+				}
+				generations[current] = generation
+				pending = pending[:len(pending)-1]
+			}
+		}
+	}
+
+	return generations, nil
+}
+
+func decodeOID(oid string, format util.ObjectFormat) ([]byte, error) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || len(raw) != format.Size() {
+		return nil, fmt.Errorf("invalid object id %s", oid)
+	}
+
+	return raw, nil
+}
+
+// CommitGraph returns the commit-graph of the store or nil if none has been written. A single
+// objects/info/commit-graph file takes precedence over a chain in objects/info/commit-graphs.
+func (store *FilesystemStore) CommitGraph() (*CommitGraph, error) {
+	store.commitGraphMtx.Lock()
+	defer store.commitGraphMtx.Unlock()
+
+	if store.commitGraphLoaded {
+		return store.commitGraph, nil
+	}
+
+	infoDir := filepath.Join(store.location, "info")
+	graph, err := OpenCommitGraph(filepath.Join(infoDir, "commit-graph"), store.format, nil)
+	if os.IsNotExist(err) {
+		graph, err = loadCommitGraphChain(filepath.Join(infoDir, "commit-graphs"), store.format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.commitGraph = graph
+	store.commitGraphLoaded = true
+	return store.commitGraph, nil
+}
+
+// ReloadCommitGraph discards the loaded commit-graph so that it is read again on next access
+func (store *FilesystemStore) ReloadCommitGraph() {
+	store.commitGraphMtx.Lock()
+	defer store.commitGraphMtx.Unlock()
+
+	store.commitGraph = nil
+	store.commitGraphLoaded = false
+}
+
+// WriteCommitGraph writes a commit-graph of the entries and returns the number of commits written. With
+// split the entries that are not part of the existing chain are written as a new graph on top of the chain,
+// otherwise the entries replace every existing graph.
+func (store *FilesystemStore) WriteCommitGraph(entries []CommitGraphEntry, split bool) (int, error) {
+	defer store.ReloadCommitGraph()
+
+	infoDir := filepath.Join(store.location, "info")
+	chainDir := filepath.Join(infoDir, "commit-graphs")
+	if !split {
+		if err := store.mkdir(infoDir); err != nil {
+			return 0, err
+		}
+
+		tempPath, _, err := store.writeTempGraph(infoDir, entries, nil)
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(tempPath)
+
+		if err := os.Rename(tempPath, filepath.Join(infoDir, "commit-graph")); err != nil {
+			return 0, err
+		}
+
+		return len(entries), os.RemoveAll(chainDir)
+	}
+
+	base, err := loadCommitGraphChain(chainDir, store.format)
+	if err != nil {
+		return 0, err
+	}
+
+	var added []CommitGraphEntry
+	for _, entry := range entries {
+		if !base.Contains(entry.OID) {
+			added = append(added, entry)
+		}
+	}
+
+	if len(added) == 0 {
+		return 0, nil
+	}
+
+	if err := store.mkdir(chainDir); err != nil {
+		return 0, err
+	}
+
+	tempPath, checksum, err := store.writeTempGraph(chainDir, added, base)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tempPath)
+
+	if err := os.Rename(tempPath, filepath.Join(chainDir, fmt.Sprintf("graph-%x.graph", checksum))); err != nil {
+		return 0, err
+	}
+
+	checksums := []string{fmt.Sprintf("%x", checksum)}
+	for layer := base; layer != nil; layer = layer.base {
+		checksums = append([]string{fmt.Sprintf("%x", layer.Checksum())}, checksums...)
+	}
+
+	chainFile, err := ioutil.TempFile(chainDir, "tmp_chain_")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(chainFile.Name())
+
+	_, err = chainFile.WriteString(strings.Join(checksums, "\n") + "\n")
+	if err == nil {
+		err = chainFile.Sync()
+	}
+	if cerr := chainFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(chainFile.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(chainFile.Name(), filepath.Join(chainDir, "commit-graph-chain")); err != nil {
+		return 0, err
+	}
+
+	// a single commit-graph file would take precedence over the chain
+	err = os.Remove(filepath.Join(infoDir, "commit-graph"))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	return len(added), nil
+}
+
+func (store *FilesystemStore) writeTempGraph(dir string, entries []CommitGraphEntry, base *CommitGraph) (string, []byte, error) {
+	file, err := ioutil.TempFile(dir, "tmp_graph_")
+	if err != nil {
+		return "", nil, err
+	}
+
+	checksum, err := EncodeCommitGraph(file, entries, base, store.format)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", nil, err
+	}
+
+	return file.Name(), checksum, nil
+}
+
+// loadCommitGraphChain opens the graphs listed in commit-graph-chain from the bottom to the top of the
+// chain, nil is returned if there is no chain
+func loadCommitGraphChain(chainDir string, format util.ObjectFormat) (*CommitGraph, error) {
+	data, err := ioutil.ReadFile(filepath.Join(chainDir, "commit-graph-chain"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var graph *CommitGraph
+	for _, checksum := range strings.Fields(string(data)) {
+		graph, err = OpenCommitGraph(filepath.Join(chainDir, "graph-"+checksum+".graph"), format, graph)
+		if err != nil {
+			return nil, fmt.Errorf("could not open commit-graph %s: %v", checksum, err)
+		}
+	}
+
+	return graph, nil
+}
+
+// LoadCommitGraph returns the commit-graph of the store. Nil is returned if the store does not support
+// commit-graphs, none has been written or it cannot be read, since the graph is only an optimization.
+func LoadCommitGraph(store ObjectStore) *CommitGraph {
+	provider, ok := Unwrap(store).(interface{ CommitGraph() (*CommitGraph, error) })
+	if !ok {
+		return nil
+	}
+
+	graph, err := provider.CommitGraph()
+	if err != nil {
+		return nil
+	}
+
+	return graph
+}
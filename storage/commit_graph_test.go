@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	graphRoot    = "787d1387308cfec024f2169515a6402dae36d3d2"
+	graphSecond  = "badb5471f0ecef88f0cf4c6dc7fe9d729a70d536"
+	graphMaster  = "2463833fc9b67ac21c562a884e65844dbac5e2dd"
+	graphBranchA = "d1934274f7d0ab357536e6775e6549851d80f44c"
+	graphBranchB = "800f482c0c21446e28c5262a124bf6d792a92c9c"
+	graphOctopus = "c261d1ba481f2a9e07cc9b7554b2caea201f15a1"
+	graphTime    = 1609334881
+)
+
+// graphEntries is the history of testdata/commit-graph, which was written by git
+func graphEntries() []CommitGraphEntry {
+	return []CommitGraphEntry{
+		{OID: graphRoot, Tree: "5659689b2214e9ed03d6ad3a44a6bdeb8492369d", CommitTime: graphTime},
+		{OID: graphSecond, Tree: "e8960bf2b8d05ecd2435a205010631c7c5c47bf4", Parents: []string{graphRoot}, CommitTime: graphTime},
+		{OID: graphMaster, Tree: "8a01d40097b12eb0e723f222e69fa50ab0f31cdc", Parents: []string{graphSecond}, CommitTime: graphTime},
+		{OID: graphBranchA, Tree: "15435516f5c929909d511585d98bf9d411738f4e", Parents: []string{graphSecond}, CommitTime: graphTime},
+		{OID: graphBranchB, Tree: "e2f690e9152a2ff1eaaa00d9cc8832bc5073138c", Parents: []string{graphSecond}, CommitTime: graphTime},
+		{
+			OID:        graphOctopus,
+			Tree:       "578f68e38363c1872dae7b42d736ce5232e6c66a",
+			Parents:    []string{graphMaster, graphBranchA, graphBranchB},
+			CommitTime: graphTime,
+		},
+	}
+}
+
+func TestDecodeCommitGraphWrittenByGit(t *testing.T) {
+	graph, err := OpenCommitGraph("./testdata/commit-graph", util.SHA1, nil)
+	if err != nil {
+		t.Fatalf("could not open commit-graph: %v", err)
+	}
+
+	assert.Equal(t, 6, graph.Count())
+	generations := map[string]uint32{graphRoot: 1, graphSecond: 2, graphMaster: 3, graphBranchA: 3, graphBranchB: 3, graphOctopus: 4}
+	for _, expected := range graphEntries() {
+		entry, ok := graph.Lookup(expected.OID)
+		if !ok {
+			t.Errorf("commit %s not found", expected.OID)
+			continue
+		}
+
+		expected.Generation = generations[expected.OID]
+		assert.Equal(t, expected, *entry)
+	}
+
+	_, ok := graph.Lookup("404ab0364d9ca3f06936d7c7c97c1d2de1e696f3")
+	assert.False(t, ok)
+}
+
+func TestEncodeCommitGraphMatchesGit(t *testing.T) {
+	expected, err := ioutil.ReadFile("./testdata/commit-graph")
+	if err != nil {
+		t.Fatalf("could not read commit-graph: %v", err)
+	}
+
+	var buf bytes.Buffer
+	checksum, err := EncodeCommitGraph(&buf, graphEntries(), nil, util.SHA1)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+	assert.Equal(t, expected[len(expected)-20:], checksum)
+}
+
+func TestEncodeCommitGraphRejectsMissingParents(t *testing.T) {
+	entries := graphEntries()[1:]
+
+	_, err := EncodeCommitGraph(ioutil.Discard, entries, nil, util.SHA1)
+	assert.Error(t, err)
+}
+
+func TestCommitGraphChain(t *testing.T) {
+	entries := graphEntries()
+
+	var baseData bytes.Buffer
+	_, err := EncodeCommitGraph(&baseData, entries[:2], nil, util.SHA1)
+	assert.NoError(t, err)
+	base, err := DecodeCommitGraph(baseData.Bytes(), util.SHA1, nil)
+	if err != nil {
+		t.Fatalf("could not decode base graph: %v", err)
+	}
+
+	var topData bytes.Buffer
+	_, err = EncodeCommitGraph(&topData, entries[2:], base, util.SHA1)
+	assert.NoError(t, err)
+	top, err := DecodeCommitGraph(topData.Bytes(), util.SHA1, base)
+	if err != nil {
+		t.Fatalf("could not decode graph: %v", err)
+	}
+
+	assert.Equal(t, 6, top.Count())
+	assert.True(t, top.Contains(graphRoot))
+	assert.False(t, base.Contains(graphOctopus))
+
+	entry, ok := top.Lookup(graphBranchA)
+	assert.True(t, ok)
+	assert.Equal(t, []string{graphSecond}, entry.Parents)
+	assert.Equal(t, uint32(3), entry.Generation)
+
+	entry, ok = top.Lookup(graphOctopus)
+	assert.True(t, ok)
+	assert.Equal(t, []string{graphMaster, graphBranchA, graphBranchB}, entry.Parents)
+	assert.Equal(t, uint32(4), entry.Generation)
+
+	_, err = DecodeCommitGraph(topData.Bytes(), util.SHA1, nil)
+	assert.Error(t, err, "graph must not be opened without its base")
+}
+
+func TestFsStoreWriteCommitGraph(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStore(dir)
+	entries := graphEntries()
+	infoDir := filepath.Join(dir, "objects", "info")
+
+	graph, err := store.CommitGraph()
+	assert.NoError(t, err)
+	assert.Nil(t, graph)
+
+	written, err := store.WriteCommitGraph(entries[:4], true)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, written)
+
+	written, err = store.WriteCommitGraph(entries, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, written)
+
+	graph, err = store.CommitGraph()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, graph.Count())
+	assert.NotNil(t, graph.Base())
+
+	written, err = store.WriteCommitGraph(entries, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, written)
+
+	graph, err = store.CommitGraph()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, graph.Count())
+	assert.Nil(t, graph.Base())
+
+	_, err = os.Stat(filepath.Join(infoDir, "commit-graphs"))
+	assert.True(t, os.IsNotExist(err), "chain must be removed")
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ObjectCount summarizes the number of objects in a store and the disk space they use, sizes are the apparent
+// sizes of the files in bytes
+type ObjectCount struct {
+	// Number of loose objects
+	Count int
+	// Disk space used by loose objects
+	Size int64
+	// Number of objects in packs, objects that are part of several packs are counted once per pack
+	InPack int
+	// Number of packs
+	Packs int
+	// Disk space used by packs and their indexes
+	SizePack int64
+	// Number of loose objects that are also part of a pack and can be removed
+	PrunePackable int
+	// Files in the object store that are neither objects nor part of a pack
+	Garbage []string
+	// Disk space used by garbage files
+	SizeGarbage int64
+}
+
+// packFileExtensions are the files that may accompany a pack
+var packFileExtensions = []string{".pack", ".idx", ".bitmap", ".keep", ".promisor", ".rev", ".mtimes"}
+
+// CountObjects counts the loose and packed objects of the store, objects borrowed from alternates are not
+// included. Files that do not belong into the object store are reported as garbage.
+func (store *FilesystemStore) CountObjects() (*ObjectCount, error) {
+	count := &ObjectCount{}
+	packed, err := store.countPacks(count)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := ioutil.ReadDir(store.location)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bucket := range buckets {
+		if !bucket.IsDir() || !isHexBucket(bucket.Name()) {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(store.location, bucket.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			oid := bucket.Name() + f.Name()
+			if f.IsDir() || !isObjectId(oid, store.format.Size()) {
+				count.Garbage = append(count.Garbage, filepath.Join(store.location, bucket.Name(), f.Name()))
+				count.SizeGarbage += f.Size()
+				continue
+			}
+
+			count.Count++
+			count.Size += f.Size()
+			if packed(oid) {
+				count.PrunePackable++
+			}
+		}
+	}
+
+	sort.Strings(count.Garbage)
+	return count, nil
+}
+
+// countPacks adds the packs to the count and returns a function that reports whether an object is packed
+func (store *FilesystemStore) countPacks(count *ObjectCount) (func(oid string) bool, error) {
+	packDir := filepath.Join(store.location, "pack")
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, f := range files {
+		present[f.Name()] = true
+	}
+
+	var indexes []*PackIndex
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || name == multiPackIndexFile {
+			continue
+		}
+
+		extension := filepath.Ext(name)
+		base := strings.TrimSuffix(name, extension)
+		if !strings.HasPrefix(base, "pack-") || !containsString(packFileExtensions, extension) ||
+			!present[base+".pack"] || !present[base+".idx"] {
+			count.Garbage = append(count.Garbage, filepath.Join(packDir, name))
+			count.SizeGarbage += f.Size()
+			continue
+		}
+
+		switch extension {
+		case ".pack":
+			count.Packs++
+			count.SizePack += f.Size()
+		case ".idx":
+			index, err := OpenPackIndex(filepath.Join(packDir, name), store.format)
+			if err != nil {
+				return nil, err
+			}
+
+			indexes = append(indexes, index)
+			count.InPack += index.Count()
+			count.SizePack += f.Size()
+		}
+	}
+
+	return func(oid string) bool {
+		for _, index := range indexes {
+			if _, ok := index.find(oid); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func isObjectId(oid string, hashSize int) bool {
+	if len(oid) != 2*hashSize {
+		return false
+	}
+
+	_, err := hex.DecodeString(oid)
+	return err == nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCountObjects(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store, oids := createStoreWithPacks(t, dir)
+
+	// one loose object that is also packed
+	data, err := NewFsStore(ofsPackRepo).Get(packedTree)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", packedTree, err)
+	}
+	assert.NoError(t, store.Put(packedTree, data))
+
+	garbage := []string{
+		filepath.Join(dir, "objects", "pack", "tmp_pack_123"),
+		filepath.Join(dir, "objects", packedTree[:2], "notanobject"),
+	}
+	for _, path := range garbage {
+		if err := ioutil.WriteFile(path, []byte("garbage"), 0644); err != nil {
+			t.Fatalf("could not write garbage file: %v", err)
+		}
+	}
+
+	count, err := store.CountObjects()
+	if err != nil {
+		t.Fatalf("could not count objects: %v", err)
+	}
+
+	assert.Equal(t, 1, count.Count)
+	assert.True(t, count.Size > 0)
+	assert.Equal(t, 2, count.Packs)
+	assert.Equal(t, len(oids)+1, count.InPack, "the commit is part of both packs")
+	assert.True(t, count.SizePack > 0)
+	assert.Equal(t, 1, count.PrunePackable)
+	assert.ElementsMatch(t, garbage, count.Garbage)
+	assert.Equal(t, int64(2*len("garbage")), count.SizeGarbage)
+}
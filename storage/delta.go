@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"errors"
+)
+
+// A delta consists of the size of the base object and the size of the resulting object, both
+// encoded as little endian base 128 numbers, followed by a sequence of instructions. An instruction
+// either copies a range of the base object or inserts literal data that follows the instruction.
+//
+// see https://git-scm.com/docs/pack-format#_deltified_representation
+
+var ErrCorruptDelta = errors.New("delta is corrupt")
+
+const maxCopySize = 0x10000
+
+// ApplyDelta reconstructs an object from its base and the delta instructions
+func ApplyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	if baseSize != uint64(len(base)) {
+		return nil, ErrCorruptDelta
+	}
+
+	targetSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case cmd&0x80 != 0:
+			var offset, size uint64
+			for i := uint(0); i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, ErrCorruptDelta
+					}
+					offset |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			for i := uint(0); i < 3; i++ {
+				if cmd&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, ErrCorruptDelta
+					}
+					size |= uint64(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+
+			if size == 0 {
+				size = maxCopySize
+			}
+
+			if offset+size > uint64(len(base)) {
+				return nil, ErrCorruptDelta
+			}
+			target = append(target, base[offset:offset+size]...)
+		case cmd != 0:
+			if int(cmd) > len(delta) {
+				return nil, ErrCorruptDelta
+			}
+			target = append(target, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, ErrCorruptDelta
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, ErrCorruptDelta
+	}
+
+	return target, nil
+}
+
+func readDeltaSize(delta []byte) (uint64, []byte, error) {
+	var size uint64
+	var shift uint
+	for i, b := range delta {
+		size |= uint64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+
+	return 0, nil, ErrCorruptDelta
+}
+
+const deltaBlockSize = 16
+
+// CreateDelta computes the instructions that transform base into target. Blocks of the base object
+// are indexed and matches against the target are greedily extended, everything else is inserted literally.
+func CreateDelta(base, target []byte) []byte {
+	delta := appendDeltaSize(nil, uint64(len(base)))
+	delta = appendDeltaSize(delta, uint64(len(target)))
+
+	index := make(map[string]int, len(base)/deltaBlockSize+1)
+	for i := 0; i+deltaBlockSize <= len(base); i += deltaBlockSize {
+		block := string(base[i : i+deltaBlockSize])
+		if _, ok := index[block]; !ok {
+			index[block] = i
+		}
+	}
+
+	var insert []byte
+	flush := func() {
+		if len(insert) > 0 {
+			delta = append(delta, byte(len(insert)))
+			delta = append(delta, insert...)
+			insert = insert[:0]
+		}
+	}
+
+	for i := 0; i < len(target); {
+		if i+deltaBlockSize <= len(target) {
+			if offset, ok := index[string(target[i:i+deltaBlockSize])]; ok {
+				length := deltaBlockSize
+				for offset+length < len(base) && i+length < len(target) && base[offset+length] == target[i+length] {
+					length++
+				}
+
+				flush()
+				delta = appendCopy(delta, offset, length)
+				i += length
+				continue
+			}
+		}
+
+		insert = append(insert, target[i])
+		if len(insert) == 0x7f {
+			flush()
+		}
+		i++
+	}
+	flush()
+
+	return delta
+}
+
+func appendCopy(delta []byte, offset, length int) []byte {
+	for length > 0 {
+		size := length
+		if size > maxCopySize {
+			size = maxCopySize
+		}
+
+		cmd := byte(0x80)
+		var args []byte
+		for i := uint(0); i < 4; i++ {
+			if b := byte(offset >> (8 * i)); b != 0 {
+				cmd |= 1 << i
+				args = append(args, b)
+			}
+		}
+
+		for i := uint(0); i < 3; i++ {
+			if b := byte(size >> (8 * i)); b != 0 {
+				cmd |= 0x10 << i
+				args = append(args, b)
+			}
+		}
+
+		delta = append(delta, cmd)
+		delta = append(delta, args...)
+		offset += size
+		length -= size
+	}
+
+	return delta
+}
+
+func appendDeltaSize(delta []byte, size uint64) []byte {
+	for size >= 0x80 {
+		delta = append(delta, byte(size)|0x80)
+		size >>= 7
+	}
+
+	return append(delta, byte(size))
+}
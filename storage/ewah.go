@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// Bitmaps are stored EWAH compressed as
+// [number of bits] [number of words] [words] [position of the last marker word]
+// The words are a sequence of marker words, each followed by literal words. A marker word describes a
+// run of words whose bits are all set or all cleared in its lower 33 bits and the number of literal words
+// that follow the run in its upper 31 bits. Bit 0 holds the value of the bits in the run, bits 1-32 the
+// length of the run.
+//
+// see https://git-scm.com/docs/bitmap-format#_appendix_a_serialization_format_for_an_ewah_bitmap
+
+const (
+	ewahMaxRunLength     = 1<<32 - 1
+	ewahMaxLiteralLength = 1<<31 - 1
+)
+
+var ErrCorruptBitmap = errors.New("bitmap is corrupt")
+
+// Bitmap is an uncompressed set of bit positions
+type Bitmap struct {
+	words []uint64
+}
+
+func NewBitmap() *Bitmap {
+	return &Bitmap{}
+}
+
+func (b *Bitmap) Set(pos int) {
+	word := pos / 64
+	if word >= len(b.words) {
+		words := make([]uint64, word+1)
+		copy(words, b.words)
+		b.words = words
+	}
+
+	b.words[word] |= 1 << uint(pos%64)
+}
+
+func (b *Bitmap) Get(pos int) bool {
+	word := pos / 64
+	return word < len(b.words) && b.words[word]&(1<<uint(pos%64)) != 0
+}
+
+// Or adds all bits of the other bitmap to this bitmap
+func (b *Bitmap) Or(other *Bitmap) {
+	if len(other.words) > len(b.words) {
+		words := make([]uint64, len(other.words))
+		copy(words, b.words)
+		b.words = words
+	}
+
+	for i, word := range other.words {
+		b.words[i] |= word
+	}
+}
+
+// Xor toggles all bits of this bitmap that are set in the other bitmap
+func (b *Bitmap) Xor(other *Bitmap) {
+	if len(other.words) > len(b.words) {
+		words := make([]uint64, len(other.words))
+		copy(words, b.words)
+		b.words = words
+	}
+
+	for i, word := range other.words {
+		b.words[i] ^= word
+	}
+}
+
+// Count returns the number of set bits
+func (b *Bitmap) Count() int {
+	count := 0
+	for _, word := range b.words {
+		count += bits.OnesCount64(word)
+	}
+
+	return count
+}
+
+// ForEach calls fn with the positions of all set bits in ascending order
+func (b *Bitmap) ForEach(fn func(pos int)) {
+	for i, word := range b.words {
+		for word != 0 {
+			fn(i*64 + bits.TrailingZeros64(word))
+			word &= word - 1
+		}
+	}
+}
+
+func (b *Bitmap) Clone() *Bitmap {
+	words := make([]uint64, len(b.words))
+	copy(words, b.words)
+	return &Bitmap{words: words}
+}
+
+// readEWAH decodes an EWAH compressed bitmap
+func readEWAH(reader io.Reader) (*Bitmap, error) {
+	var header struct {
+		BitSize   uint32
+		WordCount uint32
+	}
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+
+	compressed := make([]uint64, header.WordCount)
+	if err := binary.Read(reader, binary.BigEndian, compressed); err != nil {
+		return nil, err
+	}
+
+	var rlwPosition uint32
+	if err := binary.Read(reader, binary.BigEndian, &rlwPosition); err != nil {
+		return nil, err
+	}
+
+	bitmap := &Bitmap{}
+	for pos := 0; pos < len(compressed); {
+		marker := compressed[pos]
+		runLength := (marker >> 1) & ewahMaxRunLength
+		literals := int(marker >> 33)
+		if pos+1+literals > len(compressed) || uint64(len(bitmap.words))+runLength > uint64(header.BitSize/64+1) {
+			return nil, ErrCorruptBitmap
+		}
+
+		var fill uint64
+		if marker&1 != 0 {
+			fill = ^uint64(0)
+		}
+
+		for i := uint64(0); i < runLength; i++ {
+			bitmap.words = append(bitmap.words, fill)
+		}
+
+		bitmap.words = append(bitmap.words, compressed[pos+1:pos+1+literals]...)
+		pos += 1 + literals
+	}
+
+	return bitmap, nil
+}
+
+// writeEWAH writes the bitmap EWAH compressed
+func writeEWAH(writer io.Writer, bitmap *Bitmap) error {
+	words := bitmap.words
+	for len(words) > 0 && words[len(words)-1] == 0 {
+		words = words[:len(words)-1]
+	}
+
+	var compressed []uint64
+	rlwPosition := 0
+	for i := 0; i < len(words) || len(compressed) == 0; {
+		rlwPosition = len(compressed)
+		compressed = append(compressed, 0)
+
+		var runBit, runLength uint64
+		if i < len(words) && words[i] == ^uint64(0) {
+			runBit = 1
+		}
+
+		for i < len(words) && runLength < ewahMaxRunLength && words[i] == -runBit {
+			runLength++
+			i++
+		}
+
+		var literals uint64
+		for i < len(words) && literals < ewahMaxLiteralLength && words[i] != 0 && words[i] != ^uint64(0) {
+			compressed = append(compressed, words[i])
+			literals++
+			i++
+		}
+
+		compressed[rlwPosition] = runBit | runLength<<1 | literals<<33
+	}
+
+	if err := binary.Write(writer, binary.BigEndian, uint32(len(words)*64)); err != nil {
+		return err
+	}
+
+	if err := binary.Write(writer, binary.BigEndian, uint32(len(compressed))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(writer, binary.BigEndian, compressed); err != nil {
+		return err
+	}
+
+	return binary.Write(writer, binary.BigEndian, uint32(rlwPosition))
+}
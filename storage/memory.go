@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore keeps all objects in memory, it is safe for concurrent use
+type MemoryStore struct {
+	objects map[string][]byte
+	format  util.ObjectFormat
+	mtx     sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithFormat(util.DefaultObjectFormat)
+}
+
+func NewMemoryStoreWithFormat(format util.ObjectFormat) *MemoryStore {
+	return &MemoryStore{
+		objects: make(map[string][]byte),
+		format:  format,
+	}
+}
+
+func (store *MemoryStore) ObjectFormat() util.ObjectFormat {
+	return store.format
+}
+
+func (store *MemoryStore) Get(oid string) ([]byte, error) {
+	if err := checkObjectId(oid); err != nil {
+		return nil, err
+	}
+
+	store.mtx.RLock()
+	defer store.mtx.RUnlock()
+
+	data, ok := store.objects[oid]
+	if !ok {
+		return nil, objectNotFound(oid)
+	}
+
+	return copyBytes(data), nil
+}
+
+func (store *MemoryStore) Open(oid string) (string, uint64, io.ReadCloser, error) {
+	data, err := store.Get(oid)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(data))
+	objectType, size, err := readLooseHeader(reader)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	return objectType, size, ioutil.NopCloser(reader), nil
+}
+
+func (store *MemoryStore) PutStream(objectType string, size uint64, reader io.Reader) (string, error) {
+	var buf bytes.Buffer
+	if err := writeStream(&buf, objectType, size, reader); err != nil {
+		return "", err
+	}
+
+	oid := store.format.Hash(buf.Bytes())
+	return oid, store.Put(oid, buf.Bytes())
+}
+
+func (store *MemoryStore) Put(oid string, data []byte) error {
+	if err := checkObjectId(oid); err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return fmt.Errorf("empty data cannot be stored")
+	}
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	if _, ok := store.objects[oid]; !ok {
+		store.objects[oid] = copyBytes(data)
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) Stat(oid string) (bool, error) {
+	if err := checkObjectId(oid); err != nil {
+		return false, err
+	}
+
+	store.mtx.RLock()
+	defer store.mtx.RUnlock()
+
+	_, ok := store.objects[oid]
+	return ok, nil
+}
+
+func (store *MemoryStore) Find(prefix string) ([]string, error) {
+	if err := checkPrefix(prefix, store.format); err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToLower(prefix)
+	store.mtx.RLock()
+	defer store.mtx.RUnlock()
+
+	oids := []string{}
+	for oid := range store.objects {
+		if strings.HasPrefix(oid, prefix) {
+			oids = append(oids, oid)
+		}
+	}
+	sort.Strings(oids)
+
+	return oids, nil
+}
+
+// Objects returns the ids of all objects in the store
+func (store *MemoryStore) Objects() ([]string, error) {
+	store.mtx.RLock()
+	defer store.mtx.RUnlock()
+
+	oids := make([]string, 0, len(store.objects))
+	for oid := range store.objects {
+		oids = append(oids, oid)
+	}
+	sort.Strings(oids)
+
+	return oids, nil
+}
+
+func (store *MemoryStore) Delete(oid string) error {
+	if err := checkObjectId(oid); err != nil {
+		return err
+	}
+
+	store.mtx.Lock()
+	defer store.mtx.Unlock()
+
+	if _, ok := store.objects[oid]; !ok {
+		return objectNotFound(oid)
+	}
+
+	delete(store.objects, oid)
+	return nil
+}
+
+func copyBytes(data []byte) []byte {
+	c := make([]byte, len(data))
+	copy(c, data)
+	return c
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemoryStorePutAndGet(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.Put(oid, fileContent); err != nil {
+		t.Fatalf("could not put object: %v", err)
+	}
+
+	data, err := store.Get(oid)
+	if err != nil {
+		t.Fatalf("could not get object: %v", err)
+	}
+	assert.Equal(t, fileContent, data)
+
+	exists, _ := store.Stat(oid)
+	assert.True(t, exists, "object exists")
+
+	oids, err := store.Find(oid[:4])
+	if err != nil {
+		t.Fatalf("could not find object: %v", err)
+	}
+	assert.Equal(t, []string{oid}, oids)
+
+	if err := store.Delete(oid); err != nil {
+		t.Fatalf("could not delete object: %v", err)
+	}
+
+	_, err = store.Get(oid)
+	assert.True(t, errors.Is(err, ErrObjectNotFound), "missing objects match ErrObjectNotFound")
+}
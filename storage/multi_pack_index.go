@@ -0,0 +1,644 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The on disk representation of a multi-pack-index is
+// MIDX [version] [hash version] [number of chunks] [number of base files] [number of packs] [table of contents] [chunks] [checksum]
+// The PNAM chunk lists the names of the indexes of the packs in lexicographic order, the position of a pack
+// in this list is its pack id. OIDF and OIDL hold a fanout table and the sorted ids of the objects. OOFF
+// holds for every object the id of the pack that contains it and its offset inside the pack. Offsets that
+// do not fit into 31 bits are stored in the LOFF chunk, the offset in OOFF is then an index into LOFF
+// with the most significant bit set.
+//
+// see https://git-scm.com/docs/gitformat-pack#_multi_pack_index_midx_files_have_the_following_format
+
+var multiPackIndexMagic = []byte("MIDX")
+
+const (
+	multiPackIndexVersion    = 1
+	multiPackIndexHeaderSize = 12
+	multiPackIndexFile       = "multi-pack-index"
+)
+
+var (
+	chunkPackNames    = chunkID("PNAM")
+	chunkObjectOffset = chunkID("OOFF")
+	chunkLargeOffsets = chunkID("LOFF")
+)
+
+var ErrCorruptMultiPackIndex = errors.New("multi-pack-index is corrupt")
+
+type MultiPackIndex struct {
+	packNames    []string
+	fanout       [256]uint32
+	oids         []byte
+	offsets      []byte
+	largeOffsets []byte
+	checksum     []byte
+	hashSize     int
+}
+
+// MultiPackIndexEntry is an object together with the pack that contains it, Pack is the position of
+// the pack index in the list of pack names
+type MultiPackIndexEntry struct {
+	OID    string
+	Pack   uint32
+	Offset int64
+}
+
+func OpenMultiPackIndex(path string, format util.ObjectFormat) (*MultiPackIndex, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodeMultiPackIndex(data, format)
+}
+
+func DecodeMultiPackIndex(data []byte, format util.ObjectFormat) (*MultiPackIndex, error) {
+	hashSize := format.Size()
+	if len(data) < multiPackIndexHeaderSize+hashSize || !bytes.Equal(data[:4], multiPackIndexMagic) {
+		return nil, ErrCorruptMultiPackIndex
+	}
+
+	if data[4] != multiPackIndexVersion {
+		return nil, fmt.Errorf("multi-pack-index version %d not supported", data[4])
+	}
+
+	if data[5] != hashVersion(format) {
+		return nil, fmt.Errorf("multi-pack-index hash version %d does not match object format %s", data[5], format)
+	}
+
+	if data[7] != 0 {
+		return nil, fmt.Errorf("multi-pack-index with base files not supported")
+	}
+
+	end := len(data) - hashSize
+	chunks, err := readChunks(data, multiPackIndexHeaderSize, int(data[6]), end)
+	if err != nil {
+		return nil, ErrCorruptMultiPackIndex
+	}
+
+	midx := &MultiPackIndex{
+		oids:         chunks[chunkOIDLookup],
+		offsets:      chunks[chunkObjectOffset],
+		largeOffsets: chunks[chunkLargeOffsets],
+		checksum:     data[end:],
+		hashSize:     hashSize,
+	}
+
+	packCount := int(binary.BigEndian.Uint32(data[8:]))
+	for _, name := range strings.Split(string(chunks[chunkPackNames]), "\x00") {
+		if name != "" {
+			midx.packNames = append(midx.packNames, name)
+		}
+	}
+
+	if len(midx.packNames) != packCount {
+		return nil, ErrCorruptMultiPackIndex
+	}
+
+	fanout := chunks[chunkOIDFanout]
+	if len(fanout) != len(midx.fanout)*4 {
+		return nil, ErrCorruptMultiPackIndex
+	}
+
+	for i := range midx.fanout {
+		midx.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+		if i > 0 && midx.fanout[i] < midx.fanout[i-1] {
+			return nil, ErrCorruptMultiPackIndex
+		}
+	}
+
+	count := int(midx.fanout[255])
+	if len(midx.oids) != count*hashSize || len(midx.offsets) != count*8 || len(midx.largeOffsets)%8 != 0 {
+		return nil, ErrCorruptMultiPackIndex
+	}
+
+	return midx, nil
+}
+
+// Count returns the number of objects in all packs of the multi-pack-index
+func (midx *MultiPackIndex) Count() int {
+	return int(midx.fanout[255])
+}
+
+// PackNames returns the names of the pack indexes in the order of their pack ids
+func (midx *MultiPackIndex) PackNames() []string {
+	return midx.packNames
+}
+
+func (midx *MultiPackIndex) Checksum() []byte {
+	return midx.checksum
+}
+
+// OID returns the object id at position n in the sorted list of object names
+func (midx *MultiPackIndex) OID(n int) string {
+	return hex.EncodeToString(midx.rawOID(n))
+}
+
+// Entry returns the object at position n together with the pack that contains it
+func (midx *MultiPackIndex) Entry(n int) (MultiPackIndexEntry, error) {
+	pack := binary.BigEndian.Uint32(midx.offsets[n*8:])
+	offset := binary.BigEndian.Uint32(midx.offsets[n*8+4:])
+	entry := MultiPackIndexEntry{OID: midx.OID(n), Pack: pack, Offset: int64(offset)}
+
+	if int(pack) >= len(midx.packNames) {
+		return entry, ErrCorruptMultiPackIndex
+	}
+
+	if len(midx.largeOffsets) > 0 && offset&largeOffsetFlag != 0 {
+		large := int(offset &^ largeOffsetFlag)
+		if (large+1)*8 > len(midx.largeOffsets) {
+			return entry, ErrCorruptMultiPackIndex
+		}
+		entry.Offset = int64(binary.BigEndian.Uint64(midx.largeOffsets[large*8:]))
+	}
+
+	return entry, nil
+}
+
+// Find returns the pack that contains the object and the offset of the object inside the pack
+func (midx *MultiPackIndex) Find(oid string) (MultiPackIndexEntry, bool) {
+	n, ok := midx.find(oid)
+	if !ok {
+		return MultiPackIndexEntry{}, false
+	}
+
+	entry, err := midx.Entry(n)
+	return entry, err == nil
+}
+
+func (midx *MultiPackIndex) Contains(oid string) bool {
+	_, ok := midx.find(oid)
+	return ok
+}
+
+// FindPrefix returns all object ids in the multi-pack-index that start with the given hex prefix
+func (midx *MultiPackIndex) FindPrefix(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 {
+		return nil
+	}
+
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+
+	lo, hi := midx.bucket(first[0])
+	start := lo + sort.Search(hi-lo, func(i int) bool {
+		return midx.OID(lo+i) >= prefix
+	})
+
+	var oids []string
+	for n := start; n < hi; n++ {
+		oid := midx.OID(n)
+		if !strings.HasPrefix(oid, prefix) {
+			break
+		}
+		oids = append(oids, oid)
+	}
+
+	return oids
+}
+
+func (midx *MultiPackIndex) find(oid string) (int, bool) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || len(raw) != midx.hashSize {
+		return 0, false
+	}
+
+	lo, hi := midx.bucket(raw[0])
+	n := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(midx.rawOID(lo+i), raw) >= 0
+	})
+
+	if n < hi && bytes.Equal(midx.rawOID(n), raw) {
+		return n, true
+	}
+
+	return 0, false
+}
+
+func (midx *MultiPackIndex) bucket(first byte) (int, int) {
+	var lo uint32
+	if first > 0 {
+		lo = midx.fanout[first-1]
+	}
+
+	return int(lo), int(midx.fanout[first])
+}
+
+func (midx *MultiPackIndex) rawOID(n int) []byte {
+	return midx.oids[n*midx.hashSize : (n+1)*midx.hashSize]
+}
+
+// EncodeMultiPackIndex writes a multi-pack-index of the entries. The pack names must be sorted and every
+// object must be listed only once. It returns the checksum of the written index.
+func EncodeMultiPackIndex(writer io.Writer, packNames []string, entries []MultiPackIndexEntry, format util.ObjectFormat) ([]byte, error) {
+	if !sort.StringsAreSorted(packNames) {
+		return nil, fmt.Errorf("pack names of multi-pack-index must be sorted")
+	}
+
+	sorted := make([]MultiPackIndexEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OID < sorted[j].OID
+	})
+
+	var names bytes.Buffer
+	for _, name := range packNames {
+		names.WriteString(name)
+		names.WriteByte(0)
+	}
+
+	for names.Len()%4 != 0 {
+		names.WriteByte(0)
+	}
+
+	needsLargeOffsets := false
+	for _, entry := range sorted {
+		if entry.Offset > 0xffffffff {
+			needsLargeOffsets = true
+		}
+	}
+
+	var fanout, oids, offsets, largeOffsets bytes.Buffer
+	var counts [256]uint32
+	for i, entry := range sorted {
+		if i > 0 && sorted[i-1].OID == entry.OID {
+			return nil, fmt.Errorf("object %s is listed more than once", entry.OID)
+		}
+
+		if int(entry.Pack) >= len(packNames) {
+			return nil, fmt.Errorf("object %s refers to unknown pack %d", entry.OID, entry.Pack)
+		}
+
+		raw, err := decodeOID(entry.OID, format)
+		if err != nil {
+			return nil, err
+		}
+		oids.Write(raw)
+		counts[raw[0]]++
+
+		offset := uint32(entry.Offset)
+		if needsLargeOffsets && entry.Offset >= int64(largeOffsetFlag) {
+			offset = largeOffsetFlag | uint32(largeOffsets.Len()/8)
+			binary.Write(&largeOffsets, binary.BigEndian, uint64(entry.Offset))
+		}
+
+		binary.Write(&offsets, binary.BigEndian, entry.Pack)
+		binary.Write(&offsets, binary.BigEndian, offset)
+	}
+
+	var total uint32
+	for _, count := range counts {
+		total += count
+		binary.Write(&fanout, binary.BigEndian, total)
+	}
+
+	chunks := []chunk{
+		{id: chunkPackNames, data: names.Bytes()},
+		{id: chunkOIDFanout, data: fanout.Bytes()},
+		{id: chunkOIDLookup, data: oids.Bytes()},
+		{id: chunkObjectOffset, data: offsets.Bytes()},
+	}
+
+	if largeOffsets.Len() > 0 {
+		chunks = append(chunks, chunk{id: chunkLargeOffsets, data: largeOffsets.Bytes()})
+	}
+
+	hasher := format.NewHasher()
+	mw := io.MultiWriter(writer, hasher)
+
+	header := append(multiPackIndexMagic[:4:4], multiPackIndexVersion, hashVersion(format), byte(len(chunks)), 0)
+	header = append(header, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(packNames)))
+	if _, err := mw.Write(header); err != nil {
+		return nil, err
+	}
+
+	if err := writeChunks(mw, len(header), chunks); err != nil {
+		return nil, err
+	}
+
+	checksum := hasher.Sum(nil)
+	if _, err := writer.Write(checksum); err != nil {
+		return nil, err
+	}
+
+	return checksum, nil
+}
+
+// MultiPackIndex returns the multi-pack-index of the store or nil if there is none or it cannot be read
+func (store *FilesystemStore) MultiPackIndex() (*MultiPackIndex, error) {
+	store.packsMtx.Lock()
+	defer store.packsMtx.Unlock()
+
+	store.rescanChangedPacksLocked()
+	if err := store.loadPacksLocked(); err != nil {
+		return nil, err
+	}
+
+	return store.midx, nil
+}
+
+// WriteMultiPackIndex writes a multi-pack-index of all packs in objects/pack. Objects that are part of
+// several packs are taken from the most recently modified pack.
+func (store *FilesystemStore) WriteMultiPackIndex() (*MultiPackIndex, error) {
+	packs, err := store.Packs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(packs) == 0 {
+		return nil, fmt.Errorf("no pack files to index")
+	}
+
+	sort.Slice(packs, func(i, j int) bool {
+		return packIndexName(packs[i]) < packIndexName(packs[j])
+	})
+
+	type candidate struct {
+		entry   MultiPackIndexEntry
+		modTime time.Time
+	}
+
+	candidates := make(map[string]candidate)
+	packNames := make([]string, len(packs))
+	for id, pack := range packs {
+		packNames[id] = packIndexName(pack)
+		stat, err := os.Stat(pack.Path())
+		if err != nil {
+			return nil, err
+		}
+
+		index := pack.Index()
+		for n := 0; n < index.Count(); n++ {
+			oid := index.OID(n)
+			if existing, ok := candidates[oid]; ok && !stat.ModTime().After(existing.modTime) {
+				continue
+			}
+
+			candidates[oid] = candidate{
+				entry:   MultiPackIndexEntry{OID: oid, Pack: uint32(id), Offset: index.Offset(n)},
+				modTime: stat.ModTime(),
+			}
+		}
+	}
+
+	entries := make([]MultiPackIndexEntry, 0, len(candidates))
+	for _, c := range candidates {
+		entries = append(entries, c.entry)
+	}
+
+	packDir := filepath.Join(store.location, "pack")
+	file, err := ioutil.TempFile(packDir, "tmp_midx_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = EncodeMultiPackIndex(file, packNames, entries, store.format)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(packDir, multiPackIndexFile)
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, err
+	}
+
+	store.ReloadPacks()
+	return OpenMultiPackIndex(path, store.format)
+}
+
+// VerifyMultiPackIndex checks the checksum of the multi-pack-index and that every object is found at
+// the recorded offset of its pack. A store without multi-pack-index is valid.
+func (store *FilesystemStore) VerifyMultiPackIndex() error {
+	path := filepath.Join(store.location, "pack", multiPackIndexFile)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	midx, err := DecodeMultiPackIndex(data, store.format)
+	if err != nil {
+		return err
+	}
+
+	hashSize := store.format.Size()
+	hasher := store.format.NewHasher()
+	hasher.Write(data[:len(data)-hashSize])
+	if !bytes.Equal(hasher.Sum(nil), midx.Checksum()) {
+		return fmt.Errorf("multi-pack-index checksum mismatch")
+	}
+
+	if !sort.StringsAreSorted(midx.PackNames()) {
+		return fmt.Errorf("multi-pack-index pack names out of order")
+	}
+
+	indexes := make([]*PackIndex, len(midx.PackNames()))
+	for id, name := range midx.PackNames() {
+		indexes[id], err = OpenPackIndex(filepath.Join(store.location, "pack", name), store.format)
+		if err != nil {
+			return fmt.Errorf("failed to load pack %s in multi-pack-index: %v", name, err)
+		}
+	}
+
+	for n := 0; n < midx.Count(); n++ {
+		if n > 0 && bytes.Compare(midx.rawOID(n-1), midx.rawOID(n)) >= 0 {
+			return fmt.Errorf("multi-pack-index object ids out of order at position %d", n)
+		}
+
+		entry, err := midx.Entry(n)
+		if err != nil {
+			return fmt.Errorf("multi-pack-index entry of %s is corrupt", entry.OID)
+		}
+
+		offset, ok := indexes[entry.Pack].FindOffset(entry.OID)
+		if !ok || offset != entry.Offset {
+			return fmt.Errorf("incorrect object offset for %s in %s", entry.OID, midx.PackNames()[entry.Pack])
+		}
+	}
+
+	return nil
+}
+
+// ExpireMultiPackIndex deletes the packs of the multi-pack-index whose objects are all taken from other
+// packs and rewrites the multi-pack-index. Packs with a .keep file are never deleted.
+func (store *FilesystemStore) ExpireMultiPackIndex() ([]string, error) {
+	midx, err := store.MultiPackIndex()
+	if err != nil || midx == nil {
+		return nil, err
+	}
+
+	referenced := make([]int, len(midx.PackNames()))
+	for n := 0; n < midx.Count(); n++ {
+		entry, err := midx.Entry(n)
+		if err != nil {
+			return nil, err
+		}
+		referenced[entry.Pack]++
+	}
+
+	var expired []string
+	for id, name := range midx.PackNames() {
+		base := strings.TrimSuffix(filepath.Join(store.location, "pack", name), ".idx")
+		if referenced[id] > 0 || fileExists(base+".keep") {
+			continue
+		}
+
+		if err := os.Remove(base + ".pack"); err != nil && !os.IsNotExist(err) {
+			return expired, err
+		}
+
+		for _, ext := range []string{".bitmap", ".idx"} {
+			if err := os.Remove(base + ext); err != nil && !os.IsNotExist(err) {
+				return expired, err
+			}
+		}
+		expired = append(expired, base+".pack")
+	}
+
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	store.ReloadPacks()
+	if _, err := store.WriteMultiPackIndex(); err != nil {
+		return expired, err
+	}
+
+	return expired, nil
+}
+
+// RepackMultiPackIndex packs the objects of small packs of the multi-pack-index into a new pack and adds
+// it to the multi-pack-index. Packs are selected from the oldest to the newest if the size of the objects
+// they contribute is below batchSize, until the selected packs add up to batchSize. A batchSize of zero
+// selects all packs. The old packs are kept, they are removed by ExpireMultiPackIndex. It returns nil if
+// there was nothing to repack.
+func (store *FilesystemStore) RepackMultiPackIndex(batchSize int64, options PackOptions) (*Packfile, error) {
+	midx, err := store.MultiPackIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	if midx == nil {
+		return nil, fmt.Errorf("no multi-pack-index to repack")
+	}
+
+	referenced := make([]int, len(midx.PackNames()))
+	for n := 0; n < midx.Count(); n++ {
+		entry, err := midx.Entry(n)
+		if err != nil {
+			return nil, err
+		}
+		referenced[entry.Pack]++
+	}
+
+	type packInfo struct {
+		id           uint32
+		modTime      time.Time
+		expectedSize int64
+	}
+
+	var infos []packInfo
+	for id, name := range midx.PackNames() {
+		base := strings.TrimSuffix(filepath.Join(store.location, "pack", name), ".idx")
+		stat, err := os.Stat(base + ".pack")
+		if err != nil || fileExists(base+".keep") || referenced[id] == 0 {
+			continue
+		}
+
+		index, err := OpenPackIndex(base+".idx", store.format)
+		if err != nil {
+			return nil, err
+		}
+
+		expectedSize := stat.Size()
+		if index.Count() > 0 {
+			expectedSize = stat.Size() * int64(referenced[id]) / int64(index.Count())
+		}
+		infos = append(infos, packInfo{id: uint32(id), modTime: stat.ModTime(), expectedSize: expectedSize})
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].modTime.Before(infos[j].modTime)
+	})
+
+	selected := make(map[uint32]bool)
+	var total int64
+	for _, info := range infos {
+		if batchSize > 0 && total >= batchSize {
+			break
+		}
+
+		if batchSize == 0 || info.expectedSize < batchSize {
+			selected[info.id] = true
+			total += info.expectedSize
+		}
+	}
+
+	if len(selected) < 2 || total < batchSize {
+		return nil, nil
+	}
+
+	var oids []string
+	for n := 0; n < midx.Count(); n++ {
+		entry, err := midx.Entry(n)
+		if err != nil {
+			return nil, err
+		}
+
+		if selected[entry.Pack] {
+			oids = append(oids, entry.OID)
+		}
+	}
+
+	pack, err := store.WritePack(oids, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := store.WriteMultiPackIndex(); err != nil {
+		return nil, err
+	}
+
+	return pack, nil
+}
+
+func packIndexName(pack *Packfile) string {
+	return strings.TrimSuffix(filepath.Base(pack.Path()), ".pack") + ".idx"
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
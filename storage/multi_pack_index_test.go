@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// createStoreWithPacks copies the objects of the test repository into two packs that share the commit
+func createStoreWithPacks(t *testing.T, dir string) (*FilesystemStore, []string) {
+	source := NewFsStore(ofsPackRepo)
+	store := NewFsStore(dir)
+	oids := []string{packedCommit, packedTree, packedTag, packedDeltaBlob, packedBaseBlob}
+	for _, oid := range oids {
+		data, err := source.Get(oid)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", oid, err)
+		}
+
+		if err := store.Put(oid, data); err != nil {
+			t.Fatalf("could not store %s: %v", oid, err)
+		}
+	}
+
+	for i, batch := range [][]string{{packedCommit, packedTree, packedTag}, {packedCommit, packedDeltaBlob, packedBaseBlob}} {
+		pack, err := store.WritePack(batch, DefaultPackOptions())
+		if err != nil {
+			t.Fatalf("could not write pack: %v", err)
+		}
+
+		modTime := time.Now().Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(pack.Path(), modTime, modTime); err != nil {
+			t.Fatalf("could not change modification time: %v", err)
+		}
+	}
+
+	for _, oid := range oids {
+		if err := store.Delete(oid); err != nil {
+			t.Fatalf("could not delete loose object %s: %v", oid, err)
+		}
+	}
+
+	return store, oids
+}
+
+func TestFsStoreReadsObjectsThroughMultiPackIndex(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store, oids := createStoreWithPacks(t, dir)
+	midx, err := store.WriteMultiPackIndex()
+	if err != nil {
+		t.Fatalf("could not write multi-pack-index: %v", err)
+	}
+
+	assert.Equal(t, len(oids), midx.Count())
+	assert.Equal(t, 2, len(midx.PackNames()))
+	assert.NoError(t, store.VerifyMultiPackIndex())
+
+	packs, err := store.Packs()
+	assert.NoError(t, err)
+	var newest *Packfile
+	for _, pack := range packs {
+		if pack.Contains(packedBaseBlob) {
+			newest = pack
+		}
+	}
+
+	entry, ok := midx.Find(packedCommit)
+	assert.True(t, ok)
+	assert.Equal(t, packIndexName(newest), midx.PackNames()[entry.Pack], "duplicates are taken from the newest pack")
+
+	for _, oid := range oids {
+		data, err := store.Get(oid)
+		if err != nil {
+			t.Errorf("could not read %s: %v", oid, err)
+			continue
+		}
+		assert.Equal(t, oid, hashData(data))
+
+		exists, err := store.Stat(oid)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	found, err := store.Find(packedCommit[:6])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{packedCommit}, found)
+}
+
+func TestVerifyMultiPackIndexDetectsCorruption(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store, _ := createStoreWithPacks(t, dir)
+	if _, err := store.WriteMultiPackIndex(); err != nil {
+		t.Fatalf("could not write multi-pack-index: %v", err)
+	}
+
+	path := filepath.Join(dir, "objects", "pack", multiPackIndexFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read multi-pack-index: %v", err)
+	}
+
+	// flip a bit inside the object offsets, which is followed by the checksum
+	data[len(data)-21] ^= 1
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatalf("could not change permissions: %v", err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("could not write multi-pack-index: %v", err)
+	}
+
+	assert.Error(t, store.VerifyMultiPackIndex())
+}
+
+func TestRepackAndExpireMultiPackIndex(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store, oids := createStoreWithPacks(t, dir)
+	if _, err := store.WriteMultiPackIndex(); err != nil {
+		t.Fatalf("could not write multi-pack-index: %v", err)
+	}
+
+	pack, err := store.RepackMultiPackIndex(0, DefaultPackOptions())
+	if err != nil {
+		t.Fatalf("could not repack: %v", err)
+	}
+	assert.Equal(t, len(oids), pack.Index().Count())
+
+	expired, err := store.ExpireMultiPackIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(expired))
+
+	packs, err := store.Packs()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(packs))
+
+	midx, err := store.MultiPackIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{packIndexName(pack)}, midx.PackNames())
+	assert.NoError(t, store.VerifyMultiPackIndex())
+
+	for _, oid := range oids {
+		_, err := store.Get(oid)
+		assert.NoError(t, err)
+	}
+}
+
+func TestDeletePackRemovesMultiPackIndex(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store, _ := createStoreWithPacks(t, dir)
+	if _, err := store.WriteMultiPackIndex(); err != nil {
+		t.Fatalf("could not write multi-pack-index: %v", err)
+	}
+
+	packs, err := store.Packs()
+	assert.NoError(t, err)
+	assert.NoError(t, store.DeletePack(packs[0]))
+
+	midx, err := store.MultiPackIndex()
+	assert.NoError(t, err)
+	assert.Nil(t, midx)
+}
+
+func TestMultiPackIndexLargeOffsets(t *testing.T) {
+	entries := []MultiPackIndexEntry{
+		{OID: packedCommit, Pack: 0, Offset: 12},
+		{OID: packedTree, Pack: 1, Offset: 1 << 33},
+	}
+
+	var buf bytes.Buffer
+	_, err := EncodeMultiPackIndex(&buf, []string{"pack-a.idx", "pack-b.idx"}, entries, util.SHA1)
+	assert.NoError(t, err)
+
+	midx, err := DecodeMultiPackIndex(buf.Bytes(), util.SHA1)
+	if err != nil {
+		t.Fatalf("could not decode multi-pack-index: %v", err)
+	}
+
+	for _, expected := range entries {
+		entry, ok := midx.Find(expected.OID)
+		assert.True(t, ok)
+		assert.Equal(t, expected, entry)
+	}
+}
@@ -0,0 +1,438 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The on disk representation of a pack file is
+// PACK [version] [number of objects] [objects] [trailer]
+// Every object starts with a variable length header containing the type and the inflated size
+// of the object. Deltified objects additionally carry a reference to their base object, either as
+// a negative offset relative to their own position (OFS_DELTA) or as the object id of the
+// base (REF_DELTA). The header is followed by the zlib compressed data.
+//
+// see https://git-scm.com/docs/pack-format
+
+var packMagic = []byte("PACK")
+
+const maxDeltaChainLength = 10000
+
+var (
+	ErrCorruptPack            = errors.New("pack file is corrupt")
+	ErrUnsupportedPackVersion = errors.New("pack version not supported")
+	ErrDeltaChainTooLong      = errors.New("delta chain too long")
+)
+
+type PackObjectType int8
+
+const (
+	PackCommit   PackObjectType = 1
+	PackTree     PackObjectType = 2
+	PackBlob     PackObjectType = 3
+	PackTag      PackObjectType = 4
+	PackOfsDelta PackObjectType = 6
+	PackRefDelta PackObjectType = 7
+)
+
+func (t PackObjectType) String() string {
+	switch t {
+	case PackCommit:
+		return "commit"
+	case PackTree:
+		return "tree"
+	case PackBlob:
+		return "blob"
+	case PackTag:
+		return "tag"
+	case PackOfsDelta:
+		return "ofs-delta"
+	case PackRefDelta:
+		return "ref-delta"
+	default:
+		return "unknown"
+	}
+}
+
+func parsePackObjectType(name string) (PackObjectType, error) {
+	switch name {
+	case "commit":
+		return PackCommit, nil
+	case "tree":
+		return PackTree, nil
+	case "blob":
+		return PackBlob, nil
+	case "tag":
+		return PackTag, nil
+	default:
+		return 0, fmt.Errorf("unknown object type %s", name)
+	}
+}
+
+// BaseResolver is used to look up base objects of REF_DELTA entries that are not part of the pack
+type BaseResolver func(oid string) ([]byte, error)
+
+type Packfile struct {
+	path         string
+	index        *PackIndex
+	format       util.ObjectFormat
+	bitmap       *PackBitmap
+	bitmapLoaded bool
+	bitmapMtx    sync.Mutex
+}
+
+// OpenPackfile opens the pack at the given path together with the index that lies next to it
+func OpenPackfile(packPath string, format util.ObjectFormat) (*Packfile, error) {
+	idxPath := strings.TrimSuffix(packPath, ".pack") + ".idx"
+	index, err := OpenPackIndex(idxPath, format)
+	if err != nil {
+		return nil, err
+	}
+
+	packFile, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	defer packFile.Close()
+
+	if _, _, err := readPackHeader(packFile); err != nil {
+		return nil, err
+	}
+
+	return &Packfile{
+		path:   packPath,
+		index:  index,
+		format: format,
+	}, nil
+}
+
+func readPackHeader(reader io.Reader) (version uint32, count uint32, err error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		return 0, 0, err
+	}
+
+	if !bytes.Equal(magic, packMagic) {
+		return 0, 0, ErrCorruptPack
+	}
+
+	if err := binary.Read(reader, binary.BigEndian, &version); err != nil {
+		return 0, 0, err
+	}
+
+	if version != 2 && version != 3 {
+		return 0, 0, ErrUnsupportedPackVersion
+	}
+
+	if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return 0, 0, err
+	}
+
+	return version, count, nil
+}
+
+func (p *Packfile) Path() string {
+	return p.path
+}
+
+func (p *Packfile) Index() *PackIndex {
+	return p.index
+}
+
+// Verify checks that the trailing checksum matches the content of the pack and the checksum
+// recorded in the index
+func (p *Packfile) Verify() error {
+	packFile, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer packFile.Close()
+
+	stat, err := packFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	hashSize := int64(p.format.Size())
+	if stat.Size() < hashSize {
+		return ErrCorruptPack
+	}
+
+	hasher := p.format.NewHasher()
+	if _, err := io.CopyN(hasher, packFile, stat.Size()-hashSize); err != nil {
+		return err
+	}
+
+	trailer := make([]byte, hashSize)
+	if _, err := io.ReadFull(packFile, trailer); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(hasher.Sum(nil), trailer) {
+		return errors.New("pack checksum mismatch")
+	}
+
+	if !bytes.Equal(trailer, p.index.PackChecksum()) {
+		return errors.New("pack checksum does not match index")
+	}
+
+	return nil
+}
+
+func (p *Packfile) Contains(oid string) bool {
+	return p.index.Contains(oid)
+}
+
+// Get returns the object in the same representation as a loose object, i.e. [type] [size]\x00[content]
+func (p *Packfile) Get(oid string, resolve BaseResolver) ([]byte, error) {
+	offset, ok := p.index.FindOffset(oid)
+	if !ok {
+		return nil, objectNotFound(oid)
+	}
+
+	return p.getAt(offset, resolve)
+}
+
+func (p *Packfile) getAt(offset int64, resolve BaseResolver) ([]byte, error) {
+	packFile, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer packFile.Close()
+
+	objectType, content, err := p.readObject(packFile, offset, resolve, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeLooseObject(objectType.String(), content), nil
+}
+
+// Open returns a reader for the content of the object. Objects that are stored in their entirety are
+// inflated while reading, deltified objects need to be reconstructed in memory.
+func (p *Packfile) Open(oid string, resolve BaseResolver) (PackObjectType, uint64, io.ReadCloser, error) {
+	offset, ok := p.index.FindOffset(oid)
+	if !ok {
+		return 0, 0, nil, objectNotFound(oid)
+	}
+
+	return p.openAt(offset, resolve)
+}
+
+func (p *Packfile) openAt(offset int64, resolve BaseResolver) (PackObjectType, uint64, io.ReadCloser, error) {
+	packFile, err := os.Open(p.path)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(packFile, offset, 1<<62))
+	objectType, size, err := readPackObjectHeader(reader)
+	if err != nil {
+		packFile.Close()
+		return 0, 0, nil, err
+	}
+
+	if objectType == PackOfsDelta || objectType == PackRefDelta {
+		defer packFile.Close()
+
+		objectType, content, err := p.readObject(packFile, offset, resolve, 0)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+
+		return objectType, uint64(len(content)), ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		packFile.Close()
+		return 0, 0, nil, err
+	}
+
+	return objectType, size, newObjectReader(io.LimitReader(zr, int64(size)), zr, packFile), nil
+}
+
+func (p *Packfile) readObject(packFile *os.File, offset int64, resolve BaseResolver, depth int) (PackObjectType, []byte, error) {
+	if depth > maxDeltaChainLength {
+		return 0, nil, ErrDeltaChainTooLong
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(packFile, offset, 1<<62))
+	objectType, size, err := readPackObjectHeader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	switch objectType {
+	case PackCommit, PackTree, PackBlob, PackTag:
+		content, err := inflate(reader, size)
+		return objectType, content, err
+	case PackOfsDelta:
+		relative, err := readBaseOffset(reader)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if relative <= 0 || relative > offset {
+			return 0, nil, ErrCorruptPack
+		}
+
+		delta, err := inflate(reader, size)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err := p.readObject(packFile, offset-relative, resolve, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		content, err := ApplyDelta(base, delta)
+		return baseType, content, err
+	case PackRefDelta:
+		rawBase := make([]byte, p.index.hashSize)
+		if _, err := io.ReadFull(reader, rawBase); err != nil {
+			return 0, nil, err
+		}
+
+		delta, err := inflate(reader, size)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		baseType, base, err := p.readBase(packFile, hex.EncodeToString(rawBase), resolve, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		content, err := ApplyDelta(base, delta)
+		return baseType, content, err
+	default:
+		return 0, nil, ErrCorruptPack
+	}
+}
+
+func (p *Packfile) readBase(packFile *os.File, oid string, resolve BaseResolver, depth int) (PackObjectType, []byte, error) {
+	if offset, ok := p.index.FindOffset(oid); ok {
+		return p.readObject(packFile, offset, resolve, depth)
+	}
+
+	if resolve == nil {
+		return 0, nil, fmt.Errorf("base object %s of delta could not be found", oid)
+	}
+
+	data, err := resolve(oid)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return decodeLooseObject(data)
+}
+
+func readPackObjectHeader(reader io.ByteReader) (PackObjectType, uint64, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	objectType := PackObjectType((b >> 4) & 0x07)
+	size := uint64(b & 0x0f)
+	shift := uint(4)
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= uint64(b&0x7f) << shift
+		shift += 7
+	}
+
+	return objectType, size, nil
+}
+
+func readBaseOffset(reader io.ByteReader) (int64, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	offset := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		offset = ((offset + 1) << 7) | int64(b&0x7f)
+	}
+
+	return offset, nil
+}
+
+// inflateBufferLimit is the largest buffer that is allocated up front for an inflated object, larger objects
+// grow their buffer while they are read since their size comes from the untrusted pack
+const inflateBufferLimit = 1 << 20
+
+// inflate decompresses an object of the pack, it fails with ErrCorruptPack if the content does not have the size
+// that the header of the object states
+func inflate(reader io.Reader, size uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	capacity := size
+	if capacity > inflateBufferLimit {
+		capacity = inflateBufferLimit
+	}
+
+	content := bytes.NewBuffer(make([]byte, 0, capacity))
+	if _, err := io.Copy(content, io.LimitReader(zr, int64(size)+1)); err != nil {
+		return nil, err
+	}
+
+	if uint64(content.Len()) != size {
+		return nil, ErrCorruptPack
+	}
+
+	return content.Bytes(), nil
+}
+
+func encodeLooseObject(objectType string, content []byte) []byte {
+	header := fmt.Sprintf("%s %d\x00", objectType, len(content))
+	return append([]byte(header), content...)
+}
+
+func decodeLooseObject(data []byte) (PackObjectType, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end == -1 {
+		return 0, nil, fmt.Errorf("malformed object")
+	}
+
+	header := strings.SplitN(string(data[:end]), " ", 2)
+	if len(header) != 2 {
+		return 0, nil, fmt.Errorf("malformed object")
+	}
+
+	objectType, err := parsePackObjectType(header[0])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size, err := strconv.ParseUint(header[1], 10, 64)
+	if err != nil || size != uint64(len(data)-end-1) {
+		return 0, nil, fmt.Errorf("malformed object")
+	}
+
+	return objectType, data[end+1:], nil
+}
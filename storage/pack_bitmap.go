@@ -0,0 +1,392 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The on disk representation of a pack bitmap is
+// BITM [version] [options] [number of entries] [pack checksum] [type bitmaps] [entries] [name hashes] [checksum]
+// Bit positions refer to the objects of the pack sorted by their offset. The four type bitmaps mark the
+// commits, trees, blobs and tags of the pack. Every entry consists of the position of a commit in the pack
+// index, the distance to an earlier entry whose bitmap was xored with the bitmap of the commit, flags and
+// the bitmap of all objects that are reachable from the commit.
+//
+// see https://git-scm.com/docs/bitmap-format
+
+var packBitmapMagic = []byte("BITM")
+
+const (
+	packBitmapVersion   = 1
+	bitmapOptionFullDAG = 0x1
+)
+
+// PackBitmap holds the reachability bitmaps of the commits of a pack
+type PackBitmap struct {
+	pack    *Packfile
+	order   []int
+	rank    []int
+	types   [4]*Bitmap
+	commits map[string]*Bitmap
+}
+
+var bitmapTypes = []PackObjectType{PackCommit, PackTree, PackBlob, PackTag}
+
+// NewPackBitmap creates an empty bitmap for the pack, the type bitmaps are computed from the pack
+func NewPackBitmap(pack *Packfile) (*PackBitmap, error) {
+	pb := newPackBitmap(pack)
+	for i := range pb.types {
+		pb.types[i] = NewBitmap()
+	}
+
+	packFile, err := os.Open(pack.Path())
+	if err != nil {
+		return nil, err
+	}
+	defer packFile.Close()
+
+	known := make(map[int64]PackObjectType)
+	for pos, n := range pb.order {
+		objectType, err := pack.typeAt(packFile, pack.index.Offset(n), known, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, bitmapType := range bitmapTypes {
+			if objectType == bitmapType {
+				pb.types[i].Set(pos)
+			}
+		}
+	}
+
+	return pb, nil
+}
+
+func newPackBitmap(pack *Packfile) *PackBitmap {
+	count := pack.index.Count()
+	pb := &PackBitmap{
+		pack:    pack,
+		order:   make([]int, count),
+		rank:    make([]int, count),
+		commits: make(map[string]*Bitmap),
+	}
+
+	for n := range pb.order {
+		pb.order[n] = n
+	}
+
+	sort.Slice(pb.order, func(i, j int) bool {
+		return pack.index.Offset(pb.order[i]) < pack.index.Offset(pb.order[j])
+	})
+
+	for pos, n := range pb.order {
+		pb.rank[n] = pos
+	}
+
+	return pb
+}
+
+func OpenPackBitmap(path string, pack *Packfile) (*PackBitmap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecodePackBitmap(data, pack)
+}
+
+func DecodePackBitmap(data []byte, pack *Packfile) (*PackBitmap, error) {
+	hashSize := pack.format.Size()
+	if len(data) < 12+2*hashSize || !bytes.Equal(data[:4], packBitmapMagic) {
+		return nil, ErrCorruptBitmap
+	}
+
+	if version := binary.BigEndian.Uint16(data[4:]); version != packBitmapVersion {
+		return nil, fmt.Errorf("bitmap version %d not supported", version)
+	}
+
+	options := binary.BigEndian.Uint16(data[6:])
+	if options&bitmapOptionFullDAG == 0 {
+		return nil, fmt.Errorf("bitmaps without full closure are not supported")
+	}
+
+	if !bytes.Equal(data[12:12+hashSize], pack.index.PackChecksum()) {
+		return nil, fmt.Errorf("bitmap does not match pack %s", filepath.Base(pack.Path()))
+	}
+
+	hasher := pack.format.NewHasher()
+	hasher.Write(data[:len(data)-hashSize])
+	if !bytes.Equal(hasher.Sum(nil), data[len(data)-hashSize:]) {
+		return nil, ErrCorruptBitmap
+	}
+
+	pb := newPackBitmap(pack)
+	reader := bytes.NewReader(data[12+hashSize : len(data)-hashSize])
+	for i := range pb.types {
+		bitmap, err := readEWAH(reader)
+		if err != nil {
+			return nil, ErrCorruptBitmap
+		}
+		pb.types[i] = bitmap
+	}
+
+	count := int(binary.BigEndian.Uint32(data[8:]))
+	entries := make([]*Bitmap, count)
+	for i := 0; i < count; i++ {
+		var header struct {
+			Position  uint32
+			XorOffset uint8
+			Flags     uint8
+		}
+		if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+			return nil, ErrCorruptBitmap
+		}
+
+		bitmap, err := readEWAH(reader)
+		if err != nil {
+			return nil, ErrCorruptBitmap
+		}
+
+		if int(header.Position) >= pack.index.Count() || int(header.XorOffset) > i {
+			return nil, ErrCorruptBitmap
+		}
+
+		if header.XorOffset > 0 {
+			bitmap.Xor(entries[i-int(header.XorOffset)])
+		}
+
+		entries[i] = bitmap
+		pb.commits[pack.index.OID(int(header.Position))] = bitmap
+	}
+
+	return pb, nil
+}
+
+// Pack returns the pack whose objects are described by the bitmap
+func (pb *PackBitmap) Pack() *Packfile {
+	return pb.pack
+}
+
+// Position returns the bit position of the object, which is its position in the pack ordered by offset
+func (pb *PackBitmap) Position(oid string) (int, bool) {
+	n, ok := pb.pack.index.find(oid)
+	if !ok {
+		return 0, false
+	}
+
+	return pb.rank[n], true
+}
+
+// OID returns the id of the object at the bit position
+func (pb *PackBitmap) OID(pos int) string {
+	return pb.pack.index.OID(pb.order[pos])
+}
+
+// ObjectType returns the type of the object at the bit position
+func (pb *PackBitmap) ObjectType(pos int) string {
+	for i, bitmapType := range bitmapTypes {
+		if pb.types[i].Get(pos) {
+			return bitmapType.String()
+		}
+	}
+
+	return ""
+}
+
+// Commit returns the bitmap of all objects that are reachable from the commit, the bitmap must not be modified
+func (pb *PackBitmap) Commit(oid string) (*Bitmap, bool) {
+	bitmap, ok := pb.commits[oid]
+	return bitmap, ok
+}
+
+// Commits returns the number of commits that have a bitmap
+func (pb *PackBitmap) Commits() int {
+	return len(pb.commits)
+}
+
+// AddCommit stores the bitmap of all objects that are reachable from the commit
+func (pb *PackBitmap) AddCommit(oid string, bitmap *Bitmap) error {
+	pos, ok := pb.Position(oid)
+	if !ok || !pb.types[0].Get(pos) {
+		return fmt.Errorf("commit %s is not part of the pack", oid)
+	}
+
+	pb.commits[oid] = bitmap
+	return nil
+}
+
+// Encode writes the bitmap, the bitmaps of the commits are written without xor compression
+func (pb *PackBitmap) Encode(writer io.Writer) error {
+	hasher := pb.pack.format.NewHasher()
+	mw := io.MultiWriter(writer, hasher)
+
+	var header bytes.Buffer
+	header.Write(packBitmapMagic)
+	binary.Write(&header, binary.BigEndian, uint16(packBitmapVersion))
+	binary.Write(&header, binary.BigEndian, uint16(bitmapOptionFullDAG))
+	binary.Write(&header, binary.BigEndian, uint32(len(pb.commits)))
+	header.Write(pb.pack.index.PackChecksum())
+	if _, err := mw.Write(header.Bytes()); err != nil {
+		return err
+	}
+
+	for _, bitmap := range pb.types {
+		if err := writeEWAH(mw, bitmap); err != nil {
+			return err
+		}
+	}
+
+	positions := make([]int, 0, len(pb.commits))
+	for oid := range pb.commits {
+		n, _ := pb.pack.index.find(oid)
+		positions = append(positions, n)
+	}
+
+	sort.Slice(positions, func(i, j int) bool {
+		return pb.rank[positions[i]] < pb.rank[positions[j]]
+	})
+
+	for _, n := range positions {
+		entryHeader := []byte{0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(entryHeader, uint32(n))
+		if _, err := mw.Write(entryHeader); err != nil {
+			return err
+		}
+
+		if err := writeEWAH(mw, pb.commits[pb.pack.index.OID(n)]); err != nil {
+			return err
+		}
+	}
+
+	_, err := writer.Write(hasher.Sum(nil))
+	return err
+}
+
+// Bitmap returns the reachability bitmap of the pack or nil if the pack has none
+func (p *Packfile) Bitmap() (*PackBitmap, error) {
+	p.bitmapMtx.Lock()
+	defer p.bitmapMtx.Unlock()
+
+	if p.bitmapLoaded {
+		return p.bitmap, nil
+	}
+
+	bitmap, err := OpenPackBitmap(strings.TrimSuffix(p.path, ".pack")+".bitmap", p)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	p.bitmap = bitmap
+	p.bitmapLoaded = true
+	return p.bitmap, nil
+}
+
+// typeAt returns the type of the object at the offset, the base objects of deltas are followed until
+// an object that is stored in its entirety is found
+func (p *Packfile) typeAt(packFile *os.File, offset int64, known map[int64]PackObjectType, depth int) (PackObjectType, error) {
+	if objectType, ok := known[offset]; ok {
+		return objectType, nil
+	}
+
+	if depth > maxDeltaChainLength {
+		return 0, ErrDeltaChainTooLong
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(packFile, offset, 1<<62))
+	objectType, _, err := readPackObjectHeader(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	switch objectType {
+	case PackOfsDelta:
+		relative, err := readBaseOffset(reader)
+		if err != nil {
+			return 0, err
+		}
+
+		if relative <= 0 || relative > offset {
+			return 0, ErrCorruptPack
+		}
+
+		if objectType, err = p.typeAt(packFile, offset-relative, known, depth+1); err != nil {
+			return 0, err
+		}
+	case PackRefDelta:
+		rawBase := make([]byte, p.index.hashSize)
+		if _, err := io.ReadFull(reader, rawBase); err != nil {
+			return 0, err
+		}
+
+		baseOffset, ok := p.index.FindOffset(hex.EncodeToString(rawBase))
+		if !ok {
+			return 0, fmt.Errorf("base object %x of delta is not part of the pack", rawBase)
+		}
+
+		if objectType, err = p.typeAt(packFile, baseOffset, known, depth+1); err != nil {
+			return 0, err
+		}
+	}
+
+	known[offset] = objectType
+	return objectType, nil
+}
+
+// WritePackBitmap writes the bitmap next to its pack
+func (store *FilesystemStore) WritePackBitmap(bitmap *PackBitmap) error {
+	defer store.ReloadPacks()
+
+	path := bitmap.Pack().Path()
+	file, err := ioutil.TempFile(filepath.Dir(path), "tmp_bitmap_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	err = bitmap.Encode(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), strings.TrimSuffix(path, ".pack")+".bitmap")
+}
+
+// LoadPackBitmap returns the bitmap of the first pack of the store that has one. Nil is returned if the store
+// does not support packs, no pack has a bitmap or it cannot be read, since bitmaps are only an optimization.
+func LoadPackBitmap(store ObjectStore) *PackBitmap {
+	fsStore, ok := Unwrap(store).(*FilesystemStore)
+	if !ok {
+		return nil
+	}
+
+	packs, err := fsStore.Packs()
+	if err != nil {
+		return nil
+	}
+
+	for _, pack := range packs {
+		if bitmap, err := pack.Bitmap(); err == nil && bitmap != nil {
+			return bitmap
+		}
+	}
+
+	return nil
+}
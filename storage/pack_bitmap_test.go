@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestEWAHRoundTrip(t *testing.T) {
+	ones := make([]int, 0, 301)
+	for pos := 64; pos < 364; pos++ {
+		ones = append(ones, pos)
+	}
+	ones = append(ones, 700)
+
+	tests := []struct {
+		name      string
+		positions []int
+	}{
+		{name: "empty"},
+		{name: "single bit", positions: []int{3}},
+		{name: "literals after a run of zeros", positions: []int{1000, 1001, 1500}},
+		{name: "run of ones", positions: ones},
+	}
+
+	for _, test := range tests {
+		bitmap := NewBitmap()
+		for _, pos := range test.positions {
+			bitmap.Set(pos)
+		}
+
+		var buf bytes.Buffer
+		assert.NoError(t, writeEWAH(&buf, bitmap), test.name)
+
+		decoded, err := readEWAH(&buf)
+		if err != nil {
+			t.Errorf("%s: could not decode bitmap: %v", test.name, err)
+			continue
+		}
+
+		var positions []int
+		decoded.ForEach(func(pos int) {
+			positions = append(positions, pos)
+		})
+		assert.Equal(t, test.positions, positions, test.name)
+		assert.Equal(t, 0, buf.Len(), "%s: trailing data", test.name)
+	}
+}
+
+func TestPackBitmapRoundTrip(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	source := NewFsStore(ofsPackRepo)
+	store := NewFsStore(dir)
+	oids := []string{packedCommit, packedTree, packedTag, packedDeltaBlob, packedBaseBlob}
+	for _, oid := range oids {
+		data, err := source.Get(oid)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", oid, err)
+		}
+
+		if err := store.Put(oid, data); err != nil {
+			t.Fatalf("could not store %s: %v", oid, err)
+		}
+	}
+
+	pack, err := store.WritePack(oids, DefaultPackOptions())
+	if err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+
+	bitmap, err := NewPackBitmap(pack)
+	if err != nil {
+		t.Fatalf("could not create bitmap: %v", err)
+	}
+
+	reachable := NewBitmap()
+	for _, oid := range oids {
+		pos, ok := bitmap.Position(oid)
+		assert.True(t, ok)
+		assert.Equal(t, oid, bitmap.OID(pos))
+		reachable.Set(pos)
+	}
+
+	commitPos, _ := bitmap.Position(packedCommit)
+	assert.Equal(t, "commit", bitmap.ObjectType(commitPos))
+	treePos, _ := bitmap.Position(packedTree)
+	assert.Equal(t, "tree", bitmap.ObjectType(treePos))
+	tagPos, _ := bitmap.Position(packedTag)
+	assert.Equal(t, "tag", bitmap.ObjectType(tagPos))
+
+	assert.NoError(t, bitmap.AddCommit(packedCommit, reachable))
+	assert.Error(t, bitmap.AddCommit(packedTree, reachable))
+	assert.NoError(t, store.WritePackBitmap(bitmap))
+
+	loaded := LoadPackBitmap(store)
+	if loaded == nil {
+		t.Fatal("bitmap could not be loaded")
+	}
+
+	assert.Equal(t, 1, loaded.Commits())
+	commitBitmap, ok := loaded.Commit(packedCommit)
+	assert.True(t, ok)
+	assert.Equal(t, len(oids), commitBitmap.Count())
+
+	blobPos, _ := loaded.Position(packedDeltaBlob)
+	assert.Equal(t, "blob", loaded.ObjectType(blobPos), "delta objects have the type of their base")
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"github.com/furisto/gog/util"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The on disk representation of a version 2 pack index is
+// \377tOc [version] [fanout table] [object names] [crc32 values] [offsets] [large offsets] [trailer]
+// where the fanout table consists of 256 entries of 4 bytes. Entry N holds the number of objects whose
+// first byte is smaller or equal to N. Offsets are 4 bytes long, if the most significant bit is set
+// the remaining bits are an index into the large offset table, which holds 8 byte offsets.
+// The trailer consists of the checksum of the pack file followed by the checksum of the index itself.
+//
+// see https://git-scm.com/docs/pack-format#_version_2_pack_idx_files_support_packs_larger_than_4_gib_and
+
+var packIndexMagic = []byte{0xff, 't', 'O', 'c'}
+
+const (
+	packIndexVersion = 2
+	largeOffsetFlag  = uint32(1) << 31
+)
+
+var (
+	ErrCorruptPackIndex            = errors.New("pack index is corrupt")
+	ErrUnsupportedPackIndexVersion = errors.New("pack index version not supported")
+)
+
+type PackIndex struct {
+	fanout       [256]uint32
+	oids         []byte
+	crcs         []uint32
+	offsets      []uint32
+	largeOffsets []uint64
+	packChecksum []byte
+	hashSize     int
+}
+
+func OpenPackIndex(path string, format util.ObjectFormat) (*PackIndex, error) {
+	indexFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer indexFile.Close()
+
+	return DecodePackIndex(bufio.NewReader(indexFile), format)
+}
+
+// DecodePackIndex reads an index whose object ids and checksums are computed with the object format
+func DecodePackIndex(reader io.Reader, format util.ObjectFormat) (*PackIndex, error) {
+	hasher := format.NewHasher()
+	tee := io.TeeReader(reader, hasher)
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(tee, magic); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(magic, packIndexMagic) {
+		return nil, ErrUnsupportedPackIndexVersion
+	}
+
+	var version uint32
+	if err := binary.Read(tee, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+
+	if version != packIndexVersion {
+		return nil, ErrUnsupportedPackIndexVersion
+	}
+
+	idx := &PackIndex{hashSize: format.Size()}
+	if err := binary.Read(tee, binary.BigEndian, &idx.fanout); err != nil {
+		return nil, err
+	}
+
+	count := idx.fanout[255]
+	for i := 1; i < len(idx.fanout); i++ {
+		if idx.fanout[i] < idx.fanout[i-1] {
+			return nil, ErrCorruptPackIndex
+		}
+	}
+
+	idx.oids = make([]byte, int(count)*idx.hashSize)
+	if _, err := io.ReadFull(tee, idx.oids); err != nil {
+		return nil, err
+	}
+
+	idx.crcs = make([]uint32, count)
+	if err := binary.Read(tee, binary.BigEndian, idx.crcs); err != nil {
+		return nil, err
+	}
+
+	idx.offsets = make([]uint32, count)
+	if err := binary.Read(tee, binary.BigEndian, idx.offsets); err != nil {
+		return nil, err
+	}
+
+	var largeCount int
+	for _, offset := range idx.offsets {
+		if offset&largeOffsetFlag != 0 {
+			largeCount++
+		}
+	}
+
+	idx.largeOffsets = make([]uint64, largeCount)
+	if err := binary.Read(tee, binary.BigEndian, idx.largeOffsets); err != nil {
+		return nil, err
+	}
+
+	// the remaining bits of a large offset must index into the table, otherwise Offset would read past it
+	for _, offset := range idx.offsets {
+		if offset&largeOffsetFlag != 0 && int(offset&^largeOffsetFlag) >= largeCount {
+			return nil, ErrCorruptPackIndex
+		}
+	}
+
+	idx.packChecksum = make([]byte, idx.hashSize)
+	if _, err := io.ReadFull(tee, idx.packChecksum); err != nil {
+		return nil, err
+	}
+
+	expected := hasher.Sum(nil)
+	checksum := make([]byte, idx.hashSize)
+	if _, err := io.ReadFull(reader, checksum); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(checksum, expected) {
+		return nil, ErrCorruptPackIndex
+	}
+
+	return idx, nil
+}
+
+// Count returns the number of objects in the pack
+func (idx *PackIndex) Count() int {
+	return int(idx.fanout[255])
+}
+
+// PackChecksum returns the checksum of the pack file that this index belongs to
+func (idx *PackIndex) PackChecksum() []byte {
+	return idx.packChecksum
+}
+
+// OID returns the object id at position n in the sorted list of object names
+func (idx *PackIndex) OID(n int) string {
+	return hex.EncodeToString(idx.rawOID(n))
+}
+
+// Offset returns the offset of the object at position n inside the pack file
+func (idx *PackIndex) Offset(n int) int64 {
+	offset := idx.offsets[n]
+	if offset&largeOffsetFlag == 0 {
+		return int64(offset)
+	}
+
+	return int64(idx.largeOffsets[offset&^largeOffsetFlag])
+}
+
+// CRC32 returns the checksum of the packed data of the object at position n
+func (idx *PackIndex) CRC32(n int) uint32 {
+	return idx.crcs[n]
+}
+
+// FindOffset returns the offset of the object inside the pack file
+func (idx *PackIndex) FindOffset(oid string) (int64, bool) {
+	n, ok := idx.find(oid)
+	if !ok {
+		return 0, false
+	}
+
+	return idx.Offset(n), true
+}
+
+// Contains reports whether the object is part of the pack
+func (idx *PackIndex) Contains(oid string) bool {
+	_, ok := idx.find(oid)
+	return ok
+}
+
+// FindPrefix returns all object ids in the pack that start with the given hex prefix
+func (idx *PackIndex) FindPrefix(prefix string) []string {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 2 {
+		return nil
+	}
+
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return nil
+	}
+
+	lo, hi := idx.bucket(first[0])
+	start := lo + sort.Search(hi-lo, func(i int) bool {
+		return idx.OID(lo+i) >= prefix
+	})
+
+	var oids []string
+	for n := start; n < hi; n++ {
+		oid := idx.OID(n)
+		if !strings.HasPrefix(oid, prefix) {
+			break
+		}
+		oids = append(oids, oid)
+	}
+
+	return oids
+}
+
+func (idx *PackIndex) find(oid string) (int, bool) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || len(raw) != idx.hashSize {
+		return 0, false
+	}
+
+	lo, hi := idx.bucket(raw[0])
+	n := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(idx.rawOID(lo+i), raw) >= 0
+	})
+
+	if n < hi && bytes.Equal(idx.rawOID(n), raw) {
+		return n, true
+	}
+
+	return 0, false
+}
+
+func (idx *PackIndex) bucket(first byte) (int, int) {
+	var lo uint32
+	if first > 0 {
+		lo = idx.fanout[first-1]
+	}
+
+	return int(lo), int(idx.fanout[first])
+}
+
+func (idx *PackIndex) rawOID(n int) []byte {
+	return idx.oids[n*idx.hashSize : (n+1)*idx.hashSize]
+}
+
+type PackIndexEntry struct {
+	OID    string
+	Offset int64
+	CRC32  uint32
+}
+
+// EncodePackIndex writes a version 2 index for the given entries, the entries do not need to be sorted
+func EncodePackIndex(writer io.Writer, entries []PackIndexEntry, packChecksum []byte, format util.ObjectFormat) error {
+	sorted := make([]PackIndexEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].OID < sorted[j].OID
+	})
+
+	hasher := format.NewHasher()
+	mw := io.MultiWriter(writer, hasher)
+
+	if _, err := mw.Write(packIndexMagic); err != nil {
+		return err
+	}
+
+	if err := binary.Write(mw, binary.BigEndian, uint32(packIndexVersion)); err != nil {
+		return err
+	}
+
+	rawOIDs := make([][]byte, len(sorted))
+	var fanout [256]uint32
+	for i, entry := range sorted {
+		raw, err := hex.DecodeString(entry.OID)
+		if err != nil {
+			return err
+		}
+		rawOIDs[i] = raw
+
+		for b := int(raw[0]); b < len(fanout); b++ {
+			fanout[b]++
+		}
+	}
+
+	if err := binary.Write(mw, binary.BigEndian, fanout); err != nil {
+		return err
+	}
+
+	for _, raw := range rawOIDs {
+		if _, err := mw.Write(raw); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range sorted {
+		if err := binary.Write(mw, binary.BigEndian, entry.CRC32); err != nil {
+			return err
+		}
+	}
+
+	var largeOffsets []uint64
+	for _, entry := range sorted {
+		offset := uint32(entry.Offset)
+		if entry.Offset >= int64(largeOffsetFlag) {
+			offset = largeOffsetFlag | uint32(len(largeOffsets))
+			largeOffsets = append(largeOffsets, uint64(entry.Offset))
+		}
+
+		if err := binary.Write(mw, binary.BigEndian, offset); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(mw, binary.BigEndian, largeOffsets); err != nil {
+		return err
+	}
+
+	if _, err := mw.Write(packChecksum); err != nil {
+		return err
+	}
+
+	_, err := writer.Write(hasher.Sum(nil))
+	return err
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const (
+	ofsPackRepo = "./testdata/ofs"
+	refPackRepo = "./testdata/ref"
+
+	packedCommit    = "1483c1067828bcc8cfc4f6cdf2d8c29676a0a24b"
+	packedTree      = "71139d8203152dee1afc9bd07f6d658cfe7c662a"
+	packedTag       = "753b1fcb2c56c207820009ec2448421b325cdfd4"
+	packedDeltaBlob = "eb6e28336b235872eba77bf274245a027606a628"
+	packedBaseBlob  = "04c71bf7d9b9d2215e0e6005143eff98ab73b00a"
+)
+
+func TestFsStoreGetPackedObjects(t *testing.T) {
+	for _, repoPath := range []string{ofsPackRepo, refPackRepo} {
+		store := NewFsStore(repoPath)
+
+		for _, oid := range []string{packedCommit, packedTree, packedTag, packedDeltaBlob, packedBaseBlob} {
+			data, err := store.Get(oid)
+			if err != nil {
+				t.Errorf("could not read %s from %s: %v", oid, repoPath, err)
+				continue
+			}
+
+			assert.Equal(t, oid, hashData(data), "hash of object read from %s", repoPath)
+		}
+	}
+}
+
+func TestFsStoreGetPackedDeltaContent(t *testing.T) {
+	store := NewFsStore(ofsPackRepo)
+
+	data, err := store.Get(packedDeltaBlob)
+	if err != nil {
+		t.Fatalf("could not read delta object: %v", err)
+	}
+
+	var expected strings.Builder
+	for i := 1; i <= 207; i++ {
+		expected.WriteString(strconv.Itoa(i) + "\n")
+	}
+
+	assert.Equal(t, "blob 720\x00"+expected.String(), string(data))
+}
+
+func TestFsStoreStatPackedObject(t *testing.T) {
+	store := NewFsStore(refPackRepo)
+
+	exists, err := store.Stat(packedCommit)
+	if err != nil {
+		t.Fatalf("could not stat packed object: %v", err)
+	}
+	assert.True(t, exists, "packed commit exists")
+
+	exists, _ = store.Stat("0000000000000000000000000000000000000000")
+	assert.False(t, exists, "unknown object exists")
+}
+
+func TestFsStoreFindPackedObject(t *testing.T) {
+	store := NewFsStore(ofsPackRepo)
+
+	oids, err := store.Find(packedTree[:6])
+	if err != nil {
+		t.Fatalf("could not find packed object: %v", err)
+	}
+
+	assert.Equal(t, []string{packedTree}, oids)
+}
+
+func TestDecodePackIndexWithLargeOffsets(t *testing.T) {
+	oids := []string{
+		"1000000000000000000000000000000000000000",
+		"2000000000000000000000000000000000000000",
+	}
+	offsets := []uint64{12, 1 << 33}
+
+	idx, err := DecodePackIndex(bytes.NewReader(createPackIndex(t, oids, offsets)), util.SHA1)
+	if err != nil {
+		t.Fatalf("could not decode pack index: %v", err)
+	}
+
+	assert.Equal(t, 2, idx.Count())
+	for i, oid := range oids {
+		offset, ok := idx.FindOffset(oid)
+		assert.True(t, ok, "oid %s is part of index", oid)
+		assert.Equal(t, int64(offsets[i]), offset, "offset of %s", oid)
+	}
+}
+
+func TestDecodePackIndexWithLargeOffsetOutOfRange(t *testing.T) {
+	oids := []string{
+		"1000000000000000000000000000000000000000",
+		"2000000000000000000000000000000000000000",
+	}
+	data := createPackIndex(t, oids, []uint64{12, 1 << 33})
+
+	// point the second offset past the single entry of the large offset table
+	position := 8 + 256*4 + len(oids)*(sha1.Size+4) + 4
+	binary.BigEndian.PutUint32(data[position:], largeOffsetFlag|5)
+	sum := sha1.Sum(data[:len(data)-sha1.Size])
+	copy(data[len(data)-sha1.Size:], sum[:])
+
+	_, err := DecodePackIndex(bytes.NewReader(data), util.SHA1)
+	assert.Equal(t, ErrCorruptPackIndex, err)
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// base size 11, target size 17, copy 6 bytes at offset 0, insert "gopher", copy 5 bytes at offset 6
+	delta := []byte{11, 17, 0x90, 6, 6, 'g', 'o', 'p', 'h', 'e', 'r', 0x91, 6, 5}
+
+	target, err := ApplyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("could not apply delta: %v", err)
+	}
+
+	assert.Equal(t, "hello gopherworld", string(target))
+}
+
+func TestInflateChecksSizeOfPackHeader(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write([]byte("content"))
+	writer.Close()
+
+	content, err := inflate(bytes.NewReader(compressed.Bytes()), 7)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "content", string(content))
+	}
+
+	for _, size := range []uint64{3, 8, 1 << 62} {
+		_, err := inflate(bytes.NewReader(compressed.Bytes()), size)
+		assert.Equal(t, ErrCorruptPack, err, "size %d", size)
+	}
+}
+
+func hashData(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func createPackIndex(t *testing.T, oids []string, offsets []uint64) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.Write(packIndexMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+
+	var fanout [256]uint32
+	for _, oid := range oids {
+		raw, _ := hex.DecodeString(oid)
+		for i := int(raw[0]); i < 256; i++ {
+			fanout[i]++
+		}
+	}
+	binary.Write(&buf, binary.BigEndian, fanout)
+
+	for _, oid := range oids {
+		raw, _ := hex.DecodeString(oid)
+		buf.Write(raw)
+	}
+
+	for range oids {
+		binary.Write(&buf, binary.BigEndian, uint32(0))
+	}
+
+	var large []uint64
+	for _, offset := range offsets {
+		if offset >= uint64(largeOffsetFlag) {
+			binary.Write(&buf, binary.BigEndian, largeOffsetFlag|uint32(len(large)))
+			large = append(large, offset)
+		} else {
+			binary.Write(&buf, binary.BigEndian, uint32(offset))
+		}
+	}
+	binary.Write(&buf, binary.BigEndian, large)
+
+	buf.Write(make([]byte, sha1.Size))
+	sum := sha1.Sum(buf.Bytes())
+	buf.Write(sum[:])
+
+	return buf.Bytes()
+}
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"compress/zlib"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+const (
+	DefaultPackWindow = 10
+	DefaultPackDepth  = 50
+)
+
+type PackOptions struct {
+	// Number of preceding objects that are considered as delta base
+	Window int
+	// Maximum length of a delta chain
+	Depth int
+	// zlib compression level of the objects from -1 (zlib default) to 9
+	Compression int
+}
+
+func DefaultPackOptions() PackOptions {
+	return PackOptions{
+		Window:      DefaultPackWindow,
+		Depth:       DefaultPackDepth,
+		Compression: zlib.DefaultCompression,
+	}
+}
+
+type packEntry struct {
+	oid        string
+	objectType PackObjectType
+	content    []byte
+	base       *packEntry
+	delta      []byte
+	depth      int
+	offset     int64
+}
+
+// PackWriter writes objects of a store as pack file. Objects are ordered by type and size, and every
+// object is compared against the preceding objects in the window to find a suitable delta base.
+type PackWriter struct {
+	store   ObjectStore
+	options PackOptions
+}
+
+func NewPackWriter(store ObjectStore, options PackOptions) *PackWriter {
+	return &PackWriter{
+		store:   store,
+		options: options,
+	}
+}
+
+// Write packs the objects and returns the index entries and the checksum of the pack
+func (pw *PackWriter) Write(writer io.Writer, oids []string) ([]PackIndexEntry, []byte, error) {
+	entries, err := pw.loadEntries(oids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].objectType != entries[j].objectType {
+			return entries[i].objectType < entries[j].objectType
+		}
+		return len(entries[i].content) > len(entries[j].content)
+	})
+
+	pw.findDeltas(entries)
+
+	cw := &countingWriter{writer: writer, hasher: pw.store.ObjectFormat().NewHasher()}
+	if _, err := cw.Write(packMagic); err != nil {
+		return nil, nil, err
+	}
+
+	if err := binary.Write(cw, binary.BigEndian, []uint32{2, uint32(len(entries))}); err != nil {
+		return nil, nil, err
+	}
+
+	indexEntries := make([]PackIndexEntry, 0, len(entries))
+	for _, entry := range entries {
+		entry.offset = cw.written
+		crc := crc32.NewIEEE()
+		if err := pw.writeEntry(io.MultiWriter(cw, crc), entry); err != nil {
+			return nil, nil, err
+		}
+
+		indexEntries = append(indexEntries, PackIndexEntry{
+			OID:    entry.oid,
+			Offset: entry.offset,
+			CRC32:  crc.Sum32(),
+		})
+	}
+
+	checksum := cw.hasher.Sum(nil)
+	if _, err := writer.Write(checksum); err != nil {
+		return nil, nil, err
+	}
+
+	return indexEntries, checksum, nil
+}
+
+func (pw *PackWriter) loadEntries(oids []string) ([]*packEntry, error) {
+	seen := make(map[string]bool, len(oids))
+	entries := make([]*packEntry, 0, len(oids))
+	for _, oid := range oids {
+		if seen[oid] {
+			continue
+		}
+		seen[oid] = true
+
+		data, err := pw.store.Get(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		objectType, content, err := decodeLooseObject(data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &packEntry{
+			oid:        oid,
+			objectType: objectType,
+			content:    content,
+		})
+	}
+
+	return entries, nil
+}
+
+func (pw *PackWriter) findDeltas(entries []*packEntry) {
+	for i, entry := range entries {
+		maxSize := len(entry.content)/2 - 20
+		for j := i - 1; j >= 0 && j >= i-pw.options.Window; j-- {
+			candidate := entries[j]
+			if candidate.objectType != entry.objectType || candidate.depth >= pw.options.Depth {
+				continue
+			}
+
+			if maxSize <= 0 {
+				break
+			}
+
+			delta := CreateDelta(candidate.content, entry.content)
+			if len(delta) < maxSize {
+				entry.base = candidate
+				entry.delta = delta
+				entry.depth = candidate.depth + 1
+				maxSize = len(delta)
+			}
+		}
+	}
+}
+
+func (pw *PackWriter) writeEntry(writer io.Writer, entry *packEntry) error {
+	objectType, data := entry.objectType, entry.content
+	if entry.base != nil {
+		objectType, data = PackOfsDelta, entry.delta
+	}
+
+	header := encodePackObjectHeader(objectType, uint64(len(data)))
+	if entry.base != nil {
+		header = append(header, encodeBaseOffset(entry.offset-entry.base.offset)...)
+	}
+
+	if _, err := writer.Write(header); err != nil {
+		return err
+	}
+
+	zw, err := zlib.NewWriterLevel(writer, pw.options.Compression)
+	if err != nil {
+		return err
+	}
+
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func encodePackObjectHeader(objectType PackObjectType, size uint64) []byte {
+	b := byte(objectType)<<4 | byte(size&0x0f)
+	size >>= 4
+
+	var header []byte
+	for size > 0 {
+		header = append(header, b|0x80)
+		b = byte(size & 0x7f)
+		size >>= 7
+	}
+
+	return append(header, b)
+}
+
+func encodeBaseOffset(offset int64) []byte {
+	encoded := []byte{byte(offset & 0x7f)}
+	for offset >>= 7; offset > 0; offset >>= 7 {
+		offset--
+		encoded = append([]byte{0x80 | byte(offset&0x7f)}, encoded...)
+	}
+
+	return encoded
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	hasher  hash.Hash
+	written int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.writer.Write(p)
+	cw.hasher.Write(p[:n])
+	cw.written += int64(n)
+	return n, err
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateDeltaRoundTrip(t *testing.T) {
+	base := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100))
+	target := append([]byte("a new first line\n"), base[:2000]...)
+	target = append(target, []byte("something in the middle\n")...)
+	target = append(target, base[2200:]...)
+
+	delta := CreateDelta(base, target)
+	assert.True(t, len(delta) < len(target)/10, "delta of size %d is not smaller than target", len(delta))
+
+	actual, err := ApplyDelta(base, delta)
+	if err != nil {
+		t.Fatalf("could not apply delta: %v", err)
+	}
+
+	assert.Equal(t, target, actual)
+}
+
+func TestFsStoreWritePack(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	source := NewFsStore(ofsPackRepo)
+	store := NewFsStore(dir)
+	oids := []string{packedCommit, packedTree, packedTag, packedDeltaBlob, packedBaseBlob}
+	for _, oid := range oids {
+		data, err := source.Get(oid)
+		if err != nil {
+			t.Fatalf("could not read %s: %v", oid, err)
+		}
+
+		if err := store.Put(oid, data); err != nil {
+			t.Fatalf("could not store %s: %v", oid, err)
+		}
+	}
+
+	pack, err := store.WritePack(oids, DefaultPackOptions())
+	if err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+	assert.Equal(t, len(oids), pack.Index().Count())
+
+	for _, oid := range oids {
+		if err := store.Delete(oid); err != nil {
+			t.Fatalf("could not delete loose object %s: %v", oid, err)
+		}
+	}
+
+	for _, oid := range oids {
+		expected, _ := source.Get(oid)
+		actual, err := store.Get(oid)
+		if err != nil {
+			t.Errorf("could not read %s from written pack: %v", oid, err)
+			continue
+		}
+
+		assert.True(t, bytes.Equal(expected, actual), "content of %s", oid)
+	}
+}
+
+func TestFsStoreWritePackSha256(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStoreWithFormat(dir, util.SHA256)
+	content := []byte(strings.Repeat("sha256 content\n", 50))
+	var oids []string
+	for i := 0; i < 3; i++ {
+		oid, err := store.PutStream("blob", uint64(len(content)+i), bytes.NewReader(append(content, bytes.Repeat([]byte("x"), i)...)))
+		if err != nil {
+			t.Fatalf("could not store blob: %v", err)
+		}
+		assert.Equal(t, util.SHA256.HexSize(), len(oid))
+		oids = append(oids, oid)
+	}
+
+	pack, err := store.WritePack(oids, DefaultPackOptions())
+	if err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+
+	for _, oid := range oids {
+		if err := store.Delete(oid); err != nil {
+			t.Fatalf("could not delete loose object %s: %v", oid, err)
+		}
+
+		data, err := store.Get(oid)
+		if err != nil {
+			t.Fatalf("could not read %s from pack: %v", oid, err)
+		}
+		assert.Equal(t, oid, util.SHA256.Hash(data))
+		assert.True(t, pack.Contains(oid))
+	}
+}
+
+func TestFsStoreHonorsCompressionLevels(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStore(dir)
+	assert.Error(t, store.SetCompression(10))
+	assert.NoError(t, store.SetCompression(0))
+
+	content := strings.Repeat("uncompressed content ", 10)
+	data := []byte(fmt.Sprintf("blob %d\x00%s", len(content), content))
+	oid := util.Hash(data)
+	if err := store.Put(oid, data); err != nil {
+		t.Fatalf("could not store object: %v", err)
+	}
+
+	loose, err := ioutil.ReadFile(filepath.Join(dir, "objects", oid[:2], oid[2:]))
+	assert.NoError(t, err)
+	assert.Contains(t, string(loose), content, "loose object is stored without compression")
+
+	options := DefaultPackOptions()
+	options.Compression = 0
+	pack, err := store.WritePack([]string{oid}, options)
+	if err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+
+	packed, err := ioutil.ReadFile(pack.Path())
+	assert.NoError(t, err)
+	assert.Contains(t, string(packed), content, "packed object is stored without compression")
+
+	stored, err := pack.Get(oid, store.Get)
+	assert.NoError(t, err)
+	assert.Equal(t, data, stored)
+}
+
+func TestFsStoreFindsPacksWrittenByOtherStores(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	source := NewFsStore(ofsPackRepo)
+	data, err := source.Get(packedCommit)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", packedCommit, err)
+	}
+
+	reader := NewFsStore(dir)
+	exists, err := reader.Stat(packedCommit)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	writer := NewFsStore(dir)
+	if err := writer.Put(packedCommit, data); err != nil {
+		t.Fatalf("could not store %s: %v", packedCommit, err)
+	}
+	if _, err := writer.WritePack([]string{packedCommit}, DefaultPackOptions()); err != nil {
+		t.Fatalf("could not write pack: %v", err)
+	}
+	if err := writer.Delete(packedCommit); err != nil {
+		t.Fatalf("could not delete loose object: %v", err)
+	}
+
+	// the reader listed the packs before the pack was written
+	actual, err := reader.Get(packedCommit)
+	if assert.NoError(t, err) {
+		assert.Equal(t, data, actual)
+	}
+
+	packs, err := reader.Packs()
+	if assert.NoError(t, err) {
+		assert.Len(t, packs, 1)
+	}
+}
@@ -1,46 +1,154 @@
 package storage
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/furisto/gog/util"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
+// ErrObjectNotFound is returned if an object is neither stored loose nor packed in the store or its alternates
+var ErrObjectNotFound = errors.New("object not found")
+
+// objectNotFound returns an error that matches ErrObjectNotFound and names the missing object
+func objectNotFound(oid string) error {
+	return fmt.Errorf("%w: %v", ErrObjectNotFound, oid)
+}
+
 type ObjectStore interface {
-	Get(oid string)  ([]byte, error)
+	Get(oid string) ([]byte, error)
 	Put(oid string, data []byte) error
 	Stat(oid string) (bool, error)
 	Find(prefix string) ([]string, error)
+	// Open returns the type and the size of the object together with a reader for its content
+	Open(oid string) (string, uint64, io.ReadCloser, error)
+	// PutStream stores an object whose content is read from the reader and returns its object id,
+	// the object id is computed while the content is written
+	PutStream(objectType string, size uint64, reader io.Reader) (string, error)
+	// ObjectFormat returns the hash algorithm that is used to compute object ids
+	ObjectFormat() util.ObjectFormat
+	// Objects returns the ids of all objects in the store in ascending order
+	Objects() ([]string, error)
+}
+
+// MinAbbrev is the minimum number of characters of an abbreviated object id
+const MinAbbrev = 4
+
+// maxAlternateDepth limits how deep alternates of alternates are followed, see
+// https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt-objectsinfoalternates
+const maxAlternateDepth = 5
+
+// SharedPermissions describes how files in the object store are shared with other users as configured
+// by core.sharedRepository
+type SharedPermissions struct {
+	// Permission bits that are granted, zero leaves the permissions to the umask
+	Perm os.FileMode
+	// Replace the permission bits instead of adding to them, used for explicit modes like 0640
+	Replace bool
+}
+
+// Mode returns the permissions of a file or directory that has been created with the given mode
+func (sp SharedPermissions) Mode(mode os.FileMode) os.FileMode {
+	if sp.Perm == 0 {
+		return mode
+	}
+
+	perm := sp.Perm
+	if mode&0200 == 0 {
+		// files that are read-only for the owner stay read-only for everybody
+		perm &^= 0222
+	}
+
+	if mode.IsDir() || mode&0100 != 0 {
+		perm |= (perm & 0444) >> 2
+	}
+
+	if mode.IsDir() {
+		// files created inside the directory inherit its group
+		perm |= os.ModeSetgid
+	}
+
+	if sp.Replace {
+		return mode&^0777 | perm
+	}
+
+	return mode | perm
 }
 
 type FilesystemStore struct {
-	location string
+	location          string
+	format            util.ObjectFormat
+	shared            SharedPermissions
+	compression       int
+	packNames         []string
+	packCache         map[string]*Packfile
+	midx              *MultiPackIndex
+	packsLoaded       bool
+	packDirModTime    time.Time
+	packsMtx          sync.Mutex
+	alternates        []*FilesystemStore
+	alternatesLoaded  bool
+	alternatesMtx     sync.Mutex
+	commitGraph       *CommitGraph
+	commitGraphLoaded bool
+	commitGraphMtx    sync.Mutex
 }
 
 func NewFsStore(path string) *FilesystemStore {
+	return NewFsStoreWithFormat(path, util.DefaultObjectFormat)
+}
+
+func NewFsStoreWithFormat(path string, format util.ObjectFormat) *FilesystemStore {
 	path = filepath.Join(path, "objects")
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		os.MkdirAll(path, 0644)
+		os.MkdirAll(path, 0777)
 	}
 
 	return &FilesystemStore{
-		location: path,
+		location:    path,
+		format:      format,
+		compression: zlib.DefaultCompression,
+	}
+}
+
+func (store *FilesystemStore) ObjectFormat() util.ObjectFormat {
+	return store.format
+}
+
+// SetCompression configures the zlib compression level of loose objects from -1 (zlib default) to 9
+func (store *FilesystemStore) SetCompression(level int) error {
+	if level < zlib.DefaultCompression || level > zlib.BestCompression {
+		return fmt.Errorf("bad zlib compression level %d", level)
 	}
+
+	store.compression = level
+	return nil
+}
+
+// SetSharedPermissions configures the permissions of files and directories that are created in the store
+func (store *FilesystemStore) SetSharedPermissions(shared SharedPermissions) {
+	store.shared = shared
 }
 
-func(store *FilesystemStore) Get(oid string) ([]byte, error) {
-	if err:= checkObjectId(oid); err != nil {
+func (store *FilesystemStore) Get(oid string) ([]byte, error) {
+	if err := checkObjectId(oid); err != nil {
 		return nil, err
 	}
 
-	objectPath:= filepath.Join(store.location, oid[:2], oid[2:])
-	exists, _ := store.Stat(objectPath)
-	if !exists {
-		return nil, fmt.Errorf("oid %v could not be found", oid)
+	objectPath := filepath.Join(store.location, oid[:2], oid[2:])
+	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
+		return store.getPacked(oid)
 	}
 
 	objectFile, err := os.Open(objectPath)
@@ -48,6 +156,8 @@ func(store *FilesystemStore) Get(oid string) ([]byte, error) {
 		return nil, err
 	}
 
+	defer objectFile.Close()
+
 	reader, err := zlib.NewReader(objectFile)
 	if err != nil {
 		return nil, err
@@ -59,31 +169,100 @@ func(store *FilesystemStore) Get(oid string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+func (store *FilesystemStore) Open(oid string) (string, uint64, io.ReadCloser, error) {
+	if err := checkObjectId(oid); err != nil {
+		return "", 0, nil, err
+	}
+
+	objectPath := filepath.Join(store.location, oid[:2], oid[2:])
+	objectFile, err := os.Open(objectPath)
+	if os.IsNotExist(err) {
+		return store.openPacked(oid)
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	zr, err := zlib.NewReader(objectFile)
+	if err != nil {
+		objectFile.Close()
+		return "", 0, nil, err
+	}
+
+	reader := bufio.NewReader(zr)
+	objectType, size, err := readLooseHeader(reader)
+	if err != nil {
+		zr.Close()
+		objectFile.Close()
+		return "", 0, nil, err
+	}
+
+	return objectType, size, newObjectReader(reader, zr, objectFile), nil
+}
+
 func (store *FilesystemStore) Find(prefix string) ([]string, error) {
-	if err:= checkObjectId(prefix); err != nil {
+	if err := checkPrefix(prefix, store.format); err != nil {
 		return nil, err
 	}
 
+	prefix = strings.ToLower(prefix)
+	oids := []string{}
 	bucket := filepath.Join(store.location, prefix[:2])
-	if _, err:= os.Stat(bucket); os.IsNotExist(err) {
-		return []string{}, nil
+	if _, err := os.Stat(bucket); err == nil {
+		files, err := ioutil.ReadDir(bucket)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if strings.HasPrefix(f.Name(), prefix[2:]) && isObjectId(prefix[:2]+f.Name(), store.format.Size()) {
+				oids = append(oids, prefix[:2]+f.Name())
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(oids))
+	for _, oid := range oids {
+		seen[oid] = true
 	}
 
-	files, err := ioutil.ReadDir(bucket)
+	packed, err := store.findPackedPrefix(prefix)
 	if err != nil {
 		return nil, err
 	}
 
-	oids := make([]string, len(files))
-	for i, f := range files {
-		oids[i] = prefix[:2] + filepath.Base(f.Name())
+	for _, oid := range packed {
+		if !seen[oid] {
+			seen[oid] = true
+			oids = append(oids, oid)
+		}
+	}
+
+	alternates, err := store.Alternates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alternate := range alternates {
+		alternateOids, err := alternate.Find(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, oid := range alternateOids {
+			if !seen[oid] {
+				seen[oid] = true
+				oids = append(oids, oid)
+			}
+		}
 	}
 
+	sort.Strings(oids)
 	return oids, nil
 }
 
-func(store *FilesystemStore) Put(oid string, data []byte) error{
-	if err:= checkObjectId(oid); err != nil {
+func (store *FilesystemStore) Put(oid string, data []byte) error {
+	if err := checkObjectId(oid); err != nil {
 		return err
 	}
 
@@ -91,54 +270,149 @@ func(store *FilesystemStore) Put(oid string, data []byte) error{
 		return fmt.Errorf("empty data cannot be stored")
 	}
 
-	bucket := oid[:2]
-	bucketPath := filepath.Join(store.location, bucket)
-	if _, err := os.Stat(bucketPath); os.IsNotExist(err) {
-		err := os.Mkdir(bucketPath, os.ModeDir)
-		if err != nil {
-			return err
-		}
-	}
-
-	objectPath:= filepath.Join(bucketPath, oid[2:])
-	_, err := os.Stat(objectPath)
-	if err == nil {
+	objectPath := filepath.Join(store.location, oid[:2], oid[2:])
+	if _, err := os.Stat(objectPath); err == nil {
 		return nil // object already exists
 	}
 
-	objectFile, err := os.Create(objectPath)
+	tempPath, err := store.writeTempObject(func(writer io.Writer) error {
+		_, err := writer.Write(data)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tempPath)
+
+	return store.finalizeObject(tempPath, oid)
+}
 
-	writer := zlib.NewWriter(objectFile)
-	_, err = writer.Write(data)
+func (store *FilesystemStore) PutStream(objectType string, size uint64, reader io.Reader) (string, error) {
+	hasher := store.format.NewHasher()
+	tempPath, err := store.writeTempObject(func(writer io.Writer) error {
+		return writeStream(io.MultiWriter(writer, hasher), objectType, size, reader)
+	})
 	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tempPath)
+
+	oid := hex.EncodeToString(hasher.Sum(nil))
+	return oid, store.finalizeObject(tempPath, oid)
+}
+
+// writeTempObject compresses the object into a temporary file inside the store. The file is synced to
+// disk before it is closed, so that it can be moved into place once it has been completely written.
+func (store *FilesystemStore) writeTempObject(write func(io.Writer) error) (string, error) {
+	objectFile, err := ioutil.TempFile(store.location, "tmp_obj_")
+	if err != nil {
+		return "", err
+	}
+
+	writer, err := zlib.NewWriterLevel(objectFile, store.compression)
+	if err != nil {
+		objectFile.Close()
+		os.Remove(objectFile.Name())
+		return "", err
+	}
+
+	err = write(writer)
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = objectFile.Sync()
+	}
+	if cerr := objectFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(objectFile.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		os.Remove(objectFile.Name())
+		return "", err
+	}
+
+	return objectFile.Name(), nil
+}
+
+// finalizeObject moves a completely written object into its bucket. An existing object is never replaced,
+// which makes concurrent writes of the same object safe, since both writers store the same content.
+func (store *FilesystemStore) finalizeObject(tempPath, oid string) error {
+	bucketPath := filepath.Join(store.location, oid[:2])
+	if err := store.mkdir(bucketPath); err != nil {
+		return err
+	}
+
+	objectPath := filepath.Join(bucketPath, oid[2:])
+	err := os.Link(tempPath, objectPath)
+	if err == nil || os.IsExist(err) {
+		return nil
+	}
+
+	// file systems without support for hard links
+	if _, err := os.Stat(objectPath); err == nil {
+		return nil
+	}
+
+	return os.Rename(tempPath, objectPath)
+}
+
+// objectFileMode returns the permissions of loose objects and packs, which are never modified once written
+func (store *FilesystemStore) objectFileMode() os.FileMode {
+	return store.shared.Mode(0444)
+}
+
+// mkdir creates the directory and adjusts its permissions to core.sharedRepository
+func (store *FilesystemStore) mkdir(path string) error {
+	if err := os.MkdirAll(path, 0777); err != nil {
 		return err
 	}
 
-	if err := writer.Close(); err != nil {
+	if store.shared.Perm == 0 {
+		return nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
 		return err
 	}
 
+	if mode := store.shared.Mode(stat.Mode()); mode != stat.Mode() {
+		return os.Chmod(path, mode)
+	}
+
 	return nil
 }
 
-func(store *FilesystemStore) Stat(oid string) (bool, error){
-	if err:= checkObjectId(oid); err != nil {
+func (store *FilesystemStore) Stat(oid string) (bool, error) {
+	exists, err := store.StatLocal(oid)
+	if err != nil || exists {
+		return exists, err
+	}
+
+	alternate, err := store.findAlternate(oid)
+	return alternate != nil, err
+}
+
+// StatLocal checks whether the object is stored in this store without consulting the alternates
+func (store *FilesystemStore) StatLocal(oid string) (bool, error) {
+	if err := checkObjectId(oid); err != nil {
 		return false, err
 	}
 
 	objectPath := filepath.Join(store.location, oid[:2], oid[2:])
-	if _, err := os.Stat(objectPath); os.IsNotExist(err) {
-		return false, nil
+	if _, err := os.Stat(objectPath); err == nil {
+		return true, nil
 	}
 
-	return true, nil
+	pack, _, err := store.findPacked(oid)
+	return pack != nil, err
 }
 
-func(store *FilesystemStore) Delete(oid string) error {
-	if err:= checkObjectId(oid); err != nil {
+func (store *FilesystemStore) Delete(oid string) error {
+	if err := checkObjectId(oid); err != nil {
 		return err
 	}
 
@@ -150,9 +424,596 @@ func(store *FilesystemStore) Delete(oid string) error {
 	return nil
 }
 
+// Packs returns all pack files in objects/pack, they are loaded on first use
+func (store *FilesystemStore) Packs() ([]*Packfile, error) {
+	store.packsMtx.Lock()
+	defer store.packsMtx.Unlock()
+
+	store.rescanChangedPacksLocked()
+	if err := store.loadPacksLocked(); err != nil {
+		return nil, err
+	}
+
+	packs := make([]*Packfile, 0, len(store.packNames))
+	for _, name := range store.packNames {
+		pack, err := store.openPackLocked(name)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+
+	return packs, nil
+}
+
+// ReloadPacks discards the known pack files and the multi-pack-index so that they are rescanned on next access
+func (store *FilesystemStore) ReloadPacks() {
+	store.packsMtx.Lock()
+	defer store.packsMtx.Unlock()
+
+	store.packNames = nil
+	store.packCache = nil
+	store.midx = nil
+	store.packsLoaded = false
+}
+
+// loadPacksLocked lists the pack files and reads the multi-pack-index, packs are opened when they are needed
+func (store *FilesystemStore) loadPacksLocked() error {
+	if store.packsLoaded {
+		return nil
+	}
+
+	packDir := filepath.Join(store.location, "pack")
+	if info, err := os.Stat(packDir); err == nil {
+		store.packDirModTime = info.ModTime()
+	}
+
+	files, err := ioutil.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var names []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".pack") {
+			names = append(names, f.Name())
+		}
+	}
+
+	// the multi-pack-index only speeds up lookups, packs are searched one by one if it cannot be read
+	midx, err := OpenMultiPackIndex(filepath.Join(packDir, multiPackIndexFile), store.format)
+	if err != nil {
+		midx = nil
+	}
+
+	store.packNames = names
+	store.packCache = make(map[string]*Packfile)
+	store.midx = midx
+	store.packsLoaded = true
+	return nil
+}
+
+// rescanChangedPacksLocked discards the known packs if packs were added or removed since they were listed, e.g. by
+// another process that repacked the repository
+func (store *FilesystemStore) rescanChangedPacksLocked() {
+	if !store.packsLoaded {
+		return
+	}
+
+	info, err := os.Stat(filepath.Join(store.location, "pack"))
+	if err != nil || info.ModTime().Equal(store.packDirModTime) {
+		return
+	}
+
+	store.packNames = nil
+	store.packCache = nil
+	store.midx = nil
+	store.packsLoaded = false
+}
+
+func (store *FilesystemStore) openPackLocked(name string) (*Packfile, error) {
+	if pack, ok := store.packCache[name]; ok {
+		return pack, nil
+	}
+
+	pack, err := OpenPackfile(filepath.Join(store.location, "pack", name), store.format)
+	if err != nil {
+		return nil, fmt.Errorf("could not open pack %s: %v", name, err)
+	}
+
+	store.packCache[name] = pack
+	return pack, nil
+}
+
+// uncoveredPacksLocked returns the packs that are not part of the multi-pack-index
+func (store *FilesystemStore) uncoveredPacksLocked() ([]*Packfile, error) {
+	covered := make(map[string]bool)
+	if store.midx != nil {
+		for _, name := range store.midx.PackNames() {
+			covered[strings.TrimSuffix(name, ".idx")+".pack"] = true
+		}
+	}
+
+	var packs []*Packfile
+	for _, name := range store.packNames {
+		if covered[name] {
+			continue
+		}
+
+		pack, err := store.openPackLocked(name)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+
+	return packs, nil
+}
+
+// findPacked returns the pack that contains the object together with the offset of the object. The
+// multi-pack-index is consulted first, only packs that it does not cover are searched one by one. If the object
+// cannot be found, the packs are rescanned in case they changed since they were listed.
+func (store *FilesystemStore) findPacked(oid string) (*Packfile, int64, error) {
+	store.packsMtx.Lock()
+	defer store.packsMtx.Unlock()
+
+	pack, offset, err := store.findPackedLocked(oid)
+	if pack != nil || err != nil {
+		return pack, offset, err
+	}
+
+	store.rescanChangedPacksLocked()
+	return store.findPackedLocked(oid)
+}
+
+func (store *FilesystemStore) findPackedLocked(oid string) (*Packfile, int64, error) {
+	if err := store.loadPacksLocked(); err != nil {
+		return nil, 0, err
+	}
+
+	if store.midx != nil {
+		if entry, ok := store.midx.Find(oid); ok {
+			name := strings.TrimSuffix(store.midx.PackNames()[entry.Pack], ".idx") + ".pack"
+			if pack, err := store.openPackLocked(name); err == nil {
+				return pack, entry.Offset, nil
+			}
+		}
+	}
+
+	packs, err := store.uncoveredPacksLocked()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, pack := range packs {
+		if offset, ok := pack.Index().FindOffset(oid); ok {
+			return pack, offset, nil
+		}
+	}
+
+	return nil, 0, nil
+}
+
+// findPackedPrefix returns the ids of all packed objects that start with the given prefix
+func (store *FilesystemStore) findPackedPrefix(prefix string) ([]string, error) {
+	store.packsMtx.Lock()
+	defer store.packsMtx.Unlock()
+
+	if err := store.loadPacksLocked(); err != nil {
+		return nil, err
+	}
+
+	var oids []string
+	if store.midx != nil {
+		oids = append(oids, store.midx.FindPrefix(prefix)...)
+	}
+
+	packs, err := store.uncoveredPacksLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pack := range packs {
+		oids = append(oids, pack.Index().FindPrefix(prefix)...)
+	}
+
+	return oids, nil
+}
+
+// WritePack packs the given objects into a new pack file inside objects/pack
+func (store *FilesystemStore) WritePack(oids []string, options PackOptions) (*Packfile, error) {
+	packDir := filepath.Join(store.location, "pack")
+	if err := store.mkdir(packDir); err != nil {
+		return nil, err
+	}
+
+	packFile, err := ioutil.TempFile(packDir, "tmp_pack_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(packFile.Name())
+
+	entries, checksum, err := NewPackWriter(store, options).Write(packFile, oids)
+	if err == nil {
+		err = packFile.Sync()
+	}
+	if cerr := packFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(packFile.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexFile, err := ioutil.TempFile(packDir, "tmp_idx_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(indexFile.Name())
+
+	err = EncodePackIndex(indexFile, entries, checksum, store.format)
+	if err == nil {
+		err = indexFile.Sync()
+	}
+	if cerr := indexFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(indexFile.Name(), store.objectFileMode())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the index is moved into place first, packs are only discovered through their .pack file
+	name := filepath.Join(packDir, fmt.Sprintf("pack-%x", checksum))
+	if err := os.Rename(indexFile.Name(), name+".idx"); err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(packFile.Name(), name+".pack"); err != nil {
+		return nil, err
+	}
+
+	store.ReloadPacks()
+	return OpenPackfile(name+".pack", store.format)
+}
+
+// DeletePack removes the pack file, its index and its bitmap from the store. A multi-pack-index that covers
+// the pack is removed as well, since it would refer to objects that no longer exist.
+func (store *FilesystemStore) DeletePack(pack *Packfile) error {
+	defer store.ReloadPacks()
+
+	midx, err := store.MultiPackIndex()
+	if err != nil {
+		return err
+	}
+
+	if midx != nil {
+		for _, name := range midx.PackNames() {
+			if name != packIndexName(pack) {
+				continue
+			}
+
+			err := os.Remove(filepath.Join(store.location, "pack", multiPackIndexFile))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if err := os.Remove(pack.Path()); err != nil {
+		return err
+	}
+
+	base := strings.TrimSuffix(pack.Path(), ".pack")
+	if err := os.Remove(base + ".bitmap"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Remove(base + ".idx")
+}
+
+// Loosen writes the packed object as loose object with the modification time of its pack. Objects that would be
+// lost with the pack thereby get the same grace period as other unreachable loose objects before they are pruned.
+func (store *FilesystemStore) Loosen(pack *Packfile, oid string) error {
+	if _, err := store.StatLoose(oid); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(pack.Path())
+	if err != nil {
+		return err
+	}
+
+	data, err := pack.Get(oid, store.Get)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Put(oid, data); err != nil {
+		return err
+	}
+
+	objectPath := filepath.Join(store.location, oid[:2], oid[2:])
+	return os.Chtimes(objectPath, info.ModTime(), info.ModTime())
+}
+
+// StatLoose returns the file info of a loose object, it fails if the object is not stored as loose object
+func (store *FilesystemStore) StatLoose(oid string) (os.FileInfo, error) {
+	if err := checkObjectId(oid); err != nil {
+		return nil, err
+	}
+
+	return os.Stat(filepath.Join(store.location, oid[:2], oid[2:]))
+}
+
+// LooseObjects returns the ids of all objects that are stored as loose object
+func (store *FilesystemStore) LooseObjects() ([]string, error) {
+	buckets, err := ioutil.ReadDir(store.location)
+	if err != nil {
+		return nil, err
+	}
+
+	var oids []string
+	for _, bucket := range buckets {
+		if !bucket.IsDir() || !isHexBucket(bucket.Name()) {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(filepath.Join(store.location, bucket.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			if oid := bucket.Name() + f.Name(); !f.IsDir() && isObjectId(oid, store.format.Size()) {
+				oids = append(oids, oid)
+			}
+		}
+	}
+
+	return oids, nil
+}
+
+// Objects returns the ids of all loose and packed objects, objects borrowed from alternates are
+// not included
+func (store *FilesystemStore) Objects() ([]string, error) {
+	oids, err := store.LooseObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	packs, err := store.Packs()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(oids))
+	for _, oid := range oids {
+		seen[oid] = true
+	}
+
+	for _, pack := range packs {
+		index := pack.Index()
+		for i := 0; i < index.Count(); i++ {
+			oid := index.OID(i)
+			if !seen[oid] {
+				seen[oid] = true
+				oids = append(oids, oid)
+			}
+		}
+	}
+
+	sort.Strings(oids)
+	return oids, nil
+}
+
+func isHexBucket(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+func (store *FilesystemStore) getPacked(oid string) ([]byte, error) {
+	pack, offset, err := store.findPacked(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	if pack != nil {
+		return pack.getAt(offset, store.Get)
+	}
+
+	alternate, err := store.findAlternate(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	if alternate != nil {
+		return alternate.Get(oid)
+	}
+
+	return nil, objectNotFound(oid)
+}
+
+func (store *FilesystemStore) openPacked(oid string) (string, uint64, io.ReadCloser, error) {
+	pack, offset, err := store.findPacked(oid)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	if pack != nil {
+		objectType, size, reader, err := pack.openAt(offset, store.Get)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		return objectType.String(), size, reader, nil
+	}
+
+	alternate, err := store.findAlternate(oid)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	if alternate != nil {
+		return alternate.Open(oid)
+	}
+
+	return "", 0, nil, objectNotFound(oid)
+}
+
+// Alternates returns the object stores listed in objects/info/alternates including their own
+// alternates. Objects are read from alternates, but never written to them.
+func (store *FilesystemStore) Alternates() ([]*FilesystemStore, error) {
+	store.alternatesMtx.Lock()
+	defer store.alternatesMtx.Unlock()
+
+	if store.alternatesLoaded {
+		return store.alternates, nil
+	}
+
+	seen := map[string]bool{filepath.Clean(store.location): true}
+	alternates, err := loadAlternates(store.location, store.format, 0, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	store.alternates = alternates
+	store.alternatesLoaded = true
+	return store.alternates, nil
+}
+
+// AddAlternate registers the object directory of another repository as alternate of this store
+func (store *FilesystemStore) AddAlternate(objectDir string) error {
+	objectDir, err := filepath.Abs(objectDir)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(objectDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s is not a valid object directory", objectDir)
+	}
+
+	infoDir := filepath.Join(store.location, "info")
+	if err := store.mkdir(infoDir); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(infoDir, "alternates"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(file, objectDir); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	store.alternatesMtx.Lock()
+	store.alternates = nil
+	store.alternatesLoaded = false
+	store.alternatesMtx.Unlock()
+	return nil
+}
+
+func (store *FilesystemStore) findAlternate(oid string) (*FilesystemStore, error) {
+	alternates, err := store.Alternates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alternate := range alternates {
+		exists, err := alternate.StatLocal(oid)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			return alternate, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// loadAlternates reads the alternates file of the object directory. Relative paths are interpreted
+// relative to the object directory, alternates that do not exist are ignored like git does.
+func loadAlternates(objectDir string, format util.ObjectFormat, depth int, seen map[string]bool) ([]*FilesystemStore, error) {
+	content, err := ioutil.ReadFile(filepath.Join(objectDir, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var alternates []*FilesystemStore
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectDir, line)
+		}
+		line = filepath.Clean(line)
+
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		if info, err := os.Stat(line); err != nil || !info.IsDir() {
+			continue
+		}
+
+		// alternates are flattened into the list of the store that loaded them
+		alternates = append(alternates, &FilesystemStore{location: line, format: format, alternatesLoaded: true})
+		if depth+1 < maxAlternateDepth {
+			nested, err := loadAlternates(line, format, depth+1, seen)
+			if err != nil {
+				return nil, err
+			}
+			alternates = append(alternates, nested...)
+		}
+	}
+
+	return alternates, nil
+}
+
 func checkObjectId(oid string) error {
-	if len(oid) < 4 {
-		fmt.Errorf("oid needs to be ast least 4 characters long")
+	if len(oid) < MinAbbrev {
+		return fmt.Errorf("oid needs to be at least %d characters long", MinAbbrev)
+	}
+
+	return nil
+}
+
+// checkPrefix verifies that the prefix is long enough and consists only of hex digits
+func checkPrefix(prefix string, format util.ObjectFormat) error {
+	if len(prefix) < MinAbbrev {
+		return fmt.Errorf("short object ID %s is too short, at least %d characters are required", prefix, MinAbbrev)
+	}
+
+	if len(prefix) > 2*format.Size() {
+		return fmt.Errorf("%s is not a valid object name", prefix)
+	}
+
+	for _, c := range prefix {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return fmt.Errorf("%s is not a valid object name", prefix)
+		}
 	}
 
 	return nil
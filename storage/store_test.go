@@ -5,8 +5,11 @@ import (
 	"compress/zlib"
 	"fmt"
 	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -162,3 +165,188 @@ func createStorageObject(dir string) (string, error){
 }
 
 
+
+func TestFsStoreReadsObjectsFromAlternates(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	shared := NewFsStore(filepath.Join(dir, "shared"))
+	NewFsStore(filepath.Join(dir, "middle"))
+	local := NewFsStore(filepath.Join(dir, "local"))
+
+	data := []byte("blob 6\x00shared")
+	oid := hashData(data)
+	if err := shared.Put(oid, data); err != nil {
+		t.Fatalf("could not write object: %v", err)
+	}
+
+	// middle references shared with a relative path, local references middle with an absolute path
+	infoDir := filepath.Join(dir, "middle", "objects", "info")
+	if err := os.MkdirAll(infoDir, 0755); err != nil {
+		t.Fatalf("could not create info directory: %v", err)
+	}
+	alternates := []byte("# comment\n../../shared/objects\n")
+	if err := ioutil.WriteFile(filepath.Join(infoDir, "alternates"), alternates, 0644); err != nil {
+		t.Fatalf("could not write alternates: %v", err)
+	}
+	if err := local.AddAlternate(filepath.Join(dir, "middle", "objects")); err != nil {
+		t.Fatalf("could not add alternate: %v", err)
+	}
+
+	stores, err := local.Alternates()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(stores))
+
+	read, err := local.Get(oid)
+	assert.NoError(t, err)
+	assert.Equal(t, data, read)
+
+	exists, err := local.Stat(oid)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = local.StatLocal(oid)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	found, err := local.Find(oid[:8])
+	assert.NoError(t, err)
+	assert.Contains(t, found, oid)
+
+	loose, err := local.LooseObjects()
+	assert.NoError(t, err)
+	assert.Empty(t, loose, "objects of alternates must not be copied")
+}
+
+func TestFsStoreIgnoresAlternateCycles(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	first := NewFsStore(filepath.Join(dir, "first"))
+	second := NewFsStore(filepath.Join(dir, "second"))
+	assert.NoError(t, first.AddAlternate(filepath.Join(dir, "second", "objects")))
+	assert.NoError(t, second.AddAlternate(filepath.Join(dir, "first", "objects")))
+
+	stores, err := first.Alternates()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(stores))
+}
+
+func TestFsStoreConcurrentPutOfSameObject(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStore(dir)
+	objectId := util.Hash(fileContent)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Put(objectId, fileContent)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	data, err := store.Get(objectId)
+	assert.NoError(t, err)
+	assert.Equal(t, fileContent, data)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "objects"))
+	if err != nil {
+		t.Fatalf("could not read object directory: %v", err)
+	}
+	for _, f := range files {
+		assert.True(t, f.IsDir(), "temporary file %s left behind", f.Name())
+	}
+}
+
+func TestFsStoreHonorsSharedPermissions(t *testing.T) {
+	tests := []struct {
+		name       string
+		shared     SharedPermissions
+		objectMode os.FileMode
+		bucketPerm os.FileMode
+	}{
+		{"group", SharedPermissions{Perm: 0660}, 0444, os.ModeSetgid | 0775},
+		{"explicit", SharedPermissions{Perm: 0640, Replace: true}, 0440, os.ModeSetgid | 0750},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := util.CreateTemporaryDir()
+			if err != nil {
+				t.Fatalf("could not create temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			store := NewFsStore(dir)
+			store.SetSharedPermissions(test.shared)
+
+			objectId, err := store.PutStream("blob", 4, bytes.NewReader([]byte("test")))
+			if err != nil {
+				t.Fatalf("could not store object: %v", err)
+			}
+
+			bucket, err := os.Stat(filepath.Join(dir, "objects", objectId[:2]))
+			if err != nil {
+				t.Fatalf("could not stat bucket: %v", err)
+			}
+			assert.True(t, bucket.IsDir())
+			assert.Equal(t, test.bucketPerm, bucket.Mode()&test.bucketPerm, "bucket permissions")
+
+			object, err := os.Stat(filepath.Join(dir, "objects", objectId[:2], objectId[2:]))
+			if err != nil {
+				t.Fatalf("could not stat object: %v", err)
+			}
+			assert.Equal(t, test.objectMode, object.Mode().Perm())
+		})
+	}
+}
+
+func TestFsStoreFindMatchesWholePrefix(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStore(dir)
+	first := "abcd000000000000000000000000000000000000"
+	second := "abce000000000000000000000000000000000000"
+	for _, oid := range []string{first, second} {
+		if err := store.Put(oid, fileContent); err != nil {
+			t.Fatalf("could not store %s: %v", oid, err)
+		}
+	}
+
+	oids, err := store.Find("abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first}, oids)
+
+	_, err = store.Find("ABC")
+	assert.Error(t, err, "prefix is too short")
+
+	oids, err = store.Find("ABCE")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{second}, oids)
+
+	_, err = store.Find("abcx")
+	assert.Error(t, err, "prefix is not hex")
+}
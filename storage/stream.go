@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type objectReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func newObjectReader(reader io.Reader, closers ...io.Closer) *objectReader {
+	return &objectReader{
+		Reader:  reader,
+		closers: closers,
+	}
+}
+
+func (or *objectReader) Close() error {
+	var err error
+	for _, closer := range or.closers {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// readLooseHeader reads the [type] [size]\x00 header that precedes the content of every object
+func readLooseHeader(reader *bufio.Reader) (string, uint64, error) {
+	header, err := reader.ReadString(0)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed object")
+	}
+
+	parts := strings.SplitN(header[:len(header)-1], " ", 2)
+	if len(parts) != 2 {
+		return "", 0, fmt.Errorf("malformed object")
+	}
+
+	size, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed object")
+	}
+
+	return parts[0], size, nil
+}
+
+// writeStream writes the header followed by exactly size bytes of the reader
+func writeStream(writer io.Writer, objectType string, size uint64, reader io.Reader) error {
+	if _, err := fmt.Fprintf(writer, "%s %d\x00", objectType, size); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(writer, reader)
+	if err != nil {
+		return err
+	}
+
+	if uint64(n) != size {
+		return fmt.Errorf("expected %d bytes of content, but read %d", size, n)
+	}
+
+	return nil
+}
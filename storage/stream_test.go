@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"bytes"
+	"github.com/furisto/gog/util"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestFsStorePutStreamAndOpen(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("streamed content\n"), 4096)
+	store := NewFsStore(dir)
+	oid, err := store.PutStream("blob", uint64(len(content)), bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("could not write stream: %v", err)
+	}
+
+	assert.Equal(t, hashData(encodeLooseObject("blob", content)), oid)
+
+	objectType, size, reader, err := store.Open(oid)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", oid, err)
+	}
+	defer reader.Close()
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("could not read %s: %v", oid, err)
+	}
+
+	assert.Equal(t, "blob", objectType)
+	assert.Equal(t, uint64(len(content)), size)
+	assert.Equal(t, content, data)
+}
+
+func TestFsStorePutStreamRejectsWrongSize(t *testing.T) {
+	dir, err := util.CreateTemporaryDir()
+	if err != nil {
+		t.Fatal("Could not create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	store := NewFsStore(dir)
+	if _, err := store.PutStream("blob", 100, bytes.NewReader([]byte("short"))); err == nil {
+		t.Errorf("expected error for stream that is shorter than the announced size")
+	}
+
+	objects, err := store.LooseObjects()
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestFsStoreOpenPackedObjects(t *testing.T) {
+	for _, repoPath := range []string{ofsPackRepo, refPackRepo} {
+		store := NewFsStore(repoPath)
+
+		for _, oid := range []string{packedCommit, packedTree, packedTag, packedDeltaBlob, packedBaseBlob} {
+			objectType, size, reader, err := store.Open(oid)
+			if err != nil {
+				t.Errorf("could not open %s from %s: %v", oid, repoPath, err)
+				continue
+			}
+
+			data, err := ioutil.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				t.Errorf("could not read %s from %s: %v", oid, repoPath, err)
+				continue
+			}
+
+			assert.Equal(t, size, uint64(len(data)))
+			assert.Equal(t, oid, hashData(encodeLooseObject(objectType, data)), "hash of object read from %s", repoPath)
+		}
+	}
+}
+
+func TestMemoryStorePutStreamAndOpen(t *testing.T) {
+	store := NewMemoryStore()
+	oid, err := store.PutStream("blob", 5, bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatalf("could not write stream: %v", err)
+	}
+
+	assert.Equal(t, "b6fc4c620b67d95f953a5c1c1230aaab5db5a1b0", oid)
+
+	objectType, size, reader, err := store.Open(oid)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", oid, err)
+	}
+	defer reader.Close()
+
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "blob", objectType)
+	assert.Equal(t, uint64(5), size)
+	assert.Equal(t, "hello", string(data))
+}
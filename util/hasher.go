@@ -2,17 +2,102 @@ package util
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
+	"strings"
 )
 
-func Hash(data ...[]byte) string {
-	sha := sha1.New()
+// ObjectFormat describes the hash algorithm that is used to compute object ids, see
+// https://git-scm.com/docs/hash-function-transition
+type ObjectFormat int8
+
+const (
+	SHA1 ObjectFormat = iota
+	SHA256
+)
+
+// DefaultObjectFormat is used by repositories that do not configure extensions.objectformat
+const DefaultObjectFormat = SHA1
+
+// ParseObjectFormat returns the object format with the given name as used by extensions.objectformat
+func ParseObjectFormat(name string) (ObjectFormat, error) {
+	switch strings.ToLower(name) {
+	case "sha1":
+		return SHA1, nil
+	case "sha256":
+		return SHA256, nil
+	default:
+		return 0, fmt.Errorf("unknown object format '%s'", name)
+	}
+}
+
+// String returns the name of the object format, i.e. sha1 or sha256
+func (f ObjectFormat) String() string {
+	switch f {
+	case SHA1:
+		return "sha1"
+	case SHA256:
+		return "sha256"
+	default:
+		return "unknown"
+	}
+}
+
+// Size returns the length of a raw object id in bytes
+func (f ObjectFormat) Size() int {
+	if f == SHA256 {
+		return sha256.Size
+	}
+
+	return sha1.Size
+}
+
+// HexSize returns the length of a hex encoded object id
+func (f ObjectFormat) HexSize() int {
+	return 2 * f.Size()
+}
+
+func (f ObjectFormat) NewHasher() hash.Hash {
+	if f == SHA256 {
+		return sha256.New()
+	}
+
+	return sha1.New()
+}
+
+func (f ObjectFormat) Hash(data ...[]byte) string {
+	h := f.NewHasher()
 	for _, d := range data {
-		sha.Write(d)
+		h.Write(d)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// HashObject computes the object id of an object whose content is read from the reader
+func (f ObjectFormat) HashObject(objectType string, size uint64, reader io.Reader) (string, error) {
+	h := f.NewHasher()
+	if _, err := fmt.Fprintf(h, "%s %d\x00", objectType, size); err != nil {
+		return "", err
+	}
+
+	n, err := io.Copy(h, reader)
+	if err != nil {
+		return "", err
 	}
 
-	return fmt.Sprintf("%x", sha.Sum(nil))
+	if uint64(n) != size {
+		return "", fmt.Errorf("expected %d bytes of content, but read %d", size, n)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func Hash(data ...[]byte) string {
+	return DefaultObjectFormat.Hash(data...)
 }
 
 func HashFile(fileName string) (string, error) {
@@ -24,4 +109,11 @@ func HashFile(fileName string) (string, error) {
 	return Hash(content), nil
 }
 
+func NewHasher() hash.Hash {
+	return DefaultObjectFormat.NewHasher()
+}
 
+// HashObject computes the object id of an object whose content is read from the reader
+func HashObject(objectType string, size uint64, reader io.Reader) (string, error) {
+	return DefaultObjectFormat.HashObject(objectType, size, reader)
+}
@@ -5,6 +5,7 @@ import (
 	"compress/zlib"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 )
 
@@ -43,3 +44,7 @@ func DecompressFile(path string) ([]byte, error) {
 
 	return buffer.Bytes(), nil
 }
+
+func CreateTemporaryDir() (string, error) {
+	return ioutil.TempDir("", "gog")
+}